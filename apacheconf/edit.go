@@ -0,0 +1,163 @@
+package apacheconf
+
+import (
+	"fmt"
+	"ssl_assistant/confedit"
+	"strings"
+)
+
+// ConfigureSSL 生成将虚拟主机的证书指向 certPath / keyPath 的行级修改：
+// 已配置 SSLCertificateFile 时替换证书与私钥路径，SSLCertificateChainFile 注释掉（新证书文件已含中间证书）；
+// 已启用 SSL（SSLEngine on 或监听 443）但未配置证书时在节末尾插入；
+// 未启用 SSL 时复制该虚拟主机为 :443 的新虚拟主机（插入在原节之后）并启用 SSL。
+// 该虚拟主机重定向到 HTTPS 时复制会造成循环重定向，返回错误
+func ConfigureSSL(v VHost, certPath, keyPath string) ([]confedit.Edit, error) {
+	if v.Cert != nil && !v.Inherited {
+		return replaceCert(v, certPath, keyPath)
+	}
+	if v.Section == nil {
+		return nil, fmt.Errorf("主服务器配置未使用 <VirtualHost>，无法自动修改，请手动配置")
+	}
+	f, err := confedit.Read(v.Section.File)
+	if err != nil {
+		return nil, err
+	}
+	prefix := childIndent(f, v.Section)
+	var lines []string
+	if !v.SSLEngine {
+		lines = append(lines, render("SSLEngine", "on"))
+	}
+	lines = append(lines, render("SSLCertificateFile", certPath), render("SSLCertificateKeyFile", keyPath))
+
+	if v.SSLEngine || hasPort(v.Addrs(), "443") {
+		if err := ownClosing(f, v.Section); err != nil {
+			return nil, err
+		}
+		return []confedit.Edit{confedit.Insert(v.Section.File, v.Section.EndLine, indent(prefix, lines)...)}, nil
+	}
+
+	if redirectsToHTTPS(Active(v.Section.Block)) {
+		return nil, fmt.Errorf("%s 的虚拟主机会重定向到 HTTPS，复制为 HTTPS 虚拟主机将循环重定向，请手动配置", v.Section.Pos())
+	}
+	if err := ownClosing(f, v.Section); err != nil {
+		return nil, err
+	}
+	open := f.Line(v.Section.Line)
+	if v.Section.EndLine <= v.Section.Line || !strings.HasSuffix(strings.TrimSpace(confedit.StripComment(open)), ">") {
+		return nil, fmt.Errorf("%s 的 <%s> 标签跨行，无法自动修改，请手动配置", v.Section.Pos(), v.Section.Name)
+	}
+	var addrs []string
+	for _, a := range v.Addrs() {
+		addrs = append(addrs, httpsAddr(a))
+	}
+	block := []string{"", confedit.Indent(open) + "<" + v.Section.Name + " " + strings.Join(addrs, " ") + ">"}
+	for n := v.Section.Line + 1; n < v.Section.EndLine; n++ {
+		block = append(block, f.Line(n))
+	}
+	block = append(block, indent(prefix, lines)...)
+	block = append(block, f.Line(v.Section.EndLine))
+	return []confedit.Edit{confedit.Insert(v.Section.File, v.Section.EndLine+1, block...)}, nil
+}
+
+// replaceCert 替换已有的证书、私钥指令，注释掉证书链指令
+func replaceCert(v VHost, certPath, keyPath string) ([]confedit.Edit, error) {
+	f, err := confedit.Read(v.Cert.File)
+	if err != nil {
+		return nil, err
+	}
+	certIndent := confedit.Indent(f.Line(v.Cert.Line))
+	edits := []confedit.Edit{confedit.Replace(v.Cert.File, v.Cert.Line, v.Cert.EndLine, certIndent+render("SSLCertificateFile", certPath))}
+	if v.Key != nil {
+		kf, err := confedit.Read(v.Key.File)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, confedit.Replace(v.Key.File, v.Key.Line, v.Key.EndLine, confedit.Indent(kf.Line(v.Key.Line))+render("SSLCertificateKeyFile", keyPath)))
+	} else {
+		edits = append(edits, confedit.Insert(v.Cert.File, v.Cert.EndLine+1, certIndent+render("SSLCertificateKeyFile", keyPath)))
+	}
+	if v.Chain != nil {
+		cf, err := confedit.Read(v.Chain.File)
+		if err != nil {
+			return nil, err
+		}
+		var lines []string
+		for n := v.Chain.Line; n <= v.Chain.EndLine; n++ {
+			line := cf.Line(n)
+			lines = append(lines, confedit.Indent(line)+"# "+strings.TrimLeft(line, " \t"))
+		}
+		edits = append(edits, confedit.Replace(v.Chain.File, v.Chain.Line, v.Chain.EndLine, lines...))
+	}
+	return edits, nil
+}
+
+// ownClosing 检查节的结束标签独占一行
+func ownClosing(f *confedit.File, section *Directive) error {
+	if !strings.HasPrefix(strings.TrimSpace(f.Line(section.EndLine)), "</") {
+		return fmt.Errorf("%s 的 </%s> 不在单独一行，无法自动修改，请手动配置", section.Pos(), section.Name)
+	}
+	return nil
+}
+
+// childIndent 节内指令的缩进：取第一条子指令，没有时在节标签的缩进上加 4 个空格
+func childIndent(f *confedit.File, section *Directive) string {
+	for _, d := range section.Block {
+		if d.File == section.File && d.Line > section.Line {
+			return confedit.Indent(f.Line(d.Line))
+		}
+	}
+	return confedit.Indent(f.Line(section.Line)) + "    "
+}
+
+// redirectsToHTTPS 指令中是否有重定向到 https 的 Redirect / RedirectMatch / RewriteRule
+func redirectsToHTTPS(dirs []*Directive) bool {
+	for _, d := range dirs {
+		if !d.Is("Redirect") && !d.Is("RedirectMatch") && !d.Is("RedirectPermanent") && !d.Is("RewriteRule") {
+			continue
+		}
+		for _, a := range d.Args {
+			if strings.HasPrefix(strings.ToLower(a), "https://") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasPort 地址列表中是否有指定端口
+func hasPort(addrs []string, port string) bool {
+	for _, a := range addrs {
+		if strings.HasSuffix(a, ":"+port) {
+			return true
+		}
+	}
+	return false
+}
+
+// httpsAddr 将虚拟主机地址的端口改为 443（*:80 → *:443，[::]:80 → [::]:443，* → *:443）
+func httpsAddr(addr string) string {
+	if i := strings.LastIndex(addr, ":"); i >= 0 && !strings.HasSuffix(addr, "]") {
+		return addr[:i] + ":443"
+	}
+	return addr + ":443"
+}
+
+func indent(prefix string, lines []string) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = prefix + l
+	}
+	return out
+}
+
+// render 生成一行指令（参数含空白、引号或 # 时加双引号）
+func render(name string, args ...string) string {
+	parts := []string{name}
+	for _, a := range args {
+		if a == "" || strings.ContainsAny(a, " \t\"'#") {
+			a = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(a) + `"`
+		}
+		parts = append(parts, a)
+	}
+	return strings.Join(parts, " ")
+}
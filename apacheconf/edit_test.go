@@ -0,0 +1,104 @@
+package apacheconf
+
+import (
+	"os"
+	"path/filepath"
+	"ssl_assistant/confedit"
+	"strings"
+	"testing"
+)
+
+// 已有证书替换路径并注释证书链；已启用 SSL 未配置证书的插入；HTTP 虚拟主机复制为 :443；重定向到 HTTPS 的报错
+func TestConfigureSSL(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "sites.conf", `Listen 80
+<VirtualHost *:80 [::]:80>
+	ServerName plain.com
+	DocumentRoot /var/www/plain
+</VirtualHost>
+<VirtualHost *:443>
+    ServerName own.com
+    SSLEngine on
+    SSLCertificateFile \
+        /etc/ssl/own.crt
+    SSLCertificateKeyFile /etc/ssl/own.key
+    SSLCertificateChainFile /etc/ssl/chain.crt
+</VirtualHost>
+<VirtualHost *:443>
+    ServerName half.com
+</VirtualHost>
+<VirtualHost *:80>
+    ServerName redirect.com
+    Redirect permanent / https://redirect.com/
+</VirtualHost>
+`)
+	cfg, err := Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := map[string]VHost{}
+	for _, v := range VirtualHosts(cfg) {
+		byName[v.ServerNames[0]] = v
+	}
+	if len(byName) != 4 || ListensOn(cfg, "443") {
+		t.Fatalf("应列出 4 个虚拟主机且未监听 443: %d", len(byName))
+	}
+	if _, err := ConfigureSSL(byName["redirect.com"], "/m/c.pem", "/m/k.pem"); err == nil || !strings.Contains(err.Error(), "重定向") {
+		t.Fatalf("重定向到 HTTPS 的虚拟主机应报错: %v", err)
+	}
+	var edits []confedit.Edit
+	for _, name := range []string{"plain.com", "own.com", "half.com"} {
+		e, err := ConfigureSSL(byName[name], "/m/"+name+"/fullchain.pem", "/m/"+name+"/privkey.pem")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		edits = append(edits, e...)
+	}
+	files, err := confedit.Render(edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `Listen 80
+<VirtualHost *:80 [::]:80>
+	ServerName plain.com
+	DocumentRoot /var/www/plain
+</VirtualHost>
+
+<VirtualHost *:443 [::]:443>
+	ServerName plain.com
+	DocumentRoot /var/www/plain
+	SSLEngine on
+	SSLCertificateFile /m/plain.com/fullchain.pem
+	SSLCertificateKeyFile /m/plain.com/privkey.pem
+</VirtualHost>
+<VirtualHost *:443>
+    ServerName own.com
+    SSLEngine on
+    SSLCertificateFile /m/own.com/fullchain.pem
+    SSLCertificateKeyFile /m/own.com/privkey.pem
+    # SSLCertificateChainFile /etc/ssl/chain.crt
+</VirtualHost>
+<VirtualHost *:443>
+    ServerName half.com
+    SSLEngine on
+    SSLCertificateFile /m/half.com/fullchain.pem
+    SSLCertificateKeyFile /m/half.com/privkey.pem
+</VirtualHost>
+<VirtualHost *:80>
+    ServerName redirect.com
+    Redirect permanent / https://redirect.com/
+</VirtualHost>
+`
+	if got := string(files[path]); got != want {
+		t.Fatalf("修改结果错误:\n%s", got)
+	}
+
+	os.WriteFile(path, files[path], 0644)
+	cfg, err = Parse(filepath.Join(dir, "sites.conf"))
+	if err != nil {
+		t.Fatalf("修改后解析失败: %v", err)
+	}
+	if sites := Sites(cfg); len(sites) != 3 || sites[0].ChainPath() != "" {
+		t.Fatalf("修改后应有 3 个启用证书的虚拟主机: %+v", sites)
+	}
+}
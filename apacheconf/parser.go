@@ -0,0 +1,455 @@
+// Package apacheconf 解析 Apache httpd 配置：逐行分词（续行、注释、引号）、<Section> 块结构、
+// Include/IncludeOptional 展开（相对 ServerRoot、通配符、目录、循环检测）、<IfModule>/<IfDefine> 条件求值，
+// 每条指令记录所在文件与行号。
+package apacheconf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Directive 一条配置指令或节（<VirtualHost ...> 等，子指令在 Block 中；Include 已展开为被包含文件的指令）
+type Directive struct {
+	Name      string
+	Args      []string
+	File      string
+	Line      int
+	EndLine   int          // 结束行（续行的最后一行；节为 </Name> 所在行）
+	IsSection bool         // 是否为节（<Name ...> ... </Name>）
+	Block     []*Directive // 节内指令
+	// Active 条件节（IfModule/IfDefine）的求值结果，其他指令恒为 true。
+	// 未生效的条件节仍保留子指令（语法检查），但其中的 Include/Define 不处理
+	Active bool
+}
+
+// Pos 返回指令位置（file:line）
+func (d *Directive) Pos() string {
+	return fmt.Sprintf("%s:%d", d.File, d.Line)
+}
+
+// Arg 返回第 i 个参数（不存在时返回空串）
+func (d *Directive) Arg(i int) string {
+	if i < len(d.Args) {
+		return d.Args[i]
+	}
+	return ""
+}
+
+// Is 指令名匹配（Apache 指令名大小写不敏感）
+func (d *Directive) Is(name string) bool {
+	return strings.EqualFold(d.Name, name)
+}
+
+// ParseError 语法或 Include 错误（带文件与行号）
+type ParseError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// Config 解析结果
+type Config struct {
+	Directives []*Directive
+	Files      []string          // 已解析的文件（主文件与全部被包含文件，按解析顺序）
+	ServerRoot string            // Include 相对路径的基准目录
+	Defines    map[string]string // Define 定义的变量（IfDefine 求值与 ${VAR} 替换）
+	Modules    map[string]bool   // LoadModule 加载的模块（模块名与源文件名，如 ssl_module / mod_ssl.c）
+	Errors     []error           // 非致命错误（Include 文件缺失、循环包含、被包含文件语法错误等）
+}
+
+// Parse 解析配置文件，Include 相对路径以自动探测的 ServerRoot 为基准（见 DetectServerRoot）
+func Parse(path string) (*Config, error) {
+	return ParseWithServerRoot(path, DetectServerRoot(path))
+}
+
+// ParseWithServerRoot 解析配置文件，Include 相对路径以 serverRoot 为基准（配置中的 ServerRoot 指令会覆盖）。
+// 主文件读取失败或语法错误时返回错误；被包含文件的问题记录在 Config.Errors 中，不中断解析。
+func ParseWithServerRoot(path, serverRoot string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseData(data, path, serverRoot)
+}
+
+// ParseBytes 解析配置内容（name 用于错误与指令位置显示）
+func ParseBytes(data []byte, name, serverRoot string) (*Config, error) {
+	return parseData(data, name, serverRoot)
+}
+
+func parseData(data []byte, name, serverRoot string) (*Config, error) {
+	if serverRoot == "" {
+		serverRoot = filepath.Dir(name)
+	}
+	p := &parser{cfg: &Config{ServerRoot: serverRoot, Defines: map[string]string{}, Modules: map[string]bool{}}}
+	dirs, err := p.parseFile(data, name, []string{cleanPath(name)})
+	if err != nil {
+		return nil, err
+	}
+	p.cfg.Directives = dirs
+	return p.cfg, nil
+}
+
+// mainConfigNames Apache 主配置文件名（httpd.conf：RHEL/源码编译；apache2.conf：Debian/Ubuntu）
+var mainConfigNames = []string{"httpd.conf", "apache2.conf"}
+
+// DetectServerRoot 探测 ServerRoot：自文件所在目录向上最多三级查找主配置文件。
+// 主配置位于 conf/ 子目录（/etc/httpd/conf/httpd.conf）时 ServerRoot 为其上级目录；找不到时使用文件所在目录
+func DetectServerRoot(path string) string {
+	dir := filepath.Dir(path)
+	for i, d := 0, dir; i < 3; i++ {
+		for _, name := range mainConfigNames {
+			if _, err := os.Stat(filepath.Join(d, "conf", name)); err == nil {
+				return d
+			}
+			if _, err := os.Stat(filepath.Join(d, name)); err == nil {
+				if filepath.Base(d) == "conf" {
+					return filepath.Dir(d)
+				}
+				return d
+			}
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	return dir
+}
+
+// cleanPath 规范化路径（循环检测用）
+func cleanPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+type parser struct {
+	cfg *Config
+}
+
+// line 一条逻辑行（已合并续行）
+type line struct {
+	text string
+	num  int
+	end  int // 最后一个物理行
+}
+
+// splitLines 拆分逻辑行：行尾 \ 续行合并，跳过空行与 # 注释行
+func splitLines(data []byte) []line {
+	var out []line
+	var buf strings.Builder
+	start := 0
+	physical := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i, raw := range physical {
+		if buf.Len() == 0 {
+			start = i + 1
+		}
+		trimmed := strings.TrimSpace(raw)
+		if strings.HasSuffix(trimmed, "\\") {
+			buf.WriteString(strings.TrimSuffix(trimmed, "\\"))
+			buf.WriteByte(' ')
+			continue
+		}
+		buf.WriteString(trimmed)
+		text := strings.TrimSpace(buf.String())
+		buf.Reset()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		out = append(out, line{text: text, num: start, end: i + 1})
+	}
+	if text := strings.TrimSpace(buf.String()); text != "" && !strings.HasPrefix(text, "#") {
+		out = append(out, line{text: text, num: start, end: len(physical)})
+	}
+	return out
+}
+
+// splitArgs 拆分参数：空白分隔，支持单/双引号（\ 转义引号与反斜杠）；以 # 开头的词及其后内容视为行内注释
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c == ' ' || c == '\t' {
+			i++
+			continue
+		}
+		if c == '#' {
+			break
+		}
+		var sb strings.Builder
+		if c == '"' || c == '\'' {
+			i++
+			closed := false
+			for i < len(s) {
+				ch := s[i]
+				if ch == '\\' && i+1 < len(s) && (s[i+1] == c || s[i+1] == '\\') {
+					sb.WriteByte(s[i+1])
+					i += 2
+					continue
+				}
+				i++
+				if ch == c {
+					closed = true
+					break
+				}
+				sb.WriteByte(ch)
+			}
+			if !closed {
+				return nil, fmt.Errorf("引号未闭合")
+			}
+		} else {
+			for i < len(s) && s[i] != ' ' && s[i] != '\t' {
+				sb.WriteByte(s[i])
+				i++
+			}
+		}
+		args = append(args, sb.String())
+	}
+	return args, nil
+}
+
+// frame 正在解析的节
+type frame struct {
+	dir    *Directive
+	active bool // 节内指令是否生效（外层任一条件节未生效则为 false）
+}
+
+// parseFile 解析单个文件；stack 为当前 Include 链（用于循环检测）。
+// 只有生效上下文中的 Include 会被展开，因此被包含文件的顶层总是生效的
+func (p *parser) parseFile(data []byte, name string, stack []string) ([]*Directive, error) {
+	p.cfg.Files = append(p.cfg.Files, name)
+	var top []*Directive
+	var frames []frame
+	appendDir := func(d *Directive) {
+		if len(frames) == 0 {
+			top = append(top, d)
+			return
+		}
+		f := frames[len(frames)-1].dir
+		f.Block = append(f.Block, d)
+	}
+	curActive := func() bool {
+		if len(frames) == 0 {
+			return true
+		}
+		return frames[len(frames)-1].active
+	}
+
+	for _, ln := range splitLines(data) {
+		text := ln.text
+		switch {
+		case strings.HasPrefix(text, "</"):
+			closeName := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(text, "</"), ">"))
+			if len(frames) == 0 {
+				return nil, &ParseError{File: name, Line: ln.num, Msg: fmt.Sprintf("意外的 </%s>", closeName)}
+			}
+			open := frames[len(frames)-1].dir
+			if !strings.EqualFold(open.Name, closeName) {
+				return nil, &ParseError{File: name, Line: ln.num, Msg: fmt.Sprintf("</%s> 与 <%s>（第 %d 行）不匹配", closeName, open.Name, open.Line)}
+			}
+			open.EndLine = ln.end
+			frames = frames[:len(frames)-1]
+		case strings.HasPrefix(text, "<"):
+			if !strings.HasSuffix(text, ">") {
+				return nil, &ParseError{File: name, Line: ln.num, Msg: "节标签缺少 >"}
+			}
+			words, err := splitArgs(strings.TrimSuffix(text[1:], ">"))
+			if err != nil || len(words) == 0 {
+				return nil, &ParseError{File: name, Line: ln.num, Msg: "节标签无效"}
+			}
+			d := &Directive{Name: words[0], Args: p.expand(words[1:]), File: name, Line: ln.num, EndLine: ln.end, IsSection: true, Active: true}
+			if curActive() {
+				d.Active = p.evalCondition(d)
+			} else if isConditional(d) {
+				d.Active = false
+			}
+			appendDir(d)
+			frames = append(frames, frame{dir: d, active: curActive() && d.Active})
+		default:
+			words, err := splitArgs(text)
+			if err != nil {
+				return nil, &ParseError{File: name, Line: ln.num, Msg: err.Error()}
+			}
+			if len(words) == 0 {
+				continue
+			}
+			d := &Directive{Name: words[0], Args: p.expand(words[1:]), File: name, Line: ln.num, EndLine: ln.end, Active: true}
+			if !curActive() {
+				appendDir(d)
+				continue
+			}
+			switch {
+			case d.Is("Include"), d.Is("IncludeOptional"):
+				for _, inc := range p.include(d, stack) {
+					appendDir(inc)
+				}
+				continue
+			case d.Is("ServerRoot") && d.Arg(0) != "":
+				p.cfg.ServerRoot = d.Arg(0)
+			case d.Is("Define") && d.Arg(0) != "":
+				p.cfg.Defines[d.Arg(0)] = d.Arg(1)
+			case d.Is("UnDefine"):
+				delete(p.cfg.Defines, d.Arg(0))
+			case d.Is("LoadModule"):
+				p.loadModule(d)
+			}
+			appendDir(d)
+		}
+	}
+	if len(frames) > 0 {
+		open := frames[len(frames)-1].dir
+		return nil, &ParseError{File: name, Line: open.Line, Msg: fmt.Sprintf("<%s> 缺少 </%s>", open.Name, open.Name)}
+	}
+	return top, nil
+}
+
+// varReg ${VAR} 变量引用
+var varReg = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// expand 替换参数中已 Define 的 ${VAR}（未定义的保持原样）
+func (p *parser) expand(args []string) []string {
+	for i, a := range args {
+		if !strings.Contains(a, "${") {
+			continue
+		}
+		args[i] = varReg.ReplaceAllStringFunc(a, func(m string) string {
+			if v, ok := p.cfg.Defines[m[2:len(m)-1]]; ok {
+				return v
+			}
+			return m
+		})
+	}
+	return args
+}
+
+// loadModule 记录 LoadModule 加载的模块：模块名（ssl_module）与源文件名（mod_ssl.c）均可用于 IfModule
+func (p *parser) loadModule(d *Directive) {
+	if d.Arg(0) != "" {
+		p.cfg.Modules[d.Arg(0)] = true
+	}
+	if so := filepath.Base(d.Arg(1)); strings.HasSuffix(so, ".so") {
+		p.cfg.Modules[strings.TrimSuffix(so, ".so")+".c"] = true
+	}
+}
+
+// isConditional 是否为条件节（IfModule / IfDefine）
+func isConditional(d *Directive) bool {
+	return d.Is("IfModule") || d.Is("IfDefine")
+}
+
+// evalCondition 条件节求值（其他节恒为 true）：
+// IfDefine 依据已解析的 Define；IfModule 依据已解析的 LoadModule，未见任何 LoadModule（如单独解析站点文件）时视为模块已加载。
+// 参数前缀 ! 表示取反
+func (p *parser) evalCondition(d *Directive) bool {
+	if !isConditional(d) {
+		return true
+	}
+	arg := d.Arg(0)
+	negate := strings.HasPrefix(arg, "!")
+	arg = strings.TrimPrefix(arg, "!")
+	var ok bool
+	if d.Is("IfDefine") {
+		_, ok = p.cfg.Defines[arg]
+	} else {
+		ok = len(p.cfg.Modules) == 0 || p.cfg.Modules[arg]
+	}
+	return ok != negate
+}
+
+// include 展开 Include/IncludeOptional：相对路径以 ServerRoot 为基准，支持通配符（按文件名排序）与目录（包含目录下全部文件）。
+// Include 的文件缺失或通配符无匹配记录错误，IncludeOptional 静默跳过；循环包含与被包含文件语法错误记录到 Errors 并跳过该文件
+func (p *parser) include(d *Directive, stack []string) []*Directive {
+	optional := d.Is("IncludeOptional")
+	pattern := d.Arg(0)
+	if pattern == "" {
+		p.cfg.Errors = append(p.cfg.Errors, &ParseError{File: d.File, Line: d.Line, Msg: d.Name + " 缺少文件参数"})
+		return nil
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(p.cfg.ServerRoot, pattern)
+	}
+	var files []string
+	if strings.ContainsAny(pattern, "*?[") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			p.cfg.Errors = append(p.cfg.Errors, &ParseError{File: d.File, Line: d.Line, Msg: fmt.Sprintf("%s 通配符 %s 无效: %v", d.Name, pattern, err)})
+			return nil
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			files = append(files, dirFiles(m)...)
+		}
+		if len(files) == 0 && !optional {
+			p.cfg.Errors = append(p.cfg.Errors, &ParseError{File: d.File, Line: d.Line, Msg: fmt.Sprintf("Include %s 没有匹配的文件", pattern)})
+		}
+	} else if _, err := os.Stat(pattern); err != nil {
+		if !optional {
+			p.cfg.Errors = append(p.cfg.Errors, &ParseError{File: d.File, Line: d.Line, Msg: fmt.Sprintf("Include %s 失败: %v", pattern, err)})
+		}
+		return nil
+	} else {
+		files = dirFiles(pattern)
+	}
+
+	var out []*Directive
+	for _, f := range files {
+		abs := cleanPath(f)
+		if i := indexOf(stack, abs); i >= 0 {
+			chain := append(append([]string{}, stack[i:]...), abs)
+			p.cfg.Errors = append(p.cfg.Errors, &ParseError{File: d.File, Line: d.Line, Msg: "Include 循环: " + strings.Join(chain, " -> ")})
+			continue
+		}
+		data, err := os.ReadFile(f)
+		if err != nil {
+			p.cfg.Errors = append(p.cfg.Errors, &ParseError{File: d.File, Line: d.Line, Msg: fmt.Sprintf("%s %s 失败: %v", d.Name, f, err)})
+			continue
+		}
+		dirs, err := p.parseFile(data, f, append(append([]string{}, stack...), abs))
+		if err != nil {
+			p.cfg.Errors = append(p.cfg.Errors, err)
+			continue
+		}
+		out = append(out, dirs...)
+	}
+	return out
+}
+
+// dirFiles 路径为目录时返回其下全部文件（递归，按路径排序），否则返回路径本身
+func dirFiles(path string) []string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if !info.IsDir() {
+		return []string{path}
+	}
+	var files []string
+	_ = filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+		if err == nil && !fi.IsDir() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files
+}
+
+func indexOf(list []string, s string) int {
+	for i, x := range list {
+		if x == s {
+			return i
+		}
+	}
+	return -1
+}
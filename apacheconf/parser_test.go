@@ -0,0 +1,238 @@
+package apacheconf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFile 在 dir 下写入文件（自动创建目录）
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// 分词：续行、注释行、行内注释、引号、大小写不敏感的节闭合
+func TestLexerAndSections(t *testing.T) {
+	conf := `# SSLCertificateFile /comment.pem
+<VirtualHost *:443 [::]:443>
+    ServerName a.com
+    ServerAlias www.a.com \
+                api.a.com # 行内注释
+    SSLCertificateFile "/certs/a dir/cert.pem"
+    SSLCertificateKeyFile '/certs/a.key'
+    Header set X-Test "a # b"
+</virtualhost>
+`
+	cfg, err := ParseBytes([]byte(conf), "a.conf", "")
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if len(cfg.Directives) != 1 || !cfg.Directives[0].IsSection {
+		t.Fatalf("应只有一个 VirtualHost 节: %+v", cfg.Directives)
+	}
+	vh := cfg.Directives[0]
+	if !reflect.DeepEqual(vh.Args, []string{"*:443", "[::]:443"}) {
+		t.Fatalf("VirtualHost 地址错误: %v", vh.Args)
+	}
+	alias := vh.Block[1]
+	if !alias.Is("serveralias") || !reflect.DeepEqual(alias.Args, []string{"www.a.com", "api.a.com"}) || alias.Line != 4 {
+		t.Fatalf("续行/行内注释解析错误: %+v", alias)
+	}
+	if got := vh.Block[2].Arg(0); got != "/certs/a dir/cert.pem" {
+		t.Fatalf("双引号路径错误: %q", got)
+	}
+	if got := vh.Block[3].Arg(0); got != "/certs/a.key" {
+		t.Fatalf("单引号路径错误: %q", got)
+	}
+	if got := vh.Block[4].Args; !reflect.DeepEqual(got, []string{"set", "X-Test", "a # b"}) {
+		t.Fatalf("引号内 # 不应视为注释: %v", got)
+	}
+	if vh.Block[2].Pos() != "a.conf:6" {
+		t.Fatalf("指令位置错误: %s", vh.Block[2].Pos())
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	cases := map[string]string{
+		"<VirtualHost *:443>\nServerName a.com\n":                 "缺少",
+		"</VirtualHost>\n":                                        "意外",
+		"<VirtualHost *:443>\n</IfModule>\n":                      "不匹配",
+		"<VirtualHost *:443>\nServerName \"a.com\n</VirtualHost>": "引号",
+	}
+	for conf, want := range cases {
+		_, err := ParseBytes([]byte(conf), "bad.conf", "")
+		if err == nil || !strings.Contains(err.Error(), want) || !strings.HasPrefix(err.Error(), "bad.conf:") {
+			t.Fatalf("%q 应返回含 %q 与位置的错误，实际: %v", conf, want, err)
+		}
+	}
+}
+
+// Include/IncludeOptional：相对 ServerRoot、通配符、目录、缺失文件、循环包含
+func TestInclude(t *testing.T) {
+	root := t.TempDir()
+	main := writeFile(t, root, "conf/httpd.conf", `ServerRoot "`+root+`"
+Include conf.d/*.conf
+IncludeOptional sites/
+IncludeOptional missing/*.conf
+Include conf/missing.conf
+`)
+	writeFile(t, root, "conf.d/b.conf", "ServerName b.com\n")
+	writeFile(t, root, "conf.d/a.conf", "ServerName a.com\nInclude conf/httpd.conf\n")
+	writeFile(t, root, "sites/x/site.conf", "ServerName x.com\n")
+
+	if got := DetectServerRoot(main); got != root {
+		t.Fatalf("ServerRoot 探测错误: %s", got)
+	}
+	cfg, err := Parse(main)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	var names []string
+	for _, d := range cfg.Directives {
+		if d.Is("ServerName") {
+			names = append(names, d.Arg(0))
+		}
+	}
+	// 通配符按文件名排序，目录递归包含
+	if !reflect.DeepEqual(names, []string{"a.com", "b.com", "x.com"}) {
+		t.Fatalf("Include 展开顺序错误: %v", names)
+	}
+	if d := cfg.Directives[1]; d.File != filepath.Join(root, "conf.d/a.conf") || d.Line != 1 {
+		t.Fatalf("被包含指令位置错误: %s", d.Pos())
+	}
+	// IncludeOptional 缺失静默；Include 缺失与循环包含记录错误
+	if len(cfg.Errors) != 2 {
+		t.Fatalf("应记录 2 个错误，实际: %v", cfg.Errors)
+	}
+	if !strings.Contains(cfg.Errors[0].Error(), "循环") || !strings.Contains(cfg.Errors[1].Error(), "missing.conf") {
+		t.Fatalf("错误内容不符: %v", cfg.Errors)
+	}
+}
+
+// <IfModule>/<IfDefine>：LoadModule 与 Define 决定是否生效，! 取反，未生效节中的 Include 不展开
+func TestConditionals(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "ssl.conf", "SSLCertificateFile /certs/inc.pem\n")
+	conf := `LoadModule ssl_module modules/mod_ssl.so
+Define PROD
+<IfModule mod_ssl.c>
+    SSLCertificateFile /certs/ssl.pem
+</IfModule>
+<IfModule !ssl_module>
+    SSLCertificateFile /certs/nossl.pem
+</IfModule>
+<IfModule http2_module>
+    Include ssl.conf
+</IfModule>
+<IfDefine PROD>
+    <IfDefine !DEV>
+        SSLCertificateKeyFile /certs/prod.key
+    </IfDefine>
+</IfDefine>
+<IfDefine DEV>
+    SSLCertificateKeyFile /certs/dev.key
+</IfDefine>
+`
+	cfg, err := ParseBytes([]byte(conf), filepath.Join(dir, "httpd.conf"), "")
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	var got []string
+	for _, d := range Active(cfg.Directives) {
+		if strings.HasPrefix(d.Name, "SSL") {
+			got = append(got, d.Arg(0))
+		}
+	}
+	if !reflect.DeepEqual(got, []string{"/certs/ssl.pem", "/certs/prod.key"}) {
+		t.Fatalf("条件节求值错误: %v", got)
+	}
+	if len(cfg.Files) != 1 {
+		t.Fatalf("未生效节中的 Include 不应展开: %v", cfg.Files)
+	}
+
+	// 未见 LoadModule（单独解析站点文件）时 IfModule 视为已加载
+	cfg, err = ParseBytes([]byte("<IfModule mod_ssl.c>\nSSLEngine on\n</IfModule>\n"), "site.conf", "")
+	if err != nil || len(Active(cfg.Directives)) != 1 {
+		t.Fatalf("无 LoadModule 时 IfModule 应生效: %v", err)
+	}
+}
+
+// Define 变量替换（${VAR}）
+func TestDefineExpand(t *testing.T) {
+	conf := "Define CERTDIR /etc/ssl/site\nSSLCertificateFile ${CERTDIR}/cert.pem\nSSLCertificateKeyFile ${UNDEF}/key.pem\n"
+	cfg, err := ParseBytes([]byte(conf), "a.conf", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Directives[1].Arg(0); got != "/etc/ssl/site/cert.pem" {
+		t.Fatalf("变量替换错误: %s", got)
+	}
+	if got := cfg.Directives[2].Arg(0); got != "${UNDEF}/key.pem" {
+		t.Fatalf("未定义变量应保持原样: %s", got)
+	}
+}
+
+// 站点：ServerName/ServerAlias、证书链、继承主服务器证书、无 VirtualHost 回退
+func TestSites(t *testing.T) {
+	conf := `SSLCertificateFile /certs/default.pem
+SSLCertificateKeyFile /certs/default.key
+SSLCertificateChainFile /certs/default-chain.pem
+<VirtualHost *:443>
+    ServerName https://a.com:443
+    ServerAlias www.a.com
+    SSLEngine on
+    SSLCertificateFile /certs/a.pem
+    SSLCertificateKeyFile /certs/a.key
+    SSLCertificateChainFile /certs/a-chain.pem
+</VirtualHost>
+<VirtualHost *:443>
+    ServerName inherit.com
+    SSLEngine on
+</VirtualHost>
+<VirtualHost *:80>
+    ServerName plain.com
+</VirtualHost>
+<VirtualHost *:443>
+    ServerName nochain.com
+    SSLCertificateFile /certs/n.pem
+    SSLCertificateKeyFile /certs/n.key
+</VirtualHost>
+`
+	cfg, err := ParseBytes([]byte(conf), "ssl.conf", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sites := Sites(cfg)
+	if len(sites) != 3 {
+		t.Fatalf("应解析出 3 个站点，实际 %d: %+v", len(sites), sites)
+	}
+	a := sites[0]
+	if !reflect.DeepEqual(a.ServerNames, []string{"a.com", "www.a.com"}) || a.ChainPath() != "/certs/a-chain.pem" || a.Cert.Pos() != "ssl.conf:8" {
+		t.Fatalf("站点 a.com 解析错误: %+v", a)
+	}
+	if !reflect.DeepEqual(a.Addrs(), []string{"*:443"}) {
+		t.Fatalf("地址错误: %v", a.Addrs())
+	}
+	in := sites[1]
+	if !in.Inherited || in.CertPath() != "/certs/default.pem" || in.KeyPath() != "/certs/default.key" || in.ChainPath() != "/certs/default-chain.pem" {
+		t.Fatalf("继承主服务器证书错误: %+v", in)
+	}
+	if sites[2].ChainPath() != "" {
+		t.Fatalf("未配置证书链时应为空: %s", sites[2].ChainPath())
+	}
+
+	cfg, _ = ParseBytes([]byte("ServerName main.com\nSSLCertificateFile /m.pem\nSSLCertificateKeyFile /m.key\n"), "main.conf", "")
+	if s := Sites(cfg); len(s) != 1 || s[0].ServerNames[0] != "main.com" || s[0].Section != nil {
+		t.Fatalf("无 VirtualHost 时应回退为主服务器站点: %+v", s)
+	}
+}
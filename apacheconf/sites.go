@@ -0,0 +1,185 @@
+package apacheconf
+
+import "strings"
+
+// VHost 一个启用证书的虚拟主机（<VirtualHost> 节；配置中没有 VirtualHost 时为主服务器配置）
+type VHost struct {
+	Section     *Directive // <VirtualHost> 节（主服务器配置时为 nil）
+	ServerNames []string   // ServerName + ServerAlias 全部域名（已去除协议与端口）
+	Cert        *Directive // SSLCertificateFile
+	Key         *Directive // SSLCertificateKeyFile
+	Chain       *Directive // SSLCertificateChainFile（Apache 2.4.8 以前中间证书需单独配置，可能为 nil）
+	SSLEngine   bool       // SSLEngine on
+	Inherited   bool       // 证书继承自主服务器配置
+}
+
+// CertPath 证书路径
+func (v VHost) CertPath() string { return argOf(v.Cert) }
+
+// KeyPath 私钥路径
+func (v VHost) KeyPath() string { return argOf(v.Key) }
+
+// ChainPath 中间证书链路径（未配置时为空）
+func (v VHost) ChainPath() string { return argOf(v.Chain) }
+
+// Addrs 返回 <VirtualHost> 的地址列表（如 *:443、[::]:8443）
+func (v VHost) Addrs() []string {
+	if v.Section == nil {
+		return nil
+	}
+	return v.Section.Args
+}
+
+func argOf(d *Directive) string {
+	if d == nil {
+		return ""
+	}
+	return d.Arg(0)
+}
+
+// Active 返回生效的指令：未生效的条件节（IfModule/IfDefine）整体跳过，生效的条件节展开为其子指令
+func Active(dirs []*Directive) []*Directive {
+	var out []*Directive
+	for _, d := range dirs {
+		if isConditional(d) {
+			if d.Active {
+				out = append(out, Active(d.Block)...)
+			}
+			continue
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// sslDirectives 一组指令中的证书相关设置（同名指令以最后一条为准，与 Apache 行为一致）
+type sslDirectives struct {
+	cert, key, chain *Directive
+	engine           bool
+}
+
+func collectSSL(dirs []*Directive) sslDirectives {
+	var s sslDirectives
+	for _, d := range dirs {
+		switch {
+		case d.Is("SSLCertificateFile"):
+			s.cert = d
+		case d.Is("SSLCertificateKeyFile"):
+			s.key = d
+		case d.Is("SSLCertificateChainFile"):
+			s.chain = d
+		case d.Is("SSLEngine"):
+			s.engine = strings.EqualFold(d.Arg(0), "on")
+		}
+	}
+	return s
+}
+
+// Sites 返回配置中启用证书的虚拟主机：
+// 虚拟主机未配置 SSLCertificateFile 时继承主服务器配置的证书（此时要求 SSLEngine on）；
+// 配置中没有 VirtualHost 时，主服务器配置本身含 ServerName 与证书即视为一个站点。
+func Sites(cfg *Config) []VHost {
+	global := Active(cfg.Directives)
+	main := collectSSL(global)
+
+	var sites []VHost
+	found := false
+	for _, d := range global {
+		if !d.IsSection || !d.Is("VirtualHost") {
+			continue
+		}
+		found = true
+		site := buildVHost(d, main)
+		if len(site.ServerNames) > 0 && site.Cert != nil && site.Key != nil {
+			sites = append(sites, site)
+		}
+	}
+	if !found {
+		if names := serverNames(global); len(names) > 0 && main.cert != nil && main.key != nil {
+			sites = append(sites, VHost{ServerNames: names, Cert: main.cert, Key: main.key, Chain: main.chain, SSLEngine: main.engine})
+		}
+	}
+	return sites
+}
+
+// VirtualHosts 返回配置中全部有域名的 <VirtualHost>（含未启用证书的，供自动配置证书使用）
+func VirtualHosts(cfg *Config) []VHost {
+	global := Active(cfg.Directives)
+	main := collectSSL(global)
+	var out []VHost
+	for _, d := range global {
+		if d.IsSection && d.Is("VirtualHost") {
+			if v := buildVHost(d, main); len(v.ServerNames) > 0 {
+				out = append(out, v)
+			}
+		}
+	}
+	return out
+}
+
+// buildVHost 解析 <VirtualHost> 节的域名与证书（未配置证书且 SSLEngine on 时继承主服务器配置的证书）
+func buildVHost(d *Directive, main sslDirectives) VHost {
+	body := Active(d.Block)
+	own := collectSSL(body)
+	site := VHost{Section: d, ServerNames: serverNames(body), Cert: own.cert, Key: own.key, Chain: own.chain, SSLEngine: own.engine}
+	if site.Cert == nil && main.cert != nil && own.engine {
+		site.Cert, site.Inherited = main.cert, true
+	}
+	if site.Key == nil && site.Inherited {
+		site.Key = main.key
+	}
+	if site.Chain == nil && site.Inherited {
+		site.Chain = main.chain
+	}
+	return site
+}
+
+// ListensOn 配置是否监听了指定端口（Listen 443、Listen 0.0.0.0:443 https 等）
+func ListensOn(cfg *Config, port string) bool {
+	for _, d := range Active(cfg.Directives) {
+		if d.Is("Listen") && (d.Arg(0) == port || strings.HasSuffix(d.Arg(0), ":"+port)) {
+			return true
+		}
+	}
+	return false
+}
+
+// serverNames 收集 ServerName 与 ServerAlias（ServerName 可带协议与端口：https://example.com:443）
+func serverNames(dirs []*Directive) []string {
+	var names []string
+	add := func(name string) {
+		if name == "" {
+			return
+		}
+		for _, n := range names {
+			if n == name {
+				return
+			}
+		}
+		names = append(names, name)
+	}
+	for _, d := range dirs {
+		if d.Is("ServerName") {
+			add(hostOf(d.Arg(0)))
+		}
+	}
+	for _, d := range dirs {
+		if d.Is("ServerAlias") {
+			for _, a := range d.Args {
+				add(a)
+			}
+		}
+	}
+	return names
+}
+
+// hostOf 去除 ServerName 中的协议与端口
+func hostOf(name string) string {
+	if i := strings.Index(name, "://"); i >= 0 {
+		name = name[i+3:]
+	}
+	if i := strings.LastIndex(name, ":"); i >= 0 && !strings.HasSuffix(name, "]") {
+		name = name[:i]
+	}
+	return name
+}
@@ -9,12 +9,15 @@ import (
 	"github.com/robfig/cron/v3"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"ssl_assistant/apacheconf"
 	"ssl_assistant/config"
 	"ssl_assistant/db"
+	"ssl_assistant/metrics"
+	"ssl_assistant/nginxconf"
+	"ssl_assistant/notify"
 	"ssl_assistant/third/certd"
 	"ssl_assistant/third/west"
 	"ssl_assistant/utils"
@@ -24,11 +27,8 @@ import (
 	"time"
 )
 
-// 常见的 Nginx 配置文件路径
+// 常见的 Nginx 配置文件路径（面板的站点配置路径见 cert_panel.go）
 var defaultNginxPaths = []string{
-	"/www/server/panel/vhost/nginx/*.conf", // 宝塔 Nginx
-	"/www/server/apache/vhost/*.conf",      // 宝塔 Apache
-	"/opt/1panel/www/conf.d/*.conf",        // 1panel
 	"/etc/nginx/nginx.conf",
 	"/etc/nginx/conf.d/*.conf",
 	"/usr/local/nginx/conf/nginx.conf",
@@ -36,6 +36,15 @@ var defaultNginxPaths = []string{
 	"/etc/apache2/sites-enabled/*.conf", // Apache
 	"/etc/apache2/sites-available/*.conf",
 	"/etc/httpd/conf.d/*.conf",
+	"/etc/caddy/Caddyfile",     // Caddy（tls 证书 私钥）
+	"/etc/haproxy/haproxy.cfg", // HAProxy（bind ... ssl crt）
+	"/etc/traefik/*.yml",       // Traefik file provider 动态配置（tls.certificates）
+	"/etc/traefik/*.yaml",
+	"/etc/traefik/*.toml",
+	"/etc/traefik/dynamic/*.yml",
+	"/etc/traefik/dynamic/*.yaml",
+	"/etc/traefik/dynamic/*.toml",
+	"/etc/lighttpd/lighttpd.conf", // lighttpd（ssl.pemfile，跟随 include）
 	"C:\\nginx\\conf\\nginx.conf",
 	"D:\\nginx\\conf\\nginx.conf",
 }
@@ -114,110 +123,36 @@ func initConfig() {
 
 // nginxSite 表示从 Nginx 配置解析出的一个站点（server 块）
 type nginxSite struct {
-	Domain   string   // 主域名（server_name 第一个）
-	Domains  []string // server_name 全部域名（SAN 覆盖校验用）
-	CertPath string   // ssl_certificate 路径
-	KeyPath  string   // ssl_certificate_key 路径
-}
-
-// discoverPanelPaths 智能探测小皮面板（phpstudy）的 Nginx/Apache 站点配置目录。
-// phpstudy 安装目录与 Nginx 版本号不确定（如 D:\phpstudy_pro\Extensions\Nginx1.15.11\conf\vhosts），
-// 通过枚举盘符 + 遍历 Extensions 下 Nginx*/Apache* 目录动态定位；找不到时返回空，不影响原有扫描。
-// 结果进程内缓存一次（phpstudy 安装/卸载后需重启程序重新识别）。
-var (
-	panelPathsOnce sync.Once
-	panelPaths     []string
-)
-
-func discoverPanelPaths() []string {
-	panelPathsOnce.Do(func() {
-		if runtime.GOOS != "windows" {
-			return
-		}
-		// 枚举存在的盘符（覆盖 C/D/E 及网络盘、U 盘等自定义安装位置）
-		for c := 'A'; c <= 'Z'; c++ {
-			drive := string(c) + ":\\"
-			if _, err := os.Stat(drive); err != nil {
-				continue
-			}
-			panelPaths = append(panelPaths, discoverPhpstudyFromRoot(filepath.Join(drive, "phpstudy_pro"))...)
-		}
-	})
-	return panelPaths
-}
-
-// discoverPhpstudyFromRoot 给定 phpstudy 根目录，返回其 Nginx/Apache 的 vhosts 通配路径（版本号无关）
-func discoverPhpstudyFromRoot(root string) []string {
-	var paths []string
-	extDir := filepath.Join(root, "Extensions")
-	entries, err := os.ReadDir(extDir)
-	if err != nil {
-		return paths
-	}
-	for _, e := range entries {
-		if !e.IsDir() {
-			continue
-		}
-		name := e.Name()
-		// Nginx 或 Apache 开头（版本号可变），如 Nginx1.15.11、Apache2.4.39
-		if !strings.HasPrefix(name, "Nginx") && !strings.HasPrefix(name, "Apache") {
-			continue
-		}
-		vhosts := filepath.Join(extDir, name, "conf", "vhosts")
-		if info, err := os.Stat(vhosts); err == nil && info.IsDir() {
-			paths = append(paths, filepath.Join(vhosts, "*.conf"))
-		}
-	}
-	return paths
+	Domain   string     // 主域名（server_name 第一个）
+	Domains  []string   // server_name 全部域名（SAN 覆盖校验用）
+	CertPath string     // ssl_certificate 路径
+	KeyPath  string     // ssl_certificate_key 路径
+	Ports    []string   // HTTPS 监听端口（listen ... ssl / <VirtualHost *:443>，TLS 探测用）
+	Location string     // 证书指令所在位置（file:line）
+	Pairs    []certPair // 全部证书/私钥对（Nginx RSA + ECDSA 双证书时多组，第一组即 CertPath/KeyPath）
+	// Apache SSLCertificateChainFile 路径（中间证书单独存放，部署时叶子证书与证书链分别写入）
+	ChainPath string
+	// certbot / acme.sh 签发的证书（添加时直接读取原工具的证书文件，不从平台获取）
+	Import *db.CertImport
 }
 
-// defaultCertDirs 证书文件目录（新版宝塔将证书统一存于 /www/server/panel/vhost/cert/<域名>/，Nginx/Apache 共用）
-var defaultCertDirs = []string{"/www/server/panel/vhost/cert"}
-
-// scanCertDir 扫描证书目录：第一层子目录名为域名，第二层为 fullchain.pem/privkey.pem 证书文件。
-// 返回按目录名识别出的站点（域名 + 证书/私钥路径），目录不存在或文件缺失时返回空。
-func scanCertDir(certRoot string) []nginxSite {
-	var sites []nginxSite
-	// 递归两层：certRoot/<域名>/fullchain.pem
-	matches, err := filepath.Glob(filepath.Join(certRoot, "*", "fullchain.pem"))
-	if err != nil {
-		return sites
-	}
-	for _, certPath := range matches {
-		domain := filepath.Base(filepath.Dir(certPath))
-		keyPath := filepath.Join(filepath.Dir(certPath), "privkey.pem")
-		if _, err := os.Stat(keyPath); err != nil {
-			// 缺少私钥的证书目录跳过
-			continue
-		}
-		sites = append(sites, nginxSite{
-			Domain:   domain,
-			Domains:  []string{domain},
-			CertPath: certPath,
-			KeyPath:  keyPath,
-		})
-	}
-	return sites
-}
-
-// scanAllCertDirs 扫描全部证书目录（宝塔新版），聚合返回站点
+// scanAllCertDirs 扫描全部证书目录（面板站点证书目录、certbot、acme.sh），聚合返回站点
 func scanAllCertDirs() []nginxSite {
-	var sites []nginxSite
-	for _, dir := range defaultCertDirs {
-		sites = append(sites, scanCertDir(dir)...)
-	}
-	return sites
+	return append(panelCertSites(), scanImportedCerts()...)
 }
 
 // findNginxConfigs 寻找 Nginx/Apache 配置文件，聚合返回解析出的站点（按主域名去重合并，不立即添加）
-// paths 为空时使用默认路径并自动探测面板（小皮 phpstudy）站点目录
+// 并自动探测已安装面板（宝塔/aaPanel、1Panel、小皮）的站点目录
 func findNginxConfigs(paths []string) []nginxSite {
-	color.Cyan("正在寻找 Nginx/Apache 配置文件...")
+	color.Cyan("正在寻找 Nginx/Apache/Caddy/HAProxy/Traefik/lighttpd 配置文件...")
 
-	// 智能探测面板（小皮 phpstudy）站点目录并合并
+	// 智能探测面板站点目录并合并
+	for _, p := range installedPanels() {
+		color.Cyan("检测到%s: %s\n", p.Layout.Name, p.Root)
+	}
 	paths = append(paths, discoverPanelPaths()...)
 
-	// 证书目录扫描（新版宝塔统一证书目录，按域名目录自动识别）
+	// 证书目录扫描（面板站点证书目录按站点名自动识别，certbot / acme.sh）
 	certSites := scanAllCertDirs()
 
 	// 配置解析（宝塔/1Panel/原生 Nginx/Apache 等）
@@ -326,185 +261,105 @@ func unionStrings(a, b []string) []string {
 	return out
 }
 
-// serverBlockStartRegex 匹配 server 块起始（server 后跟空白与左花括号，不会误匹配 server_name）
-var serverBlockStartRegex = regexp.MustCompile(`(?m)server\s*\{`)
-
-// findServerBlocks 按大括号深度匹配提取所有 server { ... } 块（支持 location/if 等嵌套块）
-func findServerBlocks(content string) []string {
-	var blocks []string
-	pos := 0
-	for {
-		loc := serverBlockStartRegex.FindStringIndex(content[pos:])
-		if loc == nil {
-			break
-		}
-		start := pos + loc[0]
-		braceIdx := start + strings.Index(content[start:pos+loc[1]], "{")
-		depth := 0
-		end := -1
-		for i := braceIdx; i < len(content); i++ {
-			switch content[i] {
-			case '{':
-				depth++
-			case '}':
-				depth--
-				if depth == 0 {
-					end = i
-				}
-			}
-			if end > 0 {
-				break
-			}
-		}
-		if end < 0 {
-			break
-		}
-		blocks = append(blocks, content[start:end+1])
-		pos = end + 1
-	}
-	return blocks
-}
-
-// virtualHostBlockRegex 匹配 Apache VirtualHost 块（大小写不敏感，支持属性如 *:443）
-var virtualHostBlockRegex = regexp.MustCompile(`(?is)<VirtualHost[^>]*>.*?</VirtualHost>`)
-
-// Apache 指令正则（大小写不敏感，包级复用避免重复编译）
-var (
-	apacheServerNameRegex  = regexp.MustCompile(`(?i)ServerName\s+(\S+)`)
-	apacheServerAliasRegex = regexp.MustCompile(`(?i)ServerAlias\s+([^\n<#]+)`)
-	apacheSSLKeyRegex      = regexp.MustCompile(`(?i)SSLCertificateKeyFile\s+(\S+)`)
-	apacheSSLCertRegex     = regexp.MustCompile(`(?i)SSLCertificateFile\s+(\S+)`)
-)
-
-// stripApacheComments 过滤 Apache 配置中的注释行（# 开头），避免注释中的指令被误匹配
-func stripApacheComments(content string) string {
-	var lines []string
-	for _, line := range strings.Split(content, "\n") {
-		if strings.HasPrefix(strings.TrimSpace(line), "#") {
-			continue
-		}
-		lines = append(lines, line)
-	}
-	return strings.Join(lines, "\n")
-}
-
-// findApacheBlocks 提取所有 <VirtualHost>...</VirtualHost> 块（Apache 不允许嵌套 VirtualHost）
-func findApacheBlocks(content string) []string {
-	return virtualHostBlockRegex.FindAllString(content, -1)
-}
-
-// isApacheConfig 判断配置内容是否为 Apache 语法（含 <VirtualHost 标签，大小写不敏感）
+// isApacheConfig 判断配置内容是否为 Apache 语法：含 <VirtualHost / <IfModule / <IfDefine 节，
+// 或 ServerRoot、SSLCertificateFile 等 Apache 特有指令（大小写不敏感，Nginx 配置不会出现）
 func isApacheConfig(content string) bool {
-	return virtualHostOpenRegex.MatchString(content)
+	return apacheSyntaxRegex.MatchString(content)
 }
 
-// virtualHostOpenRegex 匹配 <VirtualHost 开标签（大小写不敏感）
-var virtualHostOpenRegex = regexp.MustCompile(`(?i)<VirtualHost\b`)
+// apacheSyntaxRegex 匹配 Apache 特有的节或指令（行首，大小写不敏感）
+var apacheSyntaxRegex = regexp.MustCompile(`(?im)<(VirtualHost|IfModule|IfDefine)\b|^\s*(ServerRoot|SSLCertificateFile|IncludeOptional)\s`)
 
-// parseConfigFile 按语法自动识别并解析 Nginx 或 Apache 配置文件
+// parseConfigFile 按语法自动识别并解析 Nginx、Apache 或 Caddy / HAProxy / Traefik / lighttpd 配置文件
 func parseConfigFile(path string) []nginxSite {
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return nil
 	}
+	if sites, ok := parseServerConfig(path, content, true); ok {
+		return sites
+	}
 	if isApacheConfig(string(content)) {
 		return parseApacheConfig(path)
 	}
 	return parseNginxConfig(path)
 }
 
-// 解析 Apache 配置文件，返回含证书配置的站点列表（仅收集，不添加）
+// 解析 Apache 配置文件，返回含证书配置的站点列表（仅收集，不添加）。
+// 跟随 Include/IncludeOptional（相对 ServerRoot、支持通配符与目录），未加载模块的 <IfModule> 与未定义的 <IfDefine> 中的指令不生效。
 func parseApacheConfig(path string) []nginxSite {
 	fmt.Println("解析配置文件:", path)
 
-	// 读取配置文件
-	content, err := os.ReadFile(path)
+	cfg, err := apacheconf.Parse(path)
 	if err != nil {
-		fmt.Println("读取配置文件失败:", err)
+		fmt.Println("解析配置文件失败:", err)
 		return nil
 	}
-
-	// 过滤注释行（# 开头），避免注释中的指令被误匹配
-	cleaned := stripApacheComments(string(content))
-
-	// 按 VirtualHost 块为单位解析
-	blocks := findApacheBlocks(cleaned)
-	if len(blocks) == 0 {
-		// 无 VirtualHost 块（如纯 Include），回退为整文件匹配
-		blocks = []string{cleaned}
+	for _, e := range cfg.Errors {
+		color.Yellow("  %s\n", e)
+	}
+	sites := apacheSitesFromConfig(cfg)
+	for _, site := range sites {
+		fmt.Printf("  发现站点 %s（%s）\n", site.Domain, site.Location)
 	}
+	return sites
+}
 
+// apacheSitesFromConfig 将解析出的 Apache 虚拟主机转换为 nginxSite（证书位置取 SSLCertificateFile 所在 file:line）
+func apacheSitesFromConfig(cfg *apacheconf.Config) []nginxSite {
 	var sites []nginxSite
-	for _, block := range blocks {
-		serverNameMatch := apacheServerNameRegex.FindStringSubmatch(block)
-		sslCertMatch := apacheSSLCertRegex.FindStringSubmatch(block)
-		sslKeyMatch := apacheSSLKeyRegex.FindStringSubmatch(block)
-
-		// 如果找到了 ServerName 和 SSLCertificateFile，则收集该站点
-		if len(serverNameMatch) > 0 && len(sslCertMatch) > 0 && len(sslKeyMatch) > 0 {
-			domains := []string{strings.TrimSpace(serverNameMatch[1])}
-			// ServerAlias 可包含多个域名（[^\n<#]+ 已排除行内注释）
-			if aliasMatch := apacheServerAliasRegex.FindStringSubmatch(block); len(aliasMatch) > 0 {
-				for _, d := range strings.Fields(aliasMatch[1]) {
-					domains = append(domains, d)
-				}
-			}
-			sites = append(sites, nginxSite{
-				Domain:   domains[0],
-				Domains:  domains,
-				CertPath: trimQuotes(strings.TrimSpace(sslCertMatch[1])),
-				KeyPath:  trimQuotes(strings.TrimSpace(sslKeyMatch[1])),
-			})
-		}
+	for _, v := range apacheconf.Sites(cfg) {
+		sites = append(sites, nginxSite{
+			Domain:    v.ServerNames[0],
+			Domains:   v.ServerNames,
+			CertPath:  v.CertPath(),
+			KeyPath:   v.KeyPath(),
+			ChainPath: v.ChainPath(),
+			Ports:     apacheVirtualHostPorts(v.Addrs()),
+			Location:  v.Cert.Pos(),
+		})
 	}
 	return sites
 }
 
-// 解析 Nginx 配置文件，返回含证书配置的站点列表（仅收集，不添加；添加由用户勾选后执行）
+// 解析 Nginx 配置文件，返回含证书配置的站点列表（仅收集，不添加；添加由用户勾选后执行）。
+// 跟随 include（相对 nginx 前缀目录、支持通配符），证书可声明在被包含的片段或 http 块中。
 func parseNginxConfig(path string) []nginxSite {
 	fmt.Println("解析配置文件:", path)
 
-	// 读取配置文件
-	content, err := os.ReadFile(path)
+	cfg, err := nginxconf.Parse(path)
 	if err != nil {
-		fmt.Println("读取配置文件失败:", err)
+		fmt.Println("解析配置文件失败:", err)
 		return nil
 	}
-
-	// 按 server 块为单位解析，避免多个 server 块之间字段错位（支持嵌套块）
-	serverNameRegex := regexp.MustCompile(`server_name\s+([^;]+);`)
-	sslCertRegex := regexp.MustCompile(`ssl_certificate\s+([^;]+);`)
-	sslKeyRegex := regexp.MustCompile(`ssl_certificate_key\s+([^;]+);`)
-
-	blocks := findServerBlocks(string(content))
-	if len(blocks) == 0 {
-		// 无 server 块（如纯 include 或 http 块），回退为整文件匹配
-		blocks = []string{string(content)}
+	for _, e := range cfg.Errors {
+		color.Yellow("  %s\n", e)
+	}
+	sites := nginxSitesFromConfig(cfg)
+	for _, site := range sites {
+		fmt.Printf("  发现站点 %s（%s）\n", site.Domain, site.Location)
 	}
+	return sites
+}
 
+// nginxSitesFromConfig 将解析出的 Nginx 站点转换为 nginxSite（证书位置取 ssl_certificate 所在 file:line）
+func nginxSitesFromConfig(cfg *nginxconf.Config) []nginxSite {
 	var sites []nginxSite
-	for _, block := range blocks {
-		serverNameMatch := serverNameRegex.FindStringSubmatch(block)
-		sslCertMatch := sslCertRegex.FindStringSubmatch(block)
-		sslKeyMatch := sslKeyRegex.FindStringSubmatch(block)
-
-		// 如果找到了 server_name 和 ssl_certificate，则收集该站点
-		if len(serverNameMatch) > 0 && len(sslCertMatch) > 0 && len(sslKeyMatch) > 0 {
-			serverName := strings.TrimSpace(serverNameMatch[1])
-			sslCert := trimQuotes(strings.TrimSpace(sslCertMatch[1]))
-			sslKey := trimQuotes(strings.TrimSpace(sslKeyMatch[1]))
-
-			// 分割 server_name，可能有多个域名
-			domains := strings.Fields(serverName)
-			if len(domains) > 0 {
-				sites = append(sites, nginxSite{
-					Domain:   domains[0],
-					Domains:  domains,
-					CertPath: sslCert,
-					KeyPath:  sslKey,
-				})
+	for _, site := range nginxconf.Sites(cfg) {
+		s := nginxSite{
+			Domain:   site.ServerNames[0],
+			Domains:  site.ServerNames,
+			CertPath: site.CertPath(),
+			KeyPath:  site.KeyPath(),
+			Ports:    nginxSSLPorts(site),
+			Location: site.Certificates[0].Pos(),
+		}
+		if pairs := site.Pairs(); len(pairs) > 1 {
+			for _, p := range pairs {
+				s.Pairs = append(s.Pairs, certPair{CertPath: p.CertPath(), KeyPath: p.KeyPath()})
 			}
 		}
+		sites = append(sites, s)
 	}
 	return sites
 }
@@ -522,6 +377,11 @@ func buildCertFromLocalFiles(domain, certPath, keyPath string) (db.Certificate,
 	if err != nil {
 		return cert, fmt.Errorf("读取本地私钥文件失败: %v", err)
 	}
+	if isCombinedPEM(db.Deployment{CertPath: certPath, KeyPath: keyPath}) {
+		// 证书与私钥合并在同一文件（HAProxy / lighttpd）：按块类型拆分
+		pub, priv := splitCombinedPEM(crt)
+		crt, key = []byte(pub), []byte(priv)
+	}
 	endCert, err := utils.ParseCertificate(crt)
 	if err != nil {
 		return cert, fmt.Errorf("解析本地证书失败: %v", err)
@@ -537,36 +397,88 @@ func buildCertFromLocalFiles(domain, certPath, keyPath string) (db.Certificate,
 	}
 	cert.PublicKey = string(crt)
 	cert.PrivateKey = string(key)
-	cert.CertPath = certPath
-	cert.KeyPath = keyPath
+	cert.Deployments = []db.Deployment{{CertPath: certPath, KeyPath: keyPath}}
 	cert.CertSource = "local"
+	cert.KeyType = utils.CertKeyType(endCert)
 	if len(endCert.DNSNames) > 0 {
 		cert.CertDomains = strings.Join(endCert.DNSNames, ",")
 	}
 	return cert, nil
 }
 
-// addSiteFromNginx 将一个从 Nginx 配置解析出的站点添加为证书（查重 → 平台拉取或本地回退 → SAN 校验 → 保存）
+// addSiteFromNginx 将一个从 Nginx 配置解析出的站点添加为证书（查重 → 平台拉取或本地回退 → SAN 校验 → 保存）。
+// 站点配置了多组证书（RSA + ECDSA 双证书）时按密钥类型逐组添加，每组一条记录，更新时各自独立获取与部署。
+// 域名已有对应证书记录（如同一证书同时部署在 Nginx 与 Apache）时，将配置中的路径追加为该证书的部署位置。
 func addSiteFromNginx(site nginxSite) {
 	domain := site.Domain
-	color.Cyan("添加域名: %s, 证书: %s, 私钥: %s\n", domain, site.CertPath, site.KeyPath)
-
-	if checkHasDomain(domain) {
-		color.Yellow("域名 %s 的证书信息已存在，无需重复添加\n", domain)
+	pairs := site.certPairs()
+	if len(pairs) > 1 {
+		color.Cyan("域名 %s 配置了 %d 组证书，将按密钥类型分别添加\n", domain, len(pairs))
+	}
+
+	var todo []certPair
+	for _, p := range pairs {
+		p.KeyType = localKeyType(p.CertPath)
+		color.Cyan("添加域名: %s, 证书: %s, 私钥: %s\n", certLabel(domain, p.KeyType), p.CertPath, p.KeyPath)
+		if existing, found := findCertVariant(domain, p.KeyType, p.CertPath); found {
+			if hasDeployment(existing, p.CertPath) {
+				color.Yellow("域名 %s 的证书信息已存在，无需重复添加\n", certLabel(domain, p.KeyType))
+			} else if _, err := attachDeployment(existing, deploymentOf(p)); err != nil {
+				fmt.Printf("%v\n", err)
+			} else {
+				color.Yellow("新部署位置的证书文件将在证书下次更新时写入（可执行 deploy add 立即写入）\n")
+			}
+			continue
+		}
+		todo = append(todo, p)
+	}
+	if len(todo) == 0 {
+		return
+	}
+	if site.Import != nil {
+		for _, p := range todo {
+			addSiteCertPair(site, p, db.Certificate{}, nil)
+		}
 		return
 	}
 
-	// 获取证书信息（第三方平台 API 请求，可能需要几秒到几十秒）
+	// 获取证书信息（第三方平台 API 请求，可能需要几秒到几十秒；多组证书共用一次请求结果）
 	color.Cyan("正在从证书平台获取 %s 的证书信息...\n", domain)
-	cert, err := getCertificateInfo(domain, "", 0)
-	if err != nil {
+	platformCert, platformErr := getCertificateInfo(domain, globalProviderOrder(), 0, providerRef{})
+	for _, p := range todo {
+		addSiteCertPair(site, p, platformCert, platformErr)
+	}
+}
+
+// addSiteCertPair 添加站点的一组证书：平台证书密钥类型与本地文件一致时使用平台证书，否则回退读取本地文件；
+// certbot / acme.sh 签发的证书读取原工具的证书文件
+func addSiteCertPair(site nginxSite, p certPair, platformCert db.Certificate, platformErr error) {
+	domain := site.Domain
+	label := certLabel(domain, p.KeyType)
+	cert, err := platformCert, platformErr
+	// 平台按域名只返回一种密钥类型：与本地文件类型不符时读取本地文件，且该记录无法按域名自动更新
+	mismatch := err == nil && p.KeyType != "" && cert.KeyType != p.KeyType
+	if mismatch {
+		err = fmt.Errorf("平台返回的是 %s 证书", keyTypeLabel(cert.KeyType))
+	}
+	if site.Import != nil {
+		if cert, err = importedCertificate(domain, *site.Import); err != nil {
+			fmt.Printf("读取域名 %s 的 %s 证书失败: %v\n", label, site.Import.Tool, err)
+			return
+		}
+	} else if err != nil {
 		// 平台未配置或拉取失败：回退读取本地证书文件，保证已有证书也能被纳管
 		color.Yellow("平台获取失败（%v），尝试从本地证书文件读取...\n", err)
-		cert, err = buildCertFromLocalFiles(domain, site.CertPath, site.KeyPath)
+		cert, err = buildCertFromLocalFiles(domain, p.CertPath, p.KeyPath)
 		if err != nil {
-			fmt.Printf("获取域名 %s 的证书信息失败: %v\n", domain, err)
+			fmt.Printf("获取域名 %s 的证书信息失败: %v\n", label, err)
 			return
 		}
+		cert = appendLocalChain(cert, p.ChainPath)
+		if mismatch {
+			cert.CertSource = manualSource
+			color.Yellow("域名 %s 的证书已标记为手动维护，不自动更新；%s\n", label, manualHint("<ID>"))
+		}
 	}
 	// SAN 校验：server_name 中的其他域名是否在证书覆盖范围内
 	if cert.CertDomains != "" {
@@ -581,17 +493,16 @@ func addSiteFromNginx(site nginxSite) {
 			color.Yellow("警告: 域名 %s 不在证书覆盖范围内（证书仅覆盖: %s）\n", strings.Join(missing, ","), cert.CertDomains)
 		}
 	}
-	// 设置证书路径（平台来源时覆盖为 Nginx 配置中的路径）
-	cert.CertPath = site.CertPath
-	cert.KeyPath = site.KeyPath
+	// 设置部署位置（平台来源时覆盖为 Nginx 配置中的路径）
+	cert.Deployments = []db.Deployment{deploymentOf(p)}
 
 	// 保存证书信息
 	err = db.AddCertificateToDBWrapper(cert)
 	if err != nil {
-		fmt.Printf("保存域名 %s 的证书信息失败: %v\n", domain, err)
+		fmt.Printf("保存域名 %s 的证书信息失败: %v\n", label, err)
 		return
 	}
-	color.Green("域名 %s 的证书信息已保存\n", domain)
+	color.Green("域名 %s 的证书信息已保存\n", certLabel(domain, cert.KeyType))
 }
 
 // selectAndAddNginxSites 展示检索到的站点域名，让用户回车勾选后批量添加。
@@ -628,12 +539,13 @@ func selectAndAddNginxSites(sites []nginxSite) {
 	color.Green("\n全部 %d 个域名添加完成\n", len(selected))
 }
 
-// 获取证书信息 certd/west
+// 获取证书信息：按平台顺序依次尝试，跳过未配置的平台，成功即停止
 // @param domain 域名
-// @param certSource 证书来源(west/certd)，传空自动判断
+// @param providers 平台获取顺序（平台实例引用，如 certd、certd.prod、west，见 certProviders / globalProviderOrder）
 // @param certID 来源平台证书ID（certd证书仓库ID，更新时优先使用，0表示用域名查询）
-// @return db.Certificate 证书信息
-func getCertificateInfo(domain string, certSource string, certID int) (db.Certificate, error) {
+// @param idRef certID 所属的平台实例（仅向该实例传递 certID，其余平台实例按域名查询，避免取到或覆盖其他实例中同 ID 的证书）
+// @return db.Certificate 证书信息（CertSource / ProviderInstance 为实际提供证书的平台实例；申请中时仅含这两项）
+func getCertificateInfo(domain string, providers []string, certID int, idRef providerRef) (db.Certificate, error) {
 	var cert db.Certificate
 	var crt, key []byte
 	var err error
@@ -655,49 +567,38 @@ func getCertificateInfo(domain string, certSource string, certID int) (db.Certif
 		}
 	}
 
-	switch certSource {
-	case "west":
-		color.Yellow("正在尝试使用West获取证书信息...\n")
-		err, crt, _, key = west.GetCert(domain)
-		if err != nil {
-			return db.Certificate{}, err
+	var tried, errs []string
+	for _, p := range providers {
+		ref, perr := parseProviderRef(p)
+		if perr != nil || !providerConfigured(ref) {
+			continue
 		}
-		cert.CertSource = "west"
-	case "certd":
-		color.Yellow("正在尝试使用Certd获取证书信息...\n")
+		tried = append(tried, p)
+		color.Yellow("正在尝试使用 %s 获取证书信息...\n", p)
 		var detail *certd.CertDetail
-		crt, key, detail, err = certd.GetCertificateInfo(domain, certID)
-		if err != nil {
-			if errors.Is(err, certd.ErrCertApplying) {
-				color.Yellow("Certd已自动触发证书申请，请稍后重新执行获取\n")
-			} else {
-				color.Red("Certd:%s\n", err)
-			}
-			return db.Certificate{}, err
+		id := 0
+		if ref.same(idRef) {
+			id = certID
 		}
-		cert.CertSource = "certd"
-		applyCertdDetail(detail)
-	default:
-		color.Yellow("正在尝试使用West获取证书信息...\n")
-		err, crt, _, key = west.GetCert(domain)
-		if err != nil {
-			color.Red("West:%s\n", err)
-			color.Yellow("正在尝试使用Certd获取证书信息...\n")
-			var detail *certd.CertDetail
-			crt, key, detail, err = certd.GetCertificateInfo(domain, certID)
-			if err != nil {
-				if errors.Is(err, certd.ErrCertApplying) {
-					color.Yellow("Certd已自动触发证书申请，请稍后重新执行获取\n")
-				} else {
-					color.Red("Certd:%s\n", err)
-				}
-				return db.Certificate{}, err
-			}
-			cert.CertSource = "certd"
+		crt, key, detail, err = fetchFromProvider(ref, domain, id)
+		if err == nil {
+			cert.CertSource, cert.ProviderInstance = ref.Type, ref.instance()
 			applyCertdDetail(detail)
-		} else {
-			cert.CertSource = "west"
+			break
+		}
+		if errors.Is(err, certd.ErrCertApplying) {
+			color.Yellow("Certd已自动触发证书申请，证书签发前将记录为申请中\n")
+			// 返回触发申请的平台实例，申请中记录据此跟进
+			return db.Certificate{CertSource: ref.Type, ProviderInstance: ref.instance()}, err
 		}
+		color.Red("%s:%s\n", p, err)
+		errs = append(errs, fmt.Sprintf("%s: %v", p, err))
+	}
+	if len(tried) == 0 {
+		return db.Certificate{}, fmt.Errorf("证书平台 %s 均未配置，请先配置密钥", strings.Join(providers, "、"))
+	}
+	if err != nil && len(errs) > 1 {
+		return db.Certificate{}, fmt.Errorf("%s", strings.Join(errs, "; "))
 	}
 	if err != nil {
 		return db.Certificate{}, err
@@ -728,12 +629,15 @@ func getCertificateInfo(domain string, certSource string, certID int) (db.Certif
 	}
 	cert.PublicKey = string(crt)
 	cert.PrivateKey = string(key)
+	cert.KeyType = utils.CertKeyType(endCert)
 
 	return cert, nil
 }
 
 // 添加证书
-func addCertificate() error {
+// wait 为 true 时（add --wait），证书处于申请中则阻塞等待签发后再部署；
+// configure 为 true 时（add --configure），证书部署到托管路径，并修改 Nginx/Apache 配置指向该路径
+func addCertificate(wait, configure bool) error {
 	if err := initGuide(false); err != nil {
 		return err
 	}
@@ -741,36 +645,47 @@ func addCertificate() error {
 	domain := utils.ReadInput("请输入域名: ", "")
 
 	// 获取证书信息（优先平台拉取；平台未配置/失败时回退读取本地证书文件，保证已有证书也能添加）
-	cert, err := getCertificateInfo(domain, "", 0)
+	cert, err := getCertificateInfo(domain, globalProviderOrder(), 0, providerRef{})
+	if errors.Is(err, certd.ErrCertApplying) {
+		return addPendingCertificate(domain, cert.ProviderInstance, wait, configure)
+	}
 	if err != nil {
 		color.Yellow("平台获取失败（%v），尝试从本地证书文件读取...\n", err)
-		certPath, keyPath, matched := findNginxCertPaths(domain)
-		if matched {
-			cert, err = buildCertFromLocalFiles(domain, certPath, keyPath)
+		if p, matched := findNginxCertPaths(domain, ""); matched {
+			cert, err = buildCertFromLocalFiles(domain, p.CertPath, p.KeyPath)
+			if err == nil {
+				cert = appendLocalChain(cert, p.ChainPath)
+				cert.Deployments = []db.Deployment{deploymentOf(p)}
+			}
 		}
 		if err != nil {
 			return fmt.Errorf("获取证书信息失败（平台未配置或本地证书不存在）: %s", err)
 		}
 	}
 
-	if checkHasDomain(domain) {
-		return fmt.Errorf("域名 %s 的证书信息已存在，无需重复添加\n", domain)
+	// 自动配置：部署到托管路径（替换本地回退时读取到的原路径）
+	if configure {
+		cert.Deployments = []db.Deployment{managedDeployment(domain)}
 	}
 
-	// 平台来源且尚未设置路径：自动从宝塔/Nginx 配置匹配，未匹配到再手动输入
-	if cert.CertPath == "" {
-		certPath, keyPath, found := findNginxCertPaths(domain)
-		if found {
-			fmt.Printf("已自动从 Nginx 配置找到证书路径:\n  证书: %s\n  私钥: %s\n", certPath, keyPath)
-			if utils.Confirm("是否使用自动匹配的路径") {
-				cert.CertPath = certPath
-				cert.KeyPath = keyPath
-			}
+	// 平台来源且尚未设置路径：自动从宝塔/Nginx 配置匹配（双证书时选择密钥类型一致的一组），未匹配到再手动输入
+	if len(cert.Deployments) == 0 {
+		d, err := validateDeployment(deploymentOf(resolveCertPaths(domain, cert.KeyType)))
+		if err != nil {
+			return err
 		}
-		if cert.CertPath == "" {
-			cert.CertPath = utils.ReadInput("请输入证书存放路径（需包含文件名）: ", "")
-			cert.KeyPath = utils.ReadInput("请输入私钥存放路径（需包含文件名）: ", "")
+		cert.Deployments = []db.Deployment{d}
+	}
+
+	// 域名已有该证书：将路径追加为其部署位置并写入文件，而不是重复添加记录
+	if existing, found := findCertVariant(domain, cert.KeyType, cert.Deployments[0].CertPath); found {
+		// 已部署到托管路径时重复执行 add --configure 只修改配置
+		if !configure || !hasDeployment(existing, cert.Deployments[0].CertPath) {
+			if err := addDeploymentToCert(existing, cert.Deployments[0]); err != nil || !configure {
+				return err
+			}
 		}
+		return configureWebServer(domain, cert.Deployments[0])
 	}
 
 	// 保存证书信息
@@ -783,26 +698,97 @@ func addCertificate() error {
 
 	// 更新证书文件
 	err = updateCertificateFiles(cert)
+	if err != nil || !configure {
+		return err
+	}
+	return configureWebServer(domain, cert.Deployments[0])
+}
+
+// addPendingCertificate 证书申请中（certd code=20013）时记录为申请中：
+// 保存域名与部署路径，由守护进程（cron）按退避间隔自动跟进；wait 为 true 时阻塞等待签发。
+// configure 时须同时 wait：证书签发、文件写入后才能修改配置（否则配置测试因证书文件不存在而失败）
+func addPendingCertificate(domain, instance string, wait, configure bool) error {
+	if configure && !wait {
+		return fmt.Errorf("域名 %s 的证书申请中，--configure 需配合 --wait 使用（签发后再修改配置）", domain)
+	}
+	var d db.Deployment
+	var err error
+	if configure {
+		d = managedDeployment(domain)
+	} else if d, err = validateDeployment(deploymentOf(resolveCertPaths(domain, ""))); err != nil {
+		return err
+	}
+	if existing, found := findCertVariant(domain, "", d.CertPath); found {
+		// 域名已有记录：追加部署位置（已签发的证书立即写入，申请中的随签发部署）
+		return addDeploymentToCert(existing, d)
+	}
+	cert := markCertPending(db.Certificate{Domain: domain, CertSource: "certd", ProviderInstance: instance})
+	cert.Deployments = []db.Deployment{d}
+
+	if err := db.AddCertificateToDBWrapper(cert); err != nil {
+		return fmt.Errorf("保存证书信息失败: %s", err)
+	}
+	if !wait {
+		color.Yellow("域名 %s 已记录为申请中，证书签发后由证书更新任务（cron）自动部署并重载；也可使用 add --wait 等待签发\n", domain)
+		return nil
+	}
+
+	// 重新读取以获得自增 ID（轮询更新记录依赖 ID）
+	saved, err := db.GetCertificateWrapper(domain)
 	if err != nil {
+		return fmt.Errorf("读取域名 %s 的申请记录失败: %s", domain, err)
+	}
+	if err := waitPendingCertificate(saved); err != nil {
 		return err
 	}
-	return err
+	color.Green("添加证书成功")
+	if configure {
+		return configureWebServer(domain, d)
+	}
+	return nil
 }
 
-// findNginxCertPaths 从默认配置路径（宝塔/1Panel/原生 Nginx、面板自动探测、宝塔证书目录）中查找指定域名的证书路径
-func findNginxCertPaths(domain string) (certPath, keyPath string, found bool) {
-	// 优先直查证书目录（新版宝塔 cert/<域名>/fullchain.pem，Nginx/Apache 共用）
-	for _, certRoot := range defaultCertDirs {
-		cp := filepath.Join(certRoot, domain, "fullchain.pem")
-		kp := filepath.Join(certRoot, domain, "privkey.pem")
-		if _, err := os.Stat(cp); err == nil {
-			if _, err := os.Stat(kp); err == nil {
-				return cp, kp, true
-			}
+// resolveCertPaths 确定证书部署路径：优先从宝塔/Nginx 配置自动匹配（确认后使用），未匹配到再手动输入。
+// keyType 非空时，双证书站点选择本地证书密钥类型一致的一组；Apache 配置了 SSLCertificateChainFile 时一并返回证书链路径
+func resolveCertPaths(domain, keyType string) certPair {
+	if p, found := findNginxCertPaths(domain, keyType); found {
+		fmt.Printf("已自动从 Nginx 配置找到证书路径:\n  证书: %s\n  私钥: %s\n", p.CertPath, p.KeyPath)
+		if p.ChainPath != "" {
+			fmt.Printf("  证书链: %s\n", p.ChainPath)
+		}
+		if utils.Confirm("是否使用自动匹配的路径") {
+			return p
+		}
+	}
+	return certPair{
+		CertPath: utils.ReadInput("请输入证书存放路径（需包含文件名）: ", ""),
+		KeyPath:  utils.ReadInput("请输入私钥存放路径（需包含文件名）: ", ""),
+	}
+}
+
+// findNginxCertPaths 从默认配置路径（原生 Nginx/Apache、面板站点配置与站点证书目录）中查找指定域名的证书路径；
+// 同一站点配置了多组证书时按 keyType 选择（为空取第一组）
+func findNginxCertPaths(domain, keyType string) (pair certPair, found bool) {
+	// 优先查面板站点证书目录（宝塔 cert/<站点名>/、1Panel sites/<站点名>/ssl/，为宿主机上的实际路径）
+	for _, s := range panelCertSites() {
+		if containsString(s.Domains, domain) {
+			return certPair{CertPath: s.CertPath, KeyPath: s.KeyPath}, true
 		}
 	}
 
-	// 再查配置文件（宝塔/1Panel/原生 Nginx、面板自动探测）
+	// 再查配置文件（原生 Nginx/Apache、面板站点配置）
+	eachConfigFile(func(path string) bool {
+		if pairs := extractCertPairsFromFile(path, domain); len(pairs) > 0 {
+			pair, found = pickCertPair(pairs, keyType), true
+		}
+		return found
+	})
+	return pair, found
+}
+
+// eachConfigFile 遍历默认配置路径（原生 Nginx/Apache、面板站点配置）下存在的配置文件，
+// 支持通配符路径；fn 返回 true 时停止遍历
+func eachConfigFile(fn func(path string) bool) {
 	paths := append(defaultNginxPaths, discoverPanelPaths()...)
 	for _, path := range paths {
 		if strings.Contains(path, "*") {
@@ -811,90 +797,75 @@ func findNginxCertPaths(domain string) (certPath, keyPath string, found bool) {
 				continue
 			}
 			for _, match := range matches {
-				if cp, kp, ok := extractCertPathsFromFile(match, domain); ok {
-					return cp, kp, true
+				if fn(match) {
+					return
 				}
 			}
 		} else {
 			if _, err := os.Stat(path); err == nil {
-				if cp, kp, ok := extractCertPathsFromFile(path, domain); ok {
-					return cp, kp, true
+				if fn(path) {
+					return
 				}
 			}
 		}
 	}
-	return "", "", false
 }
 
-// extractCertPathsFromFile 从配置文件中提取指定域名的 ssl 证书路径（自动识别 Nginx / Apache 语法）
+// extractCertPathsFromFile 从配置文件中提取指定域名的 ssl 证书路径（自动识别 Nginx / Apache 语法，多组证书取第一组）
 func extractCertPathsFromFile(path, domain string) (string, string, bool) {
+	pairs := extractCertPairsFromFile(path, domain)
+	if len(pairs) == 0 {
+		return "", "", false
+	}
+	return pairs[0].CertPath, pairs[0].KeyPath, true
+}
+
+// extractCertPairsFromFile 从配置文件中提取指定域名的全部证书/私钥对（Nginx 双证书时多组）
+func extractCertPairsFromFile(path, domain string) []certPair {
 	content, err := os.ReadFile(path)
 	if err != nil {
-		return "", "", false
+		return nil
+	}
+	if sites, ok := parseServerConfig(path, content, false); ok {
+		for _, site := range sites {
+			if containsString(site.Domains, domain) {
+				return site.certPairs()
+			}
+		}
+		return nil
 	}
 	if isApacheConfig(string(content)) {
-		return extractApacheCertPaths(content, domain)
+		return extractApacheCertPairs(path, domain)
 	}
-	return extractNginxCertPaths(content, domain)
+	return extractNginxCertPairs(path, domain)
 }
 
-// extractNginxCertPaths 从 Nginx 配置内容中提取指定域名的证书路径
-func extractNginxCertPaths(content []byte, domain string) (string, string, bool) {
-	serverNameRegex := regexp.MustCompile(`server_name\s+([^;]+);`)
-	sslCertRegex := regexp.MustCompile(`ssl_certificate\s+([^;]+);`)
-	sslKeyRegex := regexp.MustCompile(`ssl_certificate_key\s+([^;]+);`)
-
-	blocks := findServerBlocks(string(content))
-	for _, block := range blocks {
-		serverNameMatch := serverNameRegex.FindStringSubmatch(block)
-		if len(serverNameMatch) == 0 {
-			continue
-		}
-		for _, name := range strings.Fields(strings.TrimSpace(serverNameMatch[1])) {
-			if name != domain {
-				continue
-			}
-			certMatch := sslCertRegex.FindStringSubmatch(block)
-			keyMatch := sslKeyRegex.FindStringSubmatch(block)
-			if len(certMatch) > 0 && len(keyMatch) > 0 {
-				return trimQuotes(strings.TrimSpace(certMatch[1])), trimQuotes(strings.TrimSpace(keyMatch[1])), true
-			}
+// extractNginxCertPairs 从 Nginx 配置文件（含 include 的片段）中提取指定域名的证书/私钥对
+func extractNginxCertPairs(path, domain string) []certPair {
+	cfg, err := nginxconf.Parse(path)
+	if err != nil {
+		return nil
+	}
+	for _, site := range nginxSitesFromConfig(cfg) {
+		if containsString(site.Domains, domain) {
+			return site.certPairs()
 		}
 	}
-	return "", "", false
+	return nil
 }
 
-// extractApacheCertPaths 从 Apache 配置内容中提取指定域名的证书路径
-func extractApacheCertPaths(content []byte, domain string) (string, string, bool) {
-	// 过滤注释行，与 parseApacheConfig 保持一致
-	cleaned := stripApacheComments(string(content))
-
-	blocks := findApacheBlocks(cleaned)
-	if len(blocks) == 0 {
-		// 无 VirtualHost 块时回退整文件匹配（与 parseApacheConfig 行为对齐）
-		blocks = []string{cleaned}
+// extractApacheCertPairs 从 Apache 配置文件（含 Include 的文件）中提取指定域名的证书/私钥（及证书链）路径
+func extractApacheCertPairs(path, domain string) []certPair {
+	cfg, err := apacheconf.Parse(path)
+	if err != nil {
+		return nil
 	}
-	for _, block := range blocks {
-		serverNameMatch := apacheServerNameRegex.FindStringSubmatch(block)
-		if len(serverNameMatch) == 0 {
-			continue
-		}
-		names := []string{strings.TrimSpace(serverNameMatch[1])}
-		if aliasMatch := apacheServerAliasRegex.FindStringSubmatch(block); len(aliasMatch) > 0 {
-			names = append(names, strings.Fields(aliasMatch[1])...)
-		}
-		for _, name := range names {
-			if name != domain {
-				continue
-			}
-			certMatch := apacheSSLCertRegex.FindStringSubmatch(block)
-			keyMatch := apacheSSLKeyRegex.FindStringSubmatch(block)
-			if len(certMatch) > 0 && len(keyMatch) > 0 {
-				return trimQuotes(strings.TrimSpace(certMatch[1])), trimQuotes(strings.TrimSpace(keyMatch[1])), true
-			}
+	for _, site := range apacheSitesFromConfig(cfg) {
+		if containsString(site.Domains, domain) {
+			return site.certPairs()
 		}
 	}
-	return "", "", false
+	return nil
 }
 
 // 删除证书
@@ -983,18 +954,37 @@ func deleteCertRecord(cert db.Certificate) error {
 	}
 
 	// 删除证书文件前检查是否被其他记录共享（多域名复用同一证书文件时只删记录、保留文件）
-	if cert.CertPath != "" || cert.KeyPath != "" {
-		shared, err := isCertFileShared(cert)
+	for _, d := range cert.Deployments {
+		removeDeploymentFiles(cert, d)
+	}
+	// 附加部署目标由该证书生成，随证书一并删除（远程主机上的文件保留）
+	for _, t := range cert.Targets {
+		if t.Type != targetSSH {
+			removeCertFile(t.Path)
+		}
+	}
+	return nil
+}
+
+// removeDeploymentFiles 删除单个部署位置的证书文件（被其他记录共享的文件保留）
+func removeDeploymentFiles(cert db.Certificate, d db.Deployment) {
+	if d.CertPath != "" || d.KeyPath != "" {
+		shared, err := isCertFileShared(cert, d)
 		if err != nil {
 			color.Yellow("检查证书文件共享状态失败，仅删除数据库记录（文件已保留）: %v\n", err)
 		} else if shared {
-			color.Yellow("证书文件被其他站点共享，仅删除数据库记录，保留证书文件\n")
+			color.Yellow("证书文件 %s 被其他站点共享，仅删除数据库记录，保留证书文件\n", d.CertPath)
 		} else {
-			removeCertFile(cert.CertPath)
-			removeCertFile(cert.KeyPath)
+			removeCertFile(d.CertPath)
+			removeCertFile(d.KeyPath)
+		}
+	}
+	// 证书链文件常为多站点共用的中间证书，仅在无其他记录引用时删除
+	if d.ChainPath != "" {
+		if shared, err := isChainFileShared(cert, d); err == nil && !shared {
+			removeCertFile(d.ChainPath)
 		}
 	}
-	return nil
 }
 
 // removeCertFile 删除证书文件，文件不存在时忽略，其他错误给出提示
@@ -1007,23 +997,71 @@ func removeCertFile(path string) {
 	}
 }
 
-// isCertFileShared 检查证书文件路径是否被其他证书记录引用
-func isCertFileShared(cert db.Certificate) (bool, error) {
-	all, err := db.GetAllCertificatesWrapper()
+// certsUsingPaths 部署位置使用这些路径（原样或规范化后）的证书记录，按部署路径查询
+func certsUsingPaths(paths ...string) ([]db.Certificate, error) {
+	var queried []string
+	var certs []db.Certificate
+	seen := map[int]bool{}
+	for _, p := range paths {
+		for _, variant := range []string{p, filepath.Clean(p)} {
+			if p == "" || containsString(queried, variant) {
+				continue
+			}
+			queried = append(queried, variant)
+			found, err := db.QueryCertificatesWrapper(db.CertQuery{Path: variant})
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range found {
+				if !seen[c.ID] {
+					seen[c.ID] = true
+					certs = append(certs, c)
+				}
+			}
+		}
+	}
+	return certs, nil
+}
+
+// isCertFileShared 检查部署位置的证书/私钥文件是否被其他证书记录引用
+func isCertFileShared(cert db.Certificate, d db.Deployment) (bool, error) {
+	all, err := certsUsingPaths(d.CertPath, d.KeyPath)
 	if err != nil {
 		return false, err
 	}
-	certPath := filepath.Clean(cert.CertPath)
-	keyPath := filepath.Clean(cert.KeyPath)
+	certPath := filepath.Clean(d.CertPath)
+	keyPath := filepath.Clean(d.KeyPath)
 	for _, other := range all {
 		if other.ID == cert.ID {
 			continue
 		}
-		if certPath != "" && certPath != "." && filepath.Clean(other.CertPath) == certPath {
-			return true, nil
+		for _, od := range other.Deployments {
+			if certPath != "" && certPath != "." && filepath.Clean(od.CertPath) == certPath {
+				return true, nil
+			}
+			if keyPath != "" && keyPath != "." && filepath.Clean(od.KeyPath) == keyPath {
+				return true, nil
+			}
 		}
-		if keyPath != "" && keyPath != "." && filepath.Clean(other.KeyPath) == keyPath {
-			return true, nil
+	}
+	return false, nil
+}
+
+// isChainFileShared 检查部署位置的证书链文件是否被其他证书记录引用（作为证书链或证书文件）
+func isChainFileShared(cert db.Certificate, d db.Deployment) (bool, error) {
+	all, err := certsUsingPaths(d.ChainPath)
+	if err != nil {
+		return false, err
+	}
+	chainPath := filepath.Clean(d.ChainPath)
+	for _, other := range all {
+		if other.ID == cert.ID {
+			continue
+		}
+		for _, od := range other.Deployments {
+			if filepath.Clean(od.ChainPath) == chainPath || filepath.Clean(od.CertPath) == chainPath {
+				return true, nil
+			}
 		}
 	}
 	return false, nil
@@ -1031,8 +1069,7 @@ func isCertFileShared(cert db.Certificate) (bool, error) {
 
 // 获取证书并渲染表格
 func getCertificates() {
-	// 获取所有证书
-	certs, err := db.GetAllCertificatesWrapper()
+	certs, err := db.QueryCertificatesWrapper(overviewQuery)
 	if err != nil {
 		fmt.Println("获取证书信息失败:", err)
 		return
@@ -1043,10 +1080,24 @@ func getCertificates() {
 		color.Yellow("暂无证书，可通过菜单「1=添加」或「7=快速添加域名」导入\n")
 		return
 	}
+	renderCertTable(certs)
+}
 
-	// 显示证书信息表格（公钥/私钥列只显示文件名，避免超长路径撑爆表格；本地到期列为本地文件实际到期时间）
+// renderCertTable 渲染证书表格（公钥/私钥列只显示文件名，避免超长路径撑爆表格；本地到期列为本地文件实际到期时间）。
+// 有证书设置了标签时增加标签列
+func renderCertTable(certs []db.Certificate) {
+	withTags := false
+	for _, cert := range certs {
+		if len(cert.Tags) > 0 {
+			withTags = true
+		}
+	}
+	header := []string{"ID", "证书ID", "域名", "密钥", "状态", "创建时间", "过期时间", "本地到期", "剩余天数", "告警", "来源", "证书文件", "私钥文件"}
+	if withTags {
+		header = append(header, "标签")
+	}
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"ID", "证书ID", "域名", "状态", "创建时间", "过期时间", "本地到期", "剩余天数", "来源", "证书文件", "私钥文件"})
+	table.SetHeader(header)
 	for _, cert := range certs {
 		expireDay := time.Unix(cert.ExpireTime, 0).Sub(time.Now())
 		var certStatus string
@@ -1060,35 +1111,38 @@ func getCertificates() {
 		if expireDay < 0 {
 			remainDays = "已过期"
 		}
-		// 本地证书文件实际到期时间（每张证书一次轻量文件读取，性能开销可忽略）
-		localExpire := "-"
-		if cert.CertPath != "" {
-			if e, err := getCertFileExpireTime(cert.CertPath); err == nil {
-				localExpire = time.Unix(e, 0).Format(time.DateOnly)
-			}
+		// 申请中：显示申请中与已等待时长（此时过期时间为旧证书或空）
+		if cert.PendingSince > 0 {
+			certStatus = pendingStatus
+			remainDays = "等待" + time.Since(time.Unix(cert.PendingSince, 0)).Truncate(time.Minute).String()
 		}
-		// 路径为空时保持空串显示，避免 filepath.Base("") 返回 "."
-		certFile, keyFile := cert.CertPath, cert.KeyPath
-		if cert.CertPath != "" {
-			certFile = filepath.Base(cert.CertPath)
-		}
-		if cert.KeyPath != "" {
-			keyFile = filepath.Base(cert.KeyPath)
+		// 本地证书文件实际到期时间（多个部署位置取最早的一个，每个位置一次轻量文件读取，性能开销可忽略）
+		localExpire := "-"
+		if e, ok := localCertExpire(cert); ok {
+			localExpire = time.Unix(e, 0).Format(time.DateOnly)
 		}
+		// 多个部署位置时每行一个文件名
+		certFile, keyFile := deploymentFiles(cert)
 
-		table.Append([]string{
+		row := []string{
 			strconv.Itoa(cert.ID),
 			strconv.Itoa(cert.CertID),
 			cert.Domain,
+			keyTypeLabel(cert.KeyType),
 			certStatus,
 			time.Unix(cert.CreateTime, 0).Format(time.DateOnly),
 			time.Unix(cert.ExpireTime, 0).Format(time.DateOnly),
 			localExpire,
 			remainDays,
-			cert.CertSource,
+			alertState(cert, certAlertExpire(cert)),
+			certSourceLabel(cert),
 			certFile,
 			keyFile,
-		})
+		}
+		if withTags {
+			row = append(row, strings.Join(cert.Tags, ","))
+		}
+		table.Append(row)
 	}
 	table.Render()
 }
@@ -1103,18 +1157,21 @@ func showPlatformStatus() {
 		return color.RedString("× " + name)
 	}
 
-	// certd：api_url + key_id + key_secret 均配置才视为就绪
-	apiURL, _ := config.GetConfig("third.certd", "api_url")
-	keyID, _ := config.GetConfig("third.certd", "key_id")
-	keySecret, _ := config.GetConfig("third.certd", "key_secret")
-	certdOK := apiURL != "" && keyID != "" && keySecret != ""
-
-	// west：username + api_key 均配置才视为就绪
-	username, _ := config.GetConfig("third.west", "username")
-	apiKey, _ := config.GetConfig("third.west", "api_key")
-	westOK := username != "" && apiKey != ""
-
-	fmt.Printf("平台配置: %s  %s\n", platformMark(certdOK, "certd"), platformMark(westOK, "west"))
+	// 按获取顺序列出平台实例（密钥配置完整才视为就绪，未就绪的实例获取时跳过），其余实例随后列出
+	order := globalProviderOrder()
+	var marks []string
+	for _, p := range order {
+		ref, _ := parseProviderRef(p)
+		marks = append(marks, platformMark(providerConfigured(ref), p))
+	}
+	for _, typ := range providerNames {
+		for _, ref := range providerInstances(typ) {
+			if !containsString(order, ref.String()) {
+				marks = append(marks, platformMark(providerConfigured(ref), ref.String())+"（不在获取顺序中）")
+			}
+		}
+	}
+	fmt.Printf("平台配置: %s\n", strings.Join(marks, "  "))
 	// 数据库模式与路径（show 菜单场景数据库已初始化）
 	if mode := db.DBMode(); mode != "" {
 		color.Cyan("数据库: %s (%s)\n", mode, db.DBPath())
@@ -1156,19 +1213,34 @@ func modifyRestartCmd() error {
 }
 
 // 修改过期前检查天数
-// 同时展示/修改两个天数：
+// 同时展示/修改两类天数：
 //   - before_expiration_day：本地证书更新判断（到期前 N 天更新证书文件）
-//   - certd auto_apply_renew_days：certd 自动申请续期天数（若配置了 certd）
+//   - certd auto_apply_renew_days：certd 自动申请续期天数（每个已配置的 certd 实例各一项）
 func modifyExpirationDay() error {
 	ExpirationDay, _ := config.GetConfig("", "before_expiration_day")
-	certdRenew, _ := config.GetConfig("third.certd", "auto_apply_renew_days")
-	if certdRenew == "" {
-		certdRenew = strconv.Itoa(int(defaultBeforeExpirationDay))
-	}
-
 	fmt.Printf("本地证书提前更新天数: %s\n", color.CyanString(ExpirationDay))
-	if ExpirationDay != "" && certdRenew != "" {
-		fmt.Printf("certd 自动申请续期天数: %s（可一并修改，直接回车保持）\n", color.CyanString(certdRenew))
+
+	// 已配置 api_url 的 certd 实例
+	type certdRenew struct {
+		ref  providerRef
+		days string
+	}
+	var renews []certdRenew
+	for _, ref := range providerInstances("certd") {
+		if !config.HasSection(ref.section()) {
+			continue
+		}
+		if api, _ := config.GetConfig(ref.section(), "api_url"); api == "" {
+			continue
+		}
+		days, _ := config.GetConfig(ref.section(), "auto_apply_renew_days")
+		if days == "" {
+			days = strconv.Itoa(int(defaultBeforeExpirationDay))
+		}
+		renews = append(renews, certdRenew{ref, days})
+		if ExpirationDay != "" {
+			fmt.Printf("%s 自动申请续期天数: %s（可一并修改，直接回车保持）\n", ref, color.CyanString(days))
+		}
 	}
 
 	// 默认值取当前配置（未配置时回退默认天数），避免每次重输
@@ -1186,15 +1258,14 @@ func modifyExpirationDay() error {
 		return fmt.Errorf("保存过期前天数失败: %s", err)
 	}
 
-	// certd 天数：已配置 certd 时一并修改（保持同步），否则跳过
-	certdAPI, _ := config.GetConfig("third.certd", "api_url")
-	if certdAPI != "" {
-		newCertd := utils.ReadInput(fmt.Sprintf("请输入 certd 自动申请续期天数(如: %s): ", certdRenew), certdRenew)
+	// certd 天数：已配置的 certd 实例逐一修改（保持同步），未配置则跳过
+	for _, r := range renews {
+		newCertd := utils.ReadInput(fmt.Sprintf("请输入 %s 自动申请续期天数(如: %s): ", r.ref, r.days), r.days)
 		if n, aerr := strconv.Atoi(newCertd); aerr == nil && n > 0 {
-			if err := config.SetConfig("third.certd", "auto_apply_renew_days", strconv.Itoa(n)); err != nil {
-				color.Yellow("保存 certd 自动申请续期天数失败: %v\n", err)
+			if err := config.SetConfig(r.ref.section(), "auto_apply_renew_days", strconv.Itoa(n)); err != nil {
+				color.Yellow("保存 %s 自动申请续期天数失败: %v\n", r.ref, err)
 			} else {
-				color.Green("certd 自动申请续期天数已修改成: %d\n", n)
+				color.Green("%s 自动申请续期天数已修改成: %d\n", r.ref, n)
 			}
 		}
 	}
@@ -1216,6 +1287,17 @@ func updateCertificates() error {
 
 	updateNum := 0
 	failedNum := 0
+	pendingNum := 0
+	skippedNum := 0
+	var deployed []db.Certificate // 本次已部署的证书（重载后 TLS 探测）
+	var remotes []remoteResult    // 本次各远程主机（ssh 目标）的部署结果
+	// 本次运行的通知事件，结束时合并发送到已配置的通知渠道
+	batch := notify.NewBatch()
+	defer flushNotifications(batch)
+	// 更新结束后按告警阈值检查过期告警（先于通知发送执行，告警事件合并到本次通知）
+	defer checkExpiryAlerts(batch)
+	// 记录本次运行结果（metrics 指标）
+	defer func() { metrics.AddRun(updateNum, failedNum, skippedNum, pendingNum) }()
 	// 提前读取配置，避免循环内重复加载 ini 文件
 	BeforeExpirationDay, _ := config.GetConfig("", "before_expiration_day")
 	day, err := strconv.ParseInt(BeforeExpirationDay, 10, 64)
@@ -1224,80 +1306,165 @@ func updateCertificates() error {
 	}
 	// 更新每个证书
 	for _, cert := range certificates {
-		fmt.Printf("正在更新域名 %s 的证书...\n", cert.Domain)
+		if cert.CertSource == manualSource {
+			fmt.Printf("域名 %s 的证书为手动维护，跳过自动更新\n", certLabel(cert.Domain, cert.KeyType))
+			skippedNum++
+			continue
+		}
+		fmt.Printf("正在更新域名 %s 的证书...\n", certLabel(cert.Domain, cert.KeyType))
 
 		// 判断是否需要更新：优先以证书文件的实际过期时间为准，
 		// 避免"网站文件已过期但数据库记录仍显示有效"导致漏更新（issue #3 评论）
-		needUpdate := false
-		if cert.CertPath != "" {
-			if fileExpire, err := getCertFileExpireTime(cert.CertPath); err == nil {
-				needUpdate = fileExpire-(86400*day) <= time.Now().Unix()
-			} else {
-				// 证书文件不存在或无法解析，回退用数据库记录的过期时间判断
-				needUpdate = cert.ExpireTime-(86400*day) <= time.Now().Unix()
-			}
-		} else {
-			needUpdate = cert.ExpireTime-(86400*day) <= time.Now().Unix()
-		}
+		// 证书文件不存在或无法解析时，回退用数据库记录的过期时间判断
+		// 部署到多个位置时取最早到期的文件，任一位置未更新都会触发更新
+		expireAt := cert.ExpireTime
+		if fileExpire, ok := localCertExpire(cert); ok {
+			expireAt = fileExpire
+		}
+		// 证书级提前更新天数优先于全局配置
+		certDay := day
+		if cert.RenewDays > 0 {
+			certDay = int64(cert.RenewDays)
+		}
+		needUpdate := expireAt-(86400*certDay) <= time.Now().Unix()
 		if !needUpdate {
 			fmt.Printf("域名 %s 的证书未过期，跳过更新\n", cert.Domain)
+			skippedNum++
+			continue
+		}
+
+		if err = runHooks(hookPreFetch, cert); err != nil {
+			color.Red("域名 %s 的%v，跳过更新\n", cert.Domain, err)
+			batch.Add(notify.EventFailed, cert.Domain, "%v", err)
+			failedNum++
 			continue
 		}
 
 		var newCert db.Certificate
-		newCert, err = getCertificateInfo(cert.Domain, cert.CertSource, cert.CertID)
+		newCert, err = fetchLatestCertificate(cert, time.Now().Unix()+86400*certDay)
+		if errors.Is(err, certd.ErrCertApplying) {
+			// 证书申请中：记录申请中状态与触发申请的平台实例，由守护进程按退避间隔向该实例跟进，签发后立即部署重载
+			cert.CertSource, cert.ProviderInstance = newCert.CertSource, newCert.ProviderInstance
+			if uerr := db.UpdateCertificateInDBWrapper(markCertPending(cert)); uerr != nil {
+				fmt.Printf("记录域名 %s 的申请中状态失败: %v\n", cert.Domain, uerr)
+				failedNum++
+				continue
+			}
+			color.Yellow("域名 %s 的证书申请中，已记录，签发后将自动部署\n", cert.Domain)
+			batch.Add(notify.EventPending, cert.Domain, "平台已触发证书申请，签发后将自动部署")
+			pendingNum++
+			continue
+		}
+		if err == nil {
+			// 双证书站点：平台返回的密钥类型须与该记录一致，各类型独立部署
+			err = checkVariantKeyType(newCert, cert)
+			if err != nil && cert.CertID == 0 {
+				// 按域名只能获取到平台的另一种密钥类型：改为手动维护，避免每次更新都失败并重复通知
+				if merr := markCertManual(cert); merr == nil {
+					hint := manualHint(strconv.Itoa(cert.ID))
+					color.Yellow("域名 %s 的%v，已改为手动维护；%s\n", certLabel(cert.Domain, cert.KeyType), err, hint)
+					batch.Add(notify.EventFailed, cert.Domain, "%v，已改为手动维护，不再自动更新；%s", err, hint)
+					failedNum++
+					continue
+				}
+			}
+		}
 		if err != nil {
-			fmt.Printf("获取域名 %s 的证书信息失败: %v\n", cert.Domain, err)
+			fmt.Printf("获取域名 %s 的证书信息失败: %v\n", certLabel(cert.Domain, cert.KeyType), err)
+			batch.Add(notify.EventFailed, cert.Domain, "获取证书信息失败: %v", err)
+			recordFetchError(cert, err)
 			failedNum++
 			continue
 		}
-		// 比较基准：优先本地证书文件的实际内容（修复"DB 记录为云端证书、本地文件过期/非云端"时
-		// 比较 DB 恒相同导致本地过期文件得不到更新的问题）；文件不可读时回退 DB 记录
-		basePub, baseKey := readLocalCertFiles(cert.CertPath, cert.KeyPath)
-		if basePub == "" && baseKey == "" {
-			basePub, baseKey = cert.PublicKey, cert.PrivateKey
-		}
-		if newCert.PublicKey == basePub && newCert.PrivateKey == baseKey {
+		// 各部署位置的证书文件均已是最新证书时无需更新；任一位置不同则一次获取、全部写入
+		if certFilesUpToDate(cert, newCert) {
 			fmt.Printf("域名 %s 的证书信息未更新，无需重新下载\n", cert.Domain)
+			skippedNum++
+			if cert.PendingSince > 0 || cert.LastError != "" {
+				// 申请中期间已由其他途径部署：仅清除申请中标记；本次获取成功，清除上次获取失败记录
+				if cert.PendingSince > 0 {
+					cert = clearCertPending(cert)
+				}
+				cert.LastError = ""
+				_ = db.UpdateCertificateInDBWrapper(cert)
+			}
 			continue
 		}
 
-		// 设置证书路径和 ID
-		newCert.CertPath = cert.CertPath
-		newCert.KeyPath = cert.KeyPath
-		newCert.ID = cert.ID
-		// 保留原有平台证书ID与覆盖域名（非certd来源或detail缺失时不会被清空）
-		if newCert.CertID == 0 {
-			newCert.CertID = cert.CertID
-		}
-		if newCert.CertDomains == "" {
-			newCert.CertDomains = cert.CertDomains
+		// 设置证书路径和 ID（并保留原有平台证书ID与覆盖域名）
+		fetched := newCert
+		newCert = recordRenewal(inheritCertFields(fetched, cert))
+
+		// pre-deploy 钩子失败时放弃部署（不保存新证书，下次更新重试）
+		if err = runHooks(hookPreDeploy, newCert); err != nil {
+			color.Red("域名 %s 的%v，放弃部署\n", cert.Domain, err)
+			batch.Add(notify.EventFailed, cert.Domain, "%v", err)
+			failedNum++
+			continue
 		}
 
-		// 更新证书信息
-		err = db.UpdateCertificateInDBWrapper(newCert)
+		// 更新证书信息（记录在本次读取后被修改时基于最新记录保存，不覆盖并发的修改）
+		newCert, err = saveRenewedCert(newCert, fetched)
 		if err != nil {
 			fmt.Printf("更新域名 %s 的证书信息失败: %v\n", cert.Domain, err)
+			batch.Add(notify.EventFailed, cert.Domain, "保存证书信息失败: %v", err)
+			failedNum++
 			continue
 		}
 
-		// 更新证书文件
-		err = updateCertificateFiles(newCert)
+		// 更新证书文件（某个部署位置失败不中断其余证书，已写入的位置仍随本次重载生效）
+		var results []targetResult
+		results, err = deployCertificate(newCert)
+		for _, r := range remoteResults(cert.Domain, results) {
+			if r.Err != nil {
+				batch.Add(notify.EventFailed, cert.Domain, "远程主机 %s 部署失败: %v", r.Host, r.Err)
+			}
+			remotes = append(remotes, r)
+		}
 		if err != nil {
-			return err
+			color.Red("%v\n", err)
+			batch.Add(notify.EventFailed, cert.Domain, "写入证书文件失败: %v", err)
+			failedNum++
+			var derr *deployError
+			if errors.As(err, &derr) && derr.written > 0 {
+				deployed = append(deployed, newCert)
+			}
+			continue
 		}
+		batch.Add(notify.EventRenewed, cert.Domain, "证书已更新（来源 %s），有效期至 %s",
+			newCert.CertSource, time.Unix(newCert.ExpireTime, 0).Format(time.DateOnly))
+		deployed = append(deployed, newCert)
 		updateNum++
+		// post-deploy 钩子失败计为更新失败；证书文件已写入，仍随本次重载生效
+		if err = runHooks(hookPostDeploy, newCert); err != nil {
+			color.Red("域名 %s 的%v\n", cert.Domain, err)
+			batch.Add(notify.EventFailed, cert.Domain, "%v", err)
+			failedNum++
+		}
 	}
 
+	printRemoteResults(remotes)
+	if pendingNum > 0 {
+		color.Yellow("有 %d 个证书申请中，证书更新任务（cron）将自动跟进\n", pendingNum)
+	}
 	if updateNum == 0 && failedNum == 0 {
-		fmt.Println("本次没有需要更新的证书")
+		if pendingNum == 0 {
+			fmt.Println("本次没有需要更新的证书")
+		}
 	} else {
-		if updateNum > 0 {
-			// 执行重载命令
-			err = executeRestartCmd()
+		if len(deployed) > 0 {
+			// 执行重载命令（各证书的重载命令去重后依次执行；含部分位置写入失败的证书，已写入的位置需重载生效）
+			err = reloadCertificates(deployed)
 			if err != nil {
+				batch.Add(notify.EventReloadFailed, "", "%d 个证书已更新，但重载命令执行失败: %v", len(deployed), err)
 				return err
 			}
+			// 重载成功不代表服务已加载新证书（静默失败或其他站点抢占 SNI），探测确认
+			verifyDeployment(deployed, batch)
+			for _, herr := range runPostReloadHooks(deployed) {
+				batch.Add(notify.EventFailed, "", "%v", herr)
+				failedNum++
+			}
 		}
 		if failedNum > 0 {
 			return fmt.Errorf("更新完成，但有 %d 个证书获取/更新失败", failedNum)
@@ -1308,36 +1475,43 @@ func updateCertificates() error {
 	return nil
 }
 
-// 更新证书文件
-func updateCertificateFiles(cert db.Certificate) error {
-	// 提取文件所在的目录
-	CertPathDir := filepath.Dir(cert.CertPath)
-	KeyPathDir := filepath.Dir(cert.KeyPath)
-	//自动创建目录（INFO 或者根本不用考虑自动创建，证书路径不存在本身就说明这个路径是有问题的）
-	utils.ExistDir(CertPathDir)
-	utils.ExistDir(KeyPathDir)
+// 执行全局重载命令
+func executeRestartCmd() error {
+	restartCmd, _ := config.GetConfig("", "restart_cmd")
+	return executeReloadCmd(restartCmd)
+}
 
-	// 更新公钥文件
-	err := os.WriteFile(cert.CertPath, []byte(cert.PublicKey), 0644)
-	if err != nil {
-		return fmt.Errorf("更新域名 %s 的公钥文件失败: %v\n", cert.Domain, err)
+// certReloadCmd 证书使用的重载命令：证书级重载命令优先，为空时使用全局 restart_cmd
+func certReloadCmd(cert db.Certificate) string {
+	if strings.TrimSpace(cert.ReloadCmd) != "" {
+		return cert.ReloadCmd
 	}
+	restartCmd, _ := config.GetConfig("", "restart_cmd")
+	return restartCmd
+}
 
-	// 更新私钥文件（私钥权限收紧为 0600，避免同机其他用户可读）
-	err = os.WriteFile(cert.KeyPath, []byte(cert.PrivateKey), 0600)
-	if err != nil {
-		return fmt.Errorf("更新域名 %s 的私钥文件失败: %v\n", cert.Domain, err)
+// reloadCertificates 执行已部署证书的重载命令：相同命令只执行一次，某条失败仍继续执行其余命令
+func reloadCertificates(certs []db.Certificate) error {
+	var cmds []string
+	for _, cert := range certs {
+		if cmd := certReloadCmd(cert); !containsString(cmds, cmd) {
+			cmds = append(cmds, cmd)
+		}
 	}
-
-	// 绿色高亮提示更新成功的域名，便于在批量更新中快速识别
-	color.Green("域名 %s 的证书文件已更新\n", cert.Domain)
-	return err
+	var errs []string
+	for _, cmd := range cmds {
+		if err := executeReloadCmd(cmd); err != nil {
+			errs = append(errs, strings.TrimSpace(err.Error()))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
 }
 
-// 执行重载命令
-func executeRestartCmd() error {
-	// 获取重载命令
-	restartCmd, _ := config.GetConfig("", "restart_cmd")
+// executeReloadCmd 执行重载命令
+func executeReloadCmd(restartCmd string) error {
 	if strings.TrimSpace(restartCmd) == "" {
 		return fmt.Errorf("重载命令不存在，请先配置")
 	}
@@ -1347,22 +1521,49 @@ func executeRestartCmd() error {
 	defer cancel()
 
 	// 通过系统 shell 执行，支持引号、管道、$() 等语法（如 docker restart $(docker ps -aqf "name=openresty")）
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.CommandContext(ctx, "cmd", "/C", restartCmd)
-	} else {
-		cmd = exec.CommandContext(ctx, "sh", "-c", restartCmd)
+	output, err := shellCommand(ctx, restartCmd).CombinedOutput()
+	if err != nil {
+		err = fmt.Errorf("执行重载命令失败: %v\n%s\n", err, output)
 	}
-
-	output, err := cmd.CombinedOutput()
+	metrics.ObserveReload(err)
 	if err != nil {
-		return fmt.Errorf("执行重载命令失败: %v\n%s\n", err, output)
+		return err
 	}
 
 	color.Green("执行重载命令成功: %s\n", output)
 	return nil
 }
 
+// flushNotifications 发送本次运行收集的通知事件；通知失败只提示，不影响证书更新结果
+func flushNotifications(batch *notify.Batch) {
+	if err := batch.Flush(); err != nil {
+		color.Yellow("发送通知失败: %v\n", err)
+	}
+}
+
+// sendTestNotification 向已启用的通知渠道发送一条测试通知（notify 命令）
+func sendTestNotification() error {
+	channels := notify.LoadChannels()
+	if len(channels) == 0 {
+		return fmt.Errorf("未启用任何通知渠道，请在 config/conf.ini 中配置 [notify.<渠道>] 并设置 enable = 1")
+	}
+	for _, ch := range channels {
+		batch := notify.NewBatch()
+		for _, t := range notify.AllEvents {
+			if ch.Accepts(t) {
+				batch.Add(t, "example.com", "这是一条测试通知")
+				break
+			}
+		}
+		if err := batch.FlushTo([]notify.Notifier{ch}); err != nil {
+			color.Red("× %s\n", err)
+			continue
+		}
+		color.Green("√ %s 测试通知已发送\n", ch.Name())
+	}
+	return nil
+}
+
 // 查找 Nginx/Apache 配置目录
 func findNginxPathCmd() (err error) {
 
@@ -1434,51 +1635,36 @@ func cronTask(force bool) {
 
 	// 添加任务
 	_, err := c.AddFunc(defaultCronTime, func() {
-		logFile, err := os.OpenFile(defaultLogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		if err != nil {
-			fmt.Println("open log file failed, err:", err)
-			return
-		}
-		defer logFile.Close()
-
-		// 保存原始标准输出，任务结束（含出错）后恢复，避免污染全局输出
-		oldStdout := os.Stdout
-		oldColorOutput := color.Output
-		oldLogOutput := log.Writer()
-		oldLogFlags := log.Flags()
-		oldLogPrefix := log.Prefix()
-		os.Stdout = logFile
-		color.Output = logFile
-		defer func() {
-			os.Stdout = oldStdout
-			color.Output = oldColorOutput
-			log.SetOutput(oldLogOutput)
-			log.SetFlags(oldLogFlags)
-			log.SetPrefix(oldLogPrefix)
-		}()
-
-		log.SetOutput(logFile)
-		log.SetFlags(log.Llongfile | log.Lmicroseconds | log.Ldate)
-		log.Println("任务开始执行")
-		log.SetPrefix("Cron: ")
-		cronPid, _ := config.GetConfig("", "cron_pid")
-		pid, _ := strconv.Atoi(cronPid)
-		log.Println("cronPid", cronPid, "pid", pid, "os.Getpid()", os.Getpid())
-		if cronPid != "" && pid != os.Getpid() {
-			log.Printf("任务pid: %d 与当前进程pid: %d 不一致，跳过任务\n", pid, os.Getpid())
-			return
-		}
-
-		err = updateCertificates()
-		if err != nil {
-			log.Printf("任务执行完成，但存在错误: %s", err)
+		runCronJob(defaultLogFile, "任务开始执行", updateCertificates)
+	})
+	if err != nil {
+		color.Red("添加任务调度失败: %s", err)
+		return
+	}
+	// 申请中证书跟进：每分钟检查一次，按退避间隔轮询，签发后立即部署重载
+	lastPoll := make(map[int]time.Time)
+	_, err = c.AddFunc(pendingCronSpec, func() {
+		if !hasPendingCertificates() {
 			return
 		}
+		runCronJob(defaultLogFile, "申请中证书跟进", func() error {
+			return followPendingCertificates(lastPoll)
+		})
 	})
 	if err != nil {
-		color.Red("添加任务调度失败: %s", err)
+		color.Red("添加申请中证书跟进任务失败: %s", err)
 		return
 	}
+	// 配置漂移检测：每天检索 Web 服务器配置，与数据库比对并通知
+	if driftCheckEnabled() {
+		_, err = c.AddFunc(driftCronSpec, func() {
+			runCronJob(defaultLogFile, "配置漂移检测", checkDrift)
+		})
+		if err != nil {
+			color.Red("添加配置漂移检测任务失败: %s", err)
+			return
+		}
+	}
 	color.Green("任务挂载成功，现在可以退出程序了，证书检查会在每天凌晨4点自动执行\n")
 	color.Green("当前进程 PID: %d", os.Getpid())
 	err = config.SetConfig("", "cron_pid", strconv.Itoa(os.Getpid()))
@@ -1486,6 +1672,10 @@ func cronTask(force bool) {
 		color.Red("记录任务调度配置失败: %s", err)
 		return
 	}
+	// 配置 metrics_listen 时在守护进程内提供 /metrics
+	startDaemonMetrics()
+	// 配置 watch = 1 时监视配置与证书文件变更
+	startDaemonWatch(defaultLogFile)
 	//开始执行任务
 	c.Start()
 
@@ -1493,13 +1683,99 @@ func cronTask(force bool) {
 	select {}
 }
 
+// pendingCronSpec 申请中证书跟进任务的调度间隔
+const pendingCronSpec = "@every 1m"
+
+// cronJobMu 串行化定时任务（任务执行期间会替换 os.Stdout 等全局输出，不能并发）
+var cronJobMu sync.Mutex
+
+// runCronJob 执行一次定时任务：输出重定向到日志文件，任务结束（含出错）后恢复；
+// 任务 PID 与当前进程不一致时跳过（已被新任务进程接管）。
+func runCronJob(logPath, title string, job func() error) {
+	cronJobMu.Lock()
+	defer cronJobMu.Unlock()
+
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		fmt.Println("open log file failed, err:", err)
+		return
+	}
+	defer logFile.Close()
+
+	// 保存原始标准输出，任务结束（含出错）后恢复，避免污染全局输出
+	oldStdout := os.Stdout
+	oldColorOutput := color.Output
+	oldLogOutput := log.Writer()
+	oldLogFlags := log.Flags()
+	oldLogPrefix := log.Prefix()
+	os.Stdout = logFile
+	color.Output = logFile
+	defer func() {
+		os.Stdout = oldStdout
+		color.Output = oldColorOutput
+		log.SetOutput(oldLogOutput)
+		log.SetFlags(oldLogFlags)
+		log.SetPrefix(oldLogPrefix)
+	}()
+
+	log.SetOutput(logFile)
+	log.SetFlags(log.Llongfile | log.Lmicroseconds | log.Ldate)
+	log.Println(title)
+	log.SetPrefix("Cron: ")
+	cronPid, _ := config.GetConfig("", "cron_pid")
+	pid, _ := strconv.Atoi(cronPid)
+	log.Println("cronPid", cronPid, "pid", pid, "os.Getpid()", os.Getpid())
+	if cronPid != "" && pid != os.Getpid() {
+		log.Printf("任务pid: %d 与当前进程pid: %d 不一致，跳过任务\n", pid, os.Getpid())
+		return
+	}
+
+	if err := job(); err != nil {
+		log.Printf("任务执行完成，但存在错误: %s", err)
+	}
+	saveMetrics()
+	writeDaemonTextfile()
+}
+
+// hasPendingCertificates 是否存在申请中的证书（无申请中证书时跳过跟进，避免每分钟写日志）
+func hasPendingCertificates() bool {
+	certificates, err := db.GetAllCertificatesWrapper()
+	if err != nil {
+		return false
+	}
+	for _, cert := range certificates {
+		if cert.PendingSince > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // 获取配置信息
 // configKeyNames 配置项 key → 中文显示名
 var configKeyNames = map[string]string{
 	"is_init":                            "已初始化",
 	"restart_cmd":                        "重载命令",
 	"before_expiration_day":              "提前更新天数",
+	"pending_timeout_hours":              "申请中跟进超时(小时)",
+	"alert_days":                         "过期告警阈值",
+	"metrics_listen":                     "指标服务监听地址",
+	"metrics_textfile":                   "指标文本文件路径",
+	"probe_host":                         "TLS 探测地址",
+	"probe_after_deploy":                 "部署后 TLS 探测",
 	"debug":                              "调试模式",
+	"hooks.pre_fetch":                    "全局 pre-fetch 钩子",
+	"hooks.pre_deploy":                   "全局 pre-deploy 钩子",
+	"hooks.post_deploy":                  "全局 post-deploy 钩子",
+	"hooks.post_reload":                  "全局 post-reload 钩子",
+	"hooks.timeout":                      "钩子超时时间(秒)",
+	"managed_cert_dir":                   "托管证书目录",
+	"drift_check":                        "配置漂移检测",
+	"watch":                              "监视文件变更",
+	"watch_cert_policy":                  "证书文件被替换时的处理",
+	"provider_order":                     "证书平台获取顺序",
+	"nginx_test_cmd":                     "Nginx 配置测试命令",
+	"apache_test_cmd":                    "Apache 配置测试命令",
 	"third.certd.api_url":                "certd ApiUrl",
 	"third.certd.key_id":                 "certd KeyId",
 	"third.certd.key_secret":             "certd KeySecret",
@@ -1508,6 +1784,12 @@ var configKeyNames = map[string]string{
 	"third.certd.auto_apply_renew_days":  "certd 自动申请提前天数",
 	"third.west.username":                "西部数码 username",
 	"third.west.api_key":                 "西部数码 apiKey",
+	"notify.webhook.url":                 "Webhook 通知地址",
+	"notify.dingtalk.url":                "钉钉机器人地址",
+	"notify.wecom.url":                   "企业微信机器人地址",
+	"notify.feishu.url":                  "飞书机器人地址",
+	"notify.email.host":                  "SMTP 服务器",
+	"notify.email.to":                    "通知收件人",
 }
 
 // getConfigInfo 查看配置信息：key 名转为中文显示名，敏感值打码
@@ -1522,7 +1804,8 @@ func getConfigInfo() error {
 			continue
 		}
 		// 敏感值打码
-		if strings.Contains(entry.Key, "key_secret") || strings.Contains(entry.Key, "api_key") {
+		if strings.Contains(entry.Key, "key_secret") || strings.Contains(entry.Key, "api_key") ||
+			strings.HasSuffix(entry.Key, ".secret") || strings.HasSuffix(entry.Key, ".password") {
 			if entry.Value == "" {
 				// 未配置时如实显示，避免空值被误认为已配置（打码）
 				entry.Value = "未配置"
@@ -1530,51 +1813,83 @@ func getConfigInfo() error {
 				entry.Value = "********"
 			}
 		}
-		name := entry.Key
-		if cn, ok := configKeyNames[entry.Key]; ok {
-			name = cn
-		}
-		color.Cyan("%s: %s\n", name, entry.Value)
+		color.Cyan("%s: %s\n", configDisplayName(entry.Key), entry.Value)
 	}
 	return nil
 }
 
-// 修改密钥
+// configDisplayName 配置项显示名：平台实例配置（third.<平台>.<实例名>.<key>）按平台配置项命名，
+// 命名实例标注实例名，如 certd(prod) ApiUrl；未知配置项显示原 key
+func configDisplayName(key string) string {
+	if cn, ok := configKeyNames[key]; ok {
+		return cn
+	}
+	parts := strings.SplitN(key, ".", 4)
+	if len(parts) != 4 || parts[0] != "third" {
+		return key
+	}
+	cn, ok := configKeyNames["third."+parts[1]+"."+parts[3]]
+	if !ok {
+		return key
+	}
+	if parts[2] == defaultInstance {
+		return cn
+	}
+	platform, item, _ := strings.Cut(cn, " ")
+	return fmt.Sprintf("%s(%s) %s", platform, parts[2], item)
+}
+
+// 修改密钥：列出各平台的全部实例，可修改已有实例或新建命名实例（如 certd.prod）
 func modifyKey() {
 	for {
-		// 平台选择：
-		// - TUI 交互模式：用多选勾选（空格勾选 certd/west、回车确认、可直接回车跳过），交互直观
-		// - CLI 模式：文本输入平台名（空格分隔）
-		var thirdCs []string
+		// 平台实例选择：
+		// - TUI 交互模式：用多选勾选（空格勾选实例或"新建实例"、回车确认、可直接回车跳过），交互直观
+		// - CLI 模式：文本输入实例（空格分隔，不存在的命名实例将新建）
+		var refs []string
+		var existing []string
+		for _, typ := range []string{"certd", "west"} {
+			for _, ref := range providerInstances(typ) {
+				existing = append(existing, ref.String())
+			}
+		}
 		if utils.TUIMultiSelect != nil {
-			// 勾选要配置的平台（不勾选直接回车/确认空列表则跳过）
-			platforms := []string{"certd", "west"}
-			sel := utils.MultiSelectCheckbox(platforms, "请选择要配置的平台（可多选，不选则跳过）")
+			// 勾选要配置的实例（不勾选直接回车/确认空列表则跳过）
+			newItems := map[string]string{"新建 certd 实例": "certd", "新建 west 实例": "west"}
+			options := append(append([]string{}, existing...), "新建 certd 实例", "新建 west 实例")
+			sel := utils.MultiSelectCheckbox(options, "请选择要配置的平台实例（可多选，不选则跳过）")
 			for _, i := range sel {
-				thirdCs = append(thirdCs, platforms[i])
+				typ, isNew := newItems[options[i]]
+				if !isNew {
+					refs = append(refs, options[i])
+					continue
+				}
+				name := utils.ReadInput(fmt.Sprintf("请输入新 %s 实例名（如 prod、staging）: ", typ), "")
+				refs = append(refs, typ+"."+name)
 			}
-			if len(thirdCs) == 0 {
+			if len(refs) == 0 {
 				return // 跳过配置
 			}
 		} else {
 			// CLI：直接回车跳过配置（不配置平台时，添加域名会回退读取本地证书文件）
-			thirdC := utils.ReadInput("请选择要配置的平台，目前支持certd、west，可以单一使用，也可混用，多个平台用空格分隔（直接回车跳过配置）: ", "")
+			fmt.Printf("已有平台实例: %s\n", strings.Join(existing, "、"))
+			thirdC := utils.ReadInput("请选择要配置的平台实例，目前支持certd、west，可指定实例名（如 certd.prod，不存在时新建），多个用空格分隔（直接回车跳过配置）: ", "")
 			if thirdC == "" {
 				return
 			}
-			thirdCs = strings.Split(thirdC, " ")
+			refs = strings.Fields(thirdC)
 		}
 		valid := false
-		for _, t := range thirdCs {
-			if t != "certd" && t != "west" {
-				color.Red("平台错误，目前支持certd、west，多个平台用空格分隔")
+		for _, r := range refs {
+			ref, err := parseProviderRef(r)
+			if err != nil {
+				color.Red("%v，目前支持certd、west（可指定实例名如 certd.prod），多个用空格分隔", err)
 				continue
 			}
 			valid = true
-			if t == "certd" {
-				certd.SetConfig()
-			} else if t == "west" {
-				west.SetConfig()
+			if ref.Type == "certd" {
+				certd.SetConfig(ref.section())
+			} else if ref.Type == "west" {
+				west.SetConfig(ref.section())
 			}
 		}
 		// 存在有效平台配置则退出，否则重新输入
@@ -1597,18 +1912,6 @@ func checkInit() bool {
 	}
 }
 
-// 检查证书是否已经存在（通过域名）
-func checkHasDomain(domain string) bool {
-	certInfo, err := db.GetCertificateWrapper(domain)
-	if err != nil {
-		if !errors.Is(err, db.ErrNotFound) {
-			color.Yellow("检查域名 %s 是否存在时出错: %v\n", domain, err)
-		}
-		return false
-	}
-	return certInfo.Domain != ""
-}
-
 // trimQuotes 去掉字符串首尾的引号（Apache/Nginx 配置中的路径值可能带引号，如 SSLCertificateFile "path"）
 func trimQuotes(s string) string {
 	return strings.Trim(s, `"'`)
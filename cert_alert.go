@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"github.com/fatih/color"
+	"sort"
+	"ssl_assistant/config"
+	"ssl_assistant/db"
+	"ssl_assistant/notify"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultAlertDays 默认过期告警阈值（剩余天数），与 before_expiration_day 的自动更新相互独立
+var defaultAlertDays = []int{30, 14, 7, 1}
+
+// alertThresholds 读取过期告警阈值（配置 alert_days，逗号分隔，如 30,14,7,1；0 关闭告警），按从大到小排序。
+// 未配置或全部非法时取默认值。
+func alertThresholds() []int {
+	v, _ := config.GetConfig("", "alert_days")
+	return parseAlertDays(v)
+}
+
+// parseAlertDays 解析告警阈值配置（忽略非法与重复项，结果从大到小排序）
+func parseAlertDays(v string) []int {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return defaultAlertDays
+	}
+	if v == "0" {
+		return nil
+	}
+	seen := make(map[int]bool)
+	var days []int
+	for _, s := range strings.Split(v, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || n <= 0 || seen[n] {
+			continue
+		}
+		seen[n] = true
+		days = append(days, n)
+	}
+	if len(days) == 0 {
+		return defaultAlertDays
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(days)))
+	return days
+}
+
+// certAlertExpire 返回用于告警判断的到期时间：数据库记录与本地证书文件中较早的一个
+// （文件未更新或平台记录滞后时都能及时告警）；均不可用时返回 0
+func certAlertExpire(cert db.Certificate) int64 {
+	expireAt := cert.ExpireTime
+	if fileExpire, ok := localCertExpire(cert); ok && (expireAt <= 0 || fileExpire < expireAt) {
+		expireAt = fileExpire
+	}
+	return expireAt
+}
+
+// remainingDays 返回到期时间距 now 的剩余天数（不足一天按 0 计，已过期为负数）
+func remainingDays(expireAt int64, now time.Time) int64 {
+	return (expireAt - now.Unix()) / 86400
+}
+
+// nextAlert 判断证书是否需要发送过期告警，返回本次命中的阈值。
+// 剩余天数不超过某阈值即视为命中，取命中的最小阈值；每个阈值只告警一次：
+// 已告警阈值（AlertDays）不大于命中阈值时不重复告警，到期时间变化（证书已更换）时重置。
+func nextAlert(cert db.Certificate, expireAt int64, now time.Time, thresholds []int) (int, bool) {
+	if expireAt <= 0 || len(thresholds) == 0 {
+		return 0, false
+	}
+	remain := remainingDays(expireAt, now)
+	hit := 0
+	for _, d := range thresholds {
+		if remain <= int64(d) && (hit == 0 || d < hit) {
+			hit = d
+		}
+	}
+	if hit == 0 {
+		return 0, false
+	}
+	alerted := cert.AlertDays
+	if cert.AlertExpire != expireAt {
+		alerted = 0
+	}
+	if alerted != 0 && alerted <= hit {
+		return 0, false
+	}
+	return hit, true
+}
+
+// alertState 返回证书当前告警状态的显示文本（show 命令）
+func alertState(cert db.Certificate, expireAt int64) string {
+	if cert.AlertDays > 0 && cert.AlertExpire == expireAt {
+		return fmt.Sprintf("已告警(≤%d天)", cert.AlertDays)
+	}
+	return "-"
+}
+
+// checkExpiryAlerts 检查全部证书的过期告警阈值，命中的记录到通知批次并保存告警状态。
+// 与自动更新独立：本地来源或平台无法续期的证书同样会按阈值告警。
+func checkExpiryAlerts(batch *notify.Batch) {
+	thresholds := alertThresholds()
+	if len(thresholds) == 0 {
+		return
+	}
+	certificates, err := db.GetAllCertificatesWrapper()
+	if err != nil {
+		color.Yellow("检查过期告警失败: %v\n", err)
+		return
+	}
+	now := time.Now()
+	for _, cert := range certificates {
+		expireAt := certAlertExpire(cert)
+		days, ok := nextAlert(cert, expireAt, now, thresholds)
+		if !ok {
+			continue
+		}
+		expireDate := time.Unix(expireAt, 0).Format(time.DateOnly)
+		if remain := remainingDays(expireAt, now); expireAt < now.Unix() {
+			batch.Add(notify.EventExpiring, cert.Domain, "证书已于 %s 过期，请尽快处理", expireDate)
+		} else {
+			batch.Add(notify.EventExpiring, cert.Domain, "证书将于 %s 过期（剩余 %d 天，告警阈值 %d 天）", expireDate, remain, days)
+		}
+		color.Yellow("域名 %s 的证书剩余不足 %d 天，已发送过期告警\n", cert.Domain, days)
+		cert.AlertDays = days
+		cert.AlertExpire = expireAt
+		if err := db.UpdateCertificateInDBWrapper(cert); err != nil {
+			color.Yellow("保存域名 %s 的告警状态失败: %v\n", cert.Domain, err)
+		}
+	}
+}
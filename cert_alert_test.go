@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"ssl_assistant/db"
+	"testing"
+	"time"
+)
+
+// 告警阈值解析：去重、忽略非法项并从大到小排序；留空取默认值，0 关闭告警
+func TestParseAlertDays(t *testing.T) {
+	if got := parseAlertDays(""); !reflect.DeepEqual(got, defaultAlertDays) {
+		t.Fatalf("未配置应取默认阈值，实际 %v", got)
+	}
+	if got := parseAlertDays("0"); got != nil {
+		t.Fatalf("配置 0 应关闭告警，实际 %v", got)
+	}
+	if got := parseAlertDays(" 7, 30,abc,7,-1,1 "); !reflect.DeepEqual(got, []int{30, 7, 1}) {
+		t.Fatalf("阈值解析结果不符，实际 %v", got)
+	}
+	if got := parseAlertDays("abc"); !reflect.DeepEqual(got, defaultAlertDays) {
+		t.Fatalf("全部非法应回退默认阈值，实际 %v", got)
+	}
+}
+
+// 告警判断：命中最小阈值；同一到期时间下每个阈值只告警一次；到期时间变化后重置
+func TestNextAlert(t *testing.T) {
+	now := time.Now()
+	thresholds := []int{30, 14, 7, 1}
+	expireIn := func(days int) int64 { return now.Add(time.Duration(days)*24*time.Hour + time.Hour).Unix() }
+
+	if _, ok := nextAlert(db.Certificate{}, expireIn(60), now, thresholds); ok {
+		t.Fatal("剩余 60 天不应告警")
+	}
+	expireAt := expireIn(20)
+	days, ok := nextAlert(db.Certificate{}, expireAt, now, thresholds)
+	if !ok || days != 30 {
+		t.Fatalf("剩余 20 天应命中 30 天阈值，实际 %d %v", days, ok)
+	}
+	cert := db.Certificate{AlertDays: 30, AlertExpire: expireAt}
+	if _, ok := nextAlert(cert, expireAt, now, thresholds); ok {
+		t.Fatal("30 天阈值已告警，不应重复告警")
+	}
+	// 跨过多个阈值只告警最小的一个
+	expireAt = expireIn(5)
+	cert.AlertExpire = expireAt
+	if days, ok := nextAlert(cert, expireAt, now, thresholds); !ok || days != 7 {
+		t.Fatalf("剩余 5 天应命中 7 天阈值，实际 %d %v", days, ok)
+	}
+	// 到期时间变化（证书已更换）：告警状态重置
+	cert = db.Certificate{AlertDays: 1, AlertExpire: expireIn(-1)}
+	if days, ok := nextAlert(cert, expireIn(10), now, thresholds); !ok || days != 14 {
+		t.Fatalf("证书更换后应重新告警，实际 %d %v", days, ok)
+	}
+	// 已过期：命中最小阈值
+	if days, ok := nextAlert(db.Certificate{}, now.Add(-48*time.Hour).Unix(), now, thresholds); !ok || days != 1 {
+		t.Fatalf("已过期证书应命中最小阈值，实际 %d %v", days, ok)
+	}
+	if _, ok := nextAlert(db.Certificate{}, 0, now, thresholds); ok {
+		t.Fatal("无到期时间不应告警")
+	}
+}
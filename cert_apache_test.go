@@ -180,10 +180,15 @@ func TestQuotedPaths(t *testing.T) {
 }
 
 func TestExtractApacheCertPathsNoBlock(t *testing.T) {
-	// 单元级验证 extractApacheCertPaths 内部回退（无 VirtualHost 块时整文件匹配）。
-	// 注意：真实分发场景下，不含 <VirtualHost 的配置会被 isApacheConfig 判为 Nginx 语法，
-	// 因此该回退属于解析器内部的防御性逻辑。
-	cp, _, ok := extractApacheCertPaths([]byte(apacheNoBlock), "nohost.com")
+	// 不含 <VirtualHost 的配置凭 SSLCertificateFile 识别为 Apache 语法，主服务器配置视为一个站点
+	if !isApacheConfig(apacheNoBlock) {
+		t.Fatal("含 SSLCertificateFile 应识别为 Apache 配置")
+	}
+	confPath := filepath.Join(t.TempDir(), "ssl.conf")
+	if err := os.WriteFile(confPath, []byte(apacheNoBlock), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cp, _, ok := extractCertPathsFromFile(confPath, "nohost.com")
 	if !ok || cp != "/etc/httpd/nohost.pem" {
 		t.Fatalf("无 VirtualHost 块时应回退整文件匹配: ok=%v cp=%s", ok, cp)
 	}
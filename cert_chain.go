@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/pem"
+	"fmt"
+	"os"
+	"ssl_assistant/db"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// 证书链单独部署：Apache 2.4.8 以前中间证书需通过 SSLCertificateChainFile 单独配置，
+// 此时 SSLCertificateFile 只放叶子证书。平台返回的是完整证书链（叶子 + 中间证书），部署时拆分写入两个文件。
+
+// certBlocks 解析 PEM 中的全部证书块（忽略私钥等其他类型与块外文本）
+func certBlocks(pub string) []*pem.Block {
+	var blocks []*pem.Block
+	rest := []byte(pub)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return blocks
+		}
+		if block.Type == "CERTIFICATE" {
+			blocks = append(blocks, block)
+		}
+	}
+}
+
+// encodeCertBlocks 将证书块重新编码为 PEM 文本
+func encodeCertBlocks(blocks []*pem.Block) string {
+	var sb strings.Builder
+	for _, b := range blocks {
+		sb.Write(pem.EncodeToMemory(b))
+	}
+	return sb.String()
+}
+
+// splitCertChain 将完整证书链拆分为叶子证书与中间证书链；只有一张证书时 chain 为空
+func splitCertChain(pub string) (leaf, chain string) {
+	blocks := certBlocks(pub)
+	if len(blocks) == 0 {
+		return "", ""
+	}
+	return encodeCertBlocks(blocks[:1]), encodeCertBlocks(blocks[1:])
+}
+
+// normalizeCertChain 规范化 PEM 证书链（重新编码，消除换行与块外文本差异），用于比较拆分部署前后的内容
+func normalizeCertChain(pub string) string {
+	return encodeCertBlocks(certBlocks(pub))
+}
+
+// localCertChain 拼接已部署的叶子证书与证书链文件（规范化），文件不可读时对应部分为空
+func localCertChain(leafPub, chainPath string) string {
+	var chain string
+	if b, err := os.ReadFile(chainPath); err == nil {
+		chain = string(b)
+	}
+	return normalizeCertChain(leafPub + "\n" + chain)
+}
+
+// appendLocalChain 本地文件回退添加时，将证书链文件中的中间证书拼接到记录的公钥（与平台返回的完整证书链保持一致）
+func appendLocalChain(cert db.Certificate, chainPath string) db.Certificate {
+	if chainPath == "" || len(certBlocks(cert.PublicKey)) > 1 {
+		return cert
+	}
+	cert.PublicKey = localCertChain(cert.PublicKey, chainPath)
+	return cert
+}
+
+// writeCertChainFiles 拆分部署：叶子证书写入 CertPath，中间证书写入 ChainPath。
+// 平台只返回了叶子证书时保留原证书链文件（中间证书通常未变），仅提示
+func writeCertChainFiles(cert db.Certificate, d db.Deployment) error {
+	leaf, chain := splitCertChain(cert.PublicKey)
+	if leaf == "" {
+		return fmt.Errorf("更新域名 %s 的公钥文件失败: 证书内容无法解析\n", cert.Domain)
+	}
+	if err := writeDeployFile(d.CertPath, []byte(leaf), 0644, d.Mode, d.Owner); err != nil {
+		return fmt.Errorf("更新域名 %s 的公钥文件失败: %v\n", cert.Domain, err)
+	}
+	if chain == "" {
+		color.Yellow("域名 %s 的证书不含中间证书，证书链文件 %s 保持不变\n", cert.Domain, d.ChainPath)
+		return nil
+	}
+	if err := writeDeployFile(d.ChainPath, []byte(chain), 0644, d.Mode, d.Owner); err != nil {
+		return fmt.Errorf("更新域名 %s 的证书链文件失败: %v\n", cert.Domain, err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"ssl_assistant/db"
+	"strings"
+	"testing"
+)
+
+// fakeFullChain 生成两张自签证书拼接的"完整证书链"（叶子 + 中间证书），返回 PEM 文本与两张证书各自的内容
+func fakeFullChain(t *testing.T, domain string) (full, leaf, inter string) {
+	t.Helper()
+	leafPath, _ := genSelfSignedCert(t, t.TempDir(), domain, 30)
+	interPath, _ := genSelfSignedCert(t, t.TempDir(), "intermediate.test", 365)
+	l, _ := os.ReadFile(leafPath)
+	i, _ := os.ReadFile(interPath)
+	return string(l) + "\n# comment\n" + string(i), string(l), string(i)
+}
+
+func TestSplitCertChain(t *testing.T) {
+	full, leafPEM, interPEM := fakeFullChain(t, "chain.com")
+	leaf, chain := splitCertChain(full)
+	if leaf != leafPEM || chain != interPEM {
+		t.Fatalf("拆分结果错误:\nleaf=%q\nchain=%q", leaf, chain)
+	}
+	if _, chain := splitCertChain(leafPEM); chain != "" {
+		t.Fatalf("单张证书时证书链应为空: %q", chain)
+	}
+	if normalizeCertChain(full) != leafPEM+interPEM {
+		t.Fatal("规范化应去除块外文本")
+	}
+}
+
+// Apache 配置 SSLCertificateChainFile 时拆分部署，拼接后与平台证书链一致（update 据此判断无需重复部署）
+func TestUpdateCertificateFilesWithChain(t *testing.T) {
+	dir := t.TempDir()
+	full, leafPEM, interPEM := fakeFullChain(t, "chain.com")
+	d := db.Deployment{
+		CertPath:  filepath.Join(dir, "cert.pem"),
+		KeyPath:   filepath.Join(dir, "key.pem"),
+		ChainPath: filepath.Join(dir, "chain", "chain.pem"),
+	}
+	cert := db.Certificate{Domain: "chain.com", PublicKey: full, PrivateKey: "KEY", Deployments: []db.Deployment{d}}
+	if err := updateCertificateFiles(cert); err != nil {
+		t.Fatalf("部署失败: %v", err)
+	}
+	leaf, _ := os.ReadFile(d.CertPath)
+	chain, _ := os.ReadFile(d.ChainPath)
+	if string(leaf) != leafPEM || string(chain) != interPEM {
+		t.Fatalf("叶子证书/证书链写入错误:\nleaf=%q\nchain=%q", leaf, chain)
+	}
+	if localCertChain(string(leaf), d.ChainPath) != normalizeCertChain(full) {
+		t.Fatal("拼接已部署文件应与平台证书链一致")
+	}
+
+	// 平台只返回叶子证书：保留原证书链文件
+	cert.PublicKey = leafPEM
+	if err := updateCertificateFiles(cert); err != nil {
+		t.Fatal(err)
+	}
+	if chain, _ := os.ReadFile(d.ChainPath); string(chain) != interPEM {
+		t.Fatal("无中间证书时不应覆盖证书链文件")
+	}
+
+	// 本地回退添加：记录的公钥拼接证书链文件
+	got := appendLocalChain(db.Certificate{PublicKey: leafPEM}, d.ChainPath)
+	if got.PublicKey != leafPEM+interPEM {
+		t.Fatalf("本地回退应拼接证书链: %q", got.PublicKey)
+	}
+}
+
+// Apache 站点解析：跟随 Include，记录证书链路径与证书指令位置
+func TestParseApacheConfigIncludeChain(t *testing.T) {
+	root := t.TempDir()
+	main := filepath.Join(root, "conf", "httpd.conf")
+	os.MkdirAll(filepath.Join(root, "conf.d"), 0755)
+	os.MkdirAll(filepath.Dir(main), 0755)
+	os.WriteFile(main, []byte("ServerRoot \""+root+"\"\nLoadModule ssl_module modules/mod_ssl.so\nIncludeOptional conf.d/*.conf\n"), 0644)
+	site := filepath.Join(root, "conf.d", "ssl.conf")
+	os.WriteFile(site, []byte(`<IfModule ssl_module>
+<VirtualHost *:8443>
+    ServerName inc.com
+    SSLCertificateFile /certs/inc/cert.pem
+    SSLCertificateKeyFile /certs/inc/key.pem
+    SSLCertificateChainFile /certs/inc/chain.pem
+</VirtualHost>
+</IfModule>
+`), 0644)
+
+	sites := parseApacheConfig(main)
+	if len(sites) != 1 {
+		t.Fatalf("应解析出 1 个站点，实际 %d", len(sites))
+	}
+	s := sites[0]
+	if s.ChainPath != "/certs/inc/chain.pem" || s.Location != site+":4" || len(s.Ports) != 1 || s.Ports[0] != "8443" {
+		t.Fatalf("站点解析错误: %+v", s)
+	}
+	if p := s.certPairs(); p[0].ChainPath != "/certs/inc/chain.pem" {
+		t.Fatalf("证书对应包含证书链: %+v", p)
+	}
+	if pairs := extractCertPairsFromFile(main, "inc.com"); len(pairs) != 1 || !strings.HasSuffix(pairs[0].ChainPath, "chain.pem") {
+		t.Fatalf("主配置提取证书路径应跟随 Include: %+v", pairs)
+	}
+}
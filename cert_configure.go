@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"ssl_assistant/apacheconf"
+	"ssl_assistant/confedit"
+	"ssl_assistant/config"
+	"ssl_assistant/db"
+	"ssl_assistant/nginxconf"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// --- 自动配置 Web 服务器（add --configure）：将域名的 Nginx server / Apache VirtualHost 指向托管的证书路径，
+// 修改前备份原配置，修改后执行配置测试，测试失败还原，通过后重载 ---
+
+// defaultManagedCertDir 托管证书的默认目录（可通过 managed_cert_dir 修改）
+const defaultManagedCertDir = "/etc/ssl_assistant/certs"
+
+// defaultConfigTestCmds 默认配置测试命令（可通过 nginx_test_cmd / apache_test_cmd 修改，如 Docker 部署时）
+var defaultConfigTestCmds = map[string]string{
+	"nginx":  "nginx -t",
+	"apache": "apachectl -t",
+}
+
+// managedCertDir 托管证书目录（绝对路径；Windows 默认为运行目录下的 certs）
+func managedCertDir() string {
+	dir, _ := config.GetConfig("", "managed_cert_dir")
+	if dir = strings.TrimSpace(dir); dir == "" {
+		dir = defaultManagedCertDir
+		if runtime.GOOS == "windows" {
+			dir = "certs"
+		}
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
+
+// managedDeployment 托管的部署位置：<托管目录>/<域名>/fullchain.pem 与 privkey.pem（通配符域名的 * 替换为 _）
+func managedDeployment(domain string) db.Deployment {
+	dir := filepath.Join(managedCertDir(), strings.ReplaceAll(domain, "*", "_"))
+	return db.Deployment{CertPath: filepath.Join(dir, "fullchain.pem"), KeyPath: filepath.Join(dir, "privkey.pem")}
+}
+
+// configTestCmd 配置测试命令（kind 为 nginx / apache）
+func configTestCmd(kind string) string {
+	if cmd, _ := config.GetConfig("", kind+"_test_cmd"); strings.TrimSpace(cmd) != "" {
+		return cmd
+	}
+	return defaultConfigTestCmds[kind]
+}
+
+// configurePlan 配置修改计划
+type configurePlan struct {
+	edits     []confedit.Edit
+	locations []string // 修改的 server / VirtualHost 位置（file:line）
+	kinds     []string // 涉及的服务器类型（nginx / apache），决定执行哪些配置测试
+	warnings  []string
+}
+
+func (p *configurePlan) add(kind, location string, edits []confedit.Edit) {
+	p.edits = append(p.edits, edits...)
+	p.locations = append(p.locations, location)
+	if !containsString(p.kinds, kind) {
+		p.kinds = append(p.kinds, kind)
+	}
+}
+
+// apacheCandidate 匹配域名的 Apache 虚拟主机
+type apacheCandidate struct {
+	vhost   apacheconf.VHost
+	listens bool // 配置已监听 443
+}
+
+// planConfigure 在配置文件中查找域名所在的 server / VirtualHost 并生成修改：
+// 已启用 SSL 的优先（证书指向其他位置时替换为托管路径），没有时为未启用 SSL 的启用（跳过重定向到 HTTPS 的）；
+// 已指向托管路径的不再修改。同一 server 经多个入口文件解析到时只处理一次
+func planConfigure(paths []string, domain string, d db.Deployment) (configurePlan, error) {
+	var plan configurePlan
+	var nginxSSL, nginxPlain []nginxconf.Site
+	var apacheSSL, apachePlain []apacheCandidate
+	seen := map[string]bool{}
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if _, ok := parseServerConfig(path, content, false); ok {
+			continue
+		}
+		if isApacheConfig(string(content)) {
+			cfg, err := apacheconf.Parse(path)
+			if err != nil {
+				plan.warnings = append(plan.warnings, err.Error())
+				continue
+			}
+			for _, v := range apacheconf.VirtualHosts(cfg) {
+				if !containsString(v.ServerNames, domain) || seen[v.Section.Pos()] {
+					continue
+				}
+				seen[v.Section.Pos()] = true
+				c := apacheCandidate{vhost: v, listens: apacheconf.ListensOn(cfg, "443")}
+				if v.Cert != nil || v.SSLEngine || containsString(apacheVirtualHostPorts(v.Addrs()), "443") {
+					apacheSSL = append(apacheSSL, c)
+				} else {
+					apachePlain = append(apachePlain, c)
+				}
+			}
+			continue
+		}
+		cfg, err := nginxconf.Parse(path)
+		if err != nil {
+			plan.warnings = append(plan.warnings, err.Error())
+			continue
+		}
+		for _, s := range nginxconf.Servers(cfg) {
+			if !containsString(s.ServerNames, domain) || seen[s.Server.Pos()] {
+				continue
+			}
+			seen[s.Server.Pos()] = true
+			switch {
+			case len(s.SSLListens()) > 0:
+				nginxSSL = append(nginxSSL, s)
+			case !nginxRedirectsToHTTPS(s):
+				nginxPlain = append(nginxPlain, s)
+			}
+		}
+	}
+	if len(nginxSSL)+len(nginxPlain)+len(apacheSSL)+len(apachePlain) == 0 {
+		return plan, fmt.Errorf("未在 Nginx/Apache 配置中找到域名 %s 的 server / VirtualHost", domain)
+	}
+
+	if len(nginxSSL) == 0 {
+		nginxSSL = nginxPlain
+	}
+	for _, s := range nginxSSL {
+		if !s.Inherited && len(s.Certificates) == 1 && len(s.Keys) == 1 && s.CertPath() == d.CertPath && s.KeyPath() == d.KeyPath {
+			continue
+		}
+		edits, err := nginxconf.ConfigureSSL(s, filepath.ToSlash(d.CertPath), filepath.ToSlash(d.KeyPath))
+		if err != nil {
+			return plan, err
+		}
+		plan.add("nginx", s.Server.Pos(), edits)
+	}
+
+	if len(apacheSSL) == 0 {
+		apacheSSL = apachePlain
+	}
+	for _, c := range apacheSSL {
+		v := c.vhost
+		if !v.Inherited && v.Chain == nil && v.CertPath() == d.CertPath && v.KeyPath() == d.KeyPath {
+			continue
+		}
+		edits, err := apacheconf.ConfigureSSL(v, filepath.ToSlash(d.CertPath), filepath.ToSlash(d.KeyPath))
+		if err != nil {
+			return plan, err
+		}
+		plan.add("apache", v.Section.Pos(), edits)
+		if !c.listens {
+			plan.warnings = append(plan.warnings, fmt.Sprintf("%s 所在的 Apache 配置未见 Listen 443，请确认已启用 mod_ssl（如 a2enmod ssl）", v.Section.Pos()))
+		}
+	}
+	return plan, nil
+}
+
+// nginxRedirectsToHTTPS server 是否通过 return / rewrite 重定向到 HTTPS（此类 server 启用 SSL 会造成循环重定向）
+func nginxRedirectsToHTTPS(s nginxconf.Site) bool {
+	for _, name := range []string{"return", "rewrite"} {
+		for _, d := range s.Server.Children(name) {
+			for _, a := range d.Args {
+				if strings.HasPrefix(strings.ToLower(a), "https://") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// configureWebServer 修改默认配置路径下域名所在的 Nginx / Apache 配置，使其使用部署位置 d 的证书
+func configureWebServer(domain string, d db.Deployment) error {
+	var paths []string
+	eachConfigFile(func(path string) bool {
+		paths = append(paths, path)
+		return false
+	})
+	return configureWebServerFiles(paths, domain, d)
+}
+
+// configureWebServerFiles 修改指定配置文件中域名所在的 server / VirtualHost，配置测试通过后执行重载命令
+func configureWebServerFiles(paths []string, domain string, d db.Deployment) error {
+	plan, err := planConfigure(paths, domain, d)
+	for _, w := range plan.warnings {
+		color.Yellow("%s\n", w)
+	}
+	if err != nil {
+		return err
+	}
+	if len(plan.edits) == 0 {
+		color.Green("域名 %s 的配置已指向 %s，无需修改\n", domain, d.CertPath)
+		return nil
+	}
+	if err := applyConfigEdits(plan); err != nil {
+		return err
+	}
+	for _, loc := range plan.locations {
+		color.Green("已配置 %s 使用证书 %s\n", loc, d.CertPath)
+	}
+	return executeRestartCmd()
+}
+
+// applyConfigEdits 备份并修改配置文件，执行配置测试；测试失败时还原全部文件
+func applyConfigEdits(plan configurePlan) error {
+	files, err := confedit.Render(plan.edits)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	backupDir, originals, err := backupConfigFiles(names)
+	if err != nil {
+		return fmt.Errorf("备份配置文件失败: %v", err)
+	}
+	restore := func() {
+		for name, data := range originals {
+			if err := os.WriteFile(name, data, 0644); err != nil {
+				color.Red("还原 %s 失败（备份在 %s）: %v\n", name, backupDir, err)
+			}
+		}
+	}
+	for _, name := range names {
+		if err := os.WriteFile(name, files[name], 0644); err != nil {
+			restore()
+			return fmt.Errorf("写入配置文件 %s 失败，已还原: %v", name, err)
+		}
+	}
+	for _, kind := range plan.kinds {
+		cmd := configTestCmd(kind)
+		if out, err := runConfigTest(cmd); err != nil {
+			restore()
+			return fmt.Errorf("配置测试（%s）未通过，已还原修改: %v\n%s", cmd, err, out)
+		}
+	}
+	color.Green("配置测试通过，原配置已备份到 %s\n", backupDir)
+	return nil
+}
+
+// backupConfigFiles 将配置文件备份到 ~/.ssl_assistant/backups/<时间>/<原绝对路径>，返回备份目录与原内容。
+// 不备份在配置旁边，避免被 include sites-enabled/* 等通配符再次加载
+func backupConfigFiles(names []string) (string, map[string][]byte, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", nil, err
+	}
+	dir := filepath.Join(home, ".ssl_assistant", "backups", time.Now().Format("20060102-150405"))
+	originals := map[string][]byte{}
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return "", nil, err
+		}
+		abs, err := filepath.Abs(name)
+		if err != nil {
+			return "", nil, err
+		}
+		dest := filepath.Join(dir, strings.TrimPrefix(abs, filepath.VolumeName(abs)))
+		if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+			return "", nil, err
+		}
+		if err := os.WriteFile(dest, data, 0600); err != nil {
+			return "", nil, err
+		}
+		originals[name] = data
+	}
+	return dir, originals, nil
+}
+
+// runConfigTest 执行配置测试命令（60 秒超时）
+func runConfigTest(cmdline string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	out, err := shellCommand(ctx, cmdline).CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
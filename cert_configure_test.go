@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"ssl_assistant/db"
+	"strings"
+	"testing"
+)
+
+// 配置测试未通过时还原原配置；通过后保留修改并备份原配置；已指向托管路径时不再修改
+func TestConfigureWebServerFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	dir := t.TempDir()
+	original := `http {
+    server {
+        listen 80;
+        server_name cfg.com www.cfg.com;
+    }
+    server {
+        listen 80;
+        server_name cfg.com;
+        return 301 https://cfg.com$request_uri;
+    }
+}
+`
+	conf := filepath.Join(dir, "nginx.conf")
+	if err := os.WriteFile(conf, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d := db.Deployment{CertPath: "/m/cfg.com/fullchain.pem", KeyPath: "/m/cfg.com/privkey.pem"}
+	setConfig(t, "", "restart_cmd", "exit 0")
+
+	setConfig(t, "", "nginx_test_cmd", "exit 1")
+	err := configureWebServerFiles([]string{conf}, "cfg.com", d)
+	if err == nil || !strings.Contains(err.Error(), "已还原") {
+		t.Fatalf("配置测试失败应返回错误: %v", err)
+	}
+	if data, _ := os.ReadFile(conf); string(data) != original {
+		t.Fatalf("配置测试失败应还原原配置:\n%s", data)
+	}
+
+	setConfig(t, "", "nginx_test_cmd", "exit 0")
+	if err := configureWebServerFiles([]string{conf}, "cfg.com", d); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(conf)
+	if !strings.Contains(string(data), "        listen 443 ssl;\n        server_name cfg.com www.cfg.com;\n        ssl_certificate /m/cfg.com/fullchain.pem;") ||
+		strings.Count(string(data), "ssl_certificate ") != 1 {
+		t.Fatalf("应只修改未重定向的 server:\n%s", data)
+	}
+	backups, _ := filepath.Glob(filepath.Join(home, ".ssl_assistant", "backups", "*", "*"))
+	if len(backups) == 0 {
+		t.Fatal("应备份原配置")
+	}
+
+	plan, err := planConfigure([]string{conf}, "cfg.com", d)
+	if err != nil || len(plan.edits) != 0 {
+		t.Fatalf("已指向托管路径时不应再修改: %v %+v", err, plan.edits)
+	}
+	if _, err := planConfigure([]string{conf}, "none.com", d); err == nil {
+		t.Fatal("找不到域名时应报错")
+	}
+}
+
+// 托管路径位于托管目录下的域名子目录（通配符域名替换 *）
+func TestManagedDeployment(t *testing.T) {
+	root := t.TempDir()
+	setConfig(t, "", "managed_cert_dir", root)
+	d := managedDeployment("*.m.com")
+	if d.CertPath != filepath.Join(root, "_.m.com", "fullchain.pem") || d.KeyPath != filepath.Join(root, "_.m.com", "privkey.pem") {
+		t.Fatalf("托管路径错误: %+v", d)
+	}
+}
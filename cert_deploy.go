@@ -0,0 +1,348 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"ssl_assistant/db"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+)
+
+// --- 部署位置：同一证书部署到多个位置（Nginx + Apache、多个站点目录共用一张证书等），更新时一次获取、全部写入 ---
+
+// deploymentOf 证书/私钥对转换为部署位置
+func deploymentOf(p certPair) db.Deployment {
+	return db.Deployment{CertPath: p.CertPath, KeyPath: p.KeyPath, ChainPath: p.ChainPath}
+}
+
+// hasDeployment 证书是否已部署到该证书文件路径
+func hasDeployment(cert db.Certificate, certPath string) bool {
+	if certPath == "" {
+		return false
+	}
+	for _, d := range cert.Deployments {
+		if filepath.Clean(d.CertPath) == filepath.Clean(certPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// deploymentFiles 证书全部部署位置的证书/私钥文件名（表格展示用，多个位置换行显示；只显示文件名，避免超长路径撑爆表格）
+func deploymentFiles(cert db.Certificate) (certFiles, keyFiles string) {
+	var certs, keys []string
+	for _, d := range cert.Deployments {
+		certs = append(certs, baseName(d.CertPath))
+		keys = append(keys, baseName(d.KeyPath))
+	}
+	return strings.Join(certs, "\n"), strings.Join(keys, "\n")
+}
+
+// baseName 文件名；路径为空时返回空串，避免 filepath.Base("") 返回 "."
+func baseName(path string) string {
+	if path == "" {
+		return ""
+	}
+	return filepath.Base(path)
+}
+
+// localCertExpire 本地证书文件的实际到期时间：多个部署位置取最早的一个（任一位置未更新都应及时发现）；
+// 均不可读时 ok 为 false
+func localCertExpire(cert db.Certificate) (expireAt int64, ok bool) {
+	for _, d := range cert.Deployments {
+		if d.CertPath == "" {
+			continue
+		}
+		if e, err := getCertFileExpireTime(d.CertPath); err == nil && (!ok || e < expireAt) {
+			expireAt, ok = e, true
+		}
+	}
+	return expireAt, ok
+}
+
+// attachDeployment 为已有证书记录追加部署位置并保存；该证书文件路径已是部署位置时返回错误
+func attachDeployment(cert db.Certificate, d db.Deployment) (db.Certificate, error) {
+	if hasDeployment(cert, d.CertPath) {
+		return cert, fmt.Errorf("域名 %s 的证书已部署到 %s，无需重复添加", certLabel(cert.Domain, cert.KeyType), d.CertPath)
+	}
+	cert.Deployments = append(cert.Deployments, d)
+	if err := db.UpdateCertificateInDBWrapper(cert); err != nil {
+		return cert, fmt.Errorf("保存部署位置失败: %s", err)
+	}
+	color.Green("已为域名 %s 的证书添加部署位置 %s\n", certLabel(cert.Domain, cert.KeyType), d.CertPath)
+	return cert, nil
+}
+
+// addDeploymentToCert 追加部署位置并立即写入证书文件（申请中尚无证书时签发后随证书部署）
+func addDeploymentToCert(cert db.Certificate, d db.Deployment) error {
+	cert, err := attachDeployment(cert, d)
+	if err != nil {
+		return err
+	}
+	if cert.PublicKey == "" {
+		color.Yellow("证书尚未签发，签发后随证书一同部署\n")
+		return nil
+	}
+	if err := writeDeploymentFiles(cert, d); err != nil {
+		return err
+	}
+	color.Green("证书文件已写入，如服务未自动加载请手动重载\n")
+	return nil
+}
+
+// updateCertificateFiles 将证书写入全部部署位置后部署附加目标
+func updateCertificateFiles(cert db.Certificate) error {
+	_, err := deployCertificate(cert)
+	return err
+}
+
+// deployError 部分或全部部署位置写入失败（written 为写入成功的位置数，这些位置仍需重载生效）
+type deployError struct {
+	written int
+	errs    []string
+}
+
+func (e *deployError) Error() string {
+	return strings.Join(e.errs, "; ")
+}
+
+// deployCertificate 将证书写入全部部署位置后部署附加目标，返回各附加目标的结果。
+// 单个位置失败不影响其余位置与附加目标，失败的位置汇总为 *deployError 返回
+func deployCertificate(cert db.Certificate) ([]targetResult, error) {
+	if len(cert.Deployments) == 0 {
+		color.Yellow("域名 %s 未配置部署位置，仅更新证书记录\n", cert.Domain)
+		return deployCertTargets(cert), nil
+	}
+	derr := &deployError{}
+	for _, d := range cert.Deployments {
+		if err := writeDeploymentFiles(cert, d); err != nil {
+			derr.errs = append(derr.errs, strings.TrimSpace(err.Error()))
+		} else {
+			derr.written++
+		}
+	}
+	results := deployCertTargets(cert)
+	if len(derr.errs) > 0 {
+		return results, derr
+	}
+	return results, nil
+}
+
+// writeDeploymentFiles 写入单个部署位置的证书文件（属主与权限见 writeDeployFile）
+func writeDeploymentFiles(cert db.Certificate, d db.Deployment) error {
+	// 证书与私钥合并在同一文件（HAProxy crt / lighttpd ssl.pemfile）：含私钥，新建时权限 0600
+	if isCombinedPEM(d) {
+		if err := writeDeployFile(d.CertPath, []byte(combinedPEM(cert)), 0600, d.KeyMode, d.Owner); err != nil {
+			return fmt.Errorf("更新域名 %s 的证书文件 %s 失败: %v\n", cert.Domain, d.CertPath, err)
+		}
+		color.Green("域名 %s 的证书文件已更新: %s\n", cert.Domain, d.CertPath)
+		return nil
+	}
+
+	// 更新公钥文件（配置了证书链文件时拆分写入叶子证书与中间证书）
+	var err error
+	if d.ChainPath != "" {
+		err = writeCertChainFiles(cert, d)
+	} else {
+		err = writeDeployFile(d.CertPath, []byte(cert.PublicKey), 0644, d.Mode, d.Owner)
+		if err != nil {
+			err = fmt.Errorf("更新域名 %s 的公钥文件 %s 失败: %v\n", cert.Domain, d.CertPath, err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// 更新私钥文件（新建时权限 0600，避免同机其他用户可读）
+	if err := writeDeployFile(d.KeyPath, []byte(cert.PrivateKey), 0600, d.KeyMode, d.Owner); err != nil {
+		return fmt.Errorf("更新域名 %s 的私钥文件 %s 失败: %v\n", cert.Domain, d.KeyPath, err)
+	}
+
+	// 绿色高亮提示更新成功的域名，便于在批量更新中快速识别
+	color.Green("域名 %s 的证书文件已更新: %s\n", cert.Domain, d.CertPath)
+	return nil
+}
+
+// certFilesUpToDate 各部署位置的证书文件是否均与新证书一致。
+// 比较基准优先本地证书文件的实际内容（修复"DB 记录为云端证书、本地文件过期/非云端"时
+// 比较 DB 恒相同导致本地过期文件得不到更新的问题）；文件不可读时回退 DB 记录
+func certFilesUpToDate(cert, newCert db.Certificate) bool {
+	if len(cert.Deployments) == 0 {
+		return newCert.PublicKey == cert.PublicKey && newCert.PrivateKey == cert.PrivateKey
+	}
+	for _, d := range cert.Deployments {
+		basePub, baseKey := readLocalCertFiles(d.CertPath, d.KeyPath)
+		if basePub == "" && baseKey == "" {
+			basePub, baseKey = cert.PublicKey, cert.PrivateKey
+		}
+		newPub, newKey := newCert.PublicKey, newCert.PrivateKey
+		switch {
+		case d.ChainPath != "":
+			// 证书链单独部署：叶子证书与证书链文件拼接后按证书内容比较
+			basePub, newPub = localCertChain(basePub, d.ChainPath), normalizeCertChain(newPub)
+		case isCombinedPEM(d):
+			// 证书与私钥合并在同一文件：按部署后的文件内容比较
+			newPub = combinedPEM(newCert)
+			newKey = newPub
+		}
+		if newPub != basePub || newKey != baseKey {
+			return false
+		}
+	}
+	return true
+}
+
+// validateDeployment 校验并规范化部署位置（绝对路径；证书链文件不得与证书/私钥文件相同；属主存在、权限为八进制）
+func validateDeployment(d db.Deployment) (db.Deployment, error) {
+	if strings.TrimSpace(d.CertPath) == "" || strings.TrimSpace(d.KeyPath) == "" {
+		return d, errors.New("请指定证书与私钥路径（--cert / --key，合并文件时两者相同）")
+	}
+	var err error
+	for _, p := range []*string{&d.CertPath, &d.KeyPath, &d.ChainPath} {
+		if *p == "" {
+			continue
+		}
+		if *p, err = filepath.Abs(*p); err != nil {
+			return d, err
+		}
+	}
+	if d.ChainPath != "" && (d.ChainPath == d.CertPath || d.ChainPath == d.KeyPath) {
+		return d, errors.New("证书链路径不能与证书或私钥路径相同")
+	}
+	if d.ChainPath != "" && isCombinedPEM(d) {
+		return d, errors.New("证书与私钥合并文件不支持单独的证书链文件")
+	}
+	if d.Mode != "" && isCombinedPEM(d) {
+		return d, errors.New("证书与私钥合并文件含私钥，请使用 --key-mode 设置权限")
+	}
+	for _, m := range []string{d.Mode, d.KeyMode} {
+		if m == "" {
+			continue
+		}
+		if _, err := parseFileMode(m); err != nil {
+			return d, err
+		}
+	}
+	if d.Owner != "" && runtime.GOOS != "windows" {
+		if _, _, err := lookupOwner(d.Owner); err != nil {
+			return d, err
+		}
+	}
+	return d, nil
+}
+
+// deployList 列出部署位置（指定证书 ID 时只列该证书）
+func deployList(args []string) error {
+	var certs []db.Certificate
+	if len(args) > 0 {
+		cert, err := getCertByIDArg(args[0])
+		if err != nil {
+			return err
+		}
+		certs = []db.Certificate{cert}
+	} else {
+		all, err := db.GetAllCertificatesWrapper()
+		if err != nil {
+			return fmt.Errorf("获取证书列表失败: %s", err)
+		}
+		certs = all
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"证书ID", "域名", "序号", "证书文件", "私钥文件", "证书链文件", "属主", "权限", "本地到期"})
+	rows := 0
+	for _, cert := range certs {
+		for i, d := range cert.Deployments {
+			keyPath := d.KeyPath
+			if isCombinedPEM(d) {
+				keyPath = "（与证书合并）"
+			}
+			localExpire := "-"
+			if e, err := getCertFileExpireTime(d.CertPath); err == nil {
+				localExpire = time.Unix(e, 0).Format(time.DateOnly)
+			}
+			owner, mode := deploymentPerm(d)
+			table.Append([]string{strconv.Itoa(cert.ID), certLabel(cert.Domain, cert.KeyType), strconv.Itoa(i + 1), d.CertPath, keyPath, d.ChainPath, owner, mode, localExpire})
+			rows++
+		}
+	}
+	if rows == 0 {
+		color.Yellow("暂无部署位置，可通过 deploy add <证书ID> --cert <证书路径> --key <私钥路径> 添加\n")
+		return nil
+	}
+	table.Render()
+	return nil
+}
+
+// deployAdd 为证书添加部署位置并立即写入证书文件
+func deployAdd(idArg string, d db.Deployment) error {
+	cert, err := getCertByIDArg(idArg)
+	if err != nil {
+		return err
+	}
+	if d, err = validateDeployment(d); err != nil {
+		return err
+	}
+	return addDeploymentToCert(cert, d)
+}
+
+// deploySet 修改第 n 个部署位置的属主与权限（nil 为不修改，空串为恢复默认：保留已有文件的属主与权限），并立即重新写入证书文件
+func deploySet(idArg, indexArg string, owner, mode, keyMode *string) error {
+	cert, err := getCertByIDArg(idArg)
+	if err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(indexArg)
+	if err != nil || n < 1 || n > len(cert.Deployments) {
+		return fmt.Errorf("序号 %s 无效（该证书共 %d 个部署位置）", indexArg, len(cert.Deployments))
+	}
+	d := cert.Deployments[n-1]
+	for _, f := range []struct{ dst, src *string }{{&d.Owner, owner}, {&d.Mode, mode}, {&d.KeyMode, keyMode}} {
+		if f.src != nil {
+			*f.dst = strings.TrimSpace(*f.src)
+		}
+	}
+	if d, err = validateDeployment(d); err != nil {
+		return err
+	}
+	cert.Deployments[n-1] = d
+	if err := db.UpdateCertificateInDBWrapper(cert); err != nil {
+		return fmt.Errorf("保存部署位置失败: %s", err)
+	}
+	o, m := deploymentPerm(d)
+	color.Green("已修改部署位置 %s：属主 %s，权限 %s\n", d.CertPath, o, m)
+	if cert.PublicKey == "" {
+		return nil
+	}
+	// 立即重新写入，使属主与权限生效
+	return writeDeploymentFiles(cert, d)
+}
+
+// deployDel 删除证书的第 n 个部署位置（序号见 deploy list），不删除已部署的文件
+func deployDel(idArg, indexArg string) error {
+	cert, err := getCertByIDArg(idArg)
+	if err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(indexArg)
+	if err != nil || n < 1 || n > len(cert.Deployments) {
+		return fmt.Errorf("序号 %s 无效（该证书共 %d 个部署位置）", indexArg, len(cert.Deployments))
+	}
+	removed := cert.Deployments[n-1]
+	cert.Deployments = append(cert.Deployments[:n-1:n-1], cert.Deployments[n:]...)
+	if err := db.UpdateCertificateInDBWrapper(cert); err != nil {
+		return fmt.Errorf("删除部署位置失败: %s", err)
+	}
+	color.Green("已删除部署位置 %s，已部署的文件保留\n", removed.CertPath)
+	if len(cert.Deployments) == 0 {
+		color.Yellow("该证书已无部署位置，更新时仅更新证书记录\n")
+	}
+	return nil
+}
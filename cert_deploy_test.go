@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"ssl_assistant/db"
+	"testing"
+)
+
+// 一张证书部署到多个位置（普通 / 合并文件 / 拆分证书链）：一次写入全部位置，任一位置过期即需更新
+func TestUpdateCertificateFilesDeployments(t *testing.T) {
+	dir := t.TempDir()
+	full, leafPEM, interPEM := fakeFullChain(t, "multi.com")
+	nginx := db.Deployment{CertPath: filepath.Join(dir, "nginx", "multi.pem"), KeyPath: filepath.Join(dir, "nginx", "multi.key")}
+	haproxy := db.Deployment{CertPath: filepath.Join(dir, "haproxy", "multi.pem"), KeyPath: filepath.Join(dir, "haproxy", "multi.pem")}
+	apache := db.Deployment{CertPath: filepath.Join(dir, "apache", "multi.crt"), KeyPath: filepath.Join(dir, "apache", "multi.key"), ChainPath: filepath.Join(dir, "apache", "chain.crt")}
+	cert := db.Certificate{Domain: "multi.com", PublicKey: full, PrivateKey: "KEY", Deployments: []db.Deployment{nginx, haproxy, apache}}
+
+	if err := updateCertificateFiles(cert); err != nil {
+		t.Fatalf("部署失败: %v", err)
+	}
+	for path, want := range map[string]string{
+		nginx.CertPath:   full,
+		nginx.KeyPath:    "KEY",
+		haproxy.CertPath: combinedPEM(cert),
+		apache.CertPath:  leafPEM,
+		apache.ChainPath: interPEM,
+	} {
+		if got, _ := os.ReadFile(path); string(got) != want {
+			t.Fatalf("%s 内容错误: %q", path, got)
+		}
+	}
+	if !certFilesUpToDate(cert, cert) {
+		t.Fatal("全部位置已部署，应无需更新")
+	}
+
+	// 任一位置的文件不同即需要更新
+	os.WriteFile(apache.CertPath, []byte("OLD"), 0644)
+	if certFilesUpToDate(cert, cert) {
+		t.Fatal("部署位置文件过期时应需要更新")
+	}
+
+	// 单个位置写入失败不影响其余位置与附加目标，错误汇总返回并记录写入成功的位置数
+	blocker := filepath.Join(dir, "blocker")
+	os.WriteFile(blocker, nil, 0644)
+	der := filepath.Join(dir, "multi.der")
+	cert.Deployments = []db.Deployment{{CertPath: filepath.Join(blocker, "a.pem"), KeyPath: filepath.Join(blocker, "a.key")}, apache}
+	cert.Targets = db.DeployTargets{{Format: "der", Path: der}}
+	_, err := deployCertificate(cert)
+	var derr *deployError
+	if !errors.As(err, &derr) || derr.written != 1 || len(derr.errs) != 1 {
+		t.Fatalf("写入失败的位置应汇总返回: %v", err)
+	}
+	if got, _ := os.ReadFile(apache.CertPath); string(got) != leafPEM {
+		t.Fatal("其余位置应继续写入")
+	}
+	if _, err := os.Stat(der); err != nil {
+		t.Fatal("部分位置失败时仍应部署附加目标")
+	}
+}
+
+// 部署位置校验：证书/私钥必填、路径转为绝对路径、证书链不得与证书/私钥相同
+func TestValidateDeployment(t *testing.T) {
+	d, err := validateDeployment(db.Deployment{CertPath: "a.pem", KeyPath: "a.key"})
+	if err != nil || !filepath.IsAbs(d.CertPath) || !filepath.IsAbs(d.KeyPath) || d.ChainPath != "" {
+		t.Fatalf("应转为绝对路径: %+v %v", d, err)
+	}
+	if _, err := validateDeployment(db.Deployment{CertPath: "/a.pem", KeyPath: "/a.pem"}); err != nil {
+		t.Fatalf("合并文件应允许: %v", err)
+	}
+	bad := []db.Deployment{
+		{CertPath: "/a.pem"},
+		{KeyPath: "/a.key"},
+		{CertPath: "/a.pem", KeyPath: "/a.key", ChainPath: "/a.pem"},
+		{CertPath: "/a.pem", KeyPath: "/a.pem", ChainPath: "/chain.pem"},
+		{CertPath: "/a.pem", KeyPath: "/a.key", Mode: "0999"},
+		{CertPath: "/a.pem", KeyPath: "/a.key", KeyMode: "rw"},
+		{CertPath: "/a.pem", KeyPath: "/a.pem", Mode: "0640"},
+	}
+	for _, d := range bad {
+		if _, err := validateDeployment(d); err == nil {
+			t.Fatalf("应校验失败: %+v", d)
+		}
+	}
+}
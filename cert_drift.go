@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"ssl_assistant/config"
+	"ssl_assistant/db"
+	"ssl_assistant/notify"
+	"ssl_assistant/utils"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+)
+
+// --- 配置漂移检测（scan）：检索 Web 服务器配置，与数据库中的证书记录比对。
+// 手动新增/删除站点或修改证书路径后，数据库不会自动感知，续期时可能写入已不再使用的路径 ---
+
+// 漂移类型
+const (
+	driftNone      = ""          // 一致
+	driftUnmanaged = "unmanaged" // 配置中启用了 SSL 的站点未纳管
+	driftRemoved   = "removed"   // 纳管的证书在配置中已找不到对应站点
+	driftPath      = "path"      // 配置中的证书路径不在证书的部署位置中
+)
+
+// driftKindNames 漂移类型展示名
+var driftKindNames = map[string]string{
+	driftNone:      "一致",
+	driftUnmanaged: "未纳管",
+	driftRemoved:   "配置已移除",
+	driftPath:      "路径不一致",
+}
+
+// driftItem 一条比对结果：配置中站点的一组证书（Site/Pair）与对应的证书记录（Cert）
+type driftItem struct {
+	Kind string
+	Site nginxSite      // 配置中的站点（driftRemoved 时为空）
+	Pair certPair       // 站点的一组证书（driftRemoved 时为空）
+	Cert db.Certificate // 对应的证书记录（driftUnmanaged 时为空）
+}
+
+// Summary 比对结果说明
+func (d driftItem) Summary() string {
+	switch d.Kind {
+	case driftUnmanaged:
+		return fmt.Sprintf("配置 %s 使用证书 %s，未纳管", d.Site.Location, d.Pair.CertPath)
+	case driftRemoved:
+		var paths []string
+		for _, dep := range d.Cert.Deployments {
+			paths = append(paths, dep.CertPath)
+		}
+		return fmt.Sprintf("配置中未找到该域名的站点（部署位置 %s）", strings.Join(paths, "、"))
+	case driftPath:
+		var paths []string
+		for _, dep := range d.Cert.Deployments {
+			paths = append(paths, dep.CertPath)
+		}
+		return fmt.Sprintf("配置 %s 使用证书 %s，数据库部署到 %s", d.Site.Location, d.Pair.CertPath, strings.Join(paths, "、"))
+	}
+	return d.Pair.CertPath
+}
+
+// domain 比对结果对应的域名
+func (d driftItem) domain() string {
+	if d.Kind == driftRemoved {
+		return certLabel(d.Cert.Domain, d.Cert.KeyType)
+	}
+	return certLabel(d.Site.Domain, d.Pair.KeyType)
+}
+
+// pairSite 仅含该组证书的站点（添加时只处理这一组）
+func (d driftItem) pairSite() nginxSite {
+	site := d.Site
+	site.CertPath, site.KeyPath, site.ChainPath, site.Pairs = d.Pair.CertPath, d.Pair.KeyPath, d.Pair.ChainPath, nil
+	return site
+}
+
+// diffInventory 比对配置中的站点与证书记录（匹配规则与 find 添加时一致）：
+// 站点的每组证书先按部署路径、再按密钥类型匹配该站点域名的证书记录，路径匹配为一致，仅密钥类型匹配为路径不一致，均未匹配为未纳管；
+// 有部署位置、但未被任何站点匹配的证书记录为配置已移除（仅部署到 SSH 目标等无本地部署位置的记录不参与比对）
+func diffInventory(sites []nginxSite, certs []db.Certificate) []driftItem {
+	var items []driftItem
+	matched := map[int]bool{}
+	for _, site := range sites {
+		var cands []db.Certificate
+		for _, c := range certs {
+			if containsString(site.Domains, c.Domain) {
+				cands = append(cands, c)
+			}
+		}
+		for _, p := range site.certPairs() {
+			if p.KeyType == "" {
+				p.KeyType = localKeyType(p.CertPath)
+			}
+			item := driftItem{Kind: driftUnmanaged, Site: site, Pair: p}
+			for _, c := range cands {
+				if hasDeployment(c, p.CertPath) {
+					item.Kind, item.Cert = driftNone, c
+					break
+				}
+			}
+			if item.Kind == driftUnmanaged {
+				for _, c := range cands {
+					if p.KeyType == "" || c.KeyType == "" || c.KeyType == p.KeyType {
+						item.Kind, item.Cert = driftPath, c
+						break
+					}
+				}
+			}
+			if item.Kind != driftUnmanaged {
+				matched[item.Cert.ID] = true
+			}
+			items = append(items, item)
+		}
+	}
+	for _, c := range certs {
+		if !matched[c.ID] && len(c.Deployments) > 0 {
+			items = append(items, driftItem{Kind: driftRemoved, Cert: c})
+		}
+	}
+	return items
+}
+
+// driftOnly 过滤出存在漂移的比对结果
+func driftOnly(items []driftItem) []driftItem {
+	var out []driftItem
+	for _, d := range items {
+		if d.Kind != driftNone {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// detectDrift 检索默认配置路径（及 extra 中的自定义路径）并与数据库比对
+func detectDrift(extra []string) ([]driftItem, error) {
+	certs, err := db.GetAllCertificatesWrapper()
+	if err != nil {
+		return nil, fmt.Errorf("获取证书信息失败: %s", err)
+	}
+	paths := append(append([]string(nil), defaultNginxPaths...), extra...)
+	return diffInventory(findNginxConfigs(paths), certs), nil
+}
+
+// scanCommand scan 命令：列出配置中的站点与纳管状态；diff 时仅列出漂移，apply 时按配置修正数据库。
+// 未修正的漂移返回错误（非零退出码，便于脚本检测）
+func scanCommand(extra []string, diff, apply bool) error {
+	items, err := detectDrift(extra)
+	if err != nil {
+		return err
+	}
+	drift := driftOnly(items)
+	if diff || apply {
+		items = drift
+	}
+	if len(items) > 0 {
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"状态", "证书ID", "域名", "说明"})
+		table.SetAutoWrapText(false)
+		for _, d := range items {
+			id := "-"
+			if d.Kind != driftUnmanaged {
+				id = fmt.Sprint(d.Cert.ID)
+			}
+			table.Append([]string{driftKindNames[d.Kind], id, d.domain(), d.Summary()})
+		}
+		table.Render()
+	}
+	if len(drift) == 0 {
+		color.Green("配置与数据库一致\n")
+		return nil
+	}
+	if !apply {
+		return fmt.Errorf("发现 %d 处配置漂移，可执行 scan --apply 按配置修正", len(drift))
+	}
+	applyDrift(drift)
+	return nil
+}
+
+// applyDrift 按配置修正数据库：未纳管的站点添加为证书，路径不一致的追加配置中的路径为部署位置（原部署位置保留），
+// 配置已移除的证书确认后删除记录（证书文件保留；非交互环境下不删除）
+func applyDrift(items []driftItem) {
+	for _, d := range items {
+		switch d.Kind {
+		case driftUnmanaged, driftPath:
+			addSiteFromNginx(d.pairSite())
+			if d.Kind == driftPath {
+				color.Yellow("原部署位置已保留，不再使用时可执行 deploy del %d <序号> 删除\n", d.Cert.ID)
+			}
+		case driftRemoved:
+			label := certLabel(d.Cert.Domain, d.Cert.KeyType)
+			if !utils.IsInteractive() {
+				color.Yellow("非交互环境下不删除证书 %s 的记录，请确认后执行 del 删除\n", label)
+				continue
+			}
+			if !utils.Confirm(fmt.Sprintf("配置中已找不到域名 %s 的站点，是否删除证书记录（证书文件保留）", label)) {
+				continue
+			}
+			if err := db.DeleteCertificateFromDBWrapper(d.Cert.ID); err != nil {
+				color.Red("删除证书 %s 失败: %s\n", label, err)
+				continue
+			}
+			color.Green("已删除证书 %s 的记录\n", label)
+		}
+	}
+}
+
+// driftCronSpec 配置漂移检测任务的调度时间（每天 3:30，在证书更新任务之前）
+const driftCronSpec = "30 3 * * *"
+
+// driftCheckEnabled 证书更新任务是否执行配置漂移检测（drift_check = 0 关闭）
+func driftCheckEnabled() bool {
+	v, _ := config.GetConfig("", "drift_check")
+	return strings.TrimSpace(v) != "0"
+}
+
+// checkDrift 配置漂移检测任务：只报告、不修正，漂移通过通知渠道发送
+func checkDrift() error {
+	items, err := detectDrift(nil)
+	if err != nil {
+		return err
+	}
+	drift := driftOnly(items)
+	if len(drift) == 0 {
+		fmt.Println("配置与数据库一致")
+		return nil
+	}
+	batch := notify.NewBatch()
+	for _, d := range drift {
+		fmt.Printf("%s %s: %s\n", driftKindNames[d.Kind], d.domain(), d.Summary())
+		batch.Add(notify.EventDrift, d.domain(), "%s：%s", driftKindNames[d.Kind], d.Summary())
+	}
+	flushNotifications(batch)
+	return fmt.Errorf("发现 %d 处配置漂移，可执行 scan --apply 按配置修正", len(drift))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"ssl_assistant/db"
+	"testing"
+)
+
+// genCertIn 在 dir 下生成自签证书（目录不存在时创建）
+func genCertIn(t *testing.T, dir, domain string, ecdsa bool) (string, string) {
+	t.Helper()
+	os.MkdirAll(dir, 0755)
+	if ecdsa {
+		return genSelfSignedECDSACert(t, dir, domain, 30)
+	}
+	return genSelfSignedCert(t, dir, domain, 30)
+}
+
+// 配置与证书记录比对：路径一致、路径改动、新增站点、站点移除；无本地部署位置的记录不参与比对
+func TestDiffInventory(t *testing.T) {
+	dir := t.TempDir()
+	aCert, aKey := genCertIn(t, filepath.Join(dir, "a"), "a.com", false)
+	bCert, bKey := genCertIn(t, filepath.Join(dir, "b"), "b.com", false)
+	cCert, cKey := genCertIn(t, filepath.Join(dir, "c"), "c.com", false)
+	sites := []nginxSite{
+		{Domain: "a.com", Domains: []string{"a.com", "www.a.com"}, CertPath: aCert, KeyPath: aKey, Location: "a.conf:3"},
+		{Domain: "www.b.com", Domains: []string{"www.b.com", "b.com"}, CertPath: bCert, KeyPath: bKey, Location: "b.conf:5"},
+		{Domain: "c.com", Domains: []string{"c.com"}, CertPath: cCert, KeyPath: cKey, Location: "c.conf:7"},
+	}
+	certs := []db.Certificate{
+		{ID: 1, Domain: "a.com", KeyType: "rsa", Deployments: []db.Deployment{{CertPath: aCert, KeyPath: aKey}}},
+		{ID: 2, Domain: "b.com", KeyType: "rsa", Deployments: []db.Deployment{{CertPath: "/old/b.pem", KeyPath: "/old/b.key"}}},
+		{ID: 3, Domain: "gone.com", KeyType: "rsa", Deployments: []db.Deployment{{CertPath: "/etc/ssl/gone.pem", KeyPath: "/etc/ssl/gone.key"}}},
+		{ID: 4, Domain: "remote.com", Targets: db.DeployTargets{{Type: targetSSH, Format: "fullchain", Path: "/etc/ssl/r.pem"}}},
+	}
+	items := diffInventory(sites, certs)
+	got := map[string]driftItem{}
+	for _, d := range items {
+		got[d.Kind+" "+d.domain()] = d
+	}
+	if len(items) != 4 {
+		t.Fatalf("应有 4 条比对结果: %+v", got)
+	}
+	if d, ok := got[driftNone+" a.com（RSA）"]; !ok || d.Cert.ID != 1 {
+		t.Fatalf("a.com 应一致: %+v", got)
+	}
+	if d, ok := got[driftPath+" www.b.com（RSA）"]; !ok || d.Cert.ID != 2 || d.Pair.CertPath != bCert {
+		t.Fatalf("b.com 应为路径不一致（按 server_name 中的域名匹配）: %+v", got)
+	}
+	if d, ok := got[driftUnmanaged+" c.com（RSA）"]; !ok || d.Site.Location != "c.conf:7" {
+		t.Fatalf("c.com 应为未纳管: %+v", got)
+	}
+	if d, ok := got[driftRemoved+" gone.com（RSA）"]; !ok || d.Cert.ID != 3 {
+		t.Fatalf("gone.com 应为配置已移除: %+v", got)
+	}
+	if n := len(driftOnly(items)); n != 3 {
+		t.Fatalf("应有 3 处漂移，实际 %d", n)
+	}
+}
+
+// 双证书站点按密钥类型分别匹配：ECDSA 组未纳管时不影响 RSA 组
+func TestDiffInventoryKeyTypes(t *testing.T) {
+	dir := t.TempDir()
+	rsaCert, rsaKey := genCertIn(t, filepath.Join(dir, "rsa"), "d.com", false)
+	ecCert, ecKey := genCertIn(t, filepath.Join(dir, "ec"), "d.com", true)
+	sites := []nginxSite{{Domain: "d.com", Domains: []string{"d.com"}, CertPath: rsaCert, KeyPath: rsaKey,
+		Pairs: []certPair{{CertPath: rsaCert, KeyPath: rsaKey}, {CertPath: ecCert, KeyPath: ecKey}}}}
+	certs := []db.Certificate{{ID: 1, Domain: "d.com", KeyType: "rsa", Deployments: []db.Deployment{{CertPath: rsaCert, KeyPath: rsaKey}}}}
+	drift := driftOnly(diffInventory(sites, certs))
+	if len(drift) != 1 || drift[0].Kind != driftUnmanaged || drift[0].Pair.KeyType != "ecdsa" {
+		t.Fatalf("ECDSA 组应为未纳管: %+v", drift)
+	}
+}
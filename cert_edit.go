@@ -0,0 +1,489 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"ssl_assistant/db"
+	"ssl_assistant/utils"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// --- 编辑证书（edit）：修改已保存证书的部署路径、来源、平台证书ID、重载命令与提前更新天数，无需删除后重新添加 ---
+
+// editSources 可设置的证书来源（local 为按 provider_order 配置的平台顺序自动获取，manual 为手动维护、不自动更新）
+var editSources = []string{"certd", "west", "local", manualSource}
+
+// certEdit 证书修改项：nil 为不修改
+type certEdit struct {
+	Index     int     // 修改的部署位置序号（从 1 开始，见 deploy list）
+	CertPath  *string // 证书路径
+	KeyPath   *string // 私钥路径
+	ChainPath *string // 证书链路径（空串为不使用单独的证书链文件）
+	Source    *string // 证书来源
+	Providers *string // 平台获取顺序（逗号分隔，空串为使用证书来源或全局顺序）
+	CertID    *int    // 平台证书ID（0 为按域名查找）
+	ReloadCmd *string // 证书级重载命令（空串为使用全局重载命令）
+	RenewDays *int    // 证书级提前更新天数（0 为使用全局配置）
+	Move      bool    // 将原部署位置的文件移动到新路径
+}
+
+// empty 是否未指定任何修改
+func (e certEdit) empty() bool {
+	return e.CertPath == nil && e.KeyPath == nil && e.ChainPath == nil && e.Source == nil && e.Providers == nil &&
+		e.CertID == nil && e.ReloadCmd == nil && e.RenewDays == nil
+}
+
+// fileMove 部署文件移动（from 被其他证书记录引用时复制，保留原文件）
+type fileMove struct {
+	from, to string
+	copy     bool
+}
+
+// editResult 校验通过的修改：修改后的证书、需移动的文件与修改说明
+type editResult struct {
+	cert    db.Certificate
+	moves   []fileMove
+	changes []string
+	// 部署路径已修改的部署位置（序号从 0 开始，-1 为未修改）
+	deployIdx int
+}
+
+// getCertByIDOrDomain 按证书 ID 或域名查找证书；同一域名有多条记录（RSA + ECDSA 双证书）时须指定 ID
+func getCertByIDOrDomain(arg string) (db.Certificate, error) {
+	if _, err := strconv.Atoi(arg); err == nil {
+		return getCertByIDArg(arg)
+	}
+	certs, err := db.GetDomainCertificatesWrapper(arg)
+	if err != nil {
+		return db.Certificate{}, fmt.Errorf("获取证书信息失败: %s", err)
+	}
+	switch len(certs) {
+	case 0:
+		return db.Certificate{}, fmt.Errorf("域名 %s 的证书不存在", arg)
+	case 1:
+		return certs[0], nil
+	}
+	var ids []string
+	for _, c := range certs {
+		ids = append(ids, fmt.Sprintf("%d（%s）", c.ID, c.KeyType))
+	}
+	return db.Certificate{}, fmt.Errorf("域名 %s 有 %d 条证书记录，请指定证书 ID: %s", arg, len(certs), strings.Join(ids, "、"))
+}
+
+// checkWritablePath 检查部署路径可写：文件已存在时须可写入，不存在时所在目录须存在且可创建文件
+func checkWritablePath(path string) error {
+	info, err := os.Stat(path)
+	if err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("%s 是目录，请指定文件路径", path)
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return fmt.Errorf("文件 %s 不可写: %v", path, err)
+		}
+		return f.Close()
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("目录 %s 不存在", dir)
+	}
+	f, err := os.CreateTemp(dir, ".ssl_assistant-*")
+	if err != nil {
+		return fmt.Errorf("目录 %s 不可写: %v", dir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// applyCertEdit 校验修改项并应用到证书（不保存、不移动文件）
+func applyCertEdit(cert db.Certificate, e certEdit) (editResult, error) {
+	r := editResult{cert: cert, deployIdx: -1}
+	change := func(name, from, to string) {
+		if from == "" {
+			from = "（空）"
+		}
+		if to == "" {
+			to = "（空）"
+		}
+		r.changes = append(r.changes, fmt.Sprintf("%s: %s → %s", name, from, to))
+	}
+
+	if e.Source != nil && strings.TrimSpace(*e.Source) != certSourceRef(cert).String() {
+		source := strings.TrimSpace(*e.Source)
+		ref := providerRef{Type: source}
+		if source != "local" && source != manualSource {
+			var err error
+			if ref, err = parseProviderRef(source); err != nil {
+				return r, fmt.Errorf("不支持的证书来源: %s（可选 %s，平台可指定实例如 certd.prod）", source, strings.Join(editSources, " / "))
+			}
+			if err := checkProviderInstance(ref); err != nil {
+				return r, err
+			}
+		}
+		change("证书来源", certSourceRef(cert).String(), ref.String())
+		r.cert.CertSource, r.cert.ProviderInstance = ref.Type, ref.instance()
+	}
+	if e.Providers != nil {
+		list, err := parseProviders(*e.Providers)
+		if err != nil {
+			return r, err
+		}
+		for _, p := range list {
+			ref, _ := parseProviderRef(p)
+			if err := checkProviderInstance(ref); err != nil {
+				return r, err
+			}
+		}
+		if providers := strings.Join(list, ","); providers != cert.Providers {
+			change("平台获取顺序", cert.Providers, providers)
+			r.cert.Providers = providers
+		}
+	}
+	if e.CertID != nil && *e.CertID != cert.CertID {
+		if *e.CertID < 0 {
+			return r, errors.New("平台证书ID不能为负数")
+		}
+		change("平台证书ID", strconv.Itoa(cert.CertID), strconv.Itoa(*e.CertID))
+		r.cert.CertID = *e.CertID
+	}
+	if e.ReloadCmd != nil && strings.TrimSpace(*e.ReloadCmd) != cert.ReloadCmd {
+		cmd := strings.TrimSpace(*e.ReloadCmd)
+		change("重载命令", cert.ReloadCmd, cmd)
+		r.cert.ReloadCmd = cmd
+	}
+	if e.RenewDays != nil && *e.RenewDays != cert.RenewDays {
+		if *e.RenewDays < 0 {
+			return r, errors.New("提前更新天数不能为负数")
+		}
+		change("提前更新天数", strconv.Itoa(cert.RenewDays), strconv.Itoa(*e.RenewDays))
+		r.cert.RenewDays = *e.RenewDays
+	}
+
+	if e.CertPath == nil && e.KeyPath == nil && e.ChainPath == nil {
+		return r, nil
+	}
+	if len(cert.Deployments) == 0 {
+		return r, fmt.Errorf("该证书没有部署位置，请使用 deploy add %d 添加", cert.ID)
+	}
+	n := e.Index
+	if n == 0 {
+		n = 1
+	}
+	if n < 1 || n > len(cert.Deployments) {
+		return r, fmt.Errorf("序号 %d 无效（该证书共 %d 个部署位置）", n, len(cert.Deployments))
+	}
+	old := cert.Deployments[n-1]
+	d := old
+	if e.CertPath != nil {
+		d.CertPath = strings.TrimSpace(*e.CertPath)
+		// 证书与私钥合并文件只修改证书路径时，私钥路径随之修改
+		if e.KeyPath == nil && isCombinedPEM(old) {
+			d.KeyPath = d.CertPath
+		}
+	}
+	if e.KeyPath != nil {
+		d.KeyPath = strings.TrimSpace(*e.KeyPath)
+	}
+	if e.ChainPath != nil {
+		d.ChainPath = strings.TrimSpace(*e.ChainPath)
+	}
+	d, err := validateDeployment(d)
+	if err != nil {
+		return r, err
+	}
+	if d.CertPath == old.CertPath && d.KeyPath == old.KeyPath && d.ChainPath == old.ChainPath {
+		return r, nil
+	}
+	for i, other := range cert.Deployments {
+		if i != n-1 && other.CertPath == d.CertPath {
+			return r, fmt.Errorf("证书路径 %s 已是该证书的第 %d 个部署位置", d.CertPath, i+1)
+		}
+	}
+
+	pairs := []struct{ name, from, to string }{
+		{"证书路径", old.CertPath, d.CertPath},
+		{"私钥路径", old.KeyPath, d.KeyPath},
+		{"证书链路径", old.ChainPath, d.ChainPath},
+	}
+	var moved []string
+	for _, p := range pairs {
+		if p.from == p.to {
+			continue
+		}
+		change(p.name, p.from, p.to)
+		if p.to == "" || containsString(moved, p.to) {
+			continue
+		}
+		if err := checkWritablePath(p.to); err != nil {
+			return r, err
+		}
+		if !e.Move || p.from == "" {
+			continue
+		}
+		if _, err := os.Stat(p.from); err != nil {
+			continue
+		}
+		if _, err := os.Stat(p.to); err == nil {
+			return r, fmt.Errorf("目标文件 %s 已存在，无法移动（不使用 --move 时直接使用该文件）", p.to)
+		}
+		// 原文件被其他证书记录引用（多域名共用证书文件）时复制，保留原文件
+		users, err := certsUsingPaths(p.from)
+		if err != nil {
+			return r, fmt.Errorf("检查证书文件共享状态失败: %v", err)
+		}
+		shared := false
+		for _, u := range users {
+			shared = shared || u.ID != cert.ID
+		}
+		r.moves = append(r.moves, fileMove{from: p.from, to: p.to, copy: shared})
+		moved = append(moved, p.to)
+	}
+	deployments := append([]db.Deployment(nil), cert.Deployments...)
+	deployments[n-1] = d
+	r.cert.Deployments = deployments
+	r.deployIdx = n - 1
+	return r, nil
+}
+
+// copyFile 复制文件（保留权限）
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(to)
+		return err
+	}
+	return dst.Close()
+}
+
+// moveFile 移动文件：跨文件系统无法重命名时复制后删除原文件
+func moveFile(from, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+	if err := copyFile(from, to); err != nil {
+		return err
+	}
+	return os.Remove(from)
+}
+
+// applyFileMoves 移动（或复制）部署文件；某个文件失败时撤销已完成的移动
+func applyFileMoves(moves []fileMove) error {
+	for i, m := range moves {
+		var err error
+		if m.copy {
+			err = copyFile(m.from, m.to)
+		} else {
+			err = moveFile(m.from, m.to)
+		}
+		if err != nil {
+			undoFileMoves(moves[:i])
+			return fmt.Errorf("移动文件 %s 到 %s 失败: %v", m.from, m.to, err)
+		}
+	}
+	return nil
+}
+
+// undoFileMoves 撤销已完成的移动（复制的文件直接删除）
+func undoFileMoves(moves []fileMove) {
+	for i := len(moves) - 1; i >= 0; i-- {
+		m := moves[i]
+		var err error
+		if m.copy {
+			err = os.Remove(m.to)
+		} else {
+			err = moveFile(m.to, m.from)
+		}
+		if err != nil {
+			color.Yellow("还原文件 %s 失败: %v\n", m.from, err)
+		}
+	}
+}
+
+// editCommand edit 命令：未指定修改项时逐项询问（当前值为默认值）
+func editCommand(arg string, e certEdit) error {
+	cert, err := getCertByIDOrDomain(arg)
+	if err != nil {
+		return err
+	}
+	if e.empty() {
+		if !utils.IsInteractive() && utils.TUIReadInput == nil {
+			return errors.New("请指定要修改的参数（--cert / --key / --chain / --source / --providers / --cert-id / --reload / --renew-days）")
+		}
+		if e, err = editForm(cert); err != nil {
+			return err
+		}
+	}
+	return saveCertEdit(cert, e)
+}
+
+// saveCertEdit 校验并保存修改：先移动文件再保存记录，保存失败时还原文件
+func saveCertEdit(cert db.Certificate, e certEdit) error {
+	r, err := applyCertEdit(cert, e)
+	if err != nil {
+		return err
+	}
+	label := certLabel(cert.Domain, cert.KeyType)
+	if len(r.changes) == 0 {
+		color.Yellow("域名 %s 的证书信息未修改\n", label)
+		return nil
+	}
+	if err := applyFileMoves(r.moves); err != nil {
+		return err
+	}
+	if err := db.UpdateCertificateInDBWrapper(r.cert); err != nil {
+		undoFileMoves(r.moves)
+		return fmt.Errorf("保存证书信息失败: %s", err)
+	}
+	color.Green("已修改域名 %s 的证书信息:\n", label)
+	for _, c := range r.changes {
+		fmt.Printf("  %s\n", c)
+	}
+	for _, m := range r.moves {
+		if m.copy {
+			fmt.Printf("  已复制 %s → %s（原文件被其他证书记录引用，已保留）\n", m.from, m.to)
+		} else {
+			fmt.Printf("  已移动 %s → %s\n", m.from, m.to)
+		}
+	}
+	if r.deployIdx < 0 {
+		return nil
+	}
+	// 新路径尚无证书文件时立即写入数据库中的证书（否则到期前不会更新，新路径一直缺少文件）
+	d := r.cert.Deployments[r.deployIdx]
+	if _, err := os.Stat(d.CertPath); err != nil && r.cert.PublicKey != "" {
+		if err := writeDeploymentFiles(r.cert, d); err != nil {
+			return err
+		}
+	}
+	color.Yellow("Web 服务器配置仍指向原路径时请修改配置（或执行 add --configure 改用托管路径并自动修改配置）并执行重载命令\n")
+	return nil
+}
+
+// editForm 交互式编辑：逐项询问，直接回车保留当前值
+func editForm(cert db.Certificate) (certEdit, error) {
+	var e certEdit
+	ask := func(prompt, current string) string {
+		return utils.ReadInput(fmt.Sprintf("%s（当前: %s，回车保留）: ", prompt, displayOrDash(current)), current)
+	}
+	askInt := func(prompt string, current int) (int, error) {
+		s := ask(prompt, strconv.Itoa(current))
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("%s必须是非负整数", strings.TrimPrefix(prompt, "请输入"))
+		}
+		return v, nil
+	}
+
+	if len(cert.Deployments) > 0 {
+		e.Index = 1
+		if len(cert.Deployments) > 1 {
+			for i, d := range cert.Deployments {
+				fmt.Printf("  %d. %s\n", i+1, d.CertPath)
+			}
+			n, err := strconv.Atoi(utils.ReadInput(fmt.Sprintf("请输入要修改的部署位置序号(1-%d，默认: 1): ", len(cert.Deployments)), "1"))
+			if err != nil || n < 1 || n > len(cert.Deployments) {
+				return e, fmt.Errorf("部署位置序号无效")
+			}
+			e.Index = n
+		}
+		d := cert.Deployments[e.Index-1]
+		if v := ask("请输入证书路径", d.CertPath); v != d.CertPath {
+			e.CertPath = &v
+		}
+		if !isCombinedPEM(d) || e.CertPath == nil {
+			if v := ask("请输入私钥路径", d.KeyPath); v != d.KeyPath {
+				e.KeyPath = &v
+			}
+		}
+		if e.CertPath != nil || e.KeyPath != nil {
+			e.Move = utils.Confirm("是否将原文件移动到新路径")
+		}
+	}
+
+	if v := ask("请输入证书来源(certd / west / local / manual，平台可指定实例如 certd.prod)", certSourceRef(cert).String()); v != certSourceRef(cert).String() {
+		e.Source = &v
+	}
+	if v := ask("请输入平台获取顺序(如 certd.prod,west，输入 - 清空)", cert.Providers); v != cert.Providers {
+		if v == "-" {
+			v = ""
+		}
+		e.Providers = &v
+	}
+	id, err := askInt("请输入平台证书ID(0 为按域名查找)", cert.CertID)
+	if err != nil {
+		return e, err
+	}
+	if id != cert.CertID {
+		e.CertID = &id
+	}
+	if v := ask("请输入证书重载命令(输入 - 清空，使用全局重载命令)", cert.ReloadCmd); v != cert.ReloadCmd {
+		if v == "-" {
+			v = ""
+		}
+		e.ReloadCmd = &v
+	}
+	days, err := askInt("请输入提前更新天数(0 为使用全局配置)", cert.RenewDays)
+	if err != nil {
+		return e, err
+	}
+	if days != cert.RenewDays {
+		e.RenewDays = &days
+	}
+	return e, nil
+}
+
+// displayOrDash 空值显示为 -
+func displayOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// editCertificate 菜单「编辑证书」：输入证书 ID 或域名后逐项修改
+func editCertificate() error {
+	if err := initGuide(false); err != nil {
+		return err
+	}
+	arg := utils.ReadInput("请输入证书 ID 或域名: ", "")
+	if arg == "" {
+		return errors.New("证书 ID 或域名不能为空")
+	}
+	cert, err := getCertByIDOrDomain(arg)
+	if err != nil {
+		color.Red("%v\n", err)
+		return err
+	}
+	e, err := editForm(cert)
+	if err != nil {
+		color.Red("%v\n", err)
+		return err
+	}
+	if err := saveCertEdit(cert, e); err != nil {
+		color.Red("%v\n", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"ssl_assistant/db"
+	"strings"
+	"testing"
+)
+
+// 部署路径可写检查：已存在的文件、目录存在的新文件可写；目录不存在或路径为目录时报错
+func TestCheckWritablePath(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "a.pem")
+	os.WriteFile(existing, []byte("x"), 0644)
+	if err := checkWritablePath(existing); err != nil {
+		t.Fatalf("已存在的文件应可写: %v", err)
+	}
+	if err := checkWritablePath(filepath.Join(dir, "new.pem")); err != nil {
+		t.Fatalf("目录存在时新文件应可写: %v", err)
+	}
+	if err := checkWritablePath(filepath.Join(dir, "missing", "a.pem")); err == nil {
+		t.Fatal("目录不存在应报错")
+	}
+	if err := checkWritablePath(dir); err == nil {
+		t.Fatal("路径为目录应报错")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("检查后不应遗留临时文件: %v", entries)
+	}
+}
+
+// 校验并应用修改：未变化的值不记录，来源与天数校验，合并文件只改证书路径时私钥路径随之修改
+func TestApplyCertEdit(t *testing.T) {
+	dir := t.TempDir()
+	str := func(s string) *string { return &s }
+	num := func(n int) *int { return &n }
+	cert := db.Certificate{ID: 3, Domain: "e.com", CertSource: "certd", CertID: 5, Deployments: []db.Deployment{
+		{CertPath: filepath.Join(dir, "e.pem"), KeyPath: filepath.Join(dir, "e.key")},
+		{CertPath: filepath.Join(dir, "e-combined.pem"), KeyPath: filepath.Join(dir, "e-combined.pem")},
+	}}
+
+	r, err := applyCertEdit(cert, certEdit{Source: str("certd"), CertID: num(5), ReloadCmd: str(" "), RenewDays: num(0)})
+	if err != nil || len(r.changes) != 0 || r.deployIdx != -1 {
+		t.Fatalf("未变化的值不应记录修改: %v %v", r.changes, err)
+	}
+	if _, err := applyCertEdit(cert, certEdit{Source: str("acme")}); err == nil {
+		t.Fatal("不支持的来源应报错")
+	}
+	if r, err := applyCertEdit(cert, certEdit{Source: str("manual")}); err != nil || r.cert.CertSource != "manual" {
+		t.Fatalf("应可改为手动维护: %+v %v", r.cert, err)
+	}
+	if _, err := applyCertEdit(cert, certEdit{RenewDays: num(-1)}); err == nil {
+		t.Fatal("负数天数应报错")
+	}
+	r, err = applyCertEdit(cert, certEdit{Source: str("west"), CertID: num(0), ReloadCmd: str("systemctl reload postfix"), RenewDays: num(20)})
+	if err != nil || len(r.changes) != 4 {
+		t.Fatalf("应记录 4 项修改: %v %v", r.changes, err)
+	}
+	if r.cert.CertSource != "west" || r.cert.CertID != 0 || r.cert.ReloadCmd != "systemctl reload postfix" || r.cert.RenewDays != 20 {
+		t.Fatalf("修改未应用: %+v", r.cert)
+	}
+
+	newDir := filepath.Join(dir, "new")
+	os.MkdirAll(newDir, 0755)
+	combined := filepath.Join(newDir, "e.pem")
+	r, err = applyCertEdit(cert, certEdit{Index: 2, CertPath: str(combined)})
+	if err != nil || r.deployIdx != 1 {
+		t.Fatalf("修改第 2 个部署位置失败: %v", err)
+	}
+	if d := r.cert.Deployments[1]; d.CertPath != combined || d.KeyPath != combined {
+		t.Fatalf("合并文件的私钥路径应随证书路径修改: %+v", d)
+	}
+	if cert.Deployments[1].CertPath == combined {
+		t.Fatal("不应修改原证书的部署位置")
+	}
+	if _, err := applyCertEdit(cert, certEdit{Index: 3, CertPath: str(combined)}); err == nil {
+		t.Fatal("序号超出范围应报错")
+	}
+	if _, err := applyCertEdit(cert, certEdit{CertPath: str(cert.Deployments[1].CertPath)}); err == nil {
+		t.Fatal("与其他部署位置的证书路径相同应报错")
+	}
+	if _, err := applyCertEdit(cert, certEdit{CertPath: str(filepath.Join(dir, "missing", "e.pem"))}); err == nil {
+		t.Fatal("目录不存在应报错")
+	}
+	if _, err := applyCertEdit(db.Certificate{ID: 4}, certEdit{CertPath: str(combined)}); err == nil || !strings.Contains(err.Error(), "deploy add") {
+		t.Fatalf("无部署位置时应提示 deploy add: %v", err)
+	}
+}
+
+// 移动部署文件：复制时保留原文件；失败时撤销已完成的移动
+func TestApplyFileMoves(t *testing.T) {
+	dir := t.TempDir()
+	a, b, c := filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")
+	os.WriteFile(a, []byte("A"), 0600)
+	os.WriteFile(b, []byte("B"), 0644)
+	if err := applyFileMoves([]fileMove{{from: a, to: a + ".new"}, {from: b, to: b + ".new", copy: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(a); !os.IsNotExist(err) {
+		t.Fatal("移动后原文件应不存在")
+	}
+	if data, _ := os.ReadFile(b); string(data) != "B" {
+		t.Fatal("复制后应保留原文件")
+	}
+	if info, err := os.Stat(a + ".new"); err != nil || (runtime.GOOS != "windows" && info.Mode().Perm() != 0600) {
+		t.Fatalf("移动后应保留权限: %v", err)
+	}
+
+	err := applyFileMoves([]fileMove{{from: a + ".new", to: a}, {from: c, to: c + ".new"}})
+	if err == nil {
+		t.Fatal("原文件不存在应报错")
+	}
+	if _, err := os.Stat(a + ".new"); err != nil {
+		t.Fatal("失败时应撤销已完成的移动")
+	}
+}
+
+// 重载已部署证书：相同命令只执行一次，证书级命令为空时使用全局重载命令
+func TestReloadCertificates(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("重载命令依赖 POSIX shell")
+	}
+	log := filepath.Join(t.TempDir(), "reload.log")
+	setConfig(t, "", "restart_cmd", "echo global >> "+log)
+	postfix := "echo postfix >> " + log
+	certs := []db.Certificate{{ID: 1}, {ID: 2, ReloadCmd: postfix}, {ID: 3}, {ID: 4, ReloadCmd: postfix}}
+	if err := reloadCertificates(certs); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(log); string(data) != "global\npostfix\n" {
+		t.Fatalf("每条重载命令应只执行一次: %q", data)
+	}
+	if err := reloadCertificates([]db.Certificate{{ReloadCmd: "exit 3"}, {}}); err == nil {
+		t.Fatal("重载命令失败应返回错误")
+	}
+	if data, _ := os.ReadFile(log); string(data) != "global\npostfix\nglobal\n" {
+		t.Fatalf("某条命令失败时仍应执行其余命令: %q", data)
+	}
+}
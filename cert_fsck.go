@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"ssl_assistant/db"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+)
+
+// dbFsckCommand db fsck 命令：检查数据库一致性（BadgerDB 的索引与记录、SQLite 的完整性与孤立部署位置），
+// repair 时修复可自动修复的问题。存在未修复的问题时返回错误（非零退出码）
+func dbFsckCommand(repair bool) error {
+	report, err := db.CheckDatabaseWrapper(repair)
+	if err != nil {
+		return fmt.Errorf("检查数据库失败: %v", err)
+	}
+	color.Cyan("数据库: %s (%s)，证书记录 %d 条\n", report.Mode, db.DBPath(), report.Records)
+	if len(report.Issues) == 0 {
+		color.Green("未发现问题\n")
+		return nil
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"键", "问题", "处理"})
+	table.SetAutoWrapText(false)
+	for _, issue := range report.Issues {
+		state := "未修复"
+		if issue.Repaired {
+			state = "已修复"
+		}
+		table.Append([]string{issue.Key, issue.Problem, state})
+	}
+	table.Render()
+	if n := report.Unrepaired(); n > 0 {
+		if !repair {
+			return fmt.Errorf("发现 %d 个问题，可执行 db fsck --repair 修复", n)
+		}
+		return fmt.Errorf("%d 个问题无法自动修复，请手动处理", n)
+	}
+	color.Green("已修复 %d 个问题\n", len(report.Issues))
+	return nil
+}
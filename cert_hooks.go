@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"ssl_assistant/config"
+	"ssl_assistant/db"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+)
+
+// --- 钩子命令：在证书获取、部署、重载的各阶段执行用户命令（全局钩子配置在 [hooks]，证书级钩子保存在证书记录中） ---
+
+// 钩子阶段
+const (
+	hookPreFetch   = "pre-fetch"   // 从平台获取证书前，失败则跳过该证书
+	hookPreDeploy  = "pre-deploy"  // 写入证书文件前，失败则放弃部署该证书（不保存、不写入）
+	hookPostDeploy = "post-deploy" // 写入证书文件后，失败计为该证书更新失败（文件已写入，仍会重载）
+	hookPostReload = "post-reload" // 执行重载命令后（每个已部署的证书各执行一次），失败计为该证书更新失败
+)
+
+// hookStages 全部钩子阶段（按执行顺序）
+var hookStages = []string{hookPreFetch, hookPreDeploy, hookPostDeploy, hookPostReload}
+
+// defaultHookTimeout 钩子命令默认超时时间
+const defaultHookTimeout = 60 * time.Second
+
+// shellCommand 通过系统 shell 执行命令，支持引号、管道、$() 等语法（重载命令与钩子命令共用）。
+// 超时只能结束 shell 本身，其子进程可能仍占用输出管道；WaitDelay 保证超时后不再等待输出
+func shellCommand(ctx context.Context, cmdline string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", cmdline)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", cmdline)
+	}
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
+// hookConfigKey 阶段对应的配置项 key（pre-fetch → pre_fetch）
+func hookConfigKey(stage string) string {
+	return strings.ReplaceAll(stage, "-", "_")
+}
+
+// certHook 证书级钩子命令
+func certHook(h db.CertHooks, stage string) string {
+	switch stage {
+	case hookPreFetch:
+		return h.PreFetch
+	case hookPreDeploy:
+		return h.PreDeploy
+	case hookPostDeploy:
+		return h.PostDeploy
+	case hookPostReload:
+		return h.PostReload
+	}
+	return ""
+}
+
+// setCertHook 设置证书级钩子命令（cmd 为空即删除）
+func setCertHook(h *db.CertHooks, stage, cmd string) error {
+	switch stage {
+	case hookPreFetch:
+		h.PreFetch = cmd
+	case hookPreDeploy:
+		h.PreDeploy = cmd
+	case hookPostDeploy:
+		h.PostDeploy = cmd
+	case hookPostReload:
+		h.PostReload = cmd
+	default:
+		return fmt.Errorf("钩子阶段 %s 无效，可选: %s", stage, strings.Join(hookStages, " / "))
+	}
+	return nil
+}
+
+// hookTimeout 钩子命令超时时间（[hooks] timeout，单位秒）
+func hookTimeout() time.Duration {
+	v, _ := config.GetConfig("hooks", "timeout")
+	if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	return defaultHookTimeout
+}
+
+// hookEnv 钩子命令的环境变量：证书信息（部署到多个位置时路径取第一个部署位置）
+func hookEnv(stage string, cert db.Certificate) []string {
+	var certPath, keyPath string
+	if len(cert.Deployments) > 0 {
+		certPath, keyPath = cert.Deployments[0].CertPath, cert.Deployments[0].KeyPath
+	}
+	notAfter := ""
+	if cert.ExpireTime > 0 {
+		notAfter = time.Unix(cert.ExpireTime, 0).UTC().Format(time.RFC3339)
+	}
+	return []string{
+		"SSL_HOOK=" + stage,
+		"SSL_DOMAIN=" + cert.Domain,
+		"SSL_CERT_PATH=" + certPath,
+		"SSL_KEY_PATH=" + keyPath,
+		"SSL_NOT_AFTER=" + notAfter,
+		"SSL_SOURCE=" + cert.CertSource,
+		"SSL_SERIAL=" + pemSerial(cert.PublicKey),
+	}
+}
+
+// pemSerial PEM 证书（第一张）的序列号；无法解析时为空
+func pemSerial(certPEM string) string {
+	block, _ := pem.Decode([]byte(certPEM))
+	if block == nil {
+		return ""
+	}
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return ""
+	}
+	return certSerial(leaf)
+}
+
+// runHooks 执行某阶段的钩子：先全局钩子，再证书级钩子；任一失败（退出码非 0 或超时）即停止并返回错误
+func runHooks(stage string, cert db.Certificate) error {
+	global, _ := config.GetConfig("hooks", hookConfigKey(stage))
+	for _, cmd := range []string{global, certHook(cert.Hooks, stage)} {
+		if strings.TrimSpace(cmd) == "" {
+			continue
+		}
+		if err := runHook(stage, cmd, cert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runHook 执行单条钩子命令，输出原样打印
+func runHook(stage, cmdline string, cert db.Certificate) error {
+	timeout := hookTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := shellCommand(ctx, cmdline)
+	cmd.Env = append(os.Environ(), hookEnv(stage, cert)...)
+	output, err := cmd.CombinedOutput()
+	if out := strings.TrimSpace(string(output)); out != "" {
+		fmt.Println(out)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%s 钩子执行超时（%s）: %s", stage, timeout, cmdline)
+	}
+	if err != nil {
+		return fmt.Errorf("%s 钩子执行失败（%v）: %s", stage, err, cmdline)
+	}
+	return nil
+}
+
+// runPostReloadHooks 重载成功后为每个已部署的证书执行 post-reload 钩子，返回失败的错误
+func runPostReloadHooks(deployed []db.Certificate) []error {
+	var errs []error
+	for _, cert := range deployed {
+		if err := runHooks(hookPostReload, cert); err != nil {
+			color.Red("域名 %s 的%v\n", cert.Domain, err)
+			errs = append(errs, fmt.Errorf("域名 %s 的%v", cert.Domain, err))
+		}
+	}
+	return errs
+}
+
+// hookList 列出全局钩子与证书级钩子（指定证书 ID 时只列该证书）
+func hookList(args []string) error {
+	var certs []db.Certificate
+	if len(args) > 0 {
+		cert, err := getCertByIDArg(args[0])
+		if err != nil {
+			return err
+		}
+		certs = []db.Certificate{cert}
+	} else {
+		all, err := db.GetAllCertificatesWrapper()
+		if err != nil {
+			return fmt.Errorf("获取证书列表失败: %s", err)
+		}
+		certs = all
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"证书ID", "域名", "阶段", "命令"})
+	rows := 0
+	for _, stage := range hookStages {
+		if cmd, _ := config.GetConfig("hooks", hookConfigKey(stage)); strings.TrimSpace(cmd) != "" {
+			table.Append([]string{"全局", "", stage, cmd})
+			rows++
+		}
+	}
+	for _, cert := range certs {
+		for _, stage := range hookStages {
+			if cmd := certHook(cert.Hooks, stage); cmd != "" {
+				table.Append([]string{strconv.Itoa(cert.ID), cert.Domain, stage, cmd})
+				rows++
+			}
+		}
+	}
+	if rows == 0 {
+		color.Yellow("暂无钩子命令，可通过 hook set <证书ID> <阶段> <命令> 添加，全局钩子配置在 config/conf.ini 的 [hooks]\n")
+		return nil
+	}
+	table.Render()
+	fmt.Printf("钩子超时时间: %s\n", hookTimeout())
+	return nil
+}
+
+// hookSet 设置证书级钩子命令（cmd 为空即删除）
+func hookSet(idArg, stage, cmd string) error {
+	cert, err := getCertByIDArg(idArg)
+	if err != nil {
+		return err
+	}
+	cmd = strings.TrimSpace(cmd)
+	if err := setCertHook(&cert.Hooks, stage, cmd); err != nil {
+		return err
+	}
+	if err := db.UpdateCertificateInDBWrapper(cert); err != nil {
+		return fmt.Errorf("保存钩子命令失败: %s", err)
+	}
+	if cmd == "" {
+		color.Green("已删除域名 %s 的 %s 钩子\n", certLabel(cert.Domain, cert.KeyType), stage)
+		return nil
+	}
+	color.Green("已设置域名 %s 的 %s 钩子: %s\n", certLabel(cert.Domain, cert.KeyType), stage, cmd)
+	return nil
+}
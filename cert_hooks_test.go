@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"ssl_assistant/db"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 钩子按全局 → 证书级的顺序执行，环境变量包含证书信息；失败即停止
+func TestRunHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("钩子测试命令依赖 POSIX shell")
+	}
+	dir := t.TempDir()
+	certPath, keyPath := genSelfSignedCert(t, dir, "hook.com", 30)
+	cert, err := buildCertFromLocalFiles("hook.com", certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "hook.log")
+	setConfig(t, "hooks", "pre_deploy", "echo global >> "+out)
+	cert.Hooks.PreDeploy = `echo "cert $SSL_HOOK $SSL_DOMAIN $SSL_CERT_PATH $SSL_KEY_PATH $SSL_NOT_AFTER $SSL_SOURCE $SSL_SERIAL" >> ` + out
+	if err := runHooks(hookPreDeploy, cert); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(out)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	notAfter := time.Unix(cert.ExpireTime, 0).UTC().Format(time.RFC3339)
+	want := strings.Join([]string{"cert", hookPreDeploy, "hook.com", certPath, keyPath, notAfter, cert.CertSource, pemSerial(cert.PublicKey)}, " ")
+	if len(lines) != 2 || lines[0] != "global" || lines[1] != want {
+		t.Fatalf("钩子执行顺序或环境变量错误:\n%s\n期望第二行: %s", data, want)
+	}
+	if pemSerial(cert.PublicKey) == "" {
+		t.Fatal("应解析出证书序列号")
+	}
+
+	// 其他阶段未配置时不执行
+	if err := runHooks(hookPostReload, cert); err != nil {
+		t.Fatal(err)
+	}
+
+	// 全局钩子失败时不再执行证书级钩子
+	os.Remove(out)
+	setConfig(t, "hooks", "pre_deploy", "exit 3")
+	err = runHooks(hookPreDeploy, cert)
+	if err == nil || !strings.Contains(err.Error(), "pre-deploy 钩子执行失败") {
+		t.Fatalf("退出码非 0 应返回错误: %v", err)
+	}
+	if _, serr := os.Stat(out); !os.IsNotExist(serr) {
+		t.Fatal("全局钩子失败后不应执行证书级钩子")
+	}
+
+	// 超时
+	setConfig(t, "hooks", "pre_deploy", "")
+	setConfig(t, "hooks", "timeout", "1")
+	cert.Hooks.PreDeploy = "sleep 5"
+	start := time.Now()
+	if err := runHooks(hookPreDeploy, cert); err == nil || !strings.Contains(err.Error(), "超时") {
+		t.Fatalf("超时应返回错误: %v", err)
+	}
+	if time.Since(start) > 4*time.Second {
+		t.Fatal("超时后应终止钩子命令")
+	}
+}
+
+// 证书级钩子的设置与阶段校验
+func TestSetCertHook(t *testing.T) {
+	var h db.CertHooks
+	for _, stage := range hookStages {
+		if err := setCertHook(&h, stage, "echo "+stage); err != nil {
+			t.Fatal(err)
+		}
+		if certHook(h, stage) != "echo "+stage {
+			t.Fatalf("%s 钩子读写不一致: %+v", stage, h)
+		}
+	}
+	if err := setCertHook(&h, "post-fetch", "x"); err == nil {
+		t.Fatal("无效阶段应报错")
+	}
+	if hookConfigKey(hookPostReload) != "post_reload" {
+		t.Fatal("配置项 key 错误")
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"ssl_assistant/db"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// --- 导入 certbot / acme.sh 签发的证书：识别其目录结构，记录原工具是否仍在自动续期；
+// 原工具续期期间更新任务读取原工具的证书文件，接管（import takeover）后停用原工具的续期、改由证书平台获取 ---
+
+// 原工具名（同时作为导入证书的 CertSource）
+const (
+	toolCertbot = "certbot"
+	toolAcmeSh  = "acme.sh"
+)
+
+// certbotRoots certbot 配置目录（live/<证书名>/ 与 renewal/<证书名>.conf）
+var certbotRoots = []string{"/etc/letsencrypt"}
+
+// certbotSchedulers certbot 自动续期的定时任务（系统包的 cron / systemd timer、snap 安装）
+var certbotSchedulers = []string{
+	"/etc/cron.d/certbot",
+	"/lib/systemd/system/certbot.timer",
+	"/usr/lib/systemd/system/certbot-renew.timer",
+	"/etc/systemd/system/snap.certbot.renew.timer",
+}
+
+// acmeShSchedulers acme.sh 自动续期的系统定时任务（acme.sh --install-cronjob 默认写入用户 crontab，见 userCrontab）
+var acmeShSchedulers = []string{"/etc/cron.d/acme.sh"}
+
+// userCrontab 当前用户的 crontab 内容（acme.sh --install-cronjob、手动添加的 certbot renew 写在这里；测试时可替换）
+var userCrontab = func() string {
+	out, _ := exec.Command("crontab", "-l").Output()
+	return string(out)
+}
+
+// acmeShRoots acme.sh 证书目录（当前用户与 root 的默认安装目录 ~/.acme.sh）
+var acmeShRoots = func() []string {
+	roots := []string{"/root/.acme.sh"}
+	if home, err := os.UserHomeDir(); err == nil && !containsString(roots, filepath.Join(home, ".acme.sh")) {
+		roots = append([]string{filepath.Join(home, ".acme.sh")}, roots...)
+	}
+	return roots
+}
+
+// scheduled 定时任务文件存在，或用户 crontab 中包含 keyword
+func scheduled(files []string, keyword string) bool {
+	for _, f := range files {
+		if _, err := os.Stat(f); err == nil {
+			return true
+		}
+	}
+	return strings.Contains(userCrontab(), keyword)
+}
+
+// readKeyValueFile 读取 key = value / key='value' 格式的配置（certbot renewal 配置、acme.sh 域名配置）：
+// 忽略注释与 [section] 标题，值去除引号
+func readKeyValueFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	kv := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		kv[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `'"`)
+	}
+	return kv, scanner.Err()
+}
+
+// lineageSuffix certbot 重复申请同名证书时追加的序号（example.com-0001）
+var lineageSuffix = regexp.MustCompile(`-\d{4}$`)
+
+// scanCertbot 扫描 certbot 的 live/<证书名>/：路径以 renewal 配置为准，
+// 续期配置存在、未设置 autorenew = False 且存在续期定时任务时视为原工具仍在续期
+func scanCertbot(root string) []nginxSite {
+	var sites []nginxSite
+	matches, _ := filepath.Glob(filepath.Join(root, "live", "*", "fullchain.pem"))
+	for _, fullchain := range matches {
+		name := filepath.Base(filepath.Dir(fullchain))
+		imp := db.CertImport{Tool: toolCertbot, Name: name, CertPath: fullchain, KeyPath: filepath.Join(filepath.Dir(fullchain), "privkey.pem")}
+		conf := filepath.Join(root, "renewal", name+".conf")
+		if kv, err := readKeyValueFile(conf); err == nil {
+			imp.ConfPath = conf
+			if kv["fullchain"] != "" && kv["privkey"] != "" {
+				imp.CertPath, imp.KeyPath = kv["fullchain"], kv["privkey"]
+			}
+			imp.Renewing = !strings.EqualFold(kv["autorenew"], "false") && scheduled(certbotSchedulers, "certbot")
+		}
+		if site, ok := importedSite(imp, lineageSuffix.ReplaceAllString(name, ""), imp.CertPath, imp.KeyPath); ok {
+			sites = append(sites, site)
+		}
+	}
+	return sites
+}
+
+// scanAcmeSh 扫描 acme.sh 的 <域名>/ 与 <域名>_ecc/ 目录：
+// 执行过 --install-cert 时部署位置取安装路径（Le_RealFullChainPath / Le_RealKeyPath），否则取 acme.sh 目录内的文件；
+// 域名配置存在且安装了续期定时任务时视为原工具仍在续期（--remove 后配置被改名为 .conf.removed）
+func scanAcmeSh(root string) []nginxSite {
+	var sites []nginxSite
+	matches, _ := filepath.Glob(filepath.Join(root, "*", "*.conf"))
+	for _, conf := range matches {
+		dir := filepath.Dir(conf)
+		name := filepath.Base(dir)
+		if strings.TrimSuffix(filepath.Base(conf), ".conf") != strings.TrimSuffix(name, "_ecc") {
+			continue // account.conf、ca 目录等
+		}
+		kv, err := readKeyValueFile(conf)
+		if err != nil || kv["Le_Domain"] == "" {
+			continue
+		}
+		domain := kv["Le_Domain"]
+		imp := db.CertImport{
+			Tool:     toolAcmeSh,
+			Name:     name,
+			ConfPath: conf,
+			CertPath: filepath.Join(dir, "fullchain.cer"),
+			KeyPath:  filepath.Join(dir, domain+".key"),
+			Renewing: scheduled(acmeShSchedulers, "acme.sh"),
+		}
+		certPath, keyPath := imp.CertPath, imp.KeyPath
+		if kv["Le_RealFullChainPath"] != "" && kv["Le_RealKeyPath"] != "" {
+			certPath, keyPath = kv["Le_RealFullChainPath"], kv["Le_RealKeyPath"]
+		}
+		if site, ok := importedSite(imp, domain, certPath, keyPath); ok {
+			sites = append(sites, site)
+		}
+	}
+	return sites
+}
+
+// importedSite 导入证书对应的站点：域名取证书 SAN（domain 在其中时作为主域名），原工具的证书或私钥文件缺失时跳过
+func importedSite(imp db.CertImport, domain, certPath, keyPath string) (nginxSite, bool) {
+	if _, err := os.Stat(imp.KeyPath); err != nil {
+		return nginxSite{}, false
+	}
+	names := localCertNames(imp.CertPath)
+	if len(names) == 0 {
+		return nginxSite{}, false
+	}
+	if !containsString(names, domain) {
+		domain = names[0]
+	}
+	location := imp.ConfPath
+	if location == "" {
+		location = filepath.Dir(imp.CertPath)
+	}
+	return nginxSite{
+		Domain:   domain,
+		Domains:  unionStrings([]string{domain}, names),
+		CertPath: certPath,
+		KeyPath:  keyPath,
+		Location: location,
+		Import:   &imp,
+	}, true
+}
+
+// scanImportedCerts 扫描 certbot 与 acme.sh 签发的证书
+func scanImportedCerts() []nginxSite {
+	var sites []nginxSite
+	for _, root := range certbotRoots {
+		sites = append(sites, scanCertbot(root)...)
+	}
+	for _, root := range acmeShRoots() {
+		sites = append(sites, scanAcmeSh(root)...)
+	}
+	return sites
+}
+
+// importedCertificate 读取原工具维护的证书文件（来源记为原工具，部署位置由调用方设置）
+func importedCertificate(domain string, imp db.CertImport) (db.Certificate, error) {
+	cert, err := buildCertFromLocalFiles(domain, imp.CertPath, imp.KeyPath)
+	if err != nil {
+		return cert, err
+	}
+	cert.CertSource = imp.Tool
+	cert.Import = imp
+	return cert, nil
+}
+
+// fetchLatestCertificate 获取证书的最新内容：原工具仍在续期的导入证书读取原工具的证书文件，其余从证书平台获取。
+// 原工具的证书同样将在 deadline 前到期时视为原工具续期失败
+func fetchLatestCertificate(cert db.Certificate, deadline int64) (db.Certificate, error) {
+	if !cert.Import.Renewing {
+		return getCertificateInfo(cert.Domain, certProviders(cert), cert.CertID, certSourceRef(cert))
+	}
+	newCert, err := importedCertificate(cert.Domain, cert.Import)
+	if err != nil {
+		return newCert, fmt.Errorf("读取 %s 的证书文件失败: %v", cert.Import.Tool, err)
+	}
+	if newCert.ExpireTime <= deadline {
+		return newCert, fmt.Errorf("%s 未能按时续期（证书 %s 到期），请检查其续期任务，或执行 import takeover %d 改由本程序从证书平台获取",
+			cert.Import.Tool, time.Unix(newCert.ExpireTime, 0).Format(time.DateOnly), cert.ID)
+	}
+	return newCert, nil
+}
+
+// certSourceLabel 证书来源展示名（平台命名实例写作 certd.prod；原工具仍在续期的导入证书标注续期中）
+func certSourceLabel(cert db.Certificate) string {
+	if cert.Import.Renewing {
+		return cert.CertSource + "（续期中）"
+	}
+	return certSourceRef(cert).String()
+}
+
+// importCertificates 检索 certbot / acme.sh 签发的证书，勾选后添加（import 命令）
+func importCertificates() error {
+	sites := scanImportedCerts()
+	if len(sites) == 0 {
+		color.Yellow("未检索到 certbot（%s）或 acme.sh（%s）签发的证书\n", strings.Join(certbotRoots, "、"), strings.Join(acmeShRoots(), "、"))
+		return nil
+	}
+	for _, s := range sites {
+		state := "原工具已停止续期"
+		if s.Import.Renewing {
+			state = "原工具续期中"
+		}
+		fmt.Printf("发现 %s 证书 %s（%s，%s）\n", s.Import.Tool, strings.Join(s.Domains, ","), s.Location, state)
+	}
+	selectAndAddNginxSites(sites)
+	return nil
+}
+
+// importTakeover 接管导入的证书：停用原工具对该证书的自动续期（续期配置改名：certbot 为 .disabled，
+// 与 acme.sh --remove 相同为 .removed；改回原名即可恢复），此后证书到期前由本程序从证书平台获取并部署
+func importTakeover(idArg string) error {
+	cert, err := getCertByIDArg(idArg)
+	if err != nil {
+		return err
+	}
+	imp := cert.Import
+	if imp.Tool == "" {
+		return fmt.Errorf("证书 %d 不是从 certbot / acme.sh 导入的", cert.ID)
+	}
+	if imp.ConfPath != "" {
+		suffix := ".disabled"
+		if imp.Tool == toolAcmeSh {
+			suffix = ".removed"
+		}
+		if _, err := os.Stat(imp.ConfPath); err == nil {
+			if err := os.Rename(imp.ConfPath, imp.ConfPath+suffix); err != nil {
+				return fmt.Errorf("停用 %s 的续期配置失败: %v", imp.Tool, err)
+			}
+			color.Green("已停用 %s 对域名 %s 的自动续期: %s → %s%s\n", imp.Tool, cert.Domain, imp.ConfPath, imp.ConfPath, suffix)
+		}
+	}
+	cert.Import.Renewing = false
+	if cert.CertSource == imp.Tool {
+		// 与本地添加的证书相同，更新时自动探测证书平台
+		cert.CertSource = "local"
+	}
+	if err := db.UpdateCertificateInDBWrapper(cert); err != nil {
+		return fmt.Errorf("保存证书信息失败: %s", err)
+	}
+	color.Green("域名 %s 的证书已由本程序接管，到期前将从证书平台获取并部署\n", certLabel(cert.Domain, cert.KeyType))
+	if imp.Tool == toolCertbot {
+		live := filepath.Dir(imp.CertPath) + string(filepath.Separator)
+		for _, d := range cert.Deployments {
+			if strings.HasPrefix(d.CertPath, live) {
+				color.Yellow("部署位置 %s 位于 certbot 的 live 目录（指向 archive 的符号链接），建议改为独立路径（deploy add）并修改服务配置\n", d.CertPath)
+			}
+		}
+	}
+	return nil
+}
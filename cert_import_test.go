@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"ssl_assistant/db"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubSchedulers 替换续期定时任务的检测：files 为定时任务文件，crontab 为用户 crontab 内容
+func stubSchedulers(t *testing.T, files []string, crontab string) {
+	t.Helper()
+	oldCertbot, oldAcme, oldCrontab := certbotSchedulers, acmeShSchedulers, userCrontab
+	certbotSchedulers, acmeShSchedulers = files, files
+	userCrontab = func() string { return crontab }
+	t.Cleanup(func() { certbotSchedulers, acmeShSchedulers, userCrontab = oldCertbot, oldAcme, oldCrontab })
+}
+
+// certbot：live/<证书名>/ 的证书按 SAN 识别域名，renewal 配置与定时任务决定是否仍在续期
+func TestScanCertbot(t *testing.T) {
+	root := t.TempDir()
+	live := filepath.Join(root, "live", "cb.com-0001")
+	os.MkdirAll(live, 0755)
+	genSelfSignedCert(t, live, "cb.com", 60)
+	os.MkdirAll(filepath.Join(root, "renewal"), 0755)
+	conf := filepath.Join(root, "renewal", "cb.com-0001.conf")
+	os.WriteFile(conf, []byte("# renew_before_expiry = 30 days\nversion = 2.1.0\nfullchain = "+filepath.Join(live, "fullchain.pem")+
+		"\nprivkey = "+filepath.Join(live, "privkey.pem")+"\n\n[renewalparams]\nauthenticator = nginx\n"), 0644)
+	os.WriteFile(filepath.Join(live, "README"), []byte("x"), 0644)
+
+	timer := filepath.Join(root, "certbot.timer")
+	os.WriteFile(timer, nil, 0644)
+	stubSchedulers(t, []string{timer}, "")
+	sites := scanCertbot(root)
+	if len(sites) != 1 {
+		t.Fatalf("应识别 1 个证书: %+v", sites)
+	}
+	s := sites[0]
+	if s.Domain != "cb.com" || !containsString(s.Domains, "www.cb.com") || s.CertPath != filepath.Join(live, "fullchain.pem") {
+		t.Fatalf("域名或路径错误: %+v", s)
+	}
+	if s.Import == nil || s.Import.Tool != toolCertbot || s.Import.Name != "cb.com-0001" || s.Import.ConfPath != conf || !s.Import.Renewing {
+		t.Fatalf("导入信息错误: %+v", s.Import)
+	}
+
+	// 无定时任务、或 autorenew = False 时原工具不再续期
+	stubSchedulers(t, nil, "")
+	if sites := scanCertbot(root); sites[0].Import.Renewing {
+		t.Fatal("没有续期定时任务时不应视为续期中")
+	}
+	stubSchedulers(t, nil, "0 */12 * * * certbot -q renew\n")
+	if sites := scanCertbot(root); !sites[0].Import.Renewing {
+		t.Fatal("crontab 中有 certbot renew 时应视为续期中")
+	}
+	f, _ := os.OpenFile(conf, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("autorenew = False\n")
+	f.Close()
+	if sites := scanCertbot(root); sites[0].Import.Renewing {
+		t.Fatal("autorenew = False 时不应视为续期中")
+	}
+}
+
+// acme.sh：<域名>_ecc/ 目录，部署位置取 --install-cert 的安装路径
+func TestScanAcmeSh(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "as.com_ecc")
+	os.MkdirAll(dir, 0755)
+	certPath, keyPath := genSelfSignedECDSACert(t, dir, "as.com", 60)
+	os.Rename(certPath, filepath.Join(dir, "fullchain.cer"))
+	os.Rename(keyPath, filepath.Join(dir, "as.com.key"))
+	os.WriteFile(filepath.Join(dir, "as.com.conf"), []byte("Le_Domain='as.com'\nLe_Alt='www.as.com'\nLe_Keylength='ec-256'\n"+
+		"Le_RealKeyPath='/etc/nginx/ssl/as.key'\nLe_RealFullChainPath='/etc/nginx/ssl/as.pem'\n"), 0644)
+	os.WriteFile(filepath.Join(root, "account.conf"), []byte("LOG_FILE='/root/.acme.sh/acme.sh.log'\n"), 0644)
+
+	stubSchedulers(t, nil, `22 0 * * * "/root/.acme.sh"/acme.sh --cron --home "/root/.acme.sh" > /dev/null`)
+	sites := scanAcmeSh(root)
+	if len(sites) != 1 {
+		t.Fatalf("应识别 1 个证书: %+v", sites)
+	}
+	s := sites[0]
+	if s.Domain != "as.com" || s.CertPath != "/etc/nginx/ssl/as.pem" || s.KeyPath != "/etc/nginx/ssl/as.key" {
+		t.Fatalf("域名或部署路径错误: %+v", s)
+	}
+	if s.Import.Tool != toolAcmeSh || s.Import.CertPath != filepath.Join(dir, "fullchain.cer") || s.Import.KeyPath != filepath.Join(dir, "as.com.key") || !s.Import.Renewing {
+		t.Fatalf("导入信息错误: %+v", s.Import)
+	}
+
+	// --remove 后配置改名为 .conf.removed，不再识别
+	os.Rename(filepath.Join(dir, "as.com.conf"), filepath.Join(dir, "as.com.conf.removed"))
+	if sites := scanAcmeSh(root); len(sites) != 0 {
+		t.Fatalf("已移除的证书不应识别: %+v", sites)
+	}
+}
+
+// 原工具续期中的证书读取原工具的证书文件；原工具的证书也即将到期时报告续期失败
+func TestFetchLatestImported(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := genSelfSignedCert(t, dir, "fi.com", 30)
+	cert := db.Certificate{ID: 7, Domain: "fi.com", CertSource: toolCertbot,
+		Import: db.CertImport{Tool: toolCertbot, Name: "fi.com", CertPath: certPath, KeyPath: keyPath, Renewing: true}}
+	got, err := fetchLatestCertificate(cert, time.Now().Unix()+10*86400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.CertSource != toolCertbot || got.Import != cert.Import || got.PublicKey == "" {
+		t.Fatalf("应读取原工具的证书: %+v", got)
+	}
+	_, err = fetchLatestCertificate(cert, time.Now().Unix()+40*86400)
+	if err == nil || !strings.Contains(err.Error(), "未能按时续期") || !strings.Contains(err.Error(), "import takeover 7") {
+		t.Fatalf("原工具证书即将到期时应报错: %v", err)
+	}
+	if certSourceLabel(cert) != "certbot（续期中）" {
+		t.Fatalf("来源展示错误: %s", certSourceLabel(cert))
+	}
+}
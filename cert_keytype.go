@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"ssl_assistant/db"
+	"ssl_assistant/utils"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// certPair 一组证书/私钥路径（Nginx 可在同一 server 块配置多组，如 RSA + ECDSA 双证书）
+type certPair struct {
+	CertPath  string
+	KeyPath   string
+	KeyType   string // 本地证书文件的密钥类型（文件不可读时为空）
+	ChainPath string // Apache SSLCertificateChainFile（未配置时为空）
+}
+
+// certPairs 返回站点的全部证书/私钥对（未解析出多组时为 CertPath/KeyPath 一组）
+func (s nginxSite) certPairs() []certPair {
+	if len(s.Pairs) > 0 {
+		return s.Pairs
+	}
+	return []certPair{{CertPath: s.CertPath, KeyPath: s.KeyPath, ChainPath: s.ChainPath}}
+}
+
+// localKeyType 读取本地证书文件的密钥类型（rsa / ecdsa / ed25519），文件不存在或无法解析时返回空
+func localKeyType(certPath string) string {
+	if certPath == "" {
+		return ""
+	}
+	content, err := os.ReadFile(certPath)
+	if err != nil {
+		return ""
+	}
+	return utils.PEMKeyType(content)
+}
+
+// keyTypeLabel 密钥类型展示名（空串表示未知，返回空）
+func keyTypeLabel(keyType string) string {
+	switch keyType {
+	case "":
+		return ""
+	case "ecdsa":
+		return "ECDSA"
+	case "rsa":
+		return "RSA"
+	case "ed25519":
+		return "Ed25519"
+	}
+	return strings.ToUpper(keyType)
+}
+
+// certLabel 证书描述：域名（密钥类型），同一域名多证书时用于区分
+func certLabel(domain, keyType string) string {
+	if keyType == "" {
+		return domain
+	}
+	return fmt.Sprintf("%s（%s）", domain, keyTypeLabel(keyType))
+}
+
+// pickCertPair 按密钥类型选择证书/私钥对：keyType 为空或无匹配时取第一组
+func pickCertPair(pairs []certPair, keyType string) certPair {
+	if keyType != "" {
+		for _, p := range pairs {
+			if localKeyType(p.CertPath) == keyType {
+				return p
+			}
+		}
+	}
+	return pairs[0]
+}
+
+// findCertVariant 查找域名下与本地证书对应的记录：已部署到该证书路径或密钥类型相同即为同一证书；
+// keyType 为空（本地文件不可读）时取该域名最早添加的记录
+func findCertVariant(domain, keyType, certPath string) (db.Certificate, bool) {
+	certs, err := db.GetDomainCertificatesWrapper(domain)
+	if err != nil {
+		color.Yellow("检查域名 %s 是否存在时出错: %v\n", domain, err)
+		return db.Certificate{}, false
+	}
+	for _, c := range certs {
+		if hasDeployment(c, certPath) {
+			return c, true
+		}
+	}
+	for _, c := range certs {
+		if keyType == "" || c.KeyType == keyType {
+			return c, true
+		}
+	}
+	return db.Certificate{}, false
+}
+
+// manualSource 手动维护的证书来源：不自动从平台获取（如双证书站点中平台按域名只返回另一种密钥类型），仍按阈值发送过期告警
+const manualSource = "manual"
+
+// markCertManual 将无法从平台获取的密钥类型记录改为手动维护，之后的证书更新跳过该记录
+func markCertManual(cert db.Certificate) error {
+	cert.CertSource, cert.ProviderInstance = manualSource, ""
+	return db.UpdateCertificateInDBWrapper(cert)
+}
+
+// manualHint 改为手动维护后恢复自动更新的方法（平台为该密钥类型单独签发证书，并记录其证书ID）
+func manualHint(id string) string {
+	return fmt.Sprintf("如平台为该密钥类型单独签发了证书，可执行 edit %s --source certd --cert-id <证书ID> 恢复自动更新", id)
+}
+
+// checkVariantKeyType 校验平台返回的证书与记录的密钥类型一致：
+// 双证书站点各密钥类型独立部署，类型不符时拒绝写入（避免 ECDSA 证书被 RSA 证书覆盖）。
+// 记录尚无密钥类型（申请中或旧数据）时不校验，由新证书确定类型。
+func checkVariantKeyType(newCert, old db.Certificate) error {
+	if old.KeyType == "" || newCert.KeyType == "" || newCert.KeyType == old.KeyType {
+		return nil
+	}
+	return fmt.Errorf("平台返回的是 %s 证书，与该记录的 %s 证书类型不符（双证书需在平台为每种密钥类型单独签发，并使用对应的证书ID）",
+		keyTypeLabel(newCert.KeyType), keyTypeLabel(old.KeyType))
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"path/filepath"
+	"ssl_assistant/db"
+	"strings"
+	"testing"
+)
+
+// 双证书 server 块：解析出全部证书/私钥对，第一组仍为 CertPath/KeyPath
+func TestNginxDualCertPairs(t *testing.T) {
+	conf := `server {
+    listen 443 ssl;
+    server_name dual.com;
+    ssl_certificate     /certs/dual/rsa.pem;
+    ssl_certificate_key /certs/dual/rsa.key;
+    ssl_certificate     /certs/dual/ecc.pem;
+    ssl_certificate_key /certs/dual/ecc.key;
+}`
+	sites := parseNginxSitesForTest(t, conf)
+	if len(sites) != 1 {
+		t.Fatalf("应解析出 1 个站点，实际 %d", len(sites))
+	}
+	pairs := sites[0].certPairs()
+	if len(pairs) != 2 || pairs[0].CertPath != "/certs/dual/rsa.pem" || pairs[1].KeyPath != "/certs/dual/ecc.key" {
+		t.Fatalf("双证书配对错误: %+v", pairs)
+	}
+	if sites[0].CertPath != "/certs/dual/rsa.pem" {
+		t.Fatalf("CertPath 应为第一组: %s", sites[0].CertPath)
+	}
+
+	// 单证书站点不填充 Pairs，certPairs 回退为 CertPath/KeyPath
+	single := parseNginxSitesForTest(t, nestedBlockConf)[0]
+	if len(single.Pairs) != 0 || len(single.certPairs()) != 1 || single.certPairs()[0].KeyPath != "/certs/nested/privkey.pem" {
+		t.Fatalf("单证书站点配对错误: %+v", single)
+	}
+}
+
+// 按密钥类型选择证书对；无匹配时取第一组
+func TestPickCertPair(t *testing.T) {
+	rsaCert, rsaKey := genSelfSignedCert(t, t.TempDir(), "pick.com", 30)
+	eccCert, eccKey := genSelfSignedECDSACert(t, t.TempDir(), "pick.com", 30)
+	pairs := []certPair{{CertPath: rsaCert, KeyPath: rsaKey}, {CertPath: eccCert, KeyPath: eccKey}}
+
+	if got := pickCertPair(pairs, "ecdsa"); got.CertPath != eccCert {
+		t.Fatalf("应选择 ECDSA 证书: %+v", got)
+	}
+	if got := pickCertPair(pairs, ""); got.CertPath != rsaCert {
+		t.Fatalf("未指定类型应取第一组: %+v", got)
+	}
+	if got := pickCertPair(pairs, "ed25519"); got.CertPath != rsaCert {
+		t.Fatalf("无匹配应取第一组: %+v", got)
+	}
+	if kt := localKeyType(filepath.Join(t.TempDir(), "missing.pem")); kt != "" {
+		t.Fatalf("文件不存在应返回空类型: %s", kt)
+	}
+}
+
+// 平台返回的密钥类型与记录不符时拒绝部署；记录无类型（申请中/旧数据）时不校验
+func TestCheckVariantKeyType(t *testing.T) {
+	if err := checkVariantKeyType(db.Certificate{KeyType: "rsa"}, db.Certificate{KeyType: "ecdsa"}); err == nil || !strings.Contains(err.Error(), "ECDSA") {
+		t.Fatalf("类型不符应返回错误: %v", err)
+	}
+	if err := checkVariantKeyType(db.Certificate{KeyType: "ecdsa"}, db.Certificate{KeyType: "ecdsa"}); err != nil {
+		t.Fatalf("类型一致不应报错: %v", err)
+	}
+	if err := checkVariantKeyType(db.Certificate{KeyType: "rsa"}, db.Certificate{}); err != nil {
+		t.Fatalf("记录无类型不应报错: %v", err)
+	}
+}
+
+// 按域名只能获取到平台另一种密钥类型的记录改为手动维护：清除平台实例，之后的更新跳过该记录
+func TestMarkCertManual(t *testing.T) {
+	if err := db.InitDatabase(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	if err := db.AddCertificateToDBWrapper(db.Certificate{Domain: "manual-variant.com", KeyType: "ecdsa", CertSource: "certd", ProviderInstance: "prod"}); err != nil {
+		t.Fatalf("添加证书失败: %v", err)
+	}
+	cert, _ := db.GetCertificateWrapper("manual-variant.com")
+	defer db.DeleteCertificateFromDBWrapper(cert.ID)
+
+	if err := markCertManual(cert); err != nil {
+		t.Fatalf("标记手动维护失败: %v", err)
+	}
+	got, _ := db.GetCertificateByIDWrapper(cert.ID)
+	if got.CertSource != manualSource || got.ProviderInstance != "" || got.KeyType != "ecdsa" {
+		t.Fatalf("应改为手动维护并保留密钥类型: %+v", got)
+	}
+	if hint := manualHint("7"); !strings.Contains(hint, "edit 7 --source certd --cert-id") {
+		t.Fatalf("应提示恢复自动更新的方法: %s", hint)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"ssl_assistant/db"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// --- 证书列表查询（list）与标签（tag）：按来源、状态、到期时间、标签、部署路径筛选，由数据库按索引查询 ---
+
+// parseWithin 解析时间范围：30d（天）、12h / 90m（Go 时长格式），纯数字按天
+func parseWithin(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(strings.TrimSuffix(s, "d")); err == nil && n >= 0 {
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("无效的时间范围: %s（如 30d、12h）", s)
+	}
+	return d, nil
+}
+
+// overviewQuery 证书总览（show 表格与 TUI 主屏列表）的查询条件：全部证书，按到期时间升序，即将到期的排在前面
+var overviewQuery = db.CertQuery{Sort: db.SortExpire}
+
+// listCommand list 命令：按条件查询并渲染证书表格；expiring 为到期时间范围（如 30d，空串不过滤）
+func listCommand(q db.CertQuery, expiring string) error {
+	if expiring != "" {
+		within, err := parseWithin(expiring)
+		if err != nil {
+			return err
+		}
+		q.ExpiringBefore = time.Now().Add(within).Unix()
+	}
+	certs, err := db.QueryCertificatesWrapper(q)
+	if err != nil {
+		return fmt.Errorf("查询证书失败: %s", err)
+	}
+	if len(certs) == 0 {
+		color.Yellow("没有符合条件的证书\n")
+		return nil
+	}
+	renderCertTable(certs)
+	return nil
+}
+
+// validTag 标签不能包含逗号、斜杠与空白（列表中以逗号分隔显示，便于命令行筛选）
+func validTag(tag string) error {
+	if tag == "" {
+		return fmt.Errorf("标签不能为空")
+	}
+	if strings.ContainsAny(tag, ",/ \t\x00") {
+		return fmt.Errorf("标签 %q 不能包含逗号、斜杠或空白", tag)
+	}
+	return nil
+}
+
+// tagCommand tag add / del：为证书添加或删除标签
+func tagCommand(idArg string, tags []string, remove bool) error {
+	cert, err := getCertByIDArg(idArg)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if err := validTag(tag); err != nil {
+			return err
+		}
+	}
+	if remove {
+		var kept []string
+		for _, t := range cert.Tags {
+			if !containsString(tags, t) {
+				kept = append(kept, t)
+			}
+		}
+		cert.Tags = kept
+	} else {
+		cert.Tags = unionStrings(cert.Tags, tags)
+	}
+	if err := db.UpdateCertificateInDBWrapper(cert); err != nil {
+		return fmt.Errorf("保存标签失败: %s", err)
+	}
+	if len(cert.Tags) == 0 {
+		color.Green("域名 %s 已无标签\n", certLabel(cert.Domain, cert.KeyType))
+		return nil
+	}
+	color.Green("域名 %s 的标签: %s\n", certLabel(cert.Domain, cert.KeyType), strings.Join(cert.Tags, ","))
+	return nil
+}
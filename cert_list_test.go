@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWithin(t *testing.T) {
+	cases := map[string]time.Duration{
+		"30d": 30 * 24 * time.Hour,
+		"7":   7 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"90m": 90 * time.Minute,
+	}
+	for in, want := range cases {
+		if got, err := parseWithin(in); err != nil || got != want {
+			t.Errorf("parseWithin(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "abc", "-3d", "-1h"} {
+		if _, err := parseWithin(in); err == nil {
+			t.Errorf("parseWithin(%q) 应报错", in)
+		}
+	}
+}
+
+func TestValidTag(t *testing.T) {
+	for _, tag := range []string{"prod", "业务线-a", "v1.2"} {
+		if err := validTag(tag); err != nil {
+			t.Errorf("validTag(%q): %v", tag, err)
+		}
+	}
+	for _, tag := range []string{"", "a,b", "a/b", "a b"} {
+		if err := validTag(tag); err == nil {
+			t.Errorf("validTag(%q) 应报错", tag)
+		}
+	}
+}
@@ -33,16 +33,14 @@ func TestBuildCertFromLocalFiles(t *testing.T) {
 	}
 }
 
-// TestAddSiteFromNginxLocalFallback 平台未配置时，addSiteFromNginx 应回退本地证书文件成功添加
+// TestAddSiteFromNginxLocalFallback 平台未配置时，addSiteFromNginx 应回退本地证书文件成功添加（含双证书站点）
 func TestAddSiteFromNginxLocalFallback(t *testing.T) {
+	// 关闭之前测试打开的数据库，改用临时 HOME 下的数据库
+	db.CloseDatabase()
 	t.Setenv("HOME", t.TempDir())
 	t.Setenv("USERPROFILE", t.TempDir())
-	// 测试结束关闭数据库（Badger 文件被进程持有会阻止 TempDir 清理）
-	t.Cleanup(func() {
-		if db.Interface != nil {
-			db.Interface.Close()
-		}
-	})
+	// 测试结束关闭数据库（Badger 文件被进程持有会阻止 TempDir 清理），后续测试恢复 HOME 后重新打开
+	t.Cleanup(db.CloseDatabase)
 	dir := t.TempDir()
 	certPath, keyPath := genSelfSignedCert(t, dir, "local-test.com", 90)
 
@@ -62,6 +60,54 @@ func TestAddSiteFromNginxLocalFallback(t *testing.T) {
 	if cert.CertSource != "local" {
 		t.Fatalf("CertSource 应为 local，实际: %s", cert.CertSource)
 	}
+
+	// 双证书站点（RSA + ECDSA）：按密钥类型各添加一条记录，重复添加时跳过
+	rsaCert, rsaKey := genSelfSignedCert(t, t.TempDir(), "dual-add.com", 90)
+	eccCert, eccKey := genSelfSignedECDSACert(t, t.TempDir(), "dual-add.com", 90)
+	dual := nginxSite{
+		Domain:   "dual-add.com",
+		Domains:  []string{"dual-add.com"},
+		CertPath: rsaCert,
+		KeyPath:  rsaKey,
+		Pairs:    []certPair{{CertPath: rsaCert, KeyPath: rsaKey}, {CertPath: eccCert, KeyPath: eccKey}},
+	}
+	addSiteFromNginx(dual)
+	addSiteFromNginx(dual)
+
+	certs, err := db.GetDomainCertificatesWrapper("dual-add.com")
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if len(certs) != 2 {
+		t.Fatalf("应保存 2 条记录（RSA + ECDSA），实际 %d", len(certs))
+	}
+	byType := map[string]db.Certificate{}
+	for _, c := range certs {
+		byType[c.KeyType] = c
+	}
+	if byType["rsa"].Deployments[0].CertPath != rsaCert || byType["ecdsa"].Deployments[0].CertPath != eccCert || byType["ecdsa"].Deployments[0].KeyPath != eccKey {
+		t.Fatalf("各密钥类型的部署路径错误: %+v", certs)
+	}
+
+	// 同一证书部署在另一位置（如 Apache 站点）：追加为已有记录的部署位置，不新增记录，重复发现时跳过
+	apacheDir := t.TempDir()
+	apacheCert, apacheKey := filepath.Join(apacheDir, "dual.crt"), filepath.Join(apacheDir, "dual.key")
+	crt, _ := os.ReadFile(rsaCert)
+	key, _ := os.ReadFile(rsaKey)
+	os.WriteFile(apacheCert, crt, 0644)
+	os.WriteFile(apacheKey, key, 0600)
+	apache := nginxSite{Domain: "dual-add.com", Domains: []string{"dual-add.com"}, CertPath: apacheCert, KeyPath: apacheKey}
+	addSiteFromNginx(apache)
+	addSiteFromNginx(apache)
+	certs, _ = db.GetDomainCertificatesWrapper("dual-add.com")
+	if len(certs) != 2 {
+		t.Fatalf("新部署位置不应新增记录，实际 %d 条", len(certs))
+	}
+	for _, c := range certs {
+		if c.KeyType == "rsa" && (len(c.Deployments) != 2 || c.Deployments[1].CertPath != apacheCert) {
+			t.Fatalf("RSA 证书应追加部署位置: %+v", c.Deployments)
+		}
+	}
 }
 
 // readLocalCertFiles 读取本地证书/私钥文件内容，缺失返回空串
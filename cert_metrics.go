@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fatih/color"
+	"net/http"
+	"os"
+	"ssl_assistant/config"
+	"ssl_assistant/db"
+	"ssl_assistant/metrics"
+	"ssl_assistant/third/certd"
+	"ssl_assistant/third/west"
+	"strings"
+	"time"
+)
+
+// defaultMetricsListen serve 命令默认监听地址
+const defaultMetricsListen = ":9110"
+
+// fetchWestCert 从西部数码实例获取证书（记录平台请求耗时，指标按实例区分）
+func fetchWestCert(ref providerRef, domain string) (crt, key []byte, err error) {
+	start := time.Now()
+	err, crt, _, key = west.GetCert(ref.section(), domain)
+	metrics.ObservePlatform(ref.String(), time.Since(start), err)
+	return crt, key, err
+}
+
+// fetchCertdCert 从 Certd 实例获取证书（记录平台请求耗时，指标按实例区分；申请中不计为失败）
+func fetchCertdCert(ref providerRef, domain string, certID int) (crt, key []byte, detail *certd.CertDetail, err error) {
+	start := time.Now()
+	crt, key, detail, err = certd.GetCertificateInfo(ref.section(), domain, certID)
+	observed := err
+	if errors.Is(err, certd.ErrCertApplying) {
+		observed = nil
+	}
+	metrics.ObservePlatform(ref.String(), time.Since(start), observed)
+	return crt, key, detail, err
+}
+
+// recordFetchError 记录证书最近一次从平台获取失败的原因（metrics 指标 fetch_error）
+func recordFetchError(cert db.Certificate, err error) {
+	cert.LastError = err.Error()
+	cert.LastErrorTime = time.Now().Unix()
+	if uerr := db.UpdateCertificateInDBWrapper(cert); uerr != nil {
+		fmt.Printf("记录域名 %s 的获取失败状态失败: %v\n", cert.Domain, uerr)
+	}
+}
+
+// collectCertMetrics 从数据库与本地证书文件收集证书指标
+func collectCertMetrics() ([]metrics.CertInfo, error) {
+	certs, err := db.GetAllCertificatesWrapper()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]metrics.CertInfo, 0, len(certs))
+	for _, cert := range certs {
+		info := metrics.CertInfo{
+			Domain:        cert.Domain,
+			KeyType:       cert.KeyType,
+			Source:        cert.CertSource,
+			Status:        cert.Status,
+			CertID:        cert.CertID,
+			Expire:        cert.ExpireTime,
+			LastRenew:     cert.LastRenew,
+			LastError:     cert.LastError,
+			LastErrorTime: cert.LastErrorTime,
+		}
+		if fileExpire, ok := localCertExpire(cert); ok {
+			info.FileExpire = fileExpire
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// saveMetrics 将本进程的运行指标写入状态文件；失败只提示，不影响命令结果
+func saveMetrics() {
+	if err := metrics.Save(); err != nil {
+		color.Yellow("保存运行指标失败: %v\n", err)
+	}
+}
+
+// metricsCommand 输出 Prometheus 指标（metrics 命令）：未指定 textfile 时输出到标准输出
+func metricsCommand(textfile string) error {
+	if textfile == "" {
+		return metrics.Gather(os.Stdout, collectCertMetrics)
+	}
+	if err := metrics.WriteTextfile(textfile, collectCertMetrics); err != nil {
+		return err
+	}
+	color.Green("指标已写入 %s\n", textfile)
+	return nil
+}
+
+// metricsMux 返回指标 HTTP 路由（/metrics）
+func metricsMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler(collectCertMetrics))
+	return mux
+}
+
+// serveMetrics 启动指标 HTTP 服务（serve 命令，阻塞）
+func serveMetrics(listen string) error {
+	if listen == "" {
+		listen = defaultMetricsListen
+	}
+	color.Green("指标服务已启动: http://%s/metrics\n", displayListen(listen))
+	server := &http.Server{Addr: listen, Handler: metricsMux(), ReadHeaderTimeout: 10 * time.Second}
+	return server.ListenAndServe()
+}
+
+// displayListen 监听地址省略主机时补全为 localhost，便于直接复制访问
+func displayListen(listen string) string {
+	if strings.HasPrefix(listen, ":") {
+		return "localhost" + listen
+	}
+	return listen
+}
+
+// startDaemonMetrics 守护进程（cron）内启动指标服务：配置 metrics_listen 时在后台监听
+func startDaemonMetrics() {
+	listen, _ := config.GetConfig("", "metrics_listen")
+	listen = strings.TrimSpace(listen)
+	if listen == "" {
+		return
+	}
+	go func() {
+		if err := serveMetrics(listen); err != nil {
+			color.Red("指标服务启动失败: %v\n", err)
+		}
+	}()
+}
+
+// writeDaemonTextfile 守护进程任务结束后刷新 textfile（配置 metrics_textfile 时）
+func writeDaemonTextfile() {
+	path, _ := config.GetConfig("", "metrics_textfile")
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return
+	}
+	if err := metrics.WriteTextfile(path, collectCertMetrics); err != nil {
+		fmt.Printf("写入指标文件失败: %v\n", err)
+	}
+}
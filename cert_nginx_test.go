@@ -3,7 +3,7 @@ package main
 import (
 	"os"
 	"path/filepath"
-	"strings"
+	"ssl_assistant/nginxconf"
 	"testing"
 	"time"
 )
@@ -41,31 +41,61 @@ server {
 }
 `
 
-func TestFindServerBlocks(t *testing.T) {
-	// 多 server 块应全部提取
-	blocks := findServerBlocks(multiServerConf)
-	if len(blocks) != 2 {
-		t.Fatalf("应提取 2 个 server 块，实际 %d", len(blocks))
+// parseNginxSitesForTest 解析配置内容并返回站点（不涉及 include）
+func parseNginxSitesForTest(t *testing.T, content string) []nginxSite {
+	t.Helper()
+	cfg, err := nginxconf.ParseBytes([]byte(content), "test.conf", "")
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	return nginxSitesFromConfig(cfg)
+}
+
+func TestNginxServerBlocks(t *testing.T) {
+	// 多 server 块：仅 443 块含证书
+	sites := parseNginxSitesForTest(t, multiServerConf)
+	if len(sites) != 1 || sites[0].Domain != "www.bt-test.com" {
+		t.Fatalf("应解析出 1 个含证书的站点，实际 %+v", sites)
 	}
 
 	// 嵌套块：必须完整包含嵌套 location/if 之后的 ssl_certificate_key
-	blocks = findServerBlocks(nestedBlockConf)
-	if len(blocks) != 1 {
-		t.Fatalf("应提取 1 个 server 块，实际 %d", len(blocks))
+	sites = parseNginxSitesForTest(t, nestedBlockConf)
+	if len(sites) != 1 || sites[0].KeyPath != "/certs/nested/privkey.pem" {
+		t.Fatalf("嵌套块之后的 ssl_certificate_key 未解析: %+v", sites)
 	}
-	if !strings.Contains(blocks[0], "ssl_certificate_key /certs/nested/privkey.pem") {
-		t.Fatalf("嵌套块被截断，未包含块尾的 ssl_certificate_key:\n%s", blocks[0])
+	if sites[0].Location != "test.conf:5" {
+		t.Fatalf("证书指令位置错误: %s", sites[0].Location)
 	}
 
 	// 无 server 块
-	if blocks := findServerBlocks("http { include conf.d/*.conf; }"); len(blocks) != 0 {
-		t.Fatalf("无 server 块时不应有结果，实际 %d", len(blocks))
+	if sites := parseNginxSitesForTest(t, "http { server_tokens off; }"); len(sites) != 0 {
+		t.Fatalf("无 server 块时不应有结果，实际 %d", len(sites))
 	}
 
-	// server_name 不应被误判为 server 块起始
-	blocks = findServerBlocks("server_name example.com;")
-	if len(blocks) != 0 {
-		t.Fatalf("server_name 不应被误判为 server 块，实际 %d", len(blocks))
+	// server_name 不应被误判为 server 块
+	if sites := parseNginxSitesForTest(t, "server_name example.com;"); len(sites) != 0 {
+		t.Fatalf("server_name 不应被误判为 server 块，实际 %d", len(sites))
+	}
+}
+
+// 证书声明在 include 的片段中（相对 nginx 前缀目录）
+func TestExtractCertPathsFromInclude(t *testing.T) {
+	prefix := t.TempDir()
+	os.MkdirAll(filepath.Join(prefix, "snippets"), 0755)
+	os.MkdirAll(filepath.Join(prefix, "sites-enabled"), 0755)
+	os.WriteFile(filepath.Join(prefix, "nginx.conf"), []byte("http { include sites-enabled/*; }\n"), 0644)
+	os.WriteFile(filepath.Join(prefix, "snippets", "ssl-example.conf"), []byte(
+		"ssl_certificate /etc/ssl/example/fullchain.pem;\nssl_certificate_key /etc/ssl/example/privkey.pem;\n"), 0644)
+	site := filepath.Join(prefix, "sites-enabled", "example")
+	os.WriteFile(site, []byte("server {\n    listen 443 ssl;\n    server_name example.com;\n    include snippets/ssl-example.conf;\n}\n"), 0644)
+
+	cp, kp, ok := extractCertPathsFromFile(site, "example.com")
+	if !ok || cp != "/etc/ssl/example/fullchain.pem" || kp != "/etc/ssl/example/privkey.pem" {
+		t.Fatalf("include 片段中的证书未解析: ok=%v cp=%s kp=%s", ok, cp, kp)
+	}
+	sites := parseNginxConfig(filepath.Join(prefix, "nginx.conf"))
+	if len(sites) != 1 || sites[0].Location != filepath.Join(prefix, "snippets", "ssl-example.conf")+":1" {
+		t.Fatalf("从 nginx.conf 跟随 include 解析失败: %+v", sites)
 	}
 }
 
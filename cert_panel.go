@@ -0,0 +1,265 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// --- 面板识别：各面板的安装目录、站点配置路径与站点证书目录约定。
+// 面板站点的证书目录以站点名命名（一般为主域名，可自定义），站点名不是域名时按证书 SAN 识别域名 ---
+
+// panelLayout 面板的目录约定
+type panelLayout struct {
+	Name  string
+	Roots func() []string // 安装目录候选（存在的才视为已安装）
+	// Configs 站点配置路径（可含通配符）
+	Configs func(root string) []string
+	// CertDirs 站点证书目录的通配路径，路径中的 * 为站点名，目录下为 fullchain.pem / privkey.pem
+	CertDirs func(root string) []string
+}
+
+// panels 已支持的面板（新增面板在此注册）
+var panels = []panelLayout{
+	{
+		// 宝塔与 aaPanel（宝塔国际版）目录结构相同；新版证书统一存于 panel/vhost/cert/<站点名>/，Nginx/Apache 共用
+		Name:  "宝塔/aaPanel",
+		Roots: staticRoots("/www/server"),
+		Configs: func(root string) []string {
+			return []string{
+				filepath.Join(root, "panel", "vhost", "nginx", "*.conf"),
+				filepath.Join(root, "panel", "vhost", "apache", "*.conf"),
+				filepath.Join(root, "apache", "vhost", "*.conf"),
+			}
+		},
+		CertDirs: func(root string) []string {
+			return []string{filepath.Join(root, "panel", "vhost", "cert", "*")}
+		},
+	},
+	{
+		// 1Panel：旧版站点配置在 www/conf.d；新版 OpenResty 以应用方式安装在 apps/openresty/openresty。
+		// OpenResty 运行在容器内，配置中的证书路径（/www/sites/<站点名>/ssl/…）为容器路径，以站点证书目录的宿主机路径为准
+		Name:  "1Panel",
+		Roots: onePanelRoots,
+		Configs: func(root string) []string {
+			return []string{
+				filepath.Join(root, "www", "conf.d", "*.conf"),
+				filepath.Join(root, "apps", "openresty", "openresty", "conf", "conf.d", "*.conf"),
+			}
+		},
+		CertDirs: func(root string) []string {
+			return []string{
+				filepath.Join(root, "www", "sites", "*", "ssl"),
+				filepath.Join(root, "apps", "openresty", "openresty", "www", "sites", "*", "ssl"),
+			}
+		},
+	},
+	{
+		// 小皮面板 Linux 版：站点配置在 vhost/nginx、vhost/apache
+		Name:  "小皮面板",
+		Roots: staticRoots("/usr/local/phpstudy"),
+		Configs: func(root string) []string {
+			return []string{
+				filepath.Join(root, "vhost", "nginx", "*.conf"),
+				filepath.Join(root, "vhost", "apache", "*.conf"),
+			}
+		},
+	},
+	{
+		// 小皮面板 Windows 版：安装盘符与 Nginx/Apache 版本号不确定，枚举盘符与 Extensions 下的版本目录
+		Name:    "小皮面板Windows",
+		Roots:   phpstudyWindowsRoots,
+		Configs: discoverPhpstudyFromRoot,
+	},
+}
+
+// staticRoots 固定的安装目录
+func staticRoots(roots ...string) func() []string {
+	return func() []string { return roots }
+}
+
+// onePanelCtl 1Panel 的管理脚本，BASE_DIR 为安装时选择的目录（默认 /opt，面板目录为 <BASE_DIR>/1panel）
+var onePanelCtl = "/usr/local/bin/1pctl"
+
+// onePanelRoots 1Panel 安装目录：优先取 1pctl 中的 BASE_DIR，其次默认的 /opt/1panel
+func onePanelRoots() []string {
+	roots := []string{"/opt/1panel"}
+	if kv, err := readKeyValueFile(onePanelCtl); err == nil && kv["BASE_DIR"] != "" {
+		if root := filepath.Join(kv["BASE_DIR"], "1panel"); !containsString(roots, root) {
+			roots = append([]string{root}, roots...)
+		}
+	}
+	return roots
+}
+
+// phpstudyWindowsRoots 各盘符下的 phpstudy_pro 目录（覆盖 C/D/E 及网络盘、U 盘等自定义安装位置）
+func phpstudyWindowsRoots() []string {
+	if runtime.GOOS != "windows" {
+		return nil
+	}
+	var roots []string
+	for c := 'A'; c <= 'Z'; c++ {
+		drive := string(c) + ":\\"
+		if _, err := os.Stat(drive); err != nil {
+			continue
+		}
+		roots = append(roots, filepath.Join(drive, "phpstudy_pro"))
+	}
+	return roots
+}
+
+// installedPanel 已安装的面板
+type installedPanel struct {
+	Layout *panelLayout
+	Root   string
+}
+
+// 已安装面板的识别结果进程内缓存一次（面板安装/卸载后需重启程序重新识别）
+var (
+	installedPanelsOnce sync.Once
+	installedPanelsList []installedPanel
+)
+
+// installedPanels 识别已安装的面板
+func installedPanels() []installedPanel {
+	installedPanelsOnce.Do(func() {
+		installedPanelsList = detectPanels(panels)
+	})
+	return installedPanelsList
+}
+
+// detectPanels 按安装目录识别已安装的面板（同一面板可能装在多个位置）
+func detectPanels(layouts []panelLayout) []installedPanel {
+	var found []installedPanel
+	for i := range layouts {
+		for _, root := range layouts[i].Roots() {
+			if info, err := os.Stat(root); err == nil && info.IsDir() {
+				found = append(found, installedPanel{Layout: &layouts[i], Root: root})
+			}
+		}
+	}
+	return found
+}
+
+// discoverPanelPaths 已安装面板的站点配置路径（可含通配符），未安装面板时返回空，不影响原有扫描
+func discoverPanelPaths() []string {
+	var paths []string
+	for _, p := range installedPanels() {
+		paths = append(paths, p.Configs()...)
+	}
+	return paths
+}
+
+// Configs 面板的站点配置路径
+func (p installedPanel) Configs() []string {
+	if p.Layout.Configs == nil {
+		return nil
+	}
+	return p.Layout.Configs(p.Root)
+}
+
+// CertSites 面板站点证书目录中的站点
+func (p installedPanel) CertSites() []nginxSite {
+	if p.Layout.CertDirs == nil {
+		return nil
+	}
+	var sites []nginxSite
+	for _, pattern := range p.Layout.CertDirs(p.Root) {
+		sites = append(sites, scanSiteCertDirs(pattern)...)
+	}
+	return sites
+}
+
+// panelCertSites 全部已安装面板的站点证书目录中的站点
+func panelCertSites() []nginxSite {
+	var sites []nginxSite
+	for _, p := range installedPanels() {
+		sites = append(sites, p.CertSites()...)
+	}
+	return sites
+}
+
+// discoverPhpstudyFromRoot 给定 phpstudy 根目录，返回其 Nginx/Apache 的 vhosts 通配路径（版本号无关）
+func discoverPhpstudyFromRoot(root string) []string {
+	var paths []string
+	extDir := filepath.Join(root, "Extensions")
+	entries, err := os.ReadDir(extDir)
+	if err != nil {
+		return paths
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		// Nginx 或 Apache 开头（版本号可变），如 Nginx1.15.11、Apache2.4.39
+		if !strings.HasPrefix(name, "Nginx") && !strings.HasPrefix(name, "Apache") {
+			continue
+		}
+		vhosts := filepath.Join(extDir, name, "conf", "vhosts")
+		if info, err := os.Stat(vhosts); err == nil && info.IsDir() {
+			paths = append(paths, filepath.Join(vhosts, "*.conf"))
+		}
+	}
+	return paths
+}
+
+// scanCertDir 扫描证书目录：第一层子目录名为站点名，第二层为 fullchain.pem/privkey.pem 证书文件。
+// 返回识别出的站点（域名 + 证书/私钥路径），目录不存在或文件缺失时返回空。
+func scanCertDir(certRoot string) []nginxSite {
+	return scanSiteCertDirs(filepath.Join(certRoot, "*"))
+}
+
+// scanSiteCertDirs 扫描站点证书目录：pattern 中的 * 为站点名，目录下为 fullchain.pem/privkey.pem
+func scanSiteCertDirs(pattern string) []nginxSite {
+	var sites []nginxSite
+	matches, err := filepath.Glob(filepath.Join(pattern, "fullchain.pem"))
+	if err != nil {
+		return sites
+	}
+	// 站点名在路径中的层级（通配符所在的一段）
+	depth := -1
+	for i, part := range strings.Split(filepath.ToSlash(pattern), "/") {
+		if part == "*" {
+			depth = i
+		}
+	}
+	for _, certPath := range matches {
+		keyPath := filepath.Join(filepath.Dir(certPath), "privkey.pem")
+		if _, err := os.Stat(keyPath); err != nil {
+			// 缺少私钥的证书目录跳过
+			continue
+		}
+		parts := strings.Split(filepath.ToSlash(certPath), "/")
+		if depth < 0 || depth >= len(parts) {
+			continue
+		}
+		domain, domains, ok := siteDomains(parts[depth], certPath)
+		if !ok {
+			continue
+		}
+		sites = append(sites, nginxSite{
+			Domain:   domain,
+			Domains:  domains,
+			CertPath: certPath,
+			KeyPath:  keyPath,
+		})
+	}
+	return sites
+}
+
+// siteDomains 面板站点名对应的域名：站点名为域名时作为主域名，否则（自定义的站点名）取证书的第一个 SAN；
+// Domains 含证书的全部 SAN。站点名不是域名且证书无法解析时无法识别
+func siteDomains(name, certPath string) (string, []string, bool) {
+	names := localCertNames(certPath)
+	domain := name
+	if !strings.Contains(name, ".") {
+		if len(names) == 0 {
+			return "", nil, false
+		}
+		domain = names[0]
+	}
+	return domain, unionStrings([]string{domain}, names), true
+}
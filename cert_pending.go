@@ -0,0 +1,304 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fatih/color"
+	"ssl_assistant/config"
+	"ssl_assistant/db"
+	"ssl_assistant/metrics"
+	"ssl_assistant/notify"
+	"ssl_assistant/third/certd"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pendingStatus 申请中证书的状态显示值（certd code=20013：已触发申请、尚未签发）
+const pendingStatus = "申请中"
+
+// pendingTimeoutStatus 申请中跟进超时后的状态显示值（交由每日 update 重新触发）
+const pendingTimeoutStatus = "申请超时"
+
+// pendingBackoff 申请中证书的轮询退避间隔：第 n 次轮询前等待 pendingBackoff[n]，超出后固定取最后一项
+var pendingBackoff = []time.Duration{
+	time.Minute,
+	2 * time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+}
+
+var defaultPendingTimeoutHours = 24 // 默认申请中跟进超时（小时），超时后停止轮询
+
+// pendingSleep 等待函数（add --wait 轮询间隔；测试中替换以避免真实等待）
+var pendingSleep = time.Sleep
+
+// pendingInterval 返回已轮询 polls 次后的下一次轮询间隔
+func pendingInterval(polls int) time.Duration {
+	if polls < 0 {
+		polls = 0
+	}
+	if polls >= len(pendingBackoff) {
+		return pendingBackoff[len(pendingBackoff)-1]
+	}
+	return pendingBackoff[polls]
+}
+
+// pendingTimeout 读取申请中跟进超时（配置 pending_timeout_hours，非法或未配置时取默认值）
+func pendingTimeout() time.Duration {
+	hours := defaultPendingTimeoutHours
+	if v, _ := config.GetConfig("", "pending_timeout_hours"); strings.TrimSpace(v) != "" {
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && n > 0 {
+			hours = n
+		}
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// pendingExpired 判断申请中证书是否已超过跟进超时
+func pendingExpired(cert db.Certificate, now time.Time, timeout time.Duration) bool {
+	return cert.PendingSince > 0 && now.Sub(time.Unix(cert.PendingSince, 0)) >= timeout
+}
+
+// pendingDue 判断申请中证书是否到达下一次轮询时间（lastPoll 为零值表示本进程尚未轮询，立即轮询）
+func pendingDue(cert db.Certificate, lastPoll, now time.Time) bool {
+	if cert.PendingSince == 0 {
+		return false
+	}
+	if lastPoll.IsZero() {
+		return true
+	}
+	return !now.Before(lastPoll.Add(pendingInterval(cert.PendingPolls)))
+}
+
+// markCertPending 将证书标记为申请中（已处于申请中时保留原起始时间）
+func markCertPending(cert db.Certificate) db.Certificate {
+	if cert.PendingSince == 0 {
+		cert.PendingSince = time.Now().Unix()
+		cert.PendingPolls = 0
+	}
+	cert.Status = pendingStatus
+	return cert
+}
+
+// clearCertPending 清除申请中标记，状态按过期时间重新计算
+func clearCertPending(cert db.Certificate) db.Certificate {
+	cert.PendingSince = 0
+	cert.PendingPolls = 0
+	if cert.ExpireTime < time.Now().Unix() {
+		cert.Status = "过期"
+	} else {
+		cert.Status = "有效"
+	}
+	return cert
+}
+
+// inheritCertFields 新拉取的证书继承原记录的 ID/版本/部署位置，以及平台未返回时的证书ID与覆盖域名
+func inheritCertFields(newCert, old db.Certificate) db.Certificate {
+	newCert.ID = old.ID
+	newCert.Version = old.Version
+	newCert.Deployments = old.Deployments
+	newCert.Targets = old.Targets
+	newCert.Hooks = old.Hooks
+	newCert.Tags = old.Tags
+	newCert.ReloadCmd = old.ReloadCmd
+	newCert.RenewDays = old.RenewDays
+	newCert.Providers = old.Providers
+	newCert.Renewals = old.Renewals
+	if newCert.Import == (db.CertImport{}) {
+		newCert.Import = old.Import
+	}
+	// 最近一次获取失败时间作为历史保留（失败原因在获取成功后清空）
+	newCert.LastErrorTime = old.LastErrorTime
+	// 保留原有平台证书ID与覆盖域名（非certd来源或detail缺失时不会被清空）
+	if newCert.CertID == 0 {
+		newCert.CertID = old.CertID
+	}
+	if newCert.CertDomains == "" {
+		newCert.CertDomains = old.CertDomains
+	}
+	return newCert
+}
+
+// saveRenewedCert 保存续期后的证书（newCert 由 fetched 继承原记录得到）；原记录在读取后被其他进程修改时（ErrConflict，
+// 如更新任务运行期间执行了 tag / edit / deploy add），重新读取最新记录继承后再保存一次，不覆盖并发的修改
+func saveRenewedCert(newCert, fetched db.Certificate) (db.Certificate, error) {
+	err := db.UpdateCertificateInDBWrapper(newCert)
+	if !errors.Is(err, db.ErrConflict) {
+		return newCert, err
+	}
+	latest, lerr := db.GetCertificateByIDWrapper(newCert.ID)
+	if lerr != nil {
+		return newCert, err
+	}
+	newCert = recordRenewal(inheritCertFields(fetched, latest))
+	return newCert, db.UpdateCertificateInDBWrapper(newCert)
+}
+
+// errPendingTimeout 申请中证书跟进超时（已停止跟进，调用方据此发送一次通知）
+var errPendingTimeout = errors.New("已停止跟进")
+
+// pendingPollFailed 记录一次未签发的轮询：累加轮询次数（按退避间隔继续跟进），
+// 获取失败时记录失败原因；超过跟进超时则清除申请中标记并返回 errPendingTimeout。
+// 仍在申请中（err 为 ErrCertApplying）时返回 nil，其余情况返回 err
+func pendingPollFailed(cert db.Certificate, err error) error {
+	applying := errors.Is(err, certd.ErrCertApplying)
+	cert.PendingPolls++
+	if !applying {
+		cert.LastError = err.Error()
+		cert.LastErrorTime = time.Now().Unix()
+	}
+	if pendingExpired(cert, time.Now(), pendingTimeout()) {
+		cert = clearCertPending(cert)
+		cert.Status = pendingTimeoutStatus
+		if uerr := db.UpdateCertificateInDBWrapper(cert); uerr != nil {
+			return fmt.Errorf("更新域名 %s 的申请状态失败: %v", cert.Domain, uerr)
+		}
+		if applying {
+			return fmt.Errorf("域名 %s 的证书申请超过 %s 仍未签发，%w", cert.Domain, pendingTimeout(), errPendingTimeout)
+		}
+		return fmt.Errorf("域名 %s 的证书申请超过 %s 仍未签发（最近一次: %v），%w", cert.Domain, pendingTimeout(), err, errPendingTimeout)
+	}
+	if uerr := db.UpdateCertificateInDBWrapper(cert); uerr != nil {
+		return fmt.Errorf("更新域名 %s 的申请状态失败: %v", cert.Domain, uerr)
+	}
+	if applying {
+		return nil
+	}
+	return err
+}
+
+// pollPendingCertificate 轮询一次申请中证书：
+// 已签发则保存并部署证书文件（issued=true，重载由调用方统一执行；post-deploy 钩子失败时同时返回错误）；
+// 未签发（仍在申请中、获取失败或钩子失败）则累加轮询次数，超时后清除申请中标记并返回错误（见 pendingPollFailed）。
+func pollPendingCertificate(cert db.Certificate) (issued bool, err error) {
+	if err := runHooks(hookPreFetch, cert); err != nil {
+		return false, pendingPollFailed(cert, fmt.Errorf("域名 %s 的%v", cert.Domain, err))
+	}
+	newCert, err := getCertificateInfo(cert.Domain, []string{certSourceRef(cert).String()}, cert.CertID, certSourceRef(cert))
+	if err == nil {
+		err = checkVariantKeyType(newCert, cert)
+	}
+	if err != nil {
+		return false, pendingPollFailed(cert, err)
+	}
+
+	fetched := newCert
+	newCert = recordRenewal(inheritCertFields(fetched, cert))
+	// pre-deploy 钩子失败时放弃部署，保持申请中状态，按退避间隔重试
+	if err := runHooks(hookPreDeploy, newCert); err != nil {
+		return false, pendingPollFailed(cert, fmt.Errorf("域名 %s 的%v，放弃部署", cert.Domain, err))
+	}
+	if newCert, err = saveRenewedCert(newCert, fetched); err != nil {
+		return false, fmt.Errorf("更新域名 %s 的证书信息失败: %v", cert.Domain, err)
+	}
+	if err := updateCertificateFiles(newCert); err != nil {
+		return false, err
+	}
+	color.Green("域名 %s 的证书已签发并部署\n", cert.Domain)
+	if err := runHooks(hookPostDeploy, newCert); err != nil {
+		// 证书文件已写入：仍按已签发处理（调用方随后重载），同时返回钩子错误
+		return true, fmt.Errorf("域名 %s 的%v", cert.Domain, err)
+	}
+	return true, nil
+}
+
+// followPendingCertificates 跟进一轮申请中证书（守护进程定时调用）：
+// 按退避间隔轮询到期的申请中证书，有证书签发部署后立即执行重载命令。
+// lastPoll 记录本进程内各证书最近一次轮询时间（按证书 ID），由调用方跨轮次保留。
+func followPendingCertificates(lastPoll map[int]time.Time) error {
+	certificates, err := db.GetAllCertificatesWrapper()
+	if err != nil {
+		return fmt.Errorf("获取证书信息失败: %s", err)
+	}
+	now := time.Now()
+	issuedNum := 0
+	var deployed []db.Certificate
+	var errs []string
+	batch := notify.NewBatch()
+	defer flushNotifications(batch)
+	for _, cert := range certificates {
+		if !pendingDue(cert, lastPoll[cert.ID], now) {
+			continue
+		}
+		lastPoll[cert.ID] = now
+		fmt.Printf("正在跟进申请中的域名 %s（第 %d 次）...\n", cert.Domain, cert.PendingPolls+1)
+		issued, err := pollPendingCertificate(cert)
+		if err != nil {
+			errs = append(errs, err.Error())
+			// 未签发的失败按退避间隔重试（保留 lastPoll），仅在跟进超时时通知一次，避免每轮重复通知
+			if issued || errors.Is(err, errPendingTimeout) {
+				batch.Add(notify.EventFailed, cert.Domain, "%v", err)
+			}
+			if !issued {
+				continue
+			}
+		}
+		if issued {
+			issuedNum++
+			// 重新读取已签发的证书（post-reload 钩子需要新证书的到期时间与序列号）
+			if latest, lerr := db.GetCertificateByIDWrapper(cert.ID); lerr == nil {
+				cert = latest
+			}
+			deployed = append(deployed, cert)
+			batch.Add(notify.EventRenewed, cert.Domain, "申请中的证书已签发并部署")
+			delete(lastPoll, cert.ID)
+		}
+	}
+	if issuedNum > 0 {
+		metrics.AddRenewed(issuedNum)
+		if err := reloadCertificates(deployed); err != nil {
+			errs = append(errs, err.Error())
+			batch.Add(notify.EventReloadFailed, "", "%d 个证书已签发部署，但重载命令执行失败: %v", issuedNum, err)
+		} else {
+			verifyDeployment(deployed, batch)
+			for _, herr := range runPostReloadHooks(deployed) {
+				errs = append(errs, herr.Error())
+				batch.Add(notify.EventFailed, "", "%v", herr)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// waitPendingCertificate 阻塞等待申请中证书签发（add --wait）：
+// 按退避间隔轮询直至签发（部署并重载）或超时。
+func waitPendingCertificate(cert db.Certificate) error {
+	color.Cyan("正在等待域名 %s 的证书签发（最长 %s，可 Ctrl+C 退出，后台任务会继续跟进）...\n", cert.Domain, pendingTimeout())
+	for {
+		pendingSleep(pendingInterval(cert.PendingPolls))
+		issued, err := pollPendingCertificate(cert)
+		if issued {
+			// 已签发部署：即使 post-deploy 钩子失败也执行重载，使已写入的证书生效
+			if rerr := executeReloadCmd(certReloadCmd(cert)); rerr != nil {
+				return rerr
+			}
+			if err != nil {
+				return err
+			}
+			latest, lerr := db.GetCertificateByIDWrapper(cert.ID)
+			if lerr != nil {
+				return fmt.Errorf("读取域名 %s 的证书信息失败: %v", cert.Domain, lerr)
+			}
+			if herrs := runPostReloadHooks([]db.Certificate{latest}); len(herrs) > 0 {
+				return herrs[0]
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// 重新读取记录，拿到累加后的轮询次数
+		latest, err := db.GetCertificateByIDWrapper(cert.ID)
+		if err != nil {
+			return fmt.Errorf("读取域名 %s 的申请状态失败: %v", cert.Domain, err)
+		}
+		cert = latest
+		fmt.Printf("域名 %s 的证书仍在申请中（已轮询 %d 次），%s 后重试\n", cert.Domain, cert.PendingPolls, pendingInterval(cert.PendingPolls))
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"ssl_assistant/db"
+	"testing"
+	"time"
+)
+
+// 退避间隔：按轮询次数递增，超出列表后固定取最后一项
+func TestPendingInterval(t *testing.T) {
+	if got := pendingInterval(0); got != time.Minute {
+		t.Fatalf("首次轮询间隔应为 1 分钟，实际 %s", got)
+	}
+	if got := pendingInterval(2); got != 5*time.Minute {
+		t.Fatalf("第 3 次轮询间隔应为 5 分钟，实际 %s", got)
+	}
+	last := pendingBackoff[len(pendingBackoff)-1]
+	if got := pendingInterval(100); got != last {
+		t.Fatalf("超出退避列表应固定为 %s，实际 %s", last, got)
+	}
+	if got := pendingInterval(-1); got != time.Minute {
+		t.Fatalf("负数轮询次数应按 0 处理，实际 %s", got)
+	}
+}
+
+// 到期判断：非申请中不轮询；本进程未轮询过立即轮询；否则按退避间隔
+func TestPendingDue(t *testing.T) {
+	now := time.Now()
+	if pendingDue(db.Certificate{}, time.Time{}, now) {
+		t.Fatal("非申请中证书不应轮询")
+	}
+	cert := db.Certificate{PendingSince: now.Add(-time.Hour).Unix(), PendingPolls: 1}
+	if !pendingDue(cert, time.Time{}, now) {
+		t.Fatal("本进程未轮询过的申请中证书应立即轮询")
+	}
+	if pendingDue(cert, now.Add(-time.Minute), now) {
+		t.Fatal("第 2 次轮询间隔为 2 分钟，1 分钟前刚轮询不应到期")
+	}
+	if !pendingDue(cert, now.Add(-2*time.Minute), now) {
+		t.Fatal("已满 2 分钟应到期")
+	}
+}
+
+// 超时判断与申请中标记的设置/清除
+func TestPendingMarkAndExpire(t *testing.T) {
+	cert := markCertPending(db.Certificate{Domain: "pending.com"})
+	if cert.PendingSince == 0 || cert.Status != pendingStatus {
+		t.Fatalf("应标记为申请中: %+v", cert)
+	}
+	// 已处于申请中时保留原起始时间
+	cert.PendingSince = 100
+	cert.PendingPolls = 3
+	if again := markCertPending(cert); again.PendingSince != 100 || again.PendingPolls != 3 {
+		t.Fatalf("重复标记不应重置起始时间与轮询次数: %+v", again)
+	}
+
+	now := time.Now()
+	cert.PendingSince = now.Add(-25 * time.Hour).Unix()
+	if !pendingExpired(cert, now, 24*time.Hour) {
+		t.Fatal("超过 24 小时应判定超时")
+	}
+	cert.PendingSince = now.Add(-time.Hour).Unix()
+	if pendingExpired(cert, now, 24*time.Hour) {
+		t.Fatal("1 小时内不应超时")
+	}
+
+	cert.ExpireTime = now.Add(24 * time.Hour).Unix()
+	cleared := clearCertPending(cert)
+	if cleared.PendingSince != 0 || cleared.PendingPolls != 0 || cleared.Status != "有效" {
+		t.Fatalf("清除申请中后应恢复有效状态: %+v", cleared)
+	}
+}
+
+// 新拉取的证书继承原记录的 ID/部署位置/标签，平台未返回的证书ID与覆盖域名沿用原值
+func TestInheritCertFields(t *testing.T) {
+	deps := []db.Deployment{{CertPath: "/a.pem", KeyPath: "/a.key"}, {CertPath: "/b.pem", KeyPath: "/b.key"}}
+	old := db.Certificate{ID: 7, Deployments: deps, CertID: 42, CertDomains: "a.com", Tags: []string{"prod"}, ReloadCmd: "reload", RenewDays: 20}
+	got := inheritCertFields(db.Certificate{PublicKey: "new"}, old)
+	if got.ID != 7 || len(got.Deployments) != 2 || got.Deployments[1] != deps[1] || got.CertID != 42 || got.CertDomains != "a.com" || len(got.Tags) != 1 ||
+		got.ReloadCmd != "reload" || got.RenewDays != 20 {
+		t.Fatalf("字段继承错误: %+v", got)
+	}
+	got = inheritCertFields(db.Certificate{CertID: 43, CertDomains: "b.com"}, old)
+	if got.CertID != 43 || got.CertDomains != "b.com" {
+		t.Fatalf("平台返回的证书ID/覆盖域名应优先: %+v", got)
+	}
+}
+
+// 续期保存时原记录已被其他进程修改（如更新任务运行期间执行了 tag）：基于最新记录保存，不覆盖并发的修改
+func TestSaveRenewedCertConflict(t *testing.T) {
+	if err := db.InitDatabase(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	if err := db.AddCertificateToDBWrapper(db.Certificate{Domain: "renew-conflict.com", Status: "有效", CertSource: "certd"}); err != nil {
+		t.Fatalf("添加证书失败: %v", err)
+	}
+	old, _ := db.GetCertificateWrapper("renew-conflict.com")
+	defer db.DeleteCertificateFromDBWrapper(old.ID)
+
+	tagged := old
+	tagged.Tags = []string{"prod"}
+	if err := db.UpdateCertificateInDBWrapper(tagged); err != nil {
+		t.Fatal(err)
+	}
+	fetched := db.Certificate{Domain: "renew-conflict.com", Status: "有效", CertSource: "certd", ExpireTime: 1900000000}
+	saved, err := saveRenewedCert(recordRenewal(inheritCertFields(fetched, old)), fetched)
+	if err != nil {
+		t.Fatalf("冲突后应基于最新记录保存: %v", err)
+	}
+	got, _ := db.GetCertificateByIDWrapper(old.ID)
+	if got.ExpireTime != 1900000000 || len(got.Tags) != 1 || len(got.Renewals) != 1 || len(saved.Tags) != 1 {
+		t.Fatalf("应保存新证书并保留并发修改的标签: %+v", got)
+	}
+}
+
+// 申请中证书持续获取失败（如平台不可用）：按退避间隔重试、累加轮询次数并记录失败原因，超过跟进超时后停止跟进
+func TestPendingPersistentFailureBacksOff(t *testing.T) {
+	useTempConfig(t)
+	if err := db.InitDatabase(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	// 来源实例未配置：每次轮询都获取失败
+	pending := markCertPending(db.Certificate{Domain: "pending-fail.com", CertSource: "certd", ProviderInstance: "gone"})
+	pending.PendingSince = time.Now().Add(-time.Hour).Unix()
+	if err := db.AddCertificateToDBWrapper(pending); err != nil {
+		t.Fatalf("添加证书失败: %v", err)
+	}
+	cert, _ := db.GetCertificateWrapper("pending-fail.com")
+	defer db.DeleteCertificateFromDBWrapper(cert.ID)
+
+	lastPoll := map[int]time.Time{}
+	if err := followPendingCertificates(lastPoll); err == nil {
+		t.Fatal("获取失败应返回错误")
+	}
+	got, _ := db.GetCertificateByIDWrapper(cert.ID)
+	if got.PendingPolls != 1 || got.LastError == "" || got.PendingSince == 0 || lastPoll[cert.ID].IsZero() {
+		t.Fatalf("失败后应累加轮询次数、记录失败原因并保留轮询时间: %+v %v", got, lastPoll)
+	}
+	// 未到退避间隔：不再轮询
+	if err := followPendingCertificates(lastPoll); err != nil {
+		t.Fatalf("未到期不应轮询: %v", err)
+	}
+	if got, _ = db.GetCertificateByIDWrapper(cert.ID); got.PendingPolls != 1 {
+		t.Fatalf("未到期不应累加轮询次数: %d", got.PendingPolls)
+	}
+	lastPoll[cert.ID] = time.Now().Add(-pendingInterval(1))
+	if err := followPendingCertificates(lastPoll); err == nil {
+		t.Fatal("到期后应再次轮询并返回错误")
+	}
+	if got, _ = db.GetCertificateByIDWrapper(cert.ID); got.PendingPolls != 2 {
+		t.Fatalf("到期后应累加轮询次数: %d", got.PendingPolls)
+	}
+
+	// 超过跟进超时：停止跟进
+	got.PendingSince = time.Now().Add(-pendingTimeout() - time.Minute).Unix()
+	if err := db.UpdateCertificateInDBWrapper(got); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = db.GetCertificateByIDWrapper(cert.ID)
+	if issued, err := pollPendingCertificate(got); issued || !errors.Is(err, errPendingTimeout) {
+		t.Fatalf("超时应返回 errPendingTimeout: %v %v", issued, err)
+	}
+	if got, _ = db.GetCertificateByIDWrapper(cert.ID); got.PendingSince != 0 || got.Status != pendingTimeoutStatus {
+		t.Fatalf("超时后应清除申请中标记: %+v", got)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"ssl_assistant/db"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// --- 部署文件的属主、权限与 SELinux 标签：以 root 运行为服务用户（haproxy、postgres 等）部署证书时，文件须对服务可读且不过度开放 ---
+
+// restoreconCommand SELinux 标签恢复程序（测试时替换为模拟程序）
+var restoreconCommand = "restorecon"
+
+// selinuxEnabled 是否启用了 SELinux（Linux 且已挂载 selinuxfs；测试时可替换）
+var selinuxEnabled = func() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	_, err := os.Stat("/sys/fs/selinux/enforce")
+	return err == nil
+}
+
+// parseFileMode 解析八进制权限（如 0640）
+func parseFileMode(mode string) (os.FileMode, error) {
+	n, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || n > 0777 {
+		return 0, fmt.Errorf("权限 %q 无效（八进制，如 0640）", mode)
+	}
+	return os.FileMode(n), nil
+}
+
+// writeDeployFile 写入部署文件。权限优先取设置值，未设置时保留已有文件的权限，新建文件使用 defMode；
+// 属主优先取设置值，未设置时保留已有文件的属主。先写入同目录临时文件并设置权限、属主与 SELinux 标签，
+// 再原子替换目标文件，避免服务在写入过程中读到不完整或权限未设置的证书。目标为符号链接时替换链接指向的文件
+func writeDeployFile(path string, data []byte, defMode os.FileMode, mode, owner string) error {
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	perm := defMode
+	uid, gid := -1, -1
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+		uid, gid = statOwner(info)
+	}
+	if mode != "" {
+		m, err := parseFileMode(mode)
+		if err != nil {
+			return err
+		}
+		perm = m
+	}
+	if err := ensureDeployDir(filepath.Dir(path), owner); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // 替换成功后临时文件已不存在
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	// 临时文件创建时为 0600，须显式设置权限（不受 umask 影响）
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	if owner != "" {
+		if err := chownTarget(tmp.Name(), owner); err != nil {
+			return err
+		}
+	} else if uid >= 0 && (uid != os.Geteuid() || gid != os.Getegid()) {
+		// 保留原属主需要相应权限（通常为 root），失败时仅提示
+		if err := os.Chown(tmp.Name(), uid, gid); err != nil {
+			color.Yellow("保留 %s 的属主失败: %v\n", path, err)
+		}
+	}
+	restoreSELinuxLabel(tmp.Name())
+	return os.Rename(tmp.Name(), path)
+}
+
+// ensureDeployDir 创建部署目录（0755）；新建的目录设置属主并恢复 SELinux 标签
+func ensureDeployDir(dir, owner string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := chownTarget(dir, owner); err != nil {
+		return err
+	}
+	restoreSELinuxLabel(dir)
+	return nil
+}
+
+// restoreSELinuxLabel 按策略恢复文件的 SELinux 标签（未启用 SELinux 或未安装 restorecon 时跳过）。
+// 新建的文件继承目录的类型，可能与服务所需的类型不同（如 cert_t），导致服务无法读取
+func restoreSELinuxLabel(path string) {
+	if !selinuxEnabled() {
+		return
+	}
+	bin, err := exec.LookPath(restoreconCommand)
+	if err != nil {
+		return
+	}
+	if out, err := exec.Command(bin, path).CombinedOutput(); err != nil {
+		color.Yellow("恢复 %s 的 SELinux 标签失败: %v %s\n", path, err, strings.TrimSpace(string(out)))
+	}
+}
+
+// deploymentPerm 部署位置的属主与权限描述（deploy list 展示用）
+func deploymentPerm(d db.Deployment) (owner, mode string) {
+	owner, mode = d.Owner, "保留"
+	if owner == "" {
+		owner = "保留"
+	}
+	switch {
+	case isCombinedPEM(d) && d.KeyMode != "":
+		mode = d.KeyMode
+	case isCombinedPEM(d):
+	case d.Mode != "" || d.KeyMode != "":
+		mode = orDefault(d.Mode, "保留") + " / " + orDefault(d.KeyMode, "保留")
+	}
+	return owner, mode
+}
+
+// orDefault 值为空时返回默认值
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
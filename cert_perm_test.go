@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"ssl_assistant/db"
+	"strings"
+	"testing"
+)
+
+// 权限：新建文件用默认权限，已有文件保留原权限，显式设置时覆盖（不受 umask 影响）
+func TestWriteDeployFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 不支持 Unix 权限")
+	}
+	dir := filepath.Join(t.TempDir(), "new", "ssl")
+	path := filepath.Join(dir, "a.key")
+	if err := writeDeployFile(path, []byte("K1"), 0600, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Fatalf("新建文件应为默认权限 0600: %v", info.Mode())
+	}
+	if info, _ := os.Stat(dir); info.Mode().Perm() != 0755 {
+		t.Fatalf("新建目录应为 0755: %v", info.Mode())
+	}
+
+	os.Chmod(path, 0640)
+	if err := writeDeployFile(path, []byte("K2"), 0600, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+		t.Fatalf("未设置权限时应保留已有文件的权限: %v", info.Mode())
+	}
+
+	if err := writeDeployFile(path, []byte("K3"), 0600, "0664", ""); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0664 || string(data) != "K3" {
+		t.Fatalf("应设置为指定权限: %v %q", info.Mode(), data)
+	}
+	if err := writeDeployFile(path, []byte("K4"), 0600, "999", ""); err == nil {
+		t.Fatal("无效权限应报错")
+	}
+}
+
+// 启用 SELinux 时写入后对文件执行 restorecon
+func TestRestoreSELinuxLabel(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("模拟 restorecon 依赖 POSIX shell")
+	}
+	dir := t.TempDir()
+	log := filepath.Join(dir, "restorecon.log")
+	bin := filepath.Join(dir, "restorecon")
+	os.WriteFile(bin, []byte("#!/bin/sh\necho \"$@\" >> "+log+"\n"), 0755)
+	oldCmd, oldEnabled := restoreconCommand, selinuxEnabled
+	restoreconCommand = bin
+	t.Cleanup(func() { restoreconCommand, selinuxEnabled = oldCmd, oldEnabled })
+
+	cert := db.Certificate{Domain: "se.com", PublicKey: "CERT", PrivateKey: "KEY"}
+	d := db.Deployment{CertPath: filepath.Join(dir, "pki", "se.crt"), KeyPath: filepath.Join(dir, "pki", "se.key")}
+	selinuxEnabled = func() bool { return false }
+	if err := writeDeploymentFiles(cert, d); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(log); !os.IsNotExist(err) {
+		t.Fatal("未启用 SELinux 时不应执行 restorecon")
+	}
+
+	selinuxEnabled = func() bool { return true }
+	if err := writeDeploymentFiles(cert, d); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(log)
+	got := strings.Fields(string(data))
+	if len(got) != 2 || !isDeployTemp(got[0], d.CertPath) || !isDeployTemp(got[1], d.KeyPath) {
+		t.Fatalf("应在替换前对证书与私钥的临时文件执行 restorecon: %q", data)
+	}
+}
+
+// isDeployTemp 是否为部署文件在同目录下的临时文件
+func isDeployTemp(tmp, path string) bool {
+	return filepath.Dir(tmp) == filepath.Dir(path) && strings.HasPrefix(filepath.Base(tmp), "."+filepath.Base(path)+".")
+}
+
+// 原子替换：写入后目录中不残留临时文件；目标为符号链接时更新链接指向的文件
+func TestWriteDeployFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.pem")
+	if err := writeDeployFile(path, []byte("A1"), 0644, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeDeployFile(path, []byte("A2"), 0644, "0640", ""); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if data, _ := os.ReadFile(path); string(data) != "A2" || len(entries) != 1 {
+		t.Fatalf("应替换为新内容且不残留临时文件: %q %d", data, len(entries))
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	real := filepath.Join(dir, "live", "b.pem")
+	os.MkdirAll(filepath.Dir(real), 0755)
+	os.WriteFile(real, []byte("B1"), 0644)
+	link := filepath.Join(dir, "b.pem")
+	if err := os.Symlink(real, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeDeployFile(link, []byte("B2"), 0644, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Lstat(link)
+	if data, _ := os.ReadFile(real); string(data) != "B2" || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("应更新链接指向的文件并保留符号链接: %q %v", data, info.Mode())
+	}
+}
+
+// 部署位置按设置的权限写入：证书/证书链用 Mode，私钥与合并文件用 KeyMode
+func TestWriteDeploymentFilesModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 不支持 Unix 权限")
+	}
+	dir := t.TempDir()
+	cert := db.Certificate{Domain: "perm.com", PublicKey: "CERT", PrivateKey: "KEY"}
+	split := db.Deployment{CertPath: filepath.Join(dir, "a.crt"), KeyPath: filepath.Join(dir, "a.key"), Mode: "0640", KeyMode: "0640"}
+	combined := db.Deployment{CertPath: filepath.Join(dir, "b.pem"), KeyPath: filepath.Join(dir, "b.pem"), KeyMode: "0640"}
+	for _, d := range []db.Deployment{split, combined} {
+		if err := writeDeploymentFiles(cert, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range []string{split.CertPath, split.KeyPath, combined.CertPath} {
+		if info, _ := os.Stat(p); info.Mode().Perm() != 0640 {
+			t.Fatalf("%s 权限应为 0640: %v", p, info.Mode())
+		}
+	}
+}
@@ -0,0 +1,48 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// 属主：未设置时保留已有文件的属主，设置时修改（需要 root）
+func TestWriteDeployFileOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("修改属主需要 root")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.pem")
+	os.WriteFile(path, []byte("old"), 0644)
+	os.Chown(path, 65534, 65534)
+	if err := writeDeployFile(path, []byte("new"), 0644, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if uid, gid := fileOwner(t, path); uid != 65534 || gid != 65534 {
+		t.Fatalf("未设置属主时应保留已有文件的属主: %d:%d", uid, gid)
+	}
+
+	// 新建目录与文件均设置为指定属主
+	sub := filepath.Join(dir, "haproxy", "a.pem")
+	if err := writeDeployFile(sub, []byte("new"), 0600, "", "65533:65533"); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{sub, filepath.Dir(sub)} {
+		if uid, gid := fileOwner(t, p); uid != 65533 || gid != 65533 {
+			t.Fatalf("%s 属主应为 65533:65533，实际 %d:%d", p, uid, gid)
+		}
+	}
+}
+
+func fileOwner(t *testing.T, path string) (uid, gid int) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := info.Sys().(*syscall.Stat_t)
+	return int(st.Uid), int(st.Gid)
+}
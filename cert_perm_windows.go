@@ -0,0 +1,8 @@
+package main
+
+import "os"
+
+// statOwner Windows 不支持 Unix 属主，恒返回 -1（不修改）
+func statOwner(os.FileInfo) (uid, gid int) {
+	return -1, -1
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"net"
+	"os"
+	"ssl_assistant/apacheconf"
+	"ssl_assistant/config"
+	"ssl_assistant/db"
+	"ssl_assistant/nginxconf"
+	"ssl_assistant/notify"
+	"ssl_assistant/utils"
+	"strings"
+	"time"
+)
+
+// defaultProbeHost 默认探测地址：本机（直连本机 Web 服务，绕过 CDN/负载均衡，确认本机已加载新证书）
+const defaultProbeHost = "127.0.0.1"
+
+// defaultProbePort 配置中未解析到 HTTPS 监听端口时的默认探测端口
+const defaultProbePort = "443"
+
+// probeTimeout 单次 TLS 握手超时
+var probeTimeout = 5 * time.Second
+
+// probeRetries / probeRetryDelay 部署后探测的重试次数与间隔（平滑重载后 worker 切换需要短暂时间；测试中替换）
+var (
+	probeRetries    = 3
+	probeRetryDelay = 2 * time.Second
+)
+
+// probeResult 单个域名 + 端口的探测结果
+type probeResult struct {
+	Domain   string
+	Addr     string            // 探测地址 host:port
+	Served   *x509.Certificate // 服务端实际返回的叶子证书
+	Deployed *x509.Certificate // 本地部署的证书文件（叶子证书）
+	Err      error             // 连接/握手/读取文件失败
+}
+
+// Match 服务端返回的证书与部署文件一致（序列号与 SHA-256 指纹均相同）
+func (r probeResult) Match() bool {
+	return r.Err == nil && r.Served != nil && r.Deployed != nil &&
+		r.Served.SerialNumber.Cmp(r.Deployed.SerialNumber) == 0 &&
+		certFingerprint(r.Served) == certFingerprint(r.Deployed)
+}
+
+// Summary 探测结果描述
+func (r probeResult) Summary() string {
+	switch {
+	case r.Err != nil:
+		return r.Err.Error()
+	case r.Match():
+		return "一致"
+	default:
+		return fmt.Sprintf("不一致：服务端证书序列号 %s（%s，到期 %s），部署文件序列号 %s",
+			certSerial(r.Served), r.Served.Subject.CommonName, r.Served.NotAfter.Format(time.DateOnly), certSerial(r.Deployed))
+	}
+}
+
+// certFingerprint 证书 SHA-256 指纹（十六进制）
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// certSerial 证书序列号（十六进制，大写）
+func certSerial(cert *x509.Certificate) string {
+	return strings.ToUpper(cert.SerialNumber.Text(16))
+}
+
+// readLeafCertificate 读取证书文件中的第一张证书（fullchain 的叶子证书）
+func readLeafCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取证书文件失败: %v", err)
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("证书文件 %s 中未找到证书", path)
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
+
+// keyTypeCipherSuites TLS 1.2 下按证书密钥类型限定的密码套件（双证书站点按类型探测）
+var keyTypeCipherSuites = map[string][]uint16{
+	"rsa": {
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA, tls.TLS_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	},
+	"ecdsa": {
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	},
+}
+
+// fetchServedCertificate 以 SNI 连接 addr，返回服务端发送的叶子证书（不校验证书链，只比对内容）。
+// keyType 非空时限定 TLS 1.2 与对应密码套件，使双证书（RSA + ECDSA）服务端返回该类型的证书
+func fetchServedCertificate(addr, serverName, keyType string) (*x509.Certificate, error) {
+	dialer := &net.Dialer{Timeout: probeTimeout}
+	cfg := &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true, // 仅比对证书内容，过期/自签名证书同样需要识别
+	}
+	if suites, ok := keyTypeCipherSuites[keyType]; ok {
+		cfg.MaxVersion = tls.VersionTLS12
+		cfg.CipherSuites = suites
+	}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("TLS 连接 %s 失败: %v", addr, err)
+	}
+	defer conn.Close()
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s 未返回证书", addr)
+	}
+	return certs[0], nil
+}
+
+// probeDomain 探测指定域名在各端口上返回的证书是否与部署文件一致
+func probeDomain(domain, certPath, host string, ports []string) []probeResult {
+	deployed, derr := readLeafCertificate(certPath)
+	if len(ports) == 0 {
+		ports = []string{defaultProbePort}
+	}
+	var results []probeResult
+	for _, port := range ports {
+		r := probeResult{Domain: domain, Addr: net.JoinHostPort(host, port), Deployed: deployed, Err: derr}
+		if derr == nil {
+			r.Served, r.Err = fetchServedCertificate(r.Addr, domain, "")
+			// 双证书站点默认握手返回服务端优先的类型（通常为 ECDSA），类型不同时按部署证书的类型重新探测
+			if kt := utils.CertKeyType(deployed); r.Err == nil && utils.CertKeyType(r.Served) != kt {
+				if served, err := fetchServedCertificate(r.Addr, domain, kt); err == nil {
+					r.Served = served
+				}
+			}
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// probeServerName 返回用于 SNI 的域名：通配符域名无法直接作为 SNI，取覆盖域名中第一个非通配符域名
+func probeServerName(cert db.Certificate) (string, bool) {
+	if !strings.HasPrefix(cert.Domain, "*.") {
+		return cert.Domain, true
+	}
+	for _, d := range strings.Split(cert.CertDomains, ",") {
+		if d = strings.TrimSpace(d); d != "" && !strings.HasPrefix(d, "*.") {
+			return d, true
+		}
+	}
+	return "", false
+}
+
+// probeHost 读取探测地址（配置 probe_host，默认本机）
+func probeHost() string {
+	if v, _ := config.GetConfig("", "probe_host"); strings.TrimSpace(v) != "" {
+		return strings.TrimSpace(v)
+	}
+	return defaultProbeHost
+}
+
+// probeAfterDeployEnabled 部署重载后是否自动探测（配置 probe_after_deploy，默认开启，0 关闭）
+func probeAfterDeployEnabled() bool {
+	v, _ := config.GetConfig("", "probe_after_deploy")
+	return strings.TrimSpace(v) != "0"
+}
+
+// --- HTTPS 监听端口解析（listen ... ssl / <VirtualHost *:443>）---
+
+// nginxSSLPorts 解析 server 块中启用 ssl 的 listen 端口（去重，保持出现顺序）。
+// 支持 listen 443 ssl / [::]:443 ssl / 127.0.0.1:8443 ssl http2；旧写法 ssl on; 时全部 listen 视为 HTTPS。
+func nginxSSLPorts(site nginxconf.Site) []string {
+	var ports []string
+	for _, l := range site.SSLListens() {
+		if port := listenPort(l.Arg(0)); port != "" && !containsString(ports, port) {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// apacheVirtualHostPorts 解析 <VirtualHost *:443 [::]:8443> 地址列表中的端口（未写端口的地址忽略）
+func apacheVirtualHostPorts(addrs []string) []string {
+	var ports []string
+	for _, addr := range addrs {
+		if !strings.Contains(addr, ":") {
+			continue
+		}
+		if port := listenPort(addr); port != "" && !containsString(ports, port) {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// listenPort 从监听地址中取端口：443 / *:443 / 1.2.3.4:443 / [::]:443；unix 套接字等返回空
+func listenPort(addr string) string {
+	if strings.HasPrefix(addr, "unix:") {
+		return ""
+	}
+	if i := strings.LastIndex(addr, ":"); i >= 0 {
+		addr = addr[i+1:]
+	}
+	for _, c := range addr {
+		if c < '0' || c > '9' {
+			return ""
+		}
+	}
+	return addr
+}
+
+// collectSitePorts 扫描默认配置路径，返回 域名 → HTTPS 监听端口（静默解析，不输出扫描过程）
+func collectSitePorts() map[string][]string {
+	result := make(map[string][]string)
+	eachConfigFile(func(path string) bool {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return false
+		}
+		var sites []nginxSite
+		if serverSites, ok := parseServerConfig(path, content, false); ok {
+			sites = serverSites
+		} else if isApacheConfig(string(content)) {
+			if cfg, err := apacheconf.Parse(path); err == nil {
+				sites = apacheSitesFromConfig(cfg)
+			}
+		} else if cfg, err := nginxconf.Parse(path); err == nil {
+			sites = nginxSitesFromConfig(cfg)
+		}
+		for _, site := range sites {
+			for _, d := range site.Domains {
+				result[d] = unionStrings(result[d], site.Ports)
+			}
+		}
+		return false
+	})
+	return result
+}
+
+// probeCertificates 探测证书列表，返回全部结果；无证书文件或无法确定 SNI 的证书跳过
+func probeCertificates(certs []db.Certificate, host string, sitePorts map[string][]string) []probeResult {
+	var results []probeResult
+	for _, cert := range certs {
+		if len(cert.Deployments) == 0 || cert.PendingSince > 0 {
+			continue
+		}
+		serverName, ok := probeServerName(cert)
+		if !ok {
+			color.Yellow("域名 %s 为通配符证书且无可用的具体域名，跳过探测\n", cert.Domain)
+			continue
+		}
+		// 各部署位置写入的是同一证书，以第一个位置的证书文件作为期望值
+		results = append(results, probeDomain(serverName, cert.Deployments[0].CertPath, host, sitePorts[serverName])...)
+	}
+	return results
+}
+
+// verifyDeployment 部署重载后探测已更新证书是否生效（失败重试，平滑重载需短暂时间），
+// 不一致时输出告警并记录重载失败通知
+func verifyDeployment(certs []db.Certificate, batch *notify.Batch) {
+	if len(certs) == 0 || !probeAfterDeployEnabled() {
+		return
+	}
+	host := probeHost()
+	sitePorts := collectSitePorts()
+	var failed []probeResult
+	for attempt := 0; attempt < probeRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(probeRetryDelay)
+		}
+		failed = failed[:0]
+		for _, r := range probeCertificates(certs, host, sitePorts) {
+			if !r.Match() {
+				failed = append(failed, r)
+			}
+		}
+		if len(failed) == 0 {
+			color.Green("TLS 探测通过：服务端已使用新部署的证书\n")
+			return
+		}
+	}
+	for _, r := range failed {
+		color.Red("TLS 探测 %s（%s）: %s\n", r.Domain, r.Addr, r.Summary())
+		batch.Add(notify.EventReloadFailed, r.Domain, "重载后探测 %s 未生效: %s", r.Addr, r.Summary())
+	}
+}
+
+// probeCommand 探测已管理证书（probe 命令）：domains 为空时探测全部，存在不一致时返回错误
+func probeCommand(domains []string, host string) error {
+	certs, err := db.GetAllCertificatesWrapper()
+	if err != nil {
+		return fmt.Errorf("获取证书信息失败: %s", err)
+	}
+	if len(domains) > 0 {
+		var selected []db.Certificate
+		for _, cert := range certs {
+			if containsString(domains, cert.Domain) {
+				selected = append(selected, cert)
+			}
+		}
+		certs = selected
+	}
+	if len(certs) == 0 {
+		color.Yellow("没有可探测的证书\n")
+		return nil
+	}
+	if host == "" {
+		host = probeHost()
+	}
+
+	results := probeCertificates(certs, host, collectSitePorts())
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"域名", "地址", "服务端序列号", "部署文件序列号", "结果"})
+	mismatch := 0
+	for _, r := range results {
+		served, deployed := "-", "-"
+		if r.Served != nil {
+			served = certSerial(r.Served)
+		}
+		if r.Deployed != nil {
+			deployed = certSerial(r.Deployed)
+		}
+		if !r.Match() {
+			mismatch++
+		}
+		table.Append([]string{r.Domain, r.Addr, served, deployed, r.Summary()})
+	}
+	table.Render()
+	if mismatch > 0 {
+		return fmt.Errorf("%d 个探测结果与部署文件不一致", mismatch)
+	}
+	color.Green("全部 %d 个探测结果一致\n", len(results))
+	return nil
+}
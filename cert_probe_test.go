@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// startTLSStandIn 以指定证书启动本地 TLS 服务（模拟 Web 服务），返回端口
+func startTLSStandIn(t *testing.T, certPath, keyPath string) string {
+	t.Helper()
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{pair}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				_ = c.(*tls.Conn).Handshake()
+			}(conn)
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	return port
+}
+
+// 服务端返回的证书与部署文件一致 / 不一致（序列号相同但指纹不同也应判为不一致）
+func TestProbeDomain(t *testing.T) {
+	servedCert, servedKey := genSelfSignedCert(t, t.TempDir(), "probe.example.com", 30)
+	otherCert, _ := genSelfSignedCert(t, t.TempDir(), "probe.example.com", 30)
+	port := startTLSStandIn(t, servedCert, servedKey)
+
+	results := probeDomain("probe.example.com", servedCert, "127.0.0.1", []string{port})
+	if len(results) != 1 || !results[0].Match() {
+		t.Fatalf("部署文件与服务端一致时应匹配: %+v", results)
+	}
+	if results[0].Summary() != "一致" {
+		t.Fatalf("结果描述错误: %s", results[0].Summary())
+	}
+
+	results = probeDomain("probe.example.com", otherCert, "127.0.0.1", []string{port})
+	if len(results) != 1 || results[0].Match() || results[0].Err != nil {
+		t.Fatalf("部署文件与服务端不同时应判为不一致: %+v", results)
+	}
+	if !strings.Contains(results[0].Summary(), "不一致") {
+		t.Fatalf("结果描述应包含不一致: %s", results[0].Summary())
+	}
+
+	// 端口未监听：返回连接错误
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	_, closedPort, _ := net.SplitHostPort(ln.Addr().String())
+	ln.Close()
+	results = probeDomain("probe.example.com", servedCert, "127.0.0.1", []string{closedPort})
+	if results[0].Err == nil || results[0].Match() {
+		t.Fatalf("连接失败应返回错误: %+v", results[0])
+	}
+}
+
+// listen ... ssl 端口解析
+func TestNginxSSLPorts(t *testing.T) {
+	block := `server {
+    listen 80;
+    listen 443 ssl http2;
+    listen [::]:443 ssl;
+    listen 127.0.0.1:8443 ssl;
+    listen unix:/run/nginx.sock ssl;
+    # listen 9443 ssl;
+    server_name example.com;
+    ssl_certificate /certs/example.pem;
+    ssl_certificate_key /certs/example.key;
+}`
+	ports := func(content string) []string {
+		sites := parseNginxSitesForTest(t, content)
+		if len(sites) != 1 {
+			t.Fatalf("应解析出 1 个站点，实际 %d", len(sites))
+		}
+		return sites[0].Ports
+	}
+	if got := ports(block); !reflect.DeepEqual(got, []string{"443", "8443"}) {
+		t.Fatalf("ssl 端口解析错误: %v", got)
+	}
+	legacy := "server {\n    listen 443;\n    ssl on;\n    server_name a.com;\n    ssl_certificate a.pem;\n    ssl_certificate_key a.key;\n}"
+	if got := ports(legacy); !reflect.DeepEqual(got, []string{"443"}) {
+		t.Fatalf("ssl on 旧写法解析错误: %v", got)
+	}
+	plain := "server {\n    listen 80;\n    server_name a.com;\n    ssl_certificate a.pem;\n    ssl_certificate_key a.key;\n}"
+	if got := ports(plain); len(got) != 0 {
+		t.Fatalf("无 ssl 监听应为空: %v", got)
+	}
+}
+
+// <VirtualHost> 端口解析
+func TestApacheVirtualHostPorts(t *testing.T) {
+	if got := apacheVirtualHostPorts([]string{"*:443", "[::]:8443"}); !reflect.DeepEqual(got, []string{"443", "8443"}) {
+		t.Fatalf("VirtualHost 端口解析错误: %v", got)
+	}
+	if got := apacheVirtualHostPorts([]string{"example.com"}); len(got) != 0 {
+		t.Fatalf("未写端口应为空: %v", got)
+	}
+}
+
+// 双证书服务端（ECDSA 优先）：部署的 RSA 证书应按类型重新探测并判为一致
+func TestProbeDomainDualCert(t *testing.T) {
+	rsaCert, rsaKey := genSelfSignedCert(t, t.TempDir(), "dual.example.com", 30)
+	eccCert, eccKey := genSelfSignedECDSACert(t, t.TempDir(), "dual.example.com", 30)
+	var certs []tls.Certificate
+	for _, p := range [][2]string{{eccCert, eccKey}, {rsaCert, rsaKey}} {
+		pair, err := tls.LoadX509KeyPair(p[0], p[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		certs = append(certs, pair)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: certs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				_ = c.(*tls.Conn).Handshake()
+			}(conn)
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	for _, certPath := range []string{eccCert, rsaCert} {
+		results := probeDomain("dual.example.com", certPath, "127.0.0.1", []string{port})
+		if len(results) != 1 || !results[0].Match() {
+			t.Fatalf("双证书探测 %s 应一致: %s", certPath, results[0].Summary())
+		}
+	}
+}
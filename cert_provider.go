@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"ssl_assistant/config"
+	"ssl_assistant/db"
+	"ssl_assistant/third/certd"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+)
+
+// --- 证书平台（provider）：平台实例（third.<平台>.<实例名>）、获取顺序（全局 provider_order 与证书级 Providers）、
+// 未配置平台跳过、续期记录 ---
+
+// providerNames 支持的证书平台
+var providerNames = []string{"west", "certd"}
+
+// defaultProviderOrder 未配置 provider_order 时的获取顺序（每个平台展开为其全部实例）
+const defaultProviderOrder = "west,certd"
+
+// defaultInstance 默认实例名（由旧版 third.<平台> 配置迁移而来，引用时可省略实例名）
+const defaultInstance = "default"
+
+// instanceNameRegex 实例名：小写字母、数字、- 和 _
+var instanceNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// providerRef 平台实例引用：写作 <平台>（默认实例）或 <平台>.<实例名>，如 certd、certd.prod
+type providerRef struct {
+	Type string // 平台：west / certd
+	Name string // 实例名，默认实例为 default
+}
+
+// String 引用写法（默认实例省略实例名）
+func (r providerRef) String() string {
+	if r.Name == "" || r.Name == defaultInstance {
+		return r.Type
+	}
+	return r.Type + "." + r.Name
+}
+
+// instance 证书记录中保存的实例名（默认实例记为空）
+func (r providerRef) instance() string {
+	if r.Name == defaultInstance {
+		return ""
+	}
+	return r.Name
+}
+
+// section 实例的配置分区
+func (r providerRef) section() string {
+	name := r.Name
+	if name == "" {
+		name = defaultInstance
+	}
+	return "third." + r.Type + "." + name
+}
+
+// same 是否为同一平台实例（默认实例的实例名可为空或 default）
+func (r providerRef) same(o providerRef) bool {
+	return r.Type == o.Type && r.section() == o.section()
+}
+
+// parseProviderRef 解析平台实例引用（忽略大小写）；不支持的平台或实例名无效时报错
+func parseProviderRef(s string) (providerRef, error) {
+	typ, name, _ := strings.Cut(strings.ToLower(strings.TrimSpace(s)), ".")
+	if !containsString(providerNames, typ) {
+		return providerRef{}, fmt.Errorf("不支持的证书平台: %s（可选 %s）", typ, strings.Join(providerNames, " / "))
+	}
+	if name == "" {
+		name = defaultInstance
+	} else if !instanceNameRegex.MatchString(name) {
+		return providerRef{}, fmt.Errorf("平台实例名 %s 无效（仅支持小写字母、数字、- 和 _）", name)
+	}
+	return providerRef{Type: typ, Name: name}, nil
+}
+
+// certSourceRef 证书来源对应的平台实例
+func certSourceRef(cert db.Certificate) providerRef {
+	return providerRef{Type: cert.CertSource, Name: cert.ProviderInstance}
+}
+
+// providerInstances 平台已配置的实例（按配置文件中出现顺序，默认实例在前）；尚无实例时返回默认实例
+func providerInstances(typ string) []providerRef {
+	refs := []providerRef{{Type: typ, Name: defaultInstance}}
+	for _, section := range config.Sections("third." + typ + ".") {
+		name := strings.TrimPrefix(section, "third."+typ+".")
+		if name != defaultInstance && instanceNameRegex.MatchString(name) {
+			refs = append(refs, providerRef{Type: typ, Name: name})
+		}
+	}
+	if len(refs) > 1 && !config.HasSection(refs[0].section()) {
+		refs = refs[1:]
+	}
+	return refs
+}
+
+// migrateProviderConfig 将旧版 third.certd / third.west 配置迁移为默认实例（third.<平台>.default）
+func migrateProviderConfig() error {
+	for _, typ := range providerNames {
+		legacy := "third." + typ
+		if !config.HasSection(legacy) {
+			continue
+		}
+		to := providerRef{Type: typ, Name: defaultInstance}.section()
+		if err := config.RenameSection(legacy, to); err != nil {
+			return fmt.Errorf("迁移 %s 配置失败: %w", legacy, err)
+		}
+		color.Cyan("已将 %s 配置迁移为默认实例 %s\n", legacy, to)
+	}
+	return nil
+}
+
+// checkProviderInstance 检查命名实例是否存在（默认实例未配置时获取会跳过，不在此报错）
+func checkProviderInstance(ref providerRef) error {
+	if ref.instance() != "" && !config.HasSection(ref.section()) {
+		return fmt.Errorf("平台实例 %s 不存在（配置分区 %s），请先通过修改密钥新建该实例", ref, ref.section())
+	}
+	return nil
+}
+
+// providerConfigured 平台实例密钥是否配置完整（certd：api_url + key_id + key_secret；west：username + api_key）
+func providerConfigured(ref providerRef) bool {
+	var keys []string
+	switch ref.Type {
+	case "certd":
+		keys = []string{"api_url", "key_id", "key_secret"}
+	case "west":
+		keys = []string{"username", "api_key"}
+	default:
+		return false
+	}
+	// GetConfig 读取不存在的分区或键时返回空值（不会创建分区），分区缺失即视为未配置
+	for _, k := range keys {
+		if v, _ := config.GetConfig(ref.section(), k); v == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseProviders 解析平台顺序（逗号或空格分隔的平台实例引用），去重；不支持的平台或实例名无效时报错
+func parseProviders(s string) ([]string, error) {
+	var list []string
+	for _, p := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		ref, err := parseProviderRef(p)
+		if err != nil {
+			return nil, err
+		}
+		if !containsString(list, ref.String()) {
+			list = append(list, ref.String())
+		}
+	}
+	return list, nil
+}
+
+// globalProviderOrder 全局平台获取顺序（provider_order，配置有误时提示并使用默认顺序）；
+// 未配置时按默认顺序列出各平台的全部实例
+func globalProviderOrder() []string {
+	v, _ := config.GetConfig("", "provider_order")
+	if list, err := parseProviders(v); err != nil {
+		color.Yellow("provider_order 配置有误（%v），使用默认顺序 %s\n", err, defaultProviderOrder)
+	} else if len(list) > 0 {
+		return list
+	}
+	var list []string
+	for _, typ := range strings.Split(defaultProviderOrder, ",") {
+		for _, ref := range providerInstances(typ) {
+			list = append(list, ref.String())
+		}
+	}
+	return list
+}
+
+// certProviders 证书更新时的平台获取顺序：证书级 Providers 优先；
+// 未设置时使用证书来源对应的平台实例（平台证书ID 属于该实例），本地添加的证书使用全局顺序
+func certProviders(cert db.Certificate) []string {
+	if list, err := parseProviders(cert.Providers); err == nil && len(list) > 0 {
+		return list
+	}
+	if containsString(providerNames, cert.CertSource) {
+		return []string{certSourceRef(cert).String()}
+	}
+	return globalProviderOrder()
+}
+
+// fetchFromProvider 从指定平台实例获取证书（certID 仅 certd 使用）
+func fetchFromProvider(ref providerRef, domain string, certID int) (crt, key []byte, detail *certd.CertDetail, err error) {
+	switch ref.Type {
+	case "west":
+		crt, key, err = fetchWestCert(ref, domain)
+		return crt, key, nil, err
+	case "certd":
+		return fetchCertdCert(ref, domain, certID)
+	}
+	return nil, nil, nil, fmt.Errorf("不支持的证书平台: %s", ref.Type)
+}
+
+// recordRenewal 记录一次续期（时间、提供证书的平台实例与新证书到期时间）
+func recordRenewal(cert db.Certificate) db.Certificate {
+	cert.LastRenew = time.Now().Unix()
+	cert.Renewals = cert.Renewals.Add(db.RenewRecord{Time: cert.LastRenew, Provider: certSourceRef(cert).String(), ExpireTime: cert.ExpireTime})
+	return cert
+}
+
+// historyCommand history 命令：显示证书的续期记录
+func historyCommand(arg string) error {
+	cert, err := getCertByIDOrDomain(arg)
+	if err != nil {
+		return err
+	}
+	label := certLabel(cert.Domain, cert.KeyType)
+	fmt.Printf("域名 %s 的平台获取顺序: %s\n", label, strings.Join(certProviders(cert), " → "))
+	if len(cert.Renewals) == 0 {
+		color.Yellow("域名 %s 暂无续期记录\n", label)
+		return nil
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"续期时间", "平台", "有效期至"})
+	for i := len(cert.Renewals) - 1; i >= 0; i-- {
+		r := cert.Renewals[i]
+		table.Append([]string{
+			time.Unix(r.Time, 0).Format(time.DateTime),
+			r.Provider,
+			time.Unix(r.ExpireTime, 0).Format(time.DateOnly),
+		})
+	}
+	table.Render()
+	return nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"ssl_assistant/config"
+	"ssl_assistant/db"
+	"strings"
+	"testing"
+)
+
+// useTempConfig 切换到临时目录使用独立的配置文件，测试结束后切回并重新加载原配置
+func useTempConfig(t *testing.T) {
+	t.Helper()
+	oldwd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.InitConfig(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(oldwd)
+		_ = config.InitConfig()
+	})
+}
+
+// 平台顺序解析：逗号或空格分隔、忽略大小写、去重；不支持的平台报错
+func TestParseProviders(t *testing.T) {
+	list, err := parseProviders(" Certd, west certd ")
+	if err != nil || strings.Join(list, ",") != "certd,west" {
+		t.Fatalf("解析错误: %v %v", list, err)
+	}
+	if list, err := parseProviders(""); err != nil || len(list) != 0 {
+		t.Fatalf("空串应返回空列表: %v %v", list, err)
+	}
+	if _, err := parseProviders("certd,acme"); err == nil {
+		t.Fatal("不支持的平台应报错")
+	}
+	list, err = parseProviders("certd.Prod,west.default,certd.prod")
+	if err != nil || strings.Join(list, ",") != "certd.prod,west" {
+		t.Fatalf("实例引用解析错误（默认实例省略实例名）: %v %v", list, err)
+	}
+	if _, err := parseProviders("certd.a/b"); err == nil {
+		t.Fatal("实例名无效应报错")
+	}
+}
+
+// 证书平台顺序：证书级设置优先，其次为证书来源对应的平台，本地证书使用全局顺序
+func TestCertProviders(t *testing.T) {
+	setConfig(t, "", "provider_order", "")
+	if got := strings.Join(globalProviderOrder(), ","); got != defaultProviderOrder {
+		t.Fatalf("未配置时应使用默认顺序: %s", got)
+	}
+	setConfig(t, "", "provider_order", "certd")
+	if got := strings.Join(globalProviderOrder(), ","); got != "certd" {
+		t.Fatalf("应使用配置的顺序: %s", got)
+	}
+	setConfig(t, "", "provider_order", "certd,foo")
+	if got := strings.Join(globalProviderOrder(), ","); got != defaultProviderOrder {
+		t.Fatalf("配置有误时应使用默认顺序: %s", got)
+	}
+	setConfig(t, "", "provider_order", "certd,west")
+
+	cases := []struct {
+		cert db.Certificate
+		want string
+	}{
+		{db.Certificate{CertSource: "west", Providers: "certd,west"}, "certd,west"},
+		{db.Certificate{CertSource: "west"}, "west"},
+		{db.Certificate{CertSource: "certd", ProviderInstance: "prod"}, "certd.prod"},
+		{db.Certificate{CertSource: "local"}, "certd,west"},
+		{db.Certificate{CertSource: "certbot"}, "certd,west"},
+	}
+	for _, c := range cases {
+		if got := strings.Join(certProviders(c.cert), ","); got != c.want {
+			t.Errorf("%+v: 期望 %s，实际 %s", c.cert, c.want, got)
+		}
+	}
+}
+
+// 未配置密钥的平台跳过，不发起请求；全部未配置时报错
+func TestGetCertificateInfoSkipsUnconfigured(t *testing.T) {
+	certdRef, westRef := providerRef{"certd", defaultInstance}, providerRef{"west", defaultInstance}
+	for _, k := range []string{"api_url", "key_id", "key_secret"} {
+		setConfig(t, certdRef.section(), k, "")
+	}
+	setConfig(t, westRef.section(), "username", "")
+	setConfig(t, westRef.section(), "api_key", "")
+	if providerConfigured(certdRef) || providerConfigured(westRef) || providerConfigured(providerRef{"foo", defaultInstance}) {
+		t.Fatal("密钥未配置的平台不应视为就绪")
+	}
+	if providerConfigured(providerRef{"west", "missing"}) || config.HasSection("third.west.missing") {
+		t.Fatal("不存在的实例不应视为就绪，且不应创建配置分区")
+	}
+	_, err := getCertificateInfo("skip.com", []string{"west", "certd"}, 0, providerRef{})
+	if err == nil || !strings.Contains(err.Error(), "均未配置") {
+		t.Fatalf("平台均未配置应报错: %v", err)
+	}
+	setConfig(t, westRef.section(), "username", "u")
+	setConfig(t, westRef.section(), "api_key", "k")
+	if !providerConfigured(westRef) {
+		t.Fatal("username 与 api_key 均配置时应视为就绪")
+	}
+}
+
+// certdInstance 模拟 Certd 实例：记录收到的证书ID，fail 时返回确定性错误（不重试），否则返回 crt
+func certdInstance(t *testing.T, ref providerRef, crt []byte, fail bool, gotIDs *[]int) {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			CertID int `json:"certId"`
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &req)
+		*gotIDs = append(*gotIDs, req.CertID)
+		if fail {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, _ := json.Marshal(map[string]interface{}{"code": 0, "data": map[string]string{"crt": string(crt), "key": "KEY"}})
+		fmt.Fprint(w, string(data))
+	}))
+	t.Cleanup(ts.Close)
+	setConfig(t, ref.section(), "api_url", ts.URL)
+	setConfig(t, ref.section(), "key_id", "id")
+	setConfig(t, ref.section(), "key_secret", "secret")
+}
+
+// 平台证书ID只发给其所属的实例：同平台的其他实例与其他平台按域名查询
+func TestGetCertificateInfoCertIDPerInstance(t *testing.T) {
+	useTempConfig(t)
+	certPath, _ := genSelfSignedCert(t, t.TempDir(), "two.com", 30)
+	crt, _ := os.ReadFile(certPath)
+	prod, staging := providerRef{"certd", "prod"}, providerRef{"certd", "staging"}
+	var prodIDs, stagingIDs []int
+	certdInstance(t, prod, crt, true, &prodIDs)
+	certdInstance(t, staging, crt, false, &stagingIDs)
+
+	cert, err := getCertificateInfo("two.com", []string{"certd.prod", "certd.staging"}, 42, prod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prodIDs) != 1 || prodIDs[0] != 42 || len(stagingIDs) != 1 || stagingIDs[0] != 0 {
+		t.Fatalf("证书ID应只发给所属实例: prod=%v staging=%v", prodIDs, stagingIDs)
+	}
+	if cert.CertSource != "certd" || cert.ProviderInstance != "staging" {
+		t.Fatalf("应记录实际提供证书的实例: %s.%s", cert.CertSource, cert.ProviderInstance)
+	}
+
+	if _, err := getCertificateInfo("two.com", []string{"certd.staging"}, 42, staging); err != nil || stagingIDs[1] != 42 {
+		t.Fatalf("所属实例应收到证书ID: %v %v", stagingIDs, err)
+	}
+}
+
+// 续期记录：记录提供证书的平台与到期时间，续期后继承原有记录
+func TestRecordRenewal(t *testing.T) {
+	old := db.Certificate{ID: 1, CertSource: "west", Renewals: db.RenewHistory{{Time: 1, Provider: "west", ExpireTime: 2}}}
+	newCert := recordRenewal(inheritCertFields(db.Certificate{CertSource: "certd", ExpireTime: 1900000000}, old))
+	if len(newCert.Renewals) != 2 || newCert.LastRenew == 0 {
+		t.Fatalf("应追加续期记录: %+v", newCert.Renewals)
+	}
+	if r := newCert.Renewals[1]; r.Provider != "certd" || r.ExpireTime != 1900000000 || r.Time != newCert.LastRenew {
+		t.Fatalf("续期记录内容错误: %+v", r)
+	}
+	if len(old.Renewals) != 1 {
+		t.Fatal("不应修改原证书的续期记录")
+	}
+}
+
+// 平台实例：旧版配置迁移为默认实例（不覆盖命名实例），列出全部实例，配置项按实例显示
+func TestProviderInstances(t *testing.T) {
+	useTempConfig(t)
+	_ = config.SetConfig("third.certd", "api_url", "https://old.example.com")
+	_ = config.SetConfig("third.certd", "key_id", "old-id")
+	_ = config.SetConfig("third.certd.prod", "api_url", "https://prod.example.com")
+	_ = config.SetConfig("third.west", "username", "old-user")
+	if v, _ := config.GetConfig("third.certd", "api_url"); v != "https://old.example.com" {
+		t.Fatalf("写入命名实例不应修改旧版配置: %s", v)
+	}
+
+	if err := migrateProviderConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if config.HasSection("third.certd") || config.HasSection("third.west") {
+		t.Fatal("迁移后应删除旧版配置分区")
+	}
+	if v, _ := config.GetConfig("third.certd.default", "key_id"); v != "old-id" {
+		t.Fatalf("旧版配置应迁移为默认实例: %s", v)
+	}
+	if v, _ := config.GetConfig("third.west.default", "username"); v != "old-user" {
+		t.Fatalf("旧版配置应迁移为默认实例: %s", v)
+	}
+	if v, _ := config.GetConfig("third.certd.prod", "api_url"); v != "https://prod.example.com" {
+		t.Fatalf("命名实例配置不应被覆盖: %s", v)
+	}
+	if v, _ := config.GetConfig("third.certd.prod", "key_id"); v != "" {
+		t.Fatalf("迁移后命名实例不应再继承旧版配置: %s", v)
+	}
+	if err := migrateProviderConfig(); err != nil {
+		t.Fatal("重复迁移应无操作:", err)
+	}
+
+	setConfig(t, "", "provider_order", "")
+	if got := strings.Join(globalProviderOrder(), ","); got != "west,certd,certd.prod" {
+		t.Fatalf("未配置顺序时应列出全部实例: %s", got)
+	}
+	if err := checkProviderInstance(providerRef{"certd", "prod"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkProviderInstance(providerRef{"certd", "staging"}); err == nil {
+		t.Fatal("不存在的命名实例应报错")
+	}
+	str := func(s string) *string { return &s }
+	r, err := applyCertEdit(db.Certificate{CertSource: "west"}, certEdit{Source: str("certd.prod"), Providers: str("certd.prod,west")})
+	if err != nil || r.cert.CertSource != "certd" || r.cert.ProviderInstance != "prod" || r.cert.Providers != "certd.prod,west" {
+		t.Fatalf("编辑证书应支持指定平台实例: %+v %v", r.cert, err)
+	}
+	if _, err := applyCertEdit(db.Certificate{}, certEdit{Providers: str("certd.staging")}); err == nil {
+		t.Fatal("平台顺序引用不存在的实例应报错")
+	}
+	if got := configDisplayName("third.certd.prod.api_url"); got != "certd(prod) ApiUrl" {
+		t.Fatalf("命名实例配置项显示名错误: %s", got)
+	}
+	if got := configDisplayName("third.west.default.api_key"); got != "西部数码 apiKey" {
+		t.Fatalf("默认实例配置项显示名错误: %s", got)
+	}
+}
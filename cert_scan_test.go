@@ -113,3 +113,95 @@ func TestDiscoverPhpstudyFromRootMissing(t *testing.T) {
 		t.Fatalf("不存在的根目录应返回空，实际 %v", paths)
 	}
 }
+
+// panelByName 按名称取注册的面板
+func panelByName(t *testing.T, name string) *panelLayout {
+	t.Helper()
+	for i := range panels {
+		if panels[i].Name == name {
+			return &panels[i]
+		}
+	}
+	t.Fatalf("未注册面板 %s", name)
+	return nil
+}
+
+// 1Panel OpenResty 应用目录：站点配置在 conf/conf.d，证书在 www/sites/<站点名>/ssl；
+// 站点名为自定义别名（非域名）时按证书 SAN 识别域名，无法解析证书时跳过
+func TestOnePanelLayout(t *testing.T) {
+	root := t.TempDir()
+	openresty := filepath.Join(root, "apps", "openresty", "openresty")
+	os.MkdirAll(filepath.Join(openresty, "conf", "conf.d"), 0755)
+	os.WriteFile(filepath.Join(openresty, "conf", "conf.d", "a.com.conf"), []byte("server {}"), 0644)
+	sites := filepath.Join(openresty, "www", "sites")
+	for _, name := range []string{"a.com", "blog", "broken"} {
+		os.MkdirAll(filepath.Join(sites, name, "ssl"), 0755)
+	}
+	genSelfSignedCert(t, filepath.Join(sites, "a.com", "ssl"), "a.com", 30)
+	genSelfSignedCert(t, filepath.Join(sites, "blog", "ssl"), "blog.b.com", 30)
+	os.WriteFile(filepath.Join(sites, "broken", "ssl", "fullchain.pem"), []byte("crt"), 0644)
+	os.WriteFile(filepath.Join(sites, "broken", "ssl", "privkey.pem"), []byte("key"), 0600)
+
+	p := installedPanel{Layout: panelByName(t, "1Panel"), Root: root}
+	var confs []string
+	for _, pattern := range p.Configs() {
+		m, _ := filepath.Glob(pattern)
+		confs = append(confs, m...)
+	}
+	if len(confs) != 1 || filepath.Base(confs[0]) != "a.com.conf" {
+		t.Fatalf("应找到 OpenResty 站点配置: %v", confs)
+	}
+
+	got := map[string]nginxSite{}
+	for _, s := range p.CertSites() {
+		got[s.Domain] = s
+	}
+	if len(got) != 2 {
+		t.Fatalf("应识别 2 个站点（无法识别域名的跳过）: %+v", got)
+	}
+	if s := got["a.com"]; s.CertPath != filepath.Join(sites, "a.com", "ssl", "fullchain.pem") || !containsString(s.Domains, "www.a.com") {
+		t.Fatalf("站点 a.com 识别错误: %+v", s)
+	}
+	if s, ok := got["blog.b.com"]; !ok || s.KeyPath != filepath.Join(sites, "blog", "ssl", "privkey.pem") {
+		t.Fatalf("别名站点应按证书 SAN 识别域名: %+v", got)
+	}
+}
+
+// 1Panel 安装目录取 1pctl 中的 BASE_DIR
+func TestOnePanelRoots(t *testing.T) {
+	ctl := filepath.Join(t.TempDir(), "1pctl")
+	os.WriteFile(ctl, []byte("#!/bin/bash\nBASE_DIR=/data\nORIGINAL_PORT=10086\n"), 0755)
+	old := onePanelCtl
+	onePanelCtl = ctl
+	t.Cleanup(func() { onePanelCtl = old })
+	roots := onePanelRoots()
+	if len(roots) != 2 || roots[0] != filepath.Join("/data", "1panel") {
+		t.Fatalf("应优先使用 BASE_DIR 下的 1panel 目录: %v", roots)
+	}
+}
+
+// 按安装目录识别面板：宝塔/aaPanel 的站点证书目录、小皮面板 Linux 版的站点配置；未安装的面板忽略
+func TestDetectPanels(t *testing.T) {
+	bt, xp := t.TempDir(), t.TempDir()
+	os.MkdirAll(filepath.Join(bt, "panel", "vhost", "cert", "c.com"), 0755)
+	genSelfSignedCert(t, filepath.Join(bt, "panel", "vhost", "cert", "c.com"), "c.com", 30)
+	layouts := []panelLayout{*panelByName(t, "宝塔/aaPanel"), *panelByName(t, "小皮面板"), *panelByName(t, "1Panel")}
+	layouts[0].Roots = staticRoots(bt)
+	layouts[1].Roots = staticRoots(xp)
+	layouts[2].Roots = staticRoots(filepath.Join(t.TempDir(), "nope"))
+
+	found := detectPanels(layouts)
+	if len(found) != 2 || found[0].Layout.Name != "宝塔/aaPanel" || found[1].Root != xp {
+		t.Fatalf("应识别宝塔与小皮面板: %+v", found)
+	}
+	if s := found[0].CertSites(); len(s) != 1 || s[0].Domain != "c.com" {
+		t.Fatalf("宝塔证书目录识别错误: %+v", s)
+	}
+	if s := found[1].CertSites(); len(s) != 0 {
+		t.Fatalf("小皮面板没有站点证书目录约定: %+v", s)
+	}
+	confs := found[1].Configs()
+	if len(confs) != 2 || !strings.HasPrefix(confs[0], filepath.Join(xp, "vhost")) {
+		t.Fatalf("小皮面板站点配置路径错误: %v", confs)
+	}
+}
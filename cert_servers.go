@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"ssl_assistant/db"
+	"ssl_assistant/serverconf"
+	"ssl_assistant/utils"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// serverKindNames 配置类型展示名
+var serverKindNames = map[serverconf.Kind]string{
+	serverconf.Caddy:    "Caddy",
+	serverconf.HAProxy:  "HAProxy",
+	serverconf.Traefik:  "Traefik",
+	serverconf.Lighttpd: "lighttpd",
+}
+
+// parseServerConfig 解析 Caddy / HAProxy / Traefik / lighttpd 配置（按文件名与内容识别），ok 为 false 表示不是这几种配置。
+// verbose 时输出解析过程（find/init 检索），静默时用于路径匹配与端口收集
+func parseServerConfig(path string, content []byte, verbose bool) (sites []nginxSite, ok bool) {
+	kind := serverconf.Detect(path, content)
+	if kind == "" {
+		return nil, false
+	}
+	if verbose {
+		fmt.Printf("解析配置文件: %s（%s）\n", path, serverKindNames[kind])
+	}
+	parsed, err := serverconf.ParseBytes(kind, content, path)
+	if err != nil {
+		if verbose {
+			fmt.Println("解析配置文件失败:", err)
+		}
+		return nil, true
+	}
+	for _, s := range parsed {
+		site, found := serverSite(s)
+		if !found {
+			if verbose {
+				color.Yellow("  %s 未声明域名且证书文件 %s 无法读取，已跳过\n", s.Pos(), s.CertPath)
+			}
+			continue
+		}
+		if verbose {
+			fmt.Printf("  发现站点 %s（%s）\n", site.Domain, site.Location)
+		}
+		sites = append(sites, site)
+	}
+	return sites, true
+}
+
+// serverSite 转换为 nginxSite：配置未声明域名（HAProxy crt、Traefik）时取证书文件的 SAN，证书不可读则无法确定域名
+func serverSite(s serverconf.Site) (nginxSite, bool) {
+	names := s.Names
+	if len(names) == 0 {
+		names = localCertNames(s.CertPath)
+	}
+	if len(names) == 0 {
+		return nginxSite{}, false
+	}
+	return nginxSite{
+		Domain:   names[0],
+		Domains:  names,
+		CertPath: s.CertPath,
+		KeyPath:  s.KeyPath,
+		Ports:    s.Ports,
+		Location: s.Pos(),
+	}, true
+}
+
+// localCertNames 读取本地证书文件覆盖的域名（SAN，无 SAN 时取 CN）
+func localCertNames(certPath string) []string {
+	content, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil
+	}
+	cert, err := utils.ParseCertificate(content)
+	if err != nil {
+		return nil
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames
+	}
+	if cert.Subject.CommonName != "" {
+		return []string{cert.Subject.CommonName}
+	}
+	return nil
+}
+
+// --- 证书与私钥合并在同一文件（HAProxy crt、lighttpd 未配置 ssl.privkey 的 ssl.pemfile）---
+
+// isCombinedPEM 部署位置的证书与私钥路径相同时按合并文件部署
+func isCombinedPEM(d db.Deployment) bool {
+	return d.CertPath != "" && filepath.Clean(d.CertPath) == filepath.Clean(d.KeyPath)
+}
+
+// combinedPEM 合并文件内容：完整证书链在前、私钥在后
+func combinedPEM(cert db.Certificate) string {
+	pub := cert.PublicKey
+	if pub != "" && !strings.HasSuffix(pub, "\n") {
+		pub += "\n"
+	}
+	return pub + cert.PrivateKey
+}
+
+// splitCombinedPEM 拆分合并文件为证书链与私钥（按块类型，顺序不限）
+func splitCombinedPEM(content []byte) (pub, key string) {
+	var certs, keys []byte
+	rest := content
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return string(certs), string(keys)
+		}
+		switch {
+		case block.Type == "CERTIFICATE":
+			certs = append(certs, pem.EncodeToMemory(block)...)
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			keys = append(keys, pem.EncodeToMemory(block)...)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"ssl_assistant/db"
+	"strings"
+	"testing"
+)
+
+// HAProxy crt 合并文件（私钥在前）：域名取证书 SAN，本地回退按块类型拆分，部署写回合并文件（0600）
+func TestHAProxyCombinedPEM(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := genSelfSignedCert(t, dir, "edge.com", 30)
+	crt, _ := os.ReadFile(certPath)
+	key, _ := os.ReadFile(keyPath)
+	combined := filepath.Join(dir, "edge.com.pem")
+	if err := os.WriteFile(combined, append(append([]byte{}, key...), crt...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := filepath.Join(dir, "haproxy.cfg")
+	os.WriteFile(cfg, []byte("frontend https\n    bind *:443 ssl crt "+combined+"\n"), 0644)
+
+	sites := parseConfigFile(cfg)
+	if len(sites) != 1 || sites[0].Domain != "edge.com" || sites[0].Domains[1] != "www.edge.com" || sites[0].KeyPath != combined || sites[0].Ports[0] != "443" {
+		t.Fatalf("HAProxy 站点解析错误: %+v", sites)
+	}
+	if pairs := extractCertPairsFromFile(cfg, "www.edge.com"); len(pairs) != 1 || pairs[0].CertPath != combined {
+		t.Fatalf("HAProxy 证书路径提取错误: %+v", pairs)
+	}
+
+	cert, err := buildCertFromLocalFiles("edge.com", combined, combined)
+	if err != nil {
+		t.Fatalf("读取合并文件失败: %v", err)
+	}
+	if cert.PublicKey != string(crt) || cert.PrivateKey != string(key) || cert.KeyType != "rsa" {
+		t.Fatalf("合并文件拆分错误: pub=%q key 前缀=%q", cert.PublicKey, cert.PrivateKey[:30])
+	}
+
+	if err := updateCertificateFiles(cert); err != nil {
+		t.Fatalf("部署失败: %v", err)
+	}
+	written, _ := os.ReadFile(combined)
+	if string(written) != combinedPEM(cert) || !strings.HasPrefix(string(written), "-----BEGIN CERTIFICATE-----") {
+		t.Fatalf("合并文件应为证书在前、私钥在后: %q", written)
+	}
+	if info, _ := os.Stat(combined); info.Mode().Perm() != 0600 {
+		t.Fatalf("合并文件含私钥，权限应为 0600: %v", info.Mode().Perm())
+	}
+	// update 比对：部署后的文件与平台证书一致
+	pub, priv := readLocalCertFiles(combined, combined)
+	if pub != combinedPEM(cert) || priv != pub {
+		t.Fatal("合并文件比对基准错误")
+	}
+	if !isCombinedPEM(db.Deployment{CertPath: combined, KeyPath: combined}) || isCombinedPEM(db.Deployment{CertPath: certPath, KeyPath: keyPath}) {
+		t.Fatal("isCombinedPEM 判断错误")
+	}
+}
+
+// Caddy / Traefik / lighttpd 配置经 parseConfigFile 自动识别
+func TestParseServerConfigs(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := genSelfSignedCert(t, dir, "traefik.com", 30)
+
+	caddy := filepath.Join(dir, "Caddyfile")
+	os.WriteFile(caddy, []byte("caddy.com {\n    tls /c/cert.pem /c/key.pem\n}\n"), 0644)
+	if s := parseConfigFile(caddy); len(s) != 1 || s[0].Domain != "caddy.com" || s[0].Location != caddy+":2" {
+		t.Fatalf("Caddy 解析错误: %+v", s)
+	}
+
+	traefik := filepath.Join(dir, "tls.yml")
+	os.WriteFile(traefik, []byte("tls:\n  certificates:\n    - certFile: "+certPath+"\n      keyFile: "+keyPath+"\n    - certFile: /missing.pem\n      keyFile: /missing.key\n"), 0644)
+	if s := parseConfigFile(traefik); len(s) != 1 || s[0].Domain != "traefik.com" || s[0].KeyPath != keyPath {
+		t.Fatalf("Traefik 解析错误（证书不可读的条目应跳过）: %+v", s)
+	}
+
+	lighttpd := filepath.Join(dir, "lighttpd.conf")
+	os.WriteFile(lighttpd, []byte("$HTTP[\"host\"] == \"light.com\" {\n    ssl.pemfile = \"/l/light.pem\"\n}\n"), 0644)
+	if s := parseConfigFile(lighttpd); len(s) != 1 || s[0].Domain != "light.com" || s[0].KeyPath != "/l/light.pem" {
+		t.Fatalf("lighttpd 解析错误: %+v", s)
+	}
+}
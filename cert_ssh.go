@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"ssl_assistant/certfmt"
+	"ssl_assistant/db"
+	"ssl_assistant/remote"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+)
+
+// --- ssh 部署目标：持有平台凭据的主机（堡垒机）获取证书后，通过 SSH 上传到各台 Web 服务器并远程重载 ---
+
+// sshHost ssh 目标的连接参数
+func sshHost(t db.DeployTarget) remote.Host {
+	return remote.Host{Addr: t.Host, Port: t.Port, User: t.User, IdentityFile: t.IdentityFile, KnownHosts: t.KnownHosts}
+}
+
+// deploySSHTarget 上传证书（按目标格式生成）与私钥，设置权限与属主后执行远程检查与重载命令
+func deploySSHTarget(bundle *certfmt.Bundle, domain string, t db.DeployTarget) error {
+	format, err := certfmt.Normalize(t.Format)
+	if err != nil {
+		return err
+	}
+	mode, err := targetMode(format, t.Mode)
+	if err != nil {
+		return err
+	}
+	data, err := bundle.Render(format, t.Password, domain)
+	if err != nil {
+		return err
+	}
+	h := sshHost(t)
+	if err := h.Upload(t.Path, data, mode, t.Owner); err != nil {
+		return err
+	}
+	if t.KeyPath != "" {
+		key, err := bundle.Render(certfmt.Key, "", domain)
+		if err != nil {
+			return err
+		}
+		// 私钥权限固定为 0600
+		if err := h.Upload(t.KeyPath, key, 0600, t.Owner); err != nil {
+			return err
+		}
+	}
+	if strings.TrimSpace(t.Reload) == "" {
+		return nil
+	}
+	if _, err := h.Run(t.Reload); err != nil {
+		return fmt.Errorf("证书已上传，但重载命令执行失败: %v", err)
+	}
+	return nil
+}
+
+// validateSSHTarget 校验 ssh 目标：主机必填，远程路径为绝对路径，格式不含私钥时须指定远程私钥路径，本机密钥文件须存在
+func validateSSHTarget(cert db.Certificate, t db.DeployTarget) (db.DeployTarget, error) {
+	if strings.TrimSpace(t.Host) == "" {
+		return t, errors.New("ssh 目标需要指定主机（--host）")
+	}
+	if t.Port < 0 || t.Port > 65535 {
+		return t, fmt.Errorf("端口 %d 无效", t.Port)
+	}
+	if !path.IsAbs(t.Path) {
+		return t, fmt.Errorf("远程路径必须是绝对路径: %s", t.Path)
+	}
+	t.Path = path.Clean(t.Path)
+	switch {
+	case certfmt.HasKey(t.Format) && t.KeyPath != "":
+		return t, fmt.Errorf("%s 格式已包含私钥，无需指定远程私钥路径（--key-path）", t.Format)
+	case !certfmt.HasKey(t.Format) && t.KeyPath == "":
+		return t, fmt.Errorf("%s 格式不含私钥，请指定远程私钥路径（--key-path）", t.Format)
+	}
+	if t.KeyPath != "" {
+		if !path.IsAbs(t.KeyPath) {
+			return t, fmt.Errorf("远程路径必须是绝对路径: %s", t.KeyPath)
+		}
+		if t.KeyPath = path.Clean(t.KeyPath); t.KeyPath == t.Path {
+			return t, errors.New("远程私钥路径不能与证书路径相同（需要合并文件请使用 --format combined）")
+		}
+	}
+	for _, p := range []*string{&t.IdentityFile, &t.KnownHosts} {
+		if *p == "" {
+			continue
+		}
+		abs, err := filepath.Abs(*p)
+		if err != nil {
+			return t, err
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return t, fmt.Errorf("文件 %s 不存在", abs)
+		}
+		*p = abs
+	}
+	for _, x := range cert.Targets {
+		if x.Type == targetSSH && x.Host == t.Host && x.Port == t.Port && (x.Path == t.Path || x.KeyPath == t.Path || (t.KeyPath != "" && (x.Path == t.KeyPath || x.KeyPath == t.KeyPath))) {
+			return t, fmt.Errorf("主机 %s 的路径已存在部署目标", sshHost(t))
+		}
+	}
+	return t, nil
+}
+
+// remoteResult 远程主机部署结果（update 结束时汇总）
+type remoteResult struct {
+	Domain string
+	Host   string
+	Path   string
+	Err    error
+}
+
+// remoteResults 提取附加目标中 ssh 目标的结果
+func remoteResults(domain string, results []targetResult) []remoteResult {
+	var out []remoteResult
+	for _, r := range results {
+		if r.Target.Type == targetSSH {
+			out = append(out, remoteResult{Domain: domain, Host: sshHost(r.Target).String(), Path: r.Target.Path, Err: r.Err})
+		}
+	}
+	return out
+}
+
+// printRemoteResults 输出各远程主机的部署结果汇总
+func printRemoteResults(results []remoteResult) {
+	if len(results) == 0 {
+		return
+	}
+	failed := 0
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"域名", "主机", "路径", "结果"})
+	for _, r := range results {
+		state := "成功"
+		if r.Err != nil {
+			state = "失败: " + r.Err.Error()
+			failed++
+		}
+		table.Append([]string{r.Domain, r.Host, r.Path, state})
+	}
+	fmt.Println("远程主机部署结果:")
+	table.Render()
+	if failed > 0 {
+		color.Red("%d/%d 个远程目标部署失败\n", failed, len(results))
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"ssl_assistant/db"
+	"ssl_assistant/remote/remotetest"
+	"strings"
+	"testing"
+)
+
+// sshTarget 连接进程内 SSH 服务器的 ssh 目标
+func sshTarget(s *remotetest.Server, format, path, keyPath, reload string) db.DeployTarget {
+	return db.DeployTarget{Type: "ssh", Format: format, Host: s.Addr, Port: s.Port, User: s.User, IdentityFile: s.IdentityFile, KnownHosts: s.KnownHosts,
+		Path: path, KeyPath: keyPath, Reload: reload}
+}
+
+// ssh 目标：上传证书与私钥（权限正确）、执行重载命令；单台主机失败不影响其他主机，结果逐台汇总
+func TestDeploySSHTargets(t *testing.T) {
+	server := remotetest.NewServer(t)
+	dir := t.TempDir()
+	certPath, keyPath := genSelfSignedCert(t, dir, "edge.com", 30)
+	cert, err := buildCertFromLocalFiles("edge.com", certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	web1 := filepath.Join(dir, "web1")
+	marker := filepath.Join(dir, "reloaded")
+	// 已关闭的端口：模拟主机不可达
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := sshTarget(server, "fullchain", "/etc/nginx/ssl/edge.pem", "/etc/nginx/ssl/edge.key", "")
+	down.Port = l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	cert.Targets = db.DeployTargets{
+		sshTarget(server, "fullchain", filepath.Join(web1, "edge.pem"), filepath.Join(web1, "private", "edge.key"), "touch "+marker),
+		down,
+		sshTarget(server, "combined", filepath.Join(dir, "web2", "edge.pem"), "", "echo 'nginx: configuration file test failed' >&2; exit 1"),
+	}
+	results, err := deployCertificate(cert)
+	if err != nil {
+		t.Fatalf("远程目标失败不应导致部署失败: %v", err)
+	}
+	remotes := remoteResults(cert.Domain, results)
+	if len(remotes) != 3 || remotes[0].Err != nil || remotes[1].Err == nil || remotes[2].Err == nil {
+		t.Fatalf("远程部署结果错误: %+v", remotes)
+	}
+	if !strings.Contains(remotes[1].Err.Error(), "连接 deploy@127.0.0.1") || !strings.Contains(remotes[2].Err.Error(), "重载命令执行失败") {
+		t.Fatalf("失败原因错误: %v / %v", remotes[1].Err, remotes[2].Err)
+	}
+
+	pub, _ := os.ReadFile(filepath.Join(web1, "edge.pem"))
+	if string(pub) != cert.PublicKey {
+		t.Fatal("远程证书内容错误")
+	}
+	for path, mode := range map[string]os.FileMode{filepath.Join(web1, "edge.pem"): 0644, filepath.Join(web1, "private", "edge.key"): 0600, filepath.Join(dir, "web2", "edge.pem"): 0600} {
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != mode {
+			t.Fatalf("%s 权限应为 %04o: %v %v", path, mode, info, err)
+		}
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatal("上传后应执行重载命令")
+	}
+}
+
+// ssh 目标的校验：主机必填、远程绝对路径、私钥路径与格式匹配、本机密钥文件存在、不得重复
+func TestValidateSSHTarget(t *testing.T) {
+	identity := filepath.Join(t.TempDir(), "id_ed25519")
+	os.WriteFile(identity, []byte("key"), 0600)
+	cert := db.Certificate{Targets: db.DeployTargets{{Type: "ssh", Format: "fullchain", Host: "web1", Path: "/etc/ssl/a.pem", KeyPath: "/etc/ssl/a.key"}}}
+
+	got, err := validateTarget(cert, db.DeployTarget{Type: "ssh", Host: "web2", Path: "/etc/ssl/a.pem", KeyPath: "/etc/ssl//a.key", IdentityFile: identity})
+	if err != nil || got.Format != "fullchain" || got.KeyPath != "/etc/ssl/a.key" {
+		t.Fatalf("ssh 目标默认格式为 fullchain: %+v %v", got, err)
+	}
+	bad := []db.DeployTarget{
+		{Type: "ftp", Format: "pfx", Path: "/x"},
+		{Type: "ssh", Path: "/etc/ssl/b.pem", KeyPath: "/etc/ssl/b.key"},
+		{Type: "ssh", Host: "web2", Path: "etc/ssl/b.pem", KeyPath: "/etc/ssl/b.key"},
+		{Type: "ssh", Host: "web2", Path: "/etc/ssl/b.pem"},
+		{Type: "ssh", Host: "web2", Format: "combined", Path: "/etc/ssl/b.pem", KeyPath: "/etc/ssl/b.key"},
+		{Type: "ssh", Host: "web2", Path: "/etc/ssl/b.pem", KeyPath: "/etc/ssl/b.pem"},
+		{Type: "ssh", Host: "web2", Path: "/etc/ssl/b.pem", KeyPath: "/etc/ssl/b.key", IdentityFile: identity + ".missing"},
+		{Type: "ssh", Host: "web2", Port: 70000, Path: "/etc/ssl/b.pem", KeyPath: "/etc/ssl/b.key"},
+		{Type: "ssh", Host: "web1", Path: "/etc/ssl/a.pem", KeyPath: "/etc/ssl/b.key"},
+		{Format: "der", Path: "/x.der", Host: "web1"},
+	}
+	for _, target := range bad {
+		if _, err := validateTarget(cert, target); err == nil {
+			t.Fatalf("应校验失败: %+v", target)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"ssl_assistant/certfmt"
+	"ssl_assistant/db"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+)
+
+// --- 附加部署目标：同一证书按 PFX / JKS / DER 等格式额外写入其他路径（IIS、Tomcat、Java 应用等），或通过 SSH 上传到远程主机 ---
+
+// targetSSH ssh 目标类型（远程主机）；本地文件目标的类型为空
+const targetSSH = "ssh"
+
+// targetResult 单个部署目标的部署结果
+type targetResult struct {
+	Target db.DeployTarget
+	Err    error
+}
+
+// deployTargets 部署证书的全部附加目标（格式由 PEM 本地生成）。单个目标失败不影响其余目标
+func deployTargets(cert db.Certificate) []targetResult {
+	if len(cert.Targets) == 0 {
+		return nil
+	}
+	bundle, err := certfmt.Parse(cert.PublicKey, cert.PrivateKey)
+	if err != nil {
+		err = fmt.Errorf("生成失败: %v", err)
+	}
+	results := make([]targetResult, 0, len(cert.Targets))
+	for _, t := range cert.Targets {
+		terr := err
+		if terr == nil {
+			terr = deployTarget(bundle, cert.Domain, t)
+		}
+		results = append(results, targetResult{Target: t, Err: terr})
+	}
+	return results
+}
+
+// deployTarget 部署单个目标：本地目标写入文件，ssh 目标上传到远程主机并执行重载命令
+func deployTarget(bundle *certfmt.Bundle, domain string, t db.DeployTarget) error {
+	if t.Type == targetSSH {
+		return deploySSHTarget(bundle, domain, t)
+	}
+	return writeTarget(bundle, domain, t)
+}
+
+// deployCertTargets 主证书文件写入后部署附加目标：目标失败不影响主证书（服务已可加载），逐个提示结果
+func deployCertTargets(cert db.Certificate) []targetResult {
+	results := deployTargets(cert)
+	for _, r := range results {
+		if r.Err != nil {
+			color.Red("域名 %s 的部署目标 %s 更新失败: %v\n", cert.Domain, targetLabel(r.Target), r.Err)
+			continue
+		}
+		color.Green("域名 %s 的部署目标 %s 已更新\n", cert.Domain, targetLabel(r.Target))
+	}
+	return results
+}
+
+// targetLabel 目标描述：路径（格式），ssh 目标带主机
+func targetLabel(t db.DeployTarget) string {
+	if t.Type == targetSSH {
+		return fmt.Sprintf("%s:%s（%s）", sshHost(t), t.Path, t.Format)
+	}
+	return fmt.Sprintf("%s（%s）", t.Path, t.Format)
+}
+
+// writeTarget 生成并写入单个目标文件，设置权限与属主
+func writeTarget(bundle *certfmt.Bundle, domain string, t db.DeployTarget) error {
+	format, err := certfmt.Normalize(t.Format)
+	if err != nil {
+		return err
+	}
+	mode, err := targetMode(format, t.Mode)
+	if err != nil {
+		return err
+	}
+	data, err := bundle.Render(format, t.Password, domain)
+	if err != nil {
+		return err
+	}
+	// 目标文件始终使用解析出的权限（未设置时按格式取默认值，不保留已有文件的权限）
+	return writeDeployFile(t.Path, data, mode, strconv.FormatUint(uint64(mode), 8), t.Owner)
+}
+
+// targetMode 解析八进制权限；未设置时含私钥的格式为 0600，其余为 0644
+func targetMode(format, mode string) (os.FileMode, error) {
+	if mode == "" {
+		if certfmt.HasKey(format) {
+			return 0600, nil
+		}
+		return 0644, nil
+	}
+	return parseFileMode(mode)
+}
+
+// chownTarget 设置属主（user 或 user:group，支持数字 ID）；Windows 不支持，给出提示后跳过
+func chownTarget(path, owner string) error {
+	if owner == "" {
+		return nil
+	}
+	if runtime.GOOS == "windows" {
+		color.Yellow("Windows 不支持设置属主，已跳过 %s\n", path)
+		return nil
+	}
+	uid, gid, err := lookupOwner(owner)
+	if err != nil {
+		return err
+	}
+	return os.Chown(path, uid, gid)
+}
+
+// lookupOwner 解析 user[:group] 为 uid/gid（未指定组时 gid 为 -1，即不修改）
+func lookupOwner(owner string) (uid, gid int, err error) {
+	name, group, _ := strings.Cut(owner, ":")
+	uid, gid = -1, -1
+	if name != "" {
+		if uid, err = strconv.Atoi(name); err != nil {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return 0, 0, fmt.Errorf("用户 %s 不存在", name)
+			}
+			uid, _ = strconv.Atoi(u.Uid)
+		}
+	}
+	if group != "" {
+		if gid, err = strconv.Atoi(group); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return 0, 0, fmt.Errorf("用户组 %s 不存在", group)
+			}
+			gid, _ = strconv.Atoi(g.Gid)
+		}
+	}
+	return uid, gid, nil
+}
+
+// validateTarget 校验并规范化部署目标（类型、格式名、路径、密码、权限、属主；ssh 目标另校验连接参数）
+func validateTarget(cert db.Certificate, t db.DeployTarget) (db.DeployTarget, error) {
+	switch t.Type {
+	case "", "file":
+		t.Type = ""
+	case targetSSH:
+		if t.Format == "" {
+			t.Format = certfmt.FullChain
+		}
+	default:
+		return t, fmt.Errorf("不支持的目标类型 %q（可选 file、ssh）", t.Type)
+	}
+	if t.Format == "" {
+		return t, errors.New("请指定格式（--format）")
+	}
+	format, err := certfmt.Normalize(t.Format)
+	if err != nil {
+		return t, err
+	}
+	t.Format = format
+	if strings.TrimSpace(t.Path) == "" {
+		return t, errors.New("请指定目标路径（--path）")
+	}
+	if format == certfmt.JKS && t.Password == "" {
+		return t, errors.New("JKS 需要设置密码（--password）")
+	}
+	if !certfmt.NeedsPassword(format) && t.Password != "" {
+		return t, fmt.Errorf("%s 格式不使用密码", format)
+	}
+	if _, err := targetMode(format, t.Mode); err != nil {
+		return t, err
+	}
+	if t.Type == targetSSH {
+		return validateSSHTarget(cert, t)
+	}
+	if t.Host != "" || t.Port != 0 || t.User != "" || t.IdentityFile != "" || t.KnownHosts != "" || t.KeyPath != "" || t.Reload != "" {
+		return t, errors.New("--host/--port/--user/--identity/--known-hosts/--key-path/--reload 仅用于 ssh 目标（--type ssh）")
+	}
+	if t.Path, err = filepath.Abs(t.Path); err != nil {
+		return t, err
+	}
+	if t.Owner != "" && runtime.GOOS != "windows" {
+		if _, _, err := lookupOwner(t.Owner); err != nil {
+			return t, err
+		}
+	}
+	// 不得覆盖部署位置的证书文件或已有目标
+	for _, d := range cert.Deployments {
+		for _, p := range []string{d.CertPath, d.KeyPath, d.ChainPath} {
+			if p != "" && filepath.Clean(p) == t.Path {
+				return t, fmt.Errorf("路径 %s 已是证书的部署文件", t.Path)
+			}
+		}
+	}
+	for _, x := range cert.Targets {
+		if x.Type == "" && filepath.Clean(x.Path) == t.Path {
+			return t, fmt.Errorf("路径 %s 已存在部署目标", t.Path)
+		}
+	}
+	return t, nil
+}
+
+// getCertByIDArg 按命令参数中的证书 ID 获取证书
+func getCertByIDArg(arg string) (db.Certificate, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return db.Certificate{}, fmt.Errorf("证书 ID 必须是整数")
+	}
+	cert, err := db.GetCertificateByIDWrapper(id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return cert, fmt.Errorf("证书%s不存在", arg)
+		}
+		return cert, fmt.Errorf("获取证书信息失败: %s", err)
+	}
+	return cert, nil
+}
+
+// targetList 列出部署目标（指定证书 ID 时只列该证书）
+func targetList(args []string) error {
+	var certs []db.Certificate
+	if len(args) > 0 {
+		cert, err := getCertByIDArg(args[0])
+		if err != nil {
+			return err
+		}
+		certs = []db.Certificate{cert}
+	} else {
+		all, err := db.GetAllCertificatesWrapper()
+		if err != nil {
+			return fmt.Errorf("获取证书列表失败: %s", err)
+		}
+		certs = all
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"证书ID", "域名", "序号", "主机", "格式", "路径", "密码", "属主", "权限"})
+	rows := 0
+	for _, cert := range certs {
+		for i, t := range cert.Targets {
+			password := ""
+			if t.Password != "" {
+				password = "已设置"
+			}
+			mode := t.Mode
+			if mode == "" {
+				m, _ := targetMode(t.Format, "")
+				mode = fmt.Sprintf("%04o（默认）", m)
+			}
+			host, path := "本机", t.Path
+			if t.Type == targetSSH {
+				host = sshHost(t).String()
+				if t.KeyPath != "" {
+					path += "\n" + t.KeyPath + "（私钥）"
+				}
+			}
+			table.Append([]string{strconv.Itoa(cert.ID), cert.Domain, strconv.Itoa(i + 1), host, t.Format, path, password, t.Owner, mode})
+			rows++
+		}
+	}
+	if rows == 0 {
+		color.Yellow("暂无部署目标，可通过 target add <证书ID> --format pfx --path <路径> 添加\n")
+		return nil
+	}
+	table.Render()
+	return nil
+}
+
+// targetAdd 为证书添加部署目标并立即写入（申请中尚无证书时在签发后随证书部署）
+func targetAdd(idArg string, t db.DeployTarget) error {
+	cert, err := getCertByIDArg(idArg)
+	if err != nil {
+		return err
+	}
+	if t, err = validateTarget(cert, t); err != nil {
+		return err
+	}
+	cert.Targets = append(cert.Targets, t)
+	if err := db.UpdateCertificateInDBWrapper(cert); err != nil {
+		return fmt.Errorf("保存部署目标失败: %s", err)
+	}
+	color.Green("已为域名 %s 添加部署目标 %s\n", cert.Domain, targetLabel(t))
+
+	if cert.PublicKey == "" {
+		color.Yellow("证书尚未签发，签发后随证书一同部署\n")
+		return nil
+	}
+	bundle, err := certfmt.Parse(cert.PublicKey, cert.PrivateKey)
+	if err == nil {
+		err = deployTarget(bundle, cert.Domain, t)
+	}
+	if err != nil {
+		return fmt.Errorf("写入部署目标失败（目标已保存，证书续期部署时重新生成）: %v", err)
+	}
+	if t.Type == targetSSH {
+		color.Green("已部署到远程主机 %s\n", sshHost(t))
+		if t.Reload == "" {
+			color.Yellow("未设置重载命令（--reload），请手动重载远程服务\n")
+		}
+		return nil
+	}
+	color.Green("部署目标已写入，如服务未自动加载请手动重载\n")
+	return nil
+}
+
+// targetDel 删除证书的第 n 个部署目标（序号见 target list），不删除已生成的文件
+func targetDel(idArg, indexArg string) error {
+	cert, err := getCertByIDArg(idArg)
+	if err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(indexArg)
+	if err != nil || n < 1 || n > len(cert.Targets) {
+		return fmt.Errorf("序号 %s 无效（该证书共 %d 个部署目标）", indexArg, len(cert.Targets))
+	}
+	removed := cert.Targets[n-1]
+	cert.Targets = append(cert.Targets[:n-1:n-1], cert.Targets[n:]...)
+	if err := db.UpdateCertificateInDBWrapper(cert); err != nil {
+		return fmt.Errorf("删除部署目标失败: %s", err)
+	}
+	color.Green("已删除部署目标 %s，已生成的文件保留\n", targetLabel(removed))
+	return nil
+}
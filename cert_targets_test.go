@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"ssl_assistant/db"
+	"testing"
+)
+
+// 部署证书时按附加目标生成各格式文件：默认权限按是否含私钥区分，显式权限覆盖已存在文件的权限
+func TestDeployTargets(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := genSelfSignedCert(t, dir, "tomcat.com", 30)
+	cert, err := buildCertFromLocalFiles("tomcat.com", certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pfx := filepath.Join(dir, "iis", "tomcat.pfx")
+	der := filepath.Join(dir, "tomcat.der")
+	jks := filepath.Join(dir, "tomcat.jks")
+	os.WriteFile(jks, []byte("old"), 0644)
+	cert.Targets = db.DeployTargets{
+		{Format: "pfx", Path: pfx, Password: "pw"},
+		{Format: "der", Path: der},
+		{Format: "jks", Path: jks, Password: "changeit", Mode: "0640"},
+	}
+	if err := updateCertificateFiles(cert); err != nil {
+		t.Fatalf("部署失败: %v", err)
+	}
+	for path, mode := range map[string]os.FileMode{pfx: 0600, der: 0644, jks: 0640} {
+		info, err := os.Stat(path)
+		if err != nil || info.Mode().Perm() != mode {
+			t.Fatalf("%s 权限应为 %04o: %v %v", path, mode, info, err)
+		}
+	}
+	if data, _ := os.ReadFile(jks); !bytes.HasPrefix(data, []byte{0xFE, 0xED, 0xFE, 0xED}) {
+		t.Fatal("JKS 文件头错误")
+	}
+
+	// 目标失败（JKS 缺少密码）不影响主证书与其余目标
+	os.Remove(der)
+	cert.Targets = db.DeployTargets{{Format: "jks", Path: jks}, {Format: "der", Path: der}}
+	if err := updateCertificateFiles(cert); err != nil {
+		t.Fatalf("附加目标失败不应导致部署失败: %v", err)
+	}
+	if _, err := os.Stat(der); err != nil {
+		t.Fatal("其余目标应继续写入")
+	}
+}
+
+// 添加目标的校验：格式、密码、权限、与部署文件/已有目标冲突
+func TestValidateTarget(t *testing.T) {
+	cert := db.Certificate{Deployments: []db.Deployment{{CertPath: "/etc/ssl/a.pem", KeyPath: "/etc/ssl/a.key"}}, Targets: db.DeployTargets{{Format: "der", Path: "/etc/ssl/a.der"}}}
+	got, err := validateTarget(cert, db.DeployTarget{Format: "P12", Path: "/etc/ssl/a.pfx"})
+	if err != nil || got.Format != "pfx" {
+		t.Fatalf("格式别名应规范化: %+v %v", got, err)
+	}
+	bad := []db.DeployTarget{
+		{Format: "p7b", Path: "/x"},
+		{Format: "pfx"},
+		{Format: "jks", Path: "/x.jks"},
+		{Format: "der", Path: "/x.der", Password: "pw"},
+		{Format: "pfx", Path: "/x.pfx", Mode: "0999"},
+		{Format: "leaf", Path: "/etc/ssl/a.pem"},
+		{Format: "der", Path: "/etc/ssl/a.der"},
+	}
+	for _, target := range bad {
+		if _, err := validateTarget(cert, target); err == nil {
+			t.Fatalf("应校验失败: %+v", target)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"ssl_assistant/apacheconf"
+	"ssl_assistant/config"
+	"ssl_assistant/db"
+	"ssl_assistant/fswatch"
+	"ssl_assistant/nginxconf"
+	"ssl_assistant/notify"
+	"ssl_assistant/utils"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// --- 文件监视（watch）：监视 Web 服务器配置目录与证书部署目录，变更后立即比对，不必等到定时任务。
+// 新增的 SSL 站点提示添加或通知；部署的证书文件被替换（与数据库中的证书不一致）时按策略通知或重新部署 ---
+
+// watchQuiet 变更合并的静默时间（编辑器保存、部署工具替换文件时会连续产生多个事件）
+var watchQuiet = 2 * time.Second
+
+// 部署文件被替换时的处理策略（watch_cert_policy）
+const (
+	watchPolicyNotify   = "notify"   // 仅通知（默认）
+	watchPolicyRedeploy = "redeploy" // 重新写入数据库中的证书并执行重载命令
+)
+
+// watchCertPolicy 部署文件被替换时的处理策略
+func watchCertPolicy() string {
+	v, _ := config.GetConfig("", "watch_cert_policy")
+	if strings.TrimSpace(v) == watchPolicyRedeploy {
+		return watchPolicyRedeploy
+	}
+	return watchPolicyNotify
+}
+
+// watchEnabled 证书更新任务是否同时监视文件变更（watch = 1 开启）
+func watchEnabled() bool {
+	v, _ := config.GetConfig("", "watch")
+	return strings.TrimSpace(v) == "1"
+}
+
+// certWatcher 文件监视状态
+type certWatcher struct {
+	w           *fswatch.Watcher
+	extra       []string        // 额外检索的配置路径
+	interactive bool            // 发现新站点时询问是否添加（前台交互运行）
+	configDirs  map[string]bool // 监视的配置目录
+	reported    map[string]bool // 已报告的配置漂移（同一漂移只报告一次，消失后再出现时重新报告）
+}
+
+// watchKey 漂移的去重键
+func watchKey(d driftItem) string {
+	return fmt.Sprintf("%s|%d|%s|%s", d.Kind, d.Cert.ID, d.Site.Domain, d.Pair.CertPath)
+}
+
+// runWatch 监视文件变更直到出错退出；run 执行每次处理（守护进程中重定向输出到日志）
+func runWatch(extra []string, interactive bool, run func(title string, job func() error)) error {
+	w, err := fswatch.New()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	cw := &certWatcher{w: w, extra: extra, interactive: interactive, configDirs: map[string]bool{}, reported: map[string]bool{}}
+	run("启动文件监视", cw.start)
+
+	go func() {
+		for err := range w.Errors {
+			color.Yellow("监视文件变更出错: %v\n", err)
+		}
+	}()
+	fswatch.Debounce(w.Events, watchQuiet, func(paths []string) {
+		run("文件变更", func() error { return cw.handle(paths) })
+	})
+	return nil
+}
+
+// start 添加监视目录，记录当前已存在的漂移（由 scan 处理，不重复报告）
+func (cw *certWatcher) start() error {
+	certs, err := db.GetAllCertificatesWrapper()
+	if err != nil {
+		return fmt.Errorf("获取证书信息失败: %s", err)
+	}
+	items, err := detectDrift(cw.extra)
+	if err != nil {
+		return err
+	}
+	for _, d := range driftOnly(items) {
+		cw.reported[watchKey(d)] = true
+	}
+	cw.watchDirs(certs)
+	color.Green("正在监视 %d 个配置目录与证书部署目录的变更\n", len(cw.configDirs))
+	return nil
+}
+
+// watchDirs 监视配置目录（含 include 的文件所在目录）与各部署位置所在目录；目录变化（新的 include、部署位置）时补充
+func (cw *certWatcher) watchDirs(certs []db.Certificate) {
+	for _, dir := range configWatchDirs(cw.extra) {
+		if err := cw.w.Add(dir); err == nil {
+			cw.configDirs[dir] = true
+		}
+	}
+	for _, c := range certs {
+		for _, d := range c.Deployments {
+			for _, p := range []string{d.CertPath, d.KeyPath, d.ChainPath} {
+				if p != "" {
+					cw.w.Add(filepath.Dir(p))
+				}
+			}
+		}
+	}
+}
+
+// configWatchDirs 配置所在目录：默认路径与面板路径（通配符取所在目录）、各配置文件及其 include 文件所在目录
+func configWatchDirs(extra []string) []string {
+	seen := map[string]bool{}
+	var dirs []string
+	add := func(dir string) {
+		dir = filepath.Clean(dir)
+		if seen[dir] || strings.Contains(dir, "*") {
+			return
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return
+		}
+		seen[dir] = true
+		dirs = append(dirs, dir)
+	}
+	paths := append(append(append([]string(nil), defaultNginxPaths...), discoverPanelPaths()...), extra...)
+	var files []string
+	for _, p := range paths {
+		if strings.Contains(p, "*") {
+			add(filepath.Dir(p))
+			matches, _ := filepath.Glob(p)
+			files = append(files, matches...)
+			continue
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			add(p)
+			matches, _ := filepath.Glob(filepath.Join(p, "*.conf"))
+			files = append(files, matches...)
+		} else {
+			files = append(files, p)
+		}
+	}
+	for _, f := range files {
+		add(filepath.Dir(f))
+		for _, included := range includedFiles(f) {
+			add(filepath.Dir(included))
+		}
+	}
+	return dirs
+}
+
+// includedFiles Nginx / Apache 配置解析时读取的全部文件（含 include）；其他服务器的配置返回空
+func includedFiles(path string) []string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	if _, ok := parseServerConfig(path, content, false); ok {
+		return nil
+	}
+	if isApacheConfig(string(content)) {
+		if cfg, err := apacheconf.Parse(path); err == nil {
+			return cfg.Files
+		}
+		return nil
+	}
+	if cfg, err := nginxconf.Parse(path); err == nil {
+		return cfg.Files
+	}
+	return nil
+}
+
+// editorTempFile 编辑器的临时文件（交换文件、备份文件），其变更不触发配置检索
+func editorTempFile(path string) bool {
+	name := filepath.Base(path)
+	return strings.HasSuffix(name, "~") || strings.HasSuffix(name, ".swp") || strings.HasSuffix(name, ".swx") ||
+		strings.HasSuffix(name, ".tmp") || strings.HasPrefix(name, ".#") || name == "4913"
+}
+
+// watchedDeployment 变更文件对应的部署位置
+type watchedDeployment struct {
+	cert db.Certificate
+	d    db.Deployment
+}
+
+// handle 处理一批变更：部署文件变化时比对证书内容，配置目录中的文件变化时重新检索站点
+func (cw *certWatcher) handle(paths []string) error {
+	certs, err := db.GetAllCertificatesWrapper()
+	if err != nil {
+		return fmt.Errorf("获取证书信息失败: %s", err)
+	}
+	files := map[string][]watchedDeployment{}
+	for _, c := range certs {
+		for _, d := range c.Deployments {
+			for _, p := range []string{d.CertPath, d.KeyPath, d.ChainPath} {
+				if p != "" {
+					files[filepath.Clean(p)] = append(files[filepath.Clean(p)], watchedDeployment{c, d})
+				}
+			}
+		}
+	}
+	batch := notify.NewBatch()
+	checked := map[string]bool{}
+	configChanged := false
+	for _, p := range paths {
+		if refs, ok := files[p]; ok {
+			for _, ref := range refs {
+				key := fmt.Sprintf("%d|%s", ref.cert.ID, ref.d.CertPath)
+				if !checked[key] {
+					checked[key] = true
+					cw.checkDeployment(ref.cert, ref.d, batch)
+				}
+			}
+			continue
+		}
+		if cw.configDirs[filepath.Dir(p)] && !editorTempFile(p) {
+			configChanged = true
+		}
+	}
+	if configChanged {
+		if err := cw.checkConfigs(batch); err != nil {
+			color.Yellow("%v\n", err)
+		}
+	}
+	flushNotifications(batch)
+	cw.watchDirs(certs)
+	return nil
+}
+
+// deploymentReplaced 部署位置的文件是否与数据库中的证书不一致（文件被删除也算）
+func deploymentReplaced(cert db.Certificate, d db.Deployment) bool {
+	if _, err := os.Stat(d.CertPath); err != nil {
+		return true
+	}
+	if _, err := os.Stat(d.KeyPath); err != nil {
+		return true
+	}
+	single := cert
+	single.Deployments = []db.Deployment{d}
+	return !certFilesUpToDate(single, cert)
+}
+
+// checkDeployment 部署文件变化后比对：与数据库一致（如本程序刚部署）时忽略；
+// 申请中尚无证书、原工具仍在续期的导入证书不比对（由更新任务同步）
+func (cw *certWatcher) checkDeployment(cert db.Certificate, d db.Deployment, batch *notify.Batch) {
+	if cert.PublicKey == "" || cert.Import.Renewing || !deploymentReplaced(cert, d) {
+		return
+	}
+	label := certLabel(cert.Domain, cert.KeyType)
+	if watchCertPolicy() != watchPolicyRedeploy {
+		color.Yellow("域名 %s 部署的证书文件 %s 已被替换，与数据库中的证书不一致\n", label, d.CertPath)
+		batch.Add(notify.EventDrift, cert.Domain, "部署的证书文件 %s 已被替换，与数据库中的证书不一致", d.CertPath)
+		return
+	}
+	color.Yellow("域名 %s 部署的证书文件 %s 已被替换，重新部署数据库中的证书\n", label, d.CertPath)
+	if err := writeDeploymentFiles(cert, d); err != nil {
+		batch.Add(notify.EventFailed, cert.Domain, "证书文件 %s 被替换后重新部署失败: %v", d.CertPath, err)
+		return
+	}
+	if err := executeReloadCmd(certReloadCmd(cert)); err != nil {
+		batch.Add(notify.EventReloadFailed, cert.Domain, "证书文件 %s 被替换后已重新部署，但重载命令执行失败: %v", d.CertPath, err)
+		return
+	}
+	batch.Add(notify.EventDrift, cert.Domain, "部署的证书文件 %s 被替换，已重新部署并重载", d.CertPath)
+}
+
+// checkConfigs 配置变化后重新检索并比对，报告新出现的漂移；前台交互运行时询问是否添加新的 SSL 站点
+func (cw *certWatcher) checkConfigs(batch *notify.Batch) error {
+	items, err := detectDrift(cw.extra)
+	if err != nil {
+		return err
+	}
+	current := map[string]bool{}
+	for _, d := range driftOnly(items) {
+		key := watchKey(d)
+		current[key] = true
+		if cw.reported[key] {
+			continue
+		}
+		color.Yellow("%s %s: %s\n", driftKindNames[d.Kind], d.domain(), d.Summary())
+		if d.Kind == driftUnmanaged && cw.interactive &&
+			utils.Confirm(fmt.Sprintf("发现新的 SSL 站点 %s，是否添加", d.domain())) {
+			addSiteFromNginx(d.pairSite())
+			continue
+		}
+		batch.Add(notify.EventDrift, d.domain(), "%s：%s", driftKindNames[d.Kind], d.Summary())
+	}
+	cw.reported = current
+	return nil
+}
+
+// watchCommand watch 命令：前台监视文件变更（交互终端中发现新站点时询问是否添加）
+func watchCommand(extra []string) error {
+	return runWatch(extra, utils.IsInteractive(), func(title string, job func() error) {
+		if err := job(); err != nil {
+			color.Red("%s失败: %v\n", title, err)
+		}
+	})
+}
+
+// startDaemonWatch 证书更新任务配置 watch = 1 时在守护进程内监视文件变更（只通知，不询问）
+func startDaemonWatch(logPath string) {
+	if !watchEnabled() {
+		return
+	}
+	go func() {
+		err := runWatch(nil, false, func(title string, job func() error) {
+			runCronJob(logPath, title, job)
+		})
+		if err != nil {
+			color.Red("文件监视启动失败: %v\n", err)
+		}
+	}()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"ssl_assistant/db"
+	"ssl_assistant/notify"
+	"testing"
+)
+
+// 部署文件被替换（内容不同、被删除）时视为不一致；与数据库一致时忽略
+func TestDeploymentReplaced(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := genSelfSignedCert(t, dir, "w.com", 30)
+	pub, key := readLocalCertFiles(certPath, keyPath)
+	d := db.Deployment{CertPath: certPath, KeyPath: keyPath}
+	cert := db.Certificate{ID: 1, Domain: "w.com", PublicKey: pub, PrivateKey: key, Deployments: []db.Deployment{d}}
+	if deploymentReplaced(cert, d) {
+		t.Fatal("与数据库一致时不应视为被替换")
+	}
+	other := t.TempDir()
+	otherCert, _ := genSelfSignedCert(t, other, "w.com", 30)
+	data, _ := os.ReadFile(otherCert)
+	os.WriteFile(certPath, data, 0644)
+	if !deploymentReplaced(cert, d) {
+		t.Fatal("证书内容不同应视为被替换")
+	}
+	os.Remove(certPath)
+	if !deploymentReplaced(cert, d) {
+		t.Fatal("证书文件被删除应视为被替换")
+	}
+}
+
+// 按策略处理被替换的部署文件：notify 只通知，redeploy 重新写入并执行重载命令
+func TestCheckDeploymentPolicy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("重载命令依赖 POSIX shell")
+	}
+	dir := t.TempDir()
+	certPath, keyPath := genSelfSignedCert(t, dir, "p.com", 30)
+	pub, key := readLocalCertFiles(certPath, keyPath)
+	d := db.Deployment{CertPath: certPath, KeyPath: keyPath}
+	cert := db.Certificate{ID: 2, Domain: "p.com", PublicKey: pub, PrivateKey: key, Deployments: []db.Deployment{d}}
+	cw := &certWatcher{}
+	marker := filepath.Join(dir, "reloaded")
+	setConfig(t, "", "restart_cmd", "touch "+marker)
+
+	os.WriteFile(certPath, []byte("replaced"), 0644)
+	setConfig(t, "", "watch_cert_policy", "")
+	batch := notify.NewBatch()
+	cw.checkDeployment(cert, d, batch)
+	if ev := batch.Events(); len(ev) != 1 || ev[0].Type != notify.EventDrift {
+		t.Fatalf("notify 策略应发送 drift 通知: %+v", ev)
+	}
+	if data, _ := os.ReadFile(certPath); string(data) != "replaced" {
+		t.Fatal("notify 策略不应修改文件")
+	}
+
+	setConfig(t, "", "watch_cert_policy", watchPolicyRedeploy)
+	batch = notify.NewBatch()
+	cw.checkDeployment(cert, d, batch)
+	if data, _ := os.ReadFile(certPath); string(data) != pub {
+		t.Fatal("redeploy 策略应重新写入数据库中的证书")
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatal("redeploy 后应执行重载命令")
+	}
+	if ev := batch.Events(); len(ev) != 1 || ev[0].Type != notify.EventDrift {
+		t.Fatalf("应通知已重新部署: %+v", ev)
+	}
+
+	// 原工具仍在续期的导入证书不比对
+	cert.Import = db.CertImport{Tool: toolCertbot, Renewing: true}
+	os.WriteFile(certPath, []byte("renewed by certbot"), 0644)
+	batch = notify.NewBatch()
+	cw.checkDeployment(cert, d, batch)
+	if len(batch.Events()) != 0 {
+		t.Fatalf("续期中的导入证书不应处理: %+v", batch.Events())
+	}
+}
+
+// 监视目录包含配置文件所在目录与 include 的文件所在目录
+func TestConfigWatchDirs(t *testing.T) {
+	dir := t.TempDir()
+	sites := filepath.Join(dir, "sites-enabled")
+	os.MkdirAll(sites, 0755)
+	os.WriteFile(filepath.Join(sites, "a.conf"), []byte("server { server_name a.com; }\n"), 0644)
+	main := filepath.Join(dir, "nginx.conf")
+	os.WriteFile(main, []byte("http {\n    include sites-enabled/*.conf;\n}\n"), 0644)
+
+	dirs := configWatchDirs([]string{main})
+	if !containsString(dirs, dir) || !containsString(dirs, sites) {
+		t.Fatalf("应监视主配置与 include 文件所在目录: %v", dirs)
+	}
+	if !editorTempFile(filepath.Join(sites, ".a.conf.swp")) || editorTempFile(filepath.Join(sites, "b.conf")) {
+		t.Fatal("编辑器临时文件识别错误")
+	}
+}
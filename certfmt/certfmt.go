@@ -0,0 +1,196 @@
+// Package certfmt 将 PEM 证书链与私钥转换为各类部署格式：完整链、叶子证书、中间证书链、私钥、
+// 证书+私钥合并文件、DER、PKCS#12（PFX）与 JKS。全部在本地由 PEM 生成，不依赖平台提供的其他格式。
+package certfmt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 支持的部署格式
+const (
+	FullChain = "fullchain" // 叶子证书 + 中间证书（PEM）
+	Leaf      = "leaf"      // 仅叶子证书（PEM）
+	Chain     = "chain"     // 仅中间证书（PEM）
+	Key       = "key"       // 私钥（PEM）
+	Combined  = "combined"  // 完整链 + 私钥（PEM，HAProxy / lighttpd）
+	DER       = "der"       // 叶子证书（DER 二进制）
+	PFX       = "pfx"       // PKCS#12（IIS、Windows、Exchange）
+	JKS       = "jks"       // Java KeyStore（Tomcat 等）
+)
+
+// Formats 全部格式（展示与校验用）
+var Formats = []string{FullChain, Leaf, Chain, Key, Combined, DER, PFX, JKS}
+
+// aliases 格式别名
+var aliases = map[string]string{
+	"p12":     PFX,
+	"pkcs12":  PFX,
+	"cert":    Leaf,
+	"crt":     Leaf,
+	"pem":     FullChain,
+	"bundle":  FullChain,
+	"privkey": Key,
+}
+
+// Normalize 规范化格式名（大小写、别名），不支持时返回错误
+func Normalize(format string) (string, error) {
+	f := strings.ToLower(strings.TrimSpace(format))
+	if a, ok := aliases[f]; ok {
+		f = a
+	}
+	for _, x := range Formats {
+		if x == f {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("不支持的格式 %q（可选: %s）", format, strings.Join(Formats, ", "))
+}
+
+// HasKey 该格式的文件是否包含私钥（默认权限 0600）
+func HasKey(format string) bool {
+	switch format {
+	case Key, Combined, PFX, JKS:
+		return true
+	}
+	return false
+}
+
+// NeedsPassword 该格式是否需要密码
+func NeedsPassword(format string) bool {
+	return format == PFX || format == JKS
+}
+
+// Bundle 解析后的证书链与私钥
+type Bundle struct {
+	Leaf   *x509.Certificate
+	Chain  []*x509.Certificate // 中间证书（不含叶子证书）
+	Key    crypto.Signer
+	keyPEM []byte // 原始私钥 PEM（key/combined 格式原样输出）
+}
+
+// Parse 解析 PEM 证书链（首个证书为叶子证书）与私钥
+func Parse(certPEM, keyPEM string) (*Bundle, error) {
+	b := &Bundle{}
+	rest := []byte(certPEM)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("解析证书失败: %v", err)
+		}
+		if b.Leaf == nil {
+			b.Leaf = c
+		} else {
+			b.Chain = append(b.Chain, c)
+		}
+	}
+	if b.Leaf == nil {
+		return nil, errors.New("未找到证书")
+	}
+	rest = []byte(keyPEM)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			continue
+		}
+		key, err := parsePrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		b.Key, b.keyPEM = key, pem.EncodeToMemory(block)
+		break
+	}
+	return b, nil
+}
+
+// parsePrivateKey 解析 PKCS#8 / PKCS#1 / SEC1 私钥
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		switch k := key.(type) {
+		case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+			return k.(crypto.Signer), nil
+		}
+		return nil, errors.New("不支持的私钥类型")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("解析私钥失败")
+}
+
+// Render 生成指定格式的文件内容；alias 为 PFX friendlyName 与 JKS 条目别名（一般为域名）
+func (b *Bundle) Render(format, password, alias string) ([]byte, error) {
+	format, err := Normalize(format)
+	if err != nil {
+		return nil, err
+	}
+	if HasKey(format) && b.Key == nil {
+		return nil, errors.New("缺少私钥")
+	}
+	if format == JKS && password == "" {
+		return nil, errors.New("JKS 需要设置密码")
+	}
+	switch format {
+	case FullChain:
+		return b.fullChainPEM(), nil
+	case Leaf:
+		return encodeCerts(b.Leaf), nil
+	case Chain:
+		if len(b.Chain) == 0 {
+			return nil, errors.New("证书不含中间证书")
+		}
+		return encodeCerts(b.Chain...), nil
+	case Key:
+		return b.keyPEM, nil
+	case Combined:
+		return append(b.fullChainPEM(), b.keyPEM...), nil
+	case DER:
+		return b.Leaf.Raw, nil
+	case PFX:
+		return encodePKCS12(b, password, alias)
+	case JKS:
+		return encodeJKS(b, password, alias)
+	}
+	return nil, fmt.Errorf("不支持的格式 %q", format)
+}
+
+// fullChainPEM 叶子证书 + 中间证书
+func (b *Bundle) fullChainPEM() []byte {
+	return encodeCerts(append([]*x509.Certificate{b.Leaf}, b.Chain...)...)
+}
+
+// encodeCerts 编码为 PEM 证书块
+func encodeCerts(certs ...*x509.Certificate) []byte {
+	var out []byte
+	for _, c := range certs {
+		out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
+	}
+	return out
+}
+
+// pkcs8 私钥的 PKCS#8 DER
+func (b *Bundle) pkcs8() ([]byte, error) {
+	return x509.MarshalPKCS8PrivateKey(b.Key)
+}
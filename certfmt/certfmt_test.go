@@ -0,0 +1,275 @@
+package certfmt
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testBundle 生成 CA 签发的 ECDSA 叶子证书：返回完整链 PEM 与 PKCS#1/SEC1 私钥 PEM
+func testBundle(t *testing.T) (certPEM, keyPEM string) {
+	t.Helper()
+	caKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "a.com"},
+		DNSNames:     []string{"a.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTmpl, ca, key.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}))
+	keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certPEM, keyPEM
+}
+
+func TestNormalize(t *testing.T) {
+	for in, want := range map[string]string{"PFX": PFX, "p12": PFX, " jks ": JKS, "crt": Leaf, "fullchain": FullChain} {
+		if got, err := Normalize(in); err != nil || got != want {
+			t.Fatalf("Normalize(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := Normalize("p7b"); err == nil {
+		t.Fatal("不支持的格式应返回错误")
+	}
+}
+
+// PEM / DER 各格式内容
+func TestRenderPEM(t *testing.T) {
+	certPEM, keyPEM := testBundle(t)
+	b, err := Parse(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafEnd := strings.Index(certPEM, "-----END CERTIFICATE-----\n") + len("-----END CERTIFICATE-----\n")
+	cases := map[string]string{
+		FullChain: certPEM,
+		Leaf:      certPEM[:leafEnd],
+		Chain:     certPEM[leafEnd:],
+		Key:       keyPEM,
+		Combined:  certPEM + keyPEM,
+		DER:       string(b.Leaf.Raw),
+	}
+	for format, want := range cases {
+		got, err := b.Render(format, "", "a.com")
+		if err != nil || string(got) != want {
+			t.Fatalf("%s 内容错误: %v\n%s", format, err, got)
+		}
+	}
+
+	// 无中间证书、无私钥、JKS 无密码
+	noChain, _ := Parse(certPEM[:leafEnd], "")
+	if _, err := noChain.Render(Chain, "", ""); err == nil {
+		t.Fatal("无中间证书时 chain 应返回错误")
+	}
+	if _, err := noChain.Render(PFX, "pw", ""); err == nil {
+		t.Fatal("无私钥时 pfx 应返回错误")
+	}
+	if _, err := b.Render(JKS, "", ""); err == nil {
+		t.Fatal("JKS 无密码应返回错误")
+	}
+	if _, err := Parse("", keyPEM); err == nil {
+		t.Fatal("无证书应返回错误")
+	}
+}
+
+// PKCS#12 密钥派生：RFC 7292 附录 B 已知向量
+func TestPKCS12KDF(t *testing.T) {
+	salt, _ := hex.DecodeString("ffffffffffffffff")
+	got := pkcs12KDF(bmpString("sesame"), salt, 1, 2048, 24)
+	if hex.EncodeToString(got) != "7cd9fd3e2b3be7691a44e3bef0f9ea0fb9b897d4e325d9d1" {
+		t.Fatalf("KDF 结果错误: %x", got)
+	}
+}
+
+// PFX：MAC 校验、私钥袋解密还原 PKCS#8；本机有 openssl 时再用 openssl 读取
+func TestPKCS12(t *testing.T) {
+	certPEM, keyPEM := testBundle(t)
+	b, _ := Parse(certPEM, keyPEM)
+	data, err := b.Render("p12", "s3cret", "a.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pfx struct {
+		Version  int
+		AuthSafe struct {
+			ContentType asn1.ObjectIdentifier
+			Content     []byte `asn1:"tag:0,explicit"`
+		}
+		MacData macData
+	}
+	if _, err := asn1.Unmarshal(data, &pfx); err != nil || pfx.Version != 3 {
+		t.Fatalf("PFX 结构错误: %v", err)
+	}
+	pw := bmpString("s3cret")
+	mac := hmac.New(sha1.New, pkcs12KDF(pw, pfx.MacData.MacSalt, 3, pfx.MacData.Iterations, 20))
+	mac.Write(pfx.AuthSafe.Content)
+	if !hmac.Equal(mac.Sum(nil), pfx.MacData.Mac.Digest) {
+		t.Fatal("MAC 校验失败")
+	}
+
+	var safes []struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"tag:0,explicit"`
+	}
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content, &safes); err != nil || len(safes) != 2 {
+		t.Fatalf("AuthenticatedSafe 错误: %v", err)
+	}
+	if !safes[0].ContentType.Equal(oidEncryptedDataContentType) || !safes[1].ContentType.Equal(oidDataContentType) {
+		t.Fatal("证书袋应加密、私钥袋为 data")
+	}
+	var keyBagsDER []byte
+	if _, err := asn1.Unmarshal(safes[1].Content.Bytes, &keyBagsDER); err != nil {
+		t.Fatalf("私钥 SafeContents 错误: %v", err)
+	}
+	var bags []struct {
+		ID         asn1.ObjectIdentifier
+		Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+		Attributes []pkcs12Attribute `asn1:"set,optional"`
+	}
+	if _, err := asn1.Unmarshal(keyBagsDER, &bags); err != nil || len(bags) != 1 || len(bags[0].Attributes) != 2 {
+		t.Fatalf("私钥袋错误: %v %+v", err, bags)
+	}
+	var epki struct {
+		Algorithm     pkix.AlgorithmIdentifier
+		EncryptedData []byte
+	}
+	asn1.Unmarshal(bags[0].Value.Bytes, &epki)
+	var params pbeParams
+	asn1.Unmarshal(epki.Algorithm.Parameters.FullBytes, &params)
+	block, _ := des.NewTripleDESCipher(pkcs12KDF(pw, params.Salt, 1, params.Iterations, 24))
+	plain := make([]byte, len(epki.EncryptedData))
+	cipher.NewCBCDecrypter(block, pkcs12KDF(pw, params.Salt, 2, params.Iterations, 8)).CryptBlocks(plain, epki.EncryptedData)
+	plain = plain[:len(plain)-int(plain[len(plain)-1])]
+	want, _ := b.pkcs8()
+	if !bytes.Equal(plain, want) {
+		t.Fatal("私钥解密结果与 PKCS#8 不一致")
+	}
+
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("未安装 openssl，跳过互通性检查")
+	}
+	path := filepath.Join(t.TempDir(), "a.pfx")
+	os.WriteFile(path, data, 0600)
+	out, err := exec.Command(openssl, "pkcs12", "-in", path, "-passin", "pass:s3cret", "-nodes").CombinedOutput()
+	if err != nil || strings.Count(string(out), "BEGIN CERTIFICATE") != 2 || !strings.Contains(string(out), "BEGIN PRIVATE KEY") {
+		t.Fatalf("openssl 读取 PFX 失败: %v\n%s", err, out)
+	}
+}
+
+// JKS：结构、完整性摘要与 KeyProtector 还原
+func TestJKS(t *testing.T) {
+	certPEM, keyPEM := testBundle(t)
+	b, _ := Parse(certPEM, keyPEM)
+	data, err := b.Render(JKS, "changeit", "A.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pw := utf16BE("changeit")
+	body, digest := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	if !bytes.Equal(jksDigest(pw, body), digest) {
+		t.Fatal("完整性摘要错误")
+	}
+
+	r := bytes.NewReader(body)
+	var magic, version, count, tag uint32
+	binary.Read(r, binary.BigEndian, &magic)
+	binary.Read(r, binary.BigEndian, &version)
+	binary.Read(r, binary.BigEndian, &count)
+	binary.Read(r, binary.BigEndian, &tag)
+	if magic != jksMagic || version != 2 || count != 1 || tag != jksPrivateKey {
+		t.Fatalf("文件头错误: %x %d %d %d", magic, version, count, tag)
+	}
+	readUTF := func() string {
+		var n uint16
+		binary.Read(r, binary.BigEndian, &n)
+		s := make([]byte, n)
+		r.Read(s)
+		return string(s)
+	}
+	readBytes := func() []byte {
+		var n uint32
+		binary.Read(r, binary.BigEndian, &n)
+		s := make([]byte, n)
+		r.Read(s)
+		return s
+	}
+	if alias := readUTF(); alias != "a.com" {
+		t.Fatalf("别名应转为小写: %q", alias)
+	}
+	var ts int64
+	binary.Read(r, binary.BigEndian, &ts)
+
+	var epki encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(readBytes(), &epki); err != nil || !epki.Algorithm.Algorithm.Equal(oidSunKeyProtector) {
+		t.Fatalf("私钥保护结构错误: %v", err)
+	}
+	enc := epki.EncryptedData
+	salt, cipherText, check := enc[:jksSaltLen], enc[jksSaltLen:len(enc)-sha1.Size], enc[len(enc)-sha1.Size:]
+	stream := jksKeystream(pw, salt, len(cipherText))
+	plain := make([]byte, len(cipherText))
+	for i := range cipherText {
+		plain[i] = cipherText[i] ^ stream[i]
+	}
+	want, _ := b.pkcs8()
+	sum := sha1.Sum(append(append([]byte{}, pw...), plain...))
+	if !bytes.Equal(plain, want) || !bytes.Equal(sum[:], check) {
+		t.Fatal("私钥还原失败")
+	}
+
+	var chainLen uint32
+	binary.Read(r, binary.BigEndian, &chainLen)
+	if chainLen != 2 {
+		t.Fatalf("证书链应含 2 张证书: %d", chainLen)
+	}
+	for i := 0; i < 2; i++ {
+		if typ := readUTF(); typ != "X.509" {
+			t.Fatalf("证书类型错误: %q", typ)
+		}
+		readBytes()
+	}
+	if r.Len() != 0 {
+		t.Fatalf("多余数据 %d 字节", r.Len())
+	}
+}
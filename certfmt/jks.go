@@ -0,0 +1,133 @@
+package certfmt
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+)
+
+// JKS（Sun JDK KeyStore）编码：一个 PrivateKeyEntry（私钥 + 证书链），私钥以 Sun KeyProtector 算法保护，
+// 存储口令与私钥口令相同（Tomcat keystorePass 默认即用作 keyPass）。
+
+// oidSunKeyProtector Sun JDK 私钥保护算法 1.3.6.1.4.1.42.2.17.1.1
+var oidSunKeyProtector = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+
+const (
+	jksMagic       = 0xFEEDFEED
+	jksVersion     = 2
+	jksPrivateKey  = 1
+	jksWhitener    = "Mighty Aphrodite" // 完整性摘要固定前缀
+	jksSaltLen     = sha1.Size
+	jksCertType    = "X.509"
+	jksDefaultName = "server"
+)
+
+// encodeJKS 生成 JKS：条目别名为 alias 的小写形式（JKS 别名不区分大小写），为空时使用 server
+func encodeJKS(b *Bundle, password, alias string) ([]byte, error) {
+	alias = strings.ToLower(alias)
+	if alias == "" {
+		alias = jksDefaultName
+	}
+	pw := utf16BE(password)
+	keyDER, err := b.pkcs8()
+	if err != nil {
+		return nil, err
+	}
+	protected, err := jksProtectKey(keyDER, pw)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := func(v any) { _ = binary.Write(&buf, binary.BigEndian, v) }
+	writeUTF := func(s string) error {
+		if len(s) > 0xFFFF {
+			return errors.New("JKS 字符串过长")
+		}
+		w(uint16(len(s)))
+		buf.WriteString(s)
+		return nil
+	}
+	w(uint32(jksMagic))
+	w(uint32(jksVersion))
+	w(uint32(1)) // 条目数
+	w(uint32(jksPrivateKey))
+	if err := writeUTF(alias); err != nil {
+		return nil, err
+	}
+	w(time.Now().UnixMilli())
+	w(uint32(len(protected)))
+	buf.Write(protected)
+	chain := append([][]byte{b.Leaf.Raw}, chainRaws(b)...)
+	w(uint32(len(chain)))
+	for _, raw := range chain {
+		if err := writeUTF(jksCertType); err != nil {
+			return nil, err
+		}
+		w(uint32(len(raw)))
+		buf.Write(raw)
+	}
+
+	digest := jksDigest(pw, buf.Bytes())
+	buf.Write(digest)
+	return buf.Bytes(), nil
+}
+
+// jksDigest 文件完整性摘要：SHA1(口令 ‖ "Mighty Aphrodite" ‖ 内容)
+func jksDigest(pw, data []byte) []byte {
+	h := sha1.New()
+	h.Write(pw)
+	h.Write([]byte(jksWhitener))
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// jksProtectKey Sun KeyProtector：密钥流为 SHA1(口令 ‖ 上一摘要) 的串联（首个摘要为随机盐），
+// 与 PKCS#8 明文异或；输出 盐 ‖ 密文 ‖ SHA1(口令 ‖ 明文)，封装为 EncryptedPrivateKeyInfo
+func jksProtectKey(plain, pw []byte) ([]byte, error) {
+	salt, err := randomBytes(jksSaltLen)
+	if err != nil {
+		return nil, err
+	}
+	stream := jksKeystream(pw, salt, len(plain))
+	out := append([]byte{}, salt...)
+	for i := range plain {
+		out = append(out, plain[i]^stream[i])
+	}
+	check := sha1.New()
+	check.Write(pw)
+	check.Write(plain)
+	out = append(out, check.Sum(nil)...)
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidSunKeyProtector, Parameters: asn1.NullRawValue},
+		EncryptedData: out,
+	})
+}
+
+// jksKeystream 生成 n 字节密钥流
+func jksKeystream(pw, salt []byte, n int) []byte {
+	var stream []byte
+	digest := salt
+	for len(stream) < n {
+		h := sha1.New()
+		h.Write(pw)
+		h.Write(digest)
+		digest = h.Sum(nil)
+		stream = append(stream, digest...)
+	}
+	return stream[:n]
+}
+
+// chainRaws 中间证书 DER
+func chainRaws(b *Bundle) [][]byte {
+	var out [][]byte
+	for _, c := range b.Chain {
+		out = append(out, c.Raw)
+	}
+	return out
+}
@@ -0,0 +1,328 @@
+package certfmt
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"unicode/utf16"
+)
+
+// PKCS#12（RFC 7292）编码：私钥与证书均以 pbeWithSHAAnd3-KeyTripleDES-CBC 加密，HMAC-SHA1 完整性校验。
+// 该组合为 Windows / IIS / Java / OpenSSL（含 3.x 默认配置）均可读取的最大公约数。
+
+var (
+	oidDataContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidPBEWithSHAAnd3KeyTDES    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPKCS8ShroudedKeyBag      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag                  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidCertTypeX509             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidSHA1                     = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+)
+
+// pkcs12Iterations 密钥派生迭代次数（与 OpenSSL 默认一致）
+const pkcs12Iterations = 2048
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           asn1.RawValue
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data asn1.RawValue
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+// explicit0 [0] EXPLICIT 包装
+func explicit0(inner []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: inner}
+}
+
+// encodePKCS12 生成 PFX：叶子证书与私钥以 localKeyId 关联，中间证书随附
+func encodePKCS12(b *Bundle, password, alias string) ([]byte, error) {
+	pw := bmpString(password)
+	localKeyID := sha1.Sum(b.Leaf.Raw)
+	attrs, err := bagAttributes(alias, localKeyID[:])
+	if err != nil {
+		return nil, err
+	}
+
+	// 证书：叶子证书带属性，中间证书不带
+	raws := append([][]byte{b.Leaf.Raw}, chainRaws(b)...)
+	var certBags []safeBag
+	for i, raw := range raws {
+		bag, err := makeCertBag(raw)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			bag.Attributes = attrs
+		}
+		certBags = append(certBags, bag)
+	}
+	certSafe, err := encryptedSafe(certBags, pw)
+	if err != nil {
+		return nil, err
+	}
+
+	// 私钥：PKCS#8 加密后放入 pkcs8ShroudedKeyBag
+	keyDER, err := b.pkcs8()
+	if err != nil {
+		return nil, err
+	}
+	algo, ciphertext, err := pbeEncrypt(keyDER, pw)
+	if err != nil {
+		return nil, err
+	}
+	shrouded, err := asn1.Marshal(encryptedPrivateKeyInfo{Algorithm: algo, EncryptedData: ciphertext})
+	if err != nil {
+		return nil, err
+	}
+	keySafe, err := dataSafe([]safeBag{{ID: oidPKCS8ShroudedKeyBag, Value: explicit0(shrouded), Attributes: attrs}})
+	if err != nil {
+		return nil, err
+	}
+
+	authSafe, err := asn1.Marshal([]contentInfo{certSafe, keySafe})
+	if err != nil {
+		return nil, err
+	}
+	authSafeOctets, err := asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := randomBytes(8)
+	if err != nil {
+		return nil, err
+	}
+	macKey := pkcs12KDF(pw, salt, 3, pkcs12Iterations, 20)
+	mac := hmac.New(sha1.New, macKey)
+	mac.Write(authSafe)
+	return asn1.Marshal(pfxPdu{
+		Version:  3,
+		AuthSafe: contentInfo{ContentType: oidDataContentType, Content: explicit0(authSafeOctets)},
+		MacData: macData{
+			Mac:        digestInfo{Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1.NullRawValue}, Digest: mac.Sum(nil)},
+			MacSalt:    salt,
+			Iterations: pkcs12Iterations,
+		},
+	})
+}
+
+// bagAttributes friendlyName（别名）与 localKeyId（关联证书与私钥）
+func bagAttributes(alias string, localKeyID []byte) ([]pkcs12Attribute, error) {
+	var attrs []pkcs12Attribute
+	if alias != "" {
+		name, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagBMPString, Bytes: utf16BE(alias)})
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, pkcs12Attribute{ID: oidFriendlyName, Value: setOf(name)})
+	}
+	id, err := asn1.Marshal(localKeyID)
+	if err != nil {
+		return nil, err
+	}
+	return append(attrs, pkcs12Attribute{ID: oidLocalKeyID, Value: setOf(id)}), nil
+}
+
+// setOf 单元素 SET OF
+func setOf(inner []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: inner}
+}
+
+func makeCertBag(der []byte) (safeBag, error) {
+	octets, err := asn1.Marshal(der)
+	if err != nil {
+		return safeBag{}, err
+	}
+	value, err := asn1.Marshal(certBag{ID: oidCertTypeX509, Data: explicit0(octets)})
+	if err != nil {
+		return safeBag{}, err
+	}
+	return safeBag{ID: oidCertBag, Value: explicit0(value)}, nil
+}
+
+// dataSafe 不加密的 SafeContents（私钥袋本身已加密）
+func dataSafe(bags []safeBag) (contentInfo, error) {
+	data, err := asn1.Marshal(bags)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	octets, err := asn1.Marshal(data)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return contentInfo{ContentType: oidDataContentType, Content: explicit0(octets)}, nil
+}
+
+// encryptedSafe 加密的 SafeContents（证书袋）
+func encryptedSafe(bags []safeBag, pw []byte) (contentInfo, error) {
+	data, err := asn1.Marshal(bags)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	algo, ciphertext, err := pbeEncrypt(data, pw)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	ed, err := asn1.Marshal(encryptedData{
+		Version: 0,
+		EncryptedContentInfo: encryptedContentInfo{
+			ContentType:                oidDataContentType,
+			ContentEncryptionAlgorithm: algo,
+			EncryptedContent:           asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: ciphertext},
+		},
+	})
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return contentInfo{ContentType: oidEncryptedDataContentType, Content: explicit0(ed)}, nil
+}
+
+// pbeEncrypt pbeWithSHAAnd3-KeyTripleDES-CBC 加密（随机盐）
+func pbeEncrypt(plain, pw []byte) (pkix.AlgorithmIdentifier, []byte, error) {
+	salt, err := randomBytes(8)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+	params, err := asn1.Marshal(pbeParams{Salt: salt, Iterations: pkcs12Iterations})
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+	key := pkcs12KDF(pw, salt, 1, pkcs12Iterations, 24)
+	iv := pkcs12KDF(pw, salt, 2, pkcs12Iterations, 8)
+	block, err := des.NewTripleDESCipher(key)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+	pad := block.BlockSize() - len(plain)%block.BlockSize()
+	data := append(append([]byte{}, plain...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+	return pkix.AlgorithmIdentifier{Algorithm: oidPBEWithSHAAnd3KeyTDES, Parameters: asn1.RawValue{FullBytes: params}}, data, nil
+}
+
+// pkcs12KDF RFC 7292 附录 B.2 密钥派生（SHA-1）：id 1 为加密密钥、2 为 IV、3 为 MAC 密钥
+func pkcs12KDF(pw, salt []byte, id byte, iterations, size int) []byte {
+	const u, v = 20, 64
+	fill := func(src []byte) []byte {
+		if len(src) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(src)+v-1)/v))
+		for i := range out {
+			out[i] = src[i%len(src)]
+		}
+		return out
+	}
+	D := bytes.Repeat([]byte{id}, v)
+	I := append(fill(salt), fill(pw)...)
+	one := big.NewInt(1)
+	var out []byte
+	for len(out) < size {
+		h := sha1.New()
+		h.Write(D)
+		h.Write(I)
+		A := h.Sum(nil)
+		for j := 1; j < iterations; j++ {
+			sum := sha1.Sum(A)
+			A = sum[:]
+		}
+		out = append(out, A...)
+		// I_j = (I_j + B + 1) mod 2^(v*8)
+		B := new(big.Int).SetBytes(fill(A)[:v])
+		B.Add(B, one)
+		for j := 0; j < len(I); j += v {
+			Ij := new(big.Int).SetBytes(I[j : j+v])
+			Ij.Add(Ij, B)
+			b := Ij.Bytes()
+			if len(b) > v {
+				b = b[len(b)-v:]
+			}
+			chunk := I[j : j+v]
+			for k := range chunk {
+				chunk[k] = 0
+			}
+			copy(chunk[v-len(b):], b)
+		}
+	}
+	return out[:size]
+}
+
+// bmpString 密码编码为 UTF-16BE 并附加结尾的两个零字节（RFC 7292 B.1）
+func bmpString(s string) []byte {
+	return append(utf16BE(s), 0, 0)
+}
+
+// utf16BE 字符串编码为 UTF-16BE（BMPString 与 JKS 密码）
+func utf16BE(s string) []byte {
+	out := make([]byte, 0, 2*len(s))
+	for _, r := range utf16.Encode([]rune(s)) {
+		out = append(out, byte(r>>8), byte(r))
+	}
+	return out
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	return b, err
+}
@@ -0,0 +1,153 @@
+// Package confedit 对 Web 服务器配置文件做行级修改（替换、插入、删除若干行），
+// 保留文件其余内容（注释、空行、缩进、换行符）不变。修改由 nginxconf / apacheconf 按语法生成。
+package confedit
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Edit 一处行级修改：将第 Start～End 行（从 1 开始，含两端）替换为 Lines。
+// End = Start-1 表示在第 Start 行之前插入；Lines 为空表示删除
+type Edit struct {
+	File  string
+	Start int
+	End   int
+	Lines []string
+}
+
+// Insert 在第 line 行之前插入
+func Insert(file string, line int, lines ...string) Edit {
+	return Edit{File: file, Start: line, End: line - 1, Lines: lines}
+}
+
+// Replace 将第 start～end 行替换为 lines
+func Replace(file string, start, end int, lines ...string) Edit {
+	return Edit{File: file, Start: start, End: end, Lines: lines}
+}
+
+// File 按行读取的配置文件
+type File struct {
+	Lines []string
+	crlf  bool // 原文件使用 \r\n 换行
+	final bool // 原文件以换行结尾
+}
+
+// Read 读取配置文件
+func Read(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data), nil
+}
+
+// Parse 按行拆分配置内容
+func Parse(data []byte) *File {
+	text := string(data)
+	f := &File{crlf: strings.Contains(text, "\r\n")}
+	if f.crlf {
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+	}
+	f.final = strings.HasSuffix(text, "\n")
+	text = strings.TrimSuffix(text, "\n")
+	if text != "" || !f.final {
+		f.Lines = strings.Split(text, "\n")
+	}
+	return f
+}
+
+// Line 第 n 行（从 1 开始，越界时返回空串）
+func (f *File) Line(n int) string {
+	if n < 1 || n > len(f.Lines) {
+		return ""
+	}
+	return f.Lines[n-1]
+}
+
+// Bytes 按原换行符拼接
+func (f *File) Bytes() []byte {
+	sep := "\n"
+	if f.crlf {
+		sep = "\r\n"
+	}
+	text := strings.Join(f.Lines, sep)
+	if f.final {
+		text += sep
+	}
+	return []byte(text)
+}
+
+// Apply 应用同一文件的修改。修改区间不得重叠（同一位置的多处插入按给出的顺序排列）
+func (f *File) Apply(edits []Edit) error {
+	sorted := append([]Edit(nil), edits...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+	for i, e := range sorted {
+		if e.Start < 1 || e.End < e.Start-1 || e.End > len(f.Lines) {
+			return fmt.Errorf("修改位置无效: 第 %d～%d 行", e.Start, e.End)
+		}
+		if i > 0 && e.Start <= sorted[i-1].End {
+			return fmt.Errorf("修改位置重叠: 第 %d 行", e.Start)
+		}
+	}
+	// 自后向前应用，前面的行号不受影响
+	for i := len(sorted) - 1; i >= 0; i-- {
+		e := sorted[i]
+		rest := append([]string(nil), f.Lines[e.End:]...)
+		f.Lines = append(append(f.Lines[:e.Start-1], e.Lines...), rest...)
+	}
+	return nil
+}
+
+// Indent 行首缩进
+func Indent(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// StripComment 去掉行尾 # 注释（引号内的 # 不算）
+func StripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// GroupByFile 按文件分组修改
+func GroupByFile(edits []Edit) map[string][]Edit {
+	out := map[string][]Edit{}
+	for _, e := range edits {
+		out[e.File] = append(out[e.File], e)
+	}
+	return out
+}
+
+// Render 将修改应用到各文件的当前内容上（不写入磁盘），返回文件 → 修改后的内容
+func Render(edits []Edit) (map[string][]byte, error) {
+	out := map[string][]byte{}
+	for file, list := range GroupByFile(edits) {
+		f, err := Read(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.Apply(list); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		out[file] = f.Bytes()
+	}
+	return out, nil
+}
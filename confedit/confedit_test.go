@@ -0,0 +1,26 @@
+package confedit
+
+import "testing"
+
+// 替换、插入、删除按原行号定位，保留 \r\n 换行与结尾换行；区间重叠时报错
+func TestApply(t *testing.T) {
+	f := Parse([]byte("a\r\nb\r\nc\r\nd\r\n"))
+	err := f.Apply([]Edit{
+		Replace("x", 4, 4),
+		Insert("x", 2, "i1", "i2"),
+		Replace("x", 3, 3, "C"),
+		Insert("x", 5, "end"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(f.Bytes()); got != "a\r\ni1\r\ni2\r\nb\r\nC\r\nend\r\n" {
+		t.Fatalf("修改结果错误: %q", got)
+	}
+	if err := Parse([]byte("a\nb\nc")).Apply([]Edit{Replace("x", 1, 2, "y"), Replace("x", 2, 3)}); err == nil {
+		t.Fatal("区间重叠应报错")
+	}
+	if got := StripComment(`ssl_certificate "/a#b.pem"; # 注释`); got != `ssl_certificate "/a#b.pem"; ` {
+		t.Fatalf("注释去除错误: %q", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/go-ini/ini"
 	"os"
+	"strings"
 	"sync"
 )
 
@@ -78,13 +79,19 @@ func loadedRead() (release func()) {
 	return mu.RUnlock
 }
 
+// GetConfig 读取配置项；分区或配置项不存在时返回空串。
+// 只读不写：不会创建分区与配置项（否则下次保存时会把空的 [third.certd.default] 等分区写入配置文件）
 func GetConfig(rootName string, keyName string) (string, error) {
 	release := loadedRead()
 	if release == nil {
 		return "", fmt.Errorf("加载配置文件失败")
 	}
 	defer release()
-	return config.Section(rootName).Key(keyName).String(), nil
+	section, err := config.GetSection(rootName)
+	if err != nil || !section.HasKey(keyName) {
+		return "", nil
+	}
+	return section.Key(keyName).String(), nil
 }
 
 func SetConfig(rootName string, keyName string, value string) error {
@@ -95,7 +102,10 @@ func SetConfig(rootName string, keyName string, value string) error {
 			return err
 		}
 	}
-	config.Section(rootName).Key(keyName).SetValue(value)
+	// NewKey 写入分区自身（Key 在子分区缺少该项时返回父分区的同名项，如 third.certd.prod 的父分区 third.certd）
+	if _, err := config.Section(rootName).NewKey(keyName, value); err != nil {
+		return err
+	}
 	err := config.SaveTo(configPath)
 	if err != nil {
 		return fmt.Errorf("保存配置失败: %w", err)
@@ -136,12 +146,61 @@ func GetConfigs() ([]Entry, error) {
 	return configs, nil
 }
 
-func GetThirdCofig(third string, keyName string) (string, error) {
+// HasSection 分区是否存在
+func HasSection(name string) bool {
 	release := loadedRead()
 	if release == nil {
-		return "", fmt.Errorf("加载配置文件失败")
+		return false
+	}
+	defer release()
+	return config.HasSection(name)
+}
+
+// Sections 名称以 prefix 开头的分区（按 INI 文件中出现顺序）
+func Sections(prefix string) []string {
+	release := loadedRead()
+	if release == nil {
+		return nil
 	}
 	defer release()
-	rootName := "third." + third
-	return config.Section(rootName).Key(keyName).String(), nil
+	var names []string
+	for _, name := range config.SectionStrings() {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// RenameSection 将分区 from 的配置项移动到分区 to（to 中已有的配置项保留原值），删除 from 并保存
+func RenameSection(from, to string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if config == nil {
+		if err := initConfigLocked(); err != nil {
+			return err
+		}
+	}
+	src, err := config.GetSection(from)
+	if err != nil {
+		return nil
+	}
+	dst := config.Section(to)
+	// 只看 to 自身的配置项（子分区读取时会继承父分区的同名项）
+	kept := map[string]bool{}
+	for _, name := range dst.KeyStrings() {
+		kept[name] = dst.Key(name).String() != ""
+	}
+	for _, key := range src.Keys() {
+		if !kept[key.Name()] {
+			if _, err := dst.NewKey(key.Name(), key.Value()); err != nil {
+				return err
+			}
+		}
+	}
+	config.DeleteSection(from)
+	if err := config.SaveTo(configPath); err != nil {
+		return fmt.Errorf("保存配置失败: %w", err)
+	}
+	return nil
 }
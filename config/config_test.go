@@ -56,11 +56,31 @@ func TestThirdConfig(t *testing.T) {
 	if err := SetConfig(section, "api_url", "http://test.local"); err != nil {
 		t.Fatal(err)
 	}
-	// GetConfig 与 GetThirdCofig 两种方式访问 third.<name> section
-	v1, _ := GetConfig(section, "api_url")
-	v2, _ := GetThirdCofig("unit", "api_url")
-	if v1 != "http://test.local" || v2 != "http://test.local" {
-		t.Fatalf("third 配置读写不一致: %s / %s", v1, v2)
+	if v, _ := GetConfig(section, "api_url"); v != "http://test.local" {
+		t.Fatalf("third 配置读写不一致: %s", v)
+	}
+}
+
+// 读取不存在的分区或配置项返回空串，且不创建分区（保存后配置文件中不出现空分区）
+func TestGetConfigNoCreate(t *testing.T) {
+	if v, err := GetConfig("third.unit.missing", "api_url"); err != nil || v != "" {
+		t.Fatalf("不存在的分区应返回空串: %q %v", v, err)
+	}
+	if HasSection("third.unit.missing") {
+		t.Fatal("读取不应创建分区")
+	}
+	if err := SetConfig("third.unit.present", "api_url", "http://x"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := GetConfig("third.unit.present", "token"); v != "" {
+		t.Fatalf("不存在的配置项应返回空串: %q", v)
+	}
+	if err := SetConfig("", "unit_save", "1"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(configPath)
+	if strings.Contains(string(data), "third.unit.missing") || strings.Contains(string(data), "token") {
+		t.Fatalf("配置文件不应包含读取时产生的分区或配置项:\n%s", data)
 	}
 }
 
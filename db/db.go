@@ -1,18 +1,131 @@
 package db
 
 import (
+	"context"
 	"database/sql"
-	"errors"
 	"fmt"
 	_ "github.com/mattn/go-sqlite3"
 	"os"
 	"path/filepath"
 	"sort"
+	"ssl_assistant/utils"
 	"strings"
+	"time"
 )
 
 var db *sql.DB
 
+// certTableSchema certificates 建表语句（initDB 建表与 migrateCertificatesTable 重建共用，%s 为可选的 IF NOT EXISTS）
+const certTableSchema = `
+		CREATE TABLE %scertificates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			domain TEXT NOT NULL,
+			status TEXT NOT NULL,
+			create_time INTEGER NOT NULL,
+			expire_time INTEGER NOT NULL,
+			public_key TEXT NOT NULL,
+			private_key TEXT NOT NULL,
+			cert_source TEXT NOT NULL,
+			cert_id INTEGER NOT NULL DEFAULT 0,
+			cert_domains TEXT NOT NULL DEFAULT '',
+			pending_since INTEGER NOT NULL DEFAULT 0,
+			pending_polls INTEGER NOT NULL DEFAULT 0,
+			alert_days INTEGER NOT NULL DEFAULT 0,
+			alert_expire INTEGER NOT NULL DEFAULT 0,
+			last_renew INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			last_error_time INTEGER NOT NULL DEFAULT 0,
+			key_type TEXT NOT NULL DEFAULT '',
+			targets TEXT NOT NULL DEFAULT '',
+			hooks TEXT NOT NULL DEFAULT '',
+			import_info TEXT NOT NULL DEFAULT '',
+			reload_cmd TEXT NOT NULL DEFAULT '',
+			renew_days INTEGER NOT NULL DEFAULT 0,
+			providers TEXT NOT NULL DEFAULT '',
+			renewals TEXT NOT NULL DEFAULT '',
+			provider_instance TEXT NOT NULL DEFAULT '',
+			version INTEGER NOT NULL DEFAULT 0,
+			UNIQUE(domain, key_type)
+		);
+	`
+
+// deploymentTableSchema deployments 建表语句：证书部署位置（一对多，cert_id 对应 certificates.id）
+const deploymentTableSchema = `
+		CREATE TABLE IF NOT EXISTS deployments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			cert_id INTEGER NOT NULL,
+			cert_path TEXT NOT NULL,
+			key_path TEXT NOT NULL,
+			chain_path TEXT NOT NULL DEFAULT '',
+			owner TEXT NOT NULL DEFAULT '',
+			mode TEXT NOT NULL DEFAULT '',
+			key_mode TEXT NOT NULL DEFAULT '',
+			UNIQUE(cert_id, cert_path)
+		);
+	`
+
+// tagTableSchema cert_tags 建表语句：证书标签（一对多，cert_id 对应 certificates.id）
+const tagTableSchema = `
+		CREATE TABLE IF NOT EXISTS cert_tags (
+			cert_id INTEGER NOT NULL,
+			tag TEXT NOT NULL,
+			UNIQUE(cert_id, tag)
+		);
+	`
+
+// queryIndexes 查询条件使用的索引（证书表迁移重建后再创建）
+var queryIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_certificates_source ON certificates(cert_source)",
+	"CREATE INDEX IF NOT EXISTS idx_certificates_status ON certificates(status)",
+	"CREATE INDEX IF NOT EXISTS idx_certificates_expire ON certificates(expire_time)",
+	"CREATE INDEX IF NOT EXISTS idx_deployments_cert_path ON deployments(cert_path)",
+	"CREATE INDEX IF NOT EXISTS idx_deployments_key_path ON deployments(key_path)",
+	"CREATE INDEX IF NOT EXISTS idx_deployments_chain_path ON deployments(chain_path)",
+	"CREATE INDEX IF NOT EXISTS idx_cert_tags_tag ON cert_tags(tag)",
+}
+
+// certColumns certificates 表查询/写入列（顺序与 scanCertificate、certValues 一一对应）
+const certColumns = "id, domain, status, create_time, expire_time, public_key, private_key, cert_source, cert_id, cert_domains, pending_since, pending_polls, alert_days, alert_expire, last_renew, last_error, last_error_time, key_type, targets, hooks, import_info, reload_cmd, renew_days, providers, renewals, provider_instance, version"
+
+// certInsertColumns 新增证书写入列（不含自增 id）
+const certInsertColumns = "domain, status, create_time, expire_time, public_key, private_key, cert_source, cert_id, cert_domains, pending_since, pending_polls, alert_days, alert_expire, last_renew, last_error, last_error_time, key_type, targets, hooks, import_info, reload_cmd, renew_days, providers, renewals, provider_instance, version"
+
+// certUniqueKey 新版唯一约束（同一域名可保存多种密钥类型的证书，如 RSA + ECDSA 双证书）
+const certUniqueKey = "UNIQUE(domain, key_type)"
+
+// rowScanner 兼容 *sql.Row 与 *sql.Rows 的扫描接口
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanCertificate 按 certColumns 顺序扫描一行证书记录
+func scanCertificate(row rowScanner) (Certificate, error) {
+	var cert Certificate
+	err := row.Scan(&cert.ID, &cert.Domain, &cert.Status, &cert.CreateTime, &cert.ExpireTime, &cert.PublicKey, &cert.PrivateKey, &cert.CertSource, &cert.CertID, &cert.CertDomains, &cert.PendingSince, &cert.PendingPolls, &cert.AlertDays, &cert.AlertExpire, &cert.LastRenew, &cert.LastError, &cert.LastErrorTime, &cert.KeyType, &cert.Targets, &cert.Hooks, &cert.Import, &cert.ReloadCmd, &cert.RenewDays, &cert.Providers, &cert.Renewals, &cert.ProviderInstance, &cert.Version)
+	return cert, err
+}
+
+// certValues 按 certInsertColumns 顺序返回证书字段值
+func certValues(cert Certificate) []any {
+	return []any{cert.Domain, cert.Status, cert.CreateTime, cert.ExpireTime, cert.PublicKey, cert.PrivateKey, cert.CertSource, cert.CertID, cert.CertDomains, cert.PendingSince, cert.PendingPolls, cert.AlertDays, cert.AlertExpire, cert.LastRenew, cert.LastError, cert.LastErrorTime, cert.KeyType, cert.Targets, cert.Hooks, cert.Import, cert.ReloadCmd, cert.RenewDays, cert.Providers, cert.Renewals, cert.ProviderInstance, cert.Version}
+}
+
+// placeholders 返回 n 个以逗号分隔的 SQL 占位符
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// sqliteBusyTimeout 写锁被占用时的等待时间（守护进程与命令行、TUI 同时写入时排队而非立即报 database is locked）
+const sqliteBusyTimeout = 5 * time.Second
+
+// openSQLite 打开 SQLite 数据库：WAL 模式（读写互不阻塞）、busy timeout、写事务以 BEGIN IMMEDIATE 开始
+// （事务开始即获取写锁，避免读事务升级为写事务时的死锁，等待由 busy timeout 处理）
+func openSQLite(path string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("%s?_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=%d&_txlock=immediate",
+		path, sqliteBusyTimeout.Milliseconds())
+	return sql.Open("sqlite3", dsn)
+}
+
 // 初始化数据库
 func initDB() error {
 	// 获取用户主目录
@@ -28,29 +141,21 @@ func initDB() error {
 	}
 
 	// 打开数据库
-	dbPath := filepath.Join(dataDir, "ssl_assistant.db")
-	db, err = sql.Open("sqlite3", dbPath)
+	db, err = openSQLite(filepath.Join(dataDir, "ssl_assistant.db"))
 	if err != nil {
 		return fmt.Errorf("打开数据库失败: %v", err)
 	}
 
 	// 创建表
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS certificates (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			domain TEXT NOT NULL UNIQUE,
-			status TEXT NOT NULL,
-			create_time INTEGER NOT NULL,
-			expire_time INTEGER NOT NULL,
-			public_key TEXT NOT NULL,
-			private_key TEXT NOT NULL,
-			cert_path TEXT NOT NULL,
-			key_path TEXT NOT NULL,
-			cert_source TEXT NOT NULL,
-			cert_id INTEGER NOT NULL DEFAULT 0,
-			cert_domains TEXT NOT NULL DEFAULT ''
-		);
-	`)
+	_, err = db.Exec(fmt.Sprintf(certTableSchema, "IF NOT EXISTS "))
+	if err != nil {
+		return fmt.Errorf("创建表失败: %v", err)
+	}
+	_, err = db.Exec(deploymentTableSchema)
+	if err != nil {
+		return fmt.Errorf("创建表失败: %v", err)
+	}
+	_, err = db.Exec(tagTableSchema)
 	if err != nil {
 		return fmt.Errorf("创建表失败: %v", err)
 	}
@@ -61,21 +166,35 @@ func initDB() error {
 		return fmt.Errorf("迁移证书表列失败: %v", err)
 	}
 
-	// 迁移旧表：为 domain 添加 UNIQUE 约束（旧表无该约束）
+	// 迁移旧表：部署位置补充 owner / mode / key_mode 列
+	err = ensureDeploymentColumns()
+	if err != nil {
+		return fmt.Errorf("迁移部署位置表列失败: %v", err)
+	}
+
+	// 迁移旧表：唯一约束改为 (domain, key_type)（旧表无约束或为 domain UNIQUE）；部署路径移至 deployments 表
 	err = migrateCertificatesTable()
 	if err != nil {
 		return fmt.Errorf("迁移证书表失败: %v", err)
 	}
 
+	// 查询索引（来源、状态、到期时间、部署路径、标签）
+	for _, stmt := range queryIndexes {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("创建索引失败: %v", err)
+		}
+	}
+
 	return nil
 }
 
-// ensureCertColumns 检查 certificates 表是否存在 cert_id / cert_domains 列，不存在则补充
-func ensureCertColumns() error {
-	rows, err := db.Query("PRAGMA table_info(certificates)")
+// tableColumns 读取表的列名
+func tableColumns(table string) (map[string]bool, error) {
+	rows, err := db.Query("PRAGMA table_info(" + table + ")")
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 	cols := make(map[string]bool)
 	for rows.Next() {
 		var cid int
@@ -84,12 +203,19 @@ func ensureCertColumns() error {
 		var dflt sql.NullString
 		var pk int
 		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk); err != nil {
-			rows.Close()
-			return err
+			return nil, err
 		}
 		cols[name] = true
 	}
-	rows.Close()
+	return cols, rows.Err()
+}
+
+// ensureCertColumns 检查 certificates 表是否存在 cert_id / cert_domains / pending_* / alert_* / last_* / key_type / targets / hooks / import_info / reload_cmd / renew_days / providers / renewals / provider_instance / version 列，不存在则补充
+func ensureCertColumns() error {
+	cols, err := tableColumns("certificates")
+	if err != nil {
+		return err
+	}
 
 	if !cols["cert_id"] {
 		if _, err := db.Exec("ALTER TABLE certificates ADD COLUMN cert_id INTEGER NOT NULL DEFAULT 0"); err != nil {
@@ -101,10 +227,112 @@ func ensureCertColumns() error {
 			return err
 		}
 	}
+	if !cols["pending_since"] {
+		if _, err := db.Exec("ALTER TABLE certificates ADD COLUMN pending_since INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+	}
+	if !cols["pending_polls"] {
+		if _, err := db.Exec("ALTER TABLE certificates ADD COLUMN pending_polls INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+	}
+	if !cols["alert_days"] {
+		if _, err := db.Exec("ALTER TABLE certificates ADD COLUMN alert_days INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+	}
+	if !cols["alert_expire"] {
+		if _, err := db.Exec("ALTER TABLE certificates ADD COLUMN alert_expire INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+	}
+	if !cols["last_renew"] {
+		if _, err := db.Exec("ALTER TABLE certificates ADD COLUMN last_renew INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+	}
+	if !cols["last_error"] {
+		if _, err := db.Exec("ALTER TABLE certificates ADD COLUMN last_error TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	if !cols["last_error_time"] {
+		if _, err := db.Exec("ALTER TABLE certificates ADD COLUMN last_error_time INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+	}
+	if !cols["key_type"] {
+		if _, err := db.Exec("ALTER TABLE certificates ADD COLUMN key_type TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	// chain_path 为旧版表的部署路径列（已迁移至 deployments 表），仅旧表迁移读取时需要
+	if cols["cert_path"] && !cols["chain_path"] {
+		if _, err := db.Exec("ALTER TABLE certificates ADD COLUMN chain_path TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	if !cols["targets"] {
+		if _, err := db.Exec("ALTER TABLE certificates ADD COLUMN targets TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	if !cols["hooks"] {
+		if _, err := db.Exec("ALTER TABLE certificates ADD COLUMN hooks TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	if !cols["import_info"] {
+		if _, err := db.Exec("ALTER TABLE certificates ADD COLUMN import_info TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	if !cols["reload_cmd"] {
+		if _, err := db.Exec("ALTER TABLE certificates ADD COLUMN reload_cmd TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	if !cols["renew_days"] {
+		if _, err := db.Exec("ALTER TABLE certificates ADD COLUMN renew_days INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+	}
+	for _, col := range []string{"providers", "renewals", "provider_instance"} {
+		if cols[col] {
+			continue
+		}
+		if _, err := db.Exec("ALTER TABLE certificates ADD COLUMN " + col + " TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	if !cols["version"] {
+		if _, err := db.Exec("ALTER TABLE certificates ADD COLUMN version INTEGER NOT NULL DEFAULT 0"); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// migrateCertificatesTable 检查旧版 certificates 表（无 UNIQUE 约束）并重建迁移
+// ensureDeploymentColumns 检查 deployments 表是否存在 owner / mode / key_mode 列，不存在则补充
+func ensureDeploymentColumns() error {
+	cols, err := tableColumns("deployments")
+	if err != nil {
+		return err
+	}
+	for _, col := range []string{"owner", "mode", "key_mode"} {
+		if cols[col] {
+			continue
+		}
+		if _, err := db.Exec("ALTER TABLE deployments ADD COLUMN " + col + " TEXT NOT NULL DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateCertificatesTable 检查旧版 certificates 表（无 UNIQUE 约束或仅 domain UNIQUE、部署路径在证书表中）并重建迁移：
+// 按证书内容回填 key_type；部署路径移至 deployments 表，因唯一约束被合并的重复记录，其路径作为保留记录的部署位置
 func migrateCertificatesTable() error {
 	var sqlText string
 	err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type='table' AND name='certificates'`).Scan(&sqlText)
@@ -112,31 +340,37 @@ func migrateCertificatesTable() error {
 		// 表不存在则无需迁移
 		return nil
 	}
-	if strings.Contains(sqlText, "UNIQUE") {
+	legacyPaths := strings.Contains(sqlText, "cert_path")
+	if strings.Contains(sqlText, certUniqueKey) && !legacyPaths {
 		return nil
 	}
 
 	// 读取现有数据
-	rows, err := db.Query("SELECT id, domain, status, create_time, expire_time, public_key, private_key, cert_path, key_path, cert_source, cert_id, cert_domains FROM certificates")
+	rows, err := db.Query("SELECT " + certColumns + " FROM certificates")
 	if err != nil {
 		return err
 	}
 	var certs []Certificate
 	for rows.Next() {
-		var cert Certificate
-		var createTime, expireTime int64
-		if err := rows.Scan(&cert.ID, &cert.Domain, &cert.Status, &createTime, &expireTime, &cert.PublicKey, &cert.PrivateKey, &cert.CertPath, &cert.KeyPath, &cert.CertSource, &cert.CertID, &cert.CertDomains); err != nil {
+		cert, err := scanCertificate(rows)
+		if err != nil {
 			rows.Close()
 			return err
 		}
-		cert.CreateTime = createTime
-		cert.ExpireTime = expireTime
+		if cert.KeyType == "" {
+			cert.KeyType = utils.PEMKeyType([]byte(cert.PublicKey))
+		}
 		certs = append(certs, cert)
 	}
 	rows.Close()
 	if err := rows.Err(); err != nil {
 		return err
 	}
+	if legacyPaths {
+		if err := readLegacyDeployments(certs); err != nil {
+			return err
+		}
+	}
 
 	// 按 id 倒序排序，保证 UNIQUE 冲突时保留最新记录
 	sort.Slice(certs, func(i, j int) bool {
@@ -153,136 +387,320 @@ func migrateCertificatesTable() error {
 	if _, err := tx.Exec("DROP TABLE certificates"); err != nil {
 		return err
 	}
-	if _, err := tx.Exec(`
-		CREATE TABLE certificates (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			domain TEXT NOT NULL UNIQUE,
-			status TEXT NOT NULL,
-			create_time INTEGER NOT NULL,
-			expire_time INTEGER NOT NULL,
-			public_key TEXT NOT NULL,
-			private_key TEXT NOT NULL,
-			cert_path TEXT NOT NULL,
-			key_path TEXT NOT NULL,
-			cert_source TEXT NOT NULL,
-			cert_id INTEGER NOT NULL DEFAULT 0,
-			cert_domains TEXT NOT NULL DEFAULT ''
-		);
-	`); err != nil {
+	if _, err := tx.Exec(fmt.Sprintf(certTableSchema, "")); err != nil {
 		return err
 	}
 
 	// UNIQUE 冲突时忽略重复项，保留最新记录（已按 id 倒序）；显式写入原 id 保证用户记录编号不失效
 	for _, cert := range certs {
 		if _, err := tx.Exec(
-			"INSERT OR IGNORE INTO certificates (id, domain, status, create_time, expire_time, public_key, private_key, cert_path, key_path, cert_source, cert_id, cert_domains) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
-			cert.ID, cert.Domain, cert.Status, cert.CreateTime, cert.ExpireTime, cert.PublicKey, cert.PrivateKey, cert.CertPath, cert.KeyPath, cert.CertSource, cert.CertID, cert.CertDomains,
+			"INSERT OR IGNORE INTO certificates ("+certColumns+") VALUES ("+placeholders(len(certValues(cert))+1)+")",
+			append([]any{cert.ID}, certValues(cert)...)...,
 		); err != nil {
 			return err
 		}
 	}
+	// 部署位置归属到保留的记录（同一路径只保留一条）
+	for _, cert := range certs {
+		if len(cert.Deployments) == 0 {
+			continue
+		}
+		var id int
+		if err := tx.QueryRow("SELECT id FROM certificates WHERE domain = ? AND key_type = ?", cert.Domain, cert.KeyType).Scan(&id); err != nil {
+			return err
+		}
+		if err := insertDeployments(context.Background(), tx, id, cert.Deployments); err != nil {
+			return err
+		}
+	}
 	return tx.Commit()
 }
 
-// 添加证书
-func addCertificateToDB(cert Certificate) error {
-	_, err := db.Exec(
-		"INSERT INTO certificates (domain, status, create_time, expire_time, public_key, private_key, cert_path, key_path, cert_source, cert_id, cert_domains) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		cert.Domain, cert.Status, cert.CreateTime, cert.ExpireTime, cert.PublicKey, cert.PrivateKey, cert.CertPath, cert.KeyPath, cert.CertSource, cert.CertID, cert.CertDomains,
-	)
-	return err
+// readLegacyDeployments 读取旧版证书表中的部署路径（cert_path / key_path / chain_path 列）
+func readLegacyDeployments(certs []Certificate) error {
+	for i := range certs {
+		var d Deployment
+		err := db.QueryRow("SELECT cert_path, key_path, chain_path FROM certificates WHERE id = ?", certs[i].ID).Scan(&d.CertPath, &d.KeyPath, &d.ChainPath)
+		if err != nil {
+			return err
+		}
+		if d.CertPath != "" || d.KeyPath != "" {
+			certs[i].Deployments = []Deployment{d}
+		}
+	}
+	return nil
 }
 
-// 删除证书
-func deleteCertificateFromDB(id int) error {
-	result, err := db.Exec("DELETE FROM certificates WHERE id = ?", id)
-	if err != nil {
-		return err
+// execer 兼容 *sql.DB 与 *sql.Tx 的执行接口
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// insertDeployments 写入证书的部署位置（路径重复时忽略）
+func insertDeployments(ctx context.Context, tx execer, certID int, deployments []Deployment) error {
+	for _, d := range deployments {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT OR IGNORE INTO deployments (cert_id, cert_path, key_path, chain_path, owner, mode, key_mode) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			certID, d.CertPath, d.KeyPath, d.ChainPath, d.Owner, d.Mode, d.KeyMode,
+		); err != nil {
+			return err
+		}
 	}
-	affected, err := result.RowsAffected()
+	return nil
+}
+
+// loadDeployments 读取证书的部署位置（按添加顺序）
+func loadDeployments(ctx context.Context, certs []Certificate) error {
+	if len(certs) == 0 {
+		return nil
+	}
+	index := make(map[int]int, len(certs))
+	ids := make([]any, len(certs))
+	for i, cert := range certs {
+		index[cert.ID] = i
+		ids[i] = cert.ID
+	}
+	rows, err := db.QueryContext(ctx, "SELECT cert_id, cert_path, key_path, chain_path, owner, mode, key_mode FROM deployments WHERE cert_id IN ("+placeholders(len(ids))+") ORDER BY id", ids...)
 	if err != nil {
 		return err
 	}
-	if affected == 0 {
-		return ErrNotFound
+	defer rows.Close()
+	for rows.Next() {
+		var certID int
+		var d Deployment
+		if err := rows.Scan(&certID, &d.CertPath, &d.KeyPath, &d.ChainPath, &d.Owner, &d.Mode, &d.KeyMode); err != nil {
+			return err
+		}
+		if i, ok := index[certID]; ok {
+			certs[i].Deployments = append(certs[i].Deployments, d)
+		}
+	}
+	return rows.Err()
+}
+
+// insertTags 写入证书的标签（重复时忽略）
+func insertTags(ctx context.Context, tx execer, certID int, tags []string) error {
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO cert_tags (cert_id, tag) VALUES (?, ?)", certID, tag); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// 获取所有证书
-func getAllCertificates() ([]Certificate, error) {
-	rows, err := db.Query("SELECT id, domain, status, create_time, expire_time, public_key, private_key, cert_path, key_path, cert_source, cert_id, cert_domains FROM certificates")
+// loadTags 读取证书的标签（按添加顺序）
+func loadTags(ctx context.Context, certs []Certificate) error {
+	if len(certs) == 0 {
+		return nil
+	}
+	index := make(map[int]int, len(certs))
+	ids := make([]any, len(certs))
+	for i, cert := range certs {
+		index[cert.ID] = i
+		ids[i] = cert.ID
+	}
+	rows, err := db.QueryContext(ctx, "SELECT cert_id, tag FROM cert_tags WHERE cert_id IN ("+placeholders(len(ids))+") ORDER BY rowid", ids...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
+	for rows.Next() {
+		var certID int
+		var tag string
+		if err := rows.Scan(&certID, &tag); err != nil {
+			return err
+		}
+		if i, ok := index[certID]; ok {
+			certs[i].Tags = append(certs[i].Tags, tag)
+		}
+	}
+	return rows.Err()
+}
+
+// sqliteTxRetries 写事务因锁冲突失败（busy timeout 内仍未获得写锁）时的重试次数
+const sqliteTxRetries = 3
+
+// withTx 在写事务中执行 fn 并提交；写锁冲突时按退避间隔重试（ctx 取消时停止），唯一约束冲突转换为 ErrExists
+func withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	for attempt := 0; ; attempt++ {
+		err := runTx(ctx, fn)
+		if err == nil || !isSQLiteBusy(err) || attempt >= sqliteTxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt+1) * 200 * time.Millisecond):
+		}
+	}
+}
 
+// runTx 执行一次写事务（出错时回滚）
+func runTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// conflictError 唯一约束冲突（同一域名与密钥类型已有记录）转换为 ErrExists
+func conflictError(err error, domain string) error {
+	if isSQLiteUnique(err) {
+		return fmt.Errorf("域名 %s 的%w", domain, ErrExists)
+	}
+	return err
+}
+
+// 添加证书（证书记录、部署位置与标签在同一事务中写入）
+func addCertificateToDB(ctx context.Context, cert Certificate) error {
+	return withTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx,
+			"INSERT INTO certificates ("+certInsertColumns+") VALUES ("+placeholders(len(certValues(cert)))+")",
+			certValues(cert)...,
+		)
+		if err != nil {
+			return conflictError(err, cert.Domain)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if err := insertDeployments(ctx, tx, int(id), cert.Deployments); err != nil {
+			return err
+		}
+		return insertTags(ctx, tx, int(id), cert.Tags)
+	})
+}
+
+// 删除证书（连同部署位置与标签）
+func deleteCertificateFromDB(ctx context.Context, id int) error {
+	return withTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "DELETE FROM certificates WHERE id = ?", id)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return ErrNotFound
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM deployments WHERE cert_id = ?", id); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM cert_tags WHERE cert_id = ?", id)
+		return err
+	})
+}
+
+// queryCertificates 查询证书记录并读取各自的部署位置与标签
+func queryCertificates(ctx context.Context, query string, args ...any) ([]Certificate, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
 	var certificates []Certificate
 	for rows.Next() {
-		var cert Certificate
-		var createTime, expireTime int64
-		err := rows.Scan(&cert.ID, &cert.Domain, &cert.Status, &createTime, &expireTime, &cert.PublicKey, &cert.PrivateKey, &cert.CertPath, &cert.KeyPath, &cert.CertSource, &cert.CertID, &cert.CertDomains)
+		cert, err := scanCertificate(rows)
 		if err != nil {
+			rows.Close()
 			return nil, err
 		}
-
-		// 解析时间
-		cert.CreateTime = createTime
-		cert.ExpireTime = expireTime
-
 		certificates = append(certificates, cert)
 	}
-
-	return certificates, nil
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := loadDeployments(ctx, certificates); err != nil {
+		return nil, err
+	}
+	return certificates, loadTags(ctx, certificates)
 }
 
-// 获取证书
-func getCertificate(id int) (Certificate, error) {
-	var cert Certificate
-	var createTime, expireTime int64
-	err := db.QueryRow(
-		"SELECT id, domain, status, create_time, expire_time, public_key, private_key, cert_path, key_path, cert_source, cert_id, cert_domains FROM certificates WHERE id = ?",
-		id,
-	).Scan(&cert.ID, &cert.Domain, &cert.Status, &createTime, &expireTime, &cert.PublicKey, &cert.PrivateKey, &cert.CertPath, &cert.KeyPath, &cert.CertSource, &cert.CertID, &cert.CertDomains)
-	if errors.Is(err, sql.ErrNoRows) {
-		return cert, ErrNotFound
-	}
+// queryCertificate 查询单条证书记录，不存在时返回 ErrNotFound
+func queryCertificate(ctx context.Context, query string, args ...any) (Certificate, error) {
+	certs, err := queryCertificates(ctx, query, args...)
 	if err != nil {
-		return cert, err
+		return Certificate{}, err
 	}
-	cert.CreateTime = createTime
-	cert.ExpireTime = expireTime
+	if len(certs) == 0 {
+		return Certificate{}, ErrNotFound
+	}
+	return certs[0], nil
+}
 
-	return cert, nil
+// 获取所有证书
+func getAllCertificates(ctx context.Context) ([]Certificate, error) {
+	return queryCertificates(ctx, "SELECT "+certColumns+" FROM certificates")
 }
 
-// 获取证书（通过域名）
-func getDomainCertificate(domain string) (Certificate, error) {
-	var cert Certificate
-	var createTime, expireTime int64
-	err := db.QueryRow(
-		"SELECT id, domain, status, create_time, expire_time, public_key, private_key, cert_path, key_path, cert_source, cert_id, cert_domains FROM certificates WHERE domain = ?",
-		domain,
-	).Scan(&cert.ID, &cert.Domain, &cert.Status, &createTime, &expireTime, &cert.PublicKey, &cert.PrivateKey, &cert.CertPath, &cert.KeyPath, &cert.CertSource, &cert.CertID, &cert.CertDomains)
-	if errors.Is(err, sql.ErrNoRows) {
-		return cert, ErrNotFound
-	}
-	if err != nil {
-		return cert, err
+// 获取证书
+func getCertificate(ctx context.Context, id int) (Certificate, error) {
+	return queryCertificate(ctx, "SELECT "+certColumns+" FROM certificates WHERE id = ?", id)
+}
+
+// 获取证书（通过域名，多种密钥类型时返回最早添加的一条）
+func getDomainCertificate(ctx context.Context, domain string) (Certificate, error) {
+	return queryCertificate(ctx, "SELECT "+certColumns+" FROM certificates WHERE domain = ? ORDER BY id LIMIT 1", domain)
+}
+
+// 按条件查询证书（过滤、排序与分页均在 SQL 中完成）
+func queryCertificatesByCondition(ctx context.Context, q CertQuery) ([]Certificate, error) {
+	if err := q.validate(); err != nil {
+		return nil, err
 	}
-	cert.CreateTime = createTime
-	cert.ExpireTime = expireTime
+	clauses, args := q.sqlClauses()
+	return queryCertificates(ctx, "SELECT "+certColumns+" FROM certificates"+clauses, args...)
+}
 
-	return cert, nil
+// 获取域名下全部密钥类型的证书（按 id 排序）
+func getDomainCertificates(ctx context.Context, domain string) ([]Certificate, error) {
+	return queryCertificates(ctx, "SELECT "+certColumns+" FROM certificates WHERE domain = ? ORDER BY id", domain)
 }
 
-// 更新证书
-func updateCertificateInDB(cert Certificate) error {
-	_, err := db.Exec(
-		"UPDATE certificates SET domain = ?, status = ?, create_time = ?, expire_time = ?, public_key = ?, private_key = ?, cert_path = ?, key_path = ?, cert_source = ?, cert_id = ?, cert_domains = ? WHERE id = ?",
-		cert.Domain, cert.Status, cert.CreateTime, cert.ExpireTime, cert.PublicKey, cert.PrivateKey, cert.CertPath, cert.KeyPath, cert.CertSource, cert.CertID, cert.CertDomains, cert.ID,
-	)
-	return err
+// 更新证书（部署位置与标签整体替换为 cert.Deployments / cert.Tags；记录不存在时返回 ErrNotFound）。
+// 仅当记录版本仍为 cert.Version 时写入并将版本加 1，读取后已被其他进程修改时返回 ErrConflict
+func updateCertificateInDB(ctx context.Context, cert Certificate) error {
+	return withTx(ctx, func(tx *sql.Tx) error {
+		next := cert
+		next.Version++
+		result, err := tx.ExecContext(ctx,
+			"UPDATE certificates SET domain = ?, status = ?, create_time = ?, expire_time = ?, public_key = ?, private_key = ?, cert_source = ?, cert_id = ?, cert_domains = ?, pending_since = ?, pending_polls = ?, alert_days = ?, alert_expire = ?, last_renew = ?, last_error = ?, last_error_time = ?, key_type = ?, targets = ?, hooks = ?, import_info = ?, reload_cmd = ?, renew_days = ?, providers = ?, renewals = ?, provider_instance = ?, version = ? WHERE id = ? AND version = ?",
+			append(certValues(next), cert.ID, cert.Version)...,
+		)
+		if err != nil {
+			return conflictError(err, cert.Domain)
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if affected == 0 {
+			var n int
+			if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM certificates WHERE id = ?", cert.ID).Scan(&n); err != nil {
+				return err
+			}
+			if n == 0 {
+				return ErrNotFound
+			}
+			return fmt.Errorf("域名 %s 的%w", cert.Domain, ErrConflict)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM deployments WHERE cert_id = ?", cert.ID); err != nil {
+			return err
+		}
+		if err := insertDeployments(ctx, tx, cert.ID, cert.Deployments); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM cert_tags WHERE cert_id = ?", cert.ID); err != nil {
+			return err
+		}
+		return insertTags(ctx, tx, cert.ID, cert.Tags)
+	})
 }
 
 // closeDB 关闭 SQLite 数据库连接（释放文件句柄，测试与显式关闭场景使用）
@@ -6,9 +6,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/dgraph-io/badger/v3"
+	"github.com/fatih/color"
 )
 
 // 使用纯Go实现的键值存储作为SQLite的替代方案
@@ -16,6 +21,33 @@ import (
 
 var badgerDB *badger.DB
 
+// Badger 键布局：
+//
+//	meta:next_id                          自增ID
+//	meta:layout                           键布局版本（旧版数据打开时重建索引）
+//	cert:<id>                             证书记录（JSON）
+//	idx:domain:<域名>/<密钥类型>           → id，(域名, 密钥类型) 唯一，与 SQLite 的 UNIQUE(domain, key_type) 一致
+//	idx:source:<来源>/<id>                → id，按来源查询
+//	idx:expiry:<到期日 YYYYMMDD>/<id>     → id，按到期时间范围查询（申请中尚无到期时间的不建索引）
+//	idx:tag:<标签>/<id>                   → id，按标签查询
+//	idx:path:<部署路径>\x00<id>           → id，按部署位置的证书/私钥/证书链路径查询
+//
+// 记录与索引在同一事务中写入，索引由记录推导，可随时由 checkBadgerDB 重建
+const (
+	badgerNextIDKey       = "meta:next_id"
+	badgerLayoutKey       = "meta:layout"
+	badgerLayoutVersion   = "3"
+	badgerCertPrefix      = "cert:"
+	badgerIndexPrefix     = "idx:"
+	badgerDomainIdxPrefix = "idx:domain:"
+	badgerSourceIdxPrefix = "idx:source:"
+	badgerExpiryIdxPrefix = "idx:expiry:"
+	badgerTagIdxPrefix    = "idx:tag:"
+	badgerPathIdxPrefix   = "idx:path:"
+	// 旧版域名索引 domain:<域名>[/<密钥类型>]（布局版本 2 之前）
+	badgerLegacyDomainPrefix = "domain:"
+)
+
 // 初始化Badger数据库（纯Go实现，不需要CGO）
 func initBadgerDB() error {
 	// 获取用户主目录
@@ -30,17 +62,54 @@ func initBadgerDB() error {
 		return fmt.Errorf("创建数据目录失败: %v", err)
 	}
 
-	// 打开Badger数据库
+	db, err := openBadgerDB(dataDir)
+	if err != nil {
+		return err
+	}
+	badgerDB = db
+	return migrateBadgerLayout()
+}
+
+// openBadgerDB 打开指定目录下的Badger数据库
+func openBadgerDB(dataDir string) (*badger.DB, error) {
 	opts := badger.DefaultOptions(dataDir)
 	opts.Logger = nil                // 禁用日志
 	opts.ValueLogFileSize = 64 << 20 // 值日志文件默认1GB，证书数据量小，缩小到64MB避免浪费磁盘空间
 	db, err := badger.Open(opts)
 	if err != nil {
-		return fmt.Errorf("打开Badger数据库失败: %v", err)
+		return nil, fmt.Errorf("打开Badger数据库失败: %v", err)
 	}
+	return db, nil
+}
 
-	badgerDB = db
-	return nil
+// migrateBadgerLayout 旧版布局（仅 domain: 索引）的数据按当前布局重建索引，完成后记录布局版本
+func migrateBadgerLayout() error {
+	var version string
+	err := badgerDB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(badgerLayoutKey))
+		if err != nil {
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		version = string(val)
+		return err
+	})
+	if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+		return err
+	}
+	if version == badgerLayoutVersion {
+		return nil
+	}
+	report, err := checkBadgerDB(true)
+	if err != nil {
+		return fmt.Errorf("重建BadgerDB索引失败: %v", err)
+	}
+	if len(report.Issues) > 0 {
+		color.Cyan("BadgerDB 已升级到新的索引布局（处理 %d 项）\n", len(report.Issues))
+	}
+	return badgerUpdate(func(txn *badger.Txn) error {
+		return txn.Set([]byte(badgerLayoutKey), []byte(badgerLayoutVersion))
+	})
 }
 
 // 关闭Badger数据库
@@ -50,192 +119,522 @@ func closeBadgerDB() {
 	}
 }
 
-// getNextBadgerID 生成唯一自增ID（事务内原子读写，避免时间戳碰撞覆盖）
-func getNextBadgerID() (int, error) {
-	var id int
-	err := badgerDB.Update(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte("meta:next_id"))
-		if errors.Is(err, badger.ErrKeyNotFound) {
-			id = 1
-		} else if err != nil {
-			return err
-		} else {
-			val, err := item.ValueCopy(nil)
-			if err != nil {
-				return err
-			}
-			id, err = strconv.Atoi(string(val))
-			if err != nil {
-				return err
+// badgerWriteMu 串行执行写事务：Badger 只能由一个进程打开，进程内的并发写入（如守护进程的更新任务与文件监视）
+// 若并行执行，修改同一键（自增ID、域名索引）的事务提交时会因乐观并发检查失败（ErrConflict）
+var badgerWriteMu sync.Mutex
+
+// badgerUpdate 执行读写事务（进程内串行）
+func badgerUpdate(fn func(txn *badger.Txn) error) error {
+	badgerWriteMu.Lock()
+	defer badgerWriteMu.Unlock()
+	return badgerDB.Update(fn)
+}
+
+// badgerCertKey 证书记录键
+func badgerCertKey(id int) []byte {
+	return []byte(badgerCertPrefix + strconv.Itoa(id))
+}
+
+// badgerDomainKey 域名索引键：按 (域名, 密钥类型) 唯一
+func badgerDomainKey(domain, keyType string) []byte {
+	return []byte(badgerDomainIdxPrefix + domain + "/" + keyType)
+}
+
+// badgerSourceKey 来源索引键
+func badgerSourceKey(source string, id int) []byte {
+	return []byte(fmt.Sprintf("%s%s/%010d", badgerSourceIdxPrefix, source, id))
+}
+
+// badgerExpiryBucket 到期时间所在的日期桶（UTC，字典序与时间顺序一致）
+func badgerExpiryBucket(expire int64) string {
+	return time.Unix(expire, 0).UTC().Format("20060102")
+}
+
+// badgerExpiryKey 到期索引键
+func badgerExpiryKey(expire int64, id int) []byte {
+	return []byte(fmt.Sprintf("%s%s/%010d", badgerExpiryIdxPrefix, badgerExpiryBucket(expire), id))
+}
+
+// badgerTagKey 标签索引键
+func badgerTagKey(tag string, id int) []byte {
+	return []byte(fmt.Sprintf("%s%s/%010d", badgerTagIdxPrefix, tag, id))
+}
+
+// badgerPathKey 部署路径索引键（路径含 /，以 \x00 分隔ID）
+func badgerPathKey(path string, id int) []byte {
+	return []byte(fmt.Sprintf("%s%s\x00%010d", badgerPathIdxPrefix, path, id))
+}
+
+// badgerIndexKeys 记录应有的全部索引键（值均为证书ID，不重复）
+func badgerIndexKeys(cert Certificate) []string {
+	keys := []string{
+		string(badgerDomainKey(cert.Domain, cert.KeyType)),
+		string(badgerSourceKey(cert.CertSource, cert.ID)),
+	}
+	add := func(key []byte) {
+		if !containsKey(keys, string(key)) {
+			keys = append(keys, string(key))
+		}
+	}
+	if cert.ExpireTime > 0 {
+		add(badgerExpiryKey(cert.ExpireTime, cert.ID))
+	}
+	for _, tag := range cert.Tags {
+		add(badgerTagKey(tag, cert.ID))
+	}
+	for _, d := range cert.Deployments {
+		for _, p := range []string{d.CertPath, d.KeyPath, d.ChainPath} {
+			if p != "" {
+				add(badgerPathKey(p, cert.ID))
 			}
-			id++
 		}
-		return txn.Set([]byte("meta:next_id"), []byte(strconv.Itoa(id)))
+	}
+	return keys
+}
+
+// badgerNextID 在事务内分配自增ID（与记录写入同一事务，失败时不消耗ID）
+func badgerNextID(txn *badger.Txn) (int, error) {
+	id := 1
+	item, err := txn.Get([]byte(badgerNextIDKey))
+	if err == nil {
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return 0, err
+		}
+		last, err := strconv.Atoi(string(val))
+		if err != nil {
+			return 0, fmt.Errorf("自增ID损坏: %q", val)
+		}
+		id = last + 1
+	} else if !errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, err
+	}
+	return id, txn.Set([]byte(badgerNextIDKey), []byte(strconv.Itoa(id)))
+}
+
+// badgerLegacyPaths 旧版记录中的部署路径字段（部署位置拆分为 Deployments 之前）
+type badgerLegacyPaths struct {
+	CertPath  string
+	KeyPath   string
+	ChainPath string
+}
+
+// decodeBadgerCert 解析证书记录：旧版记录的部署路径转换为一个部署位置（下次更新记录时按新格式保存）
+func decodeBadgerCert(val []byte) (Certificate, error) {
+	var cert Certificate
+	if err := json.Unmarshal(val, &cert); err != nil {
+		return cert, err
+	}
+	if len(cert.Deployments) == 0 {
+		var legacy badgerLegacyPaths
+		if err := json.Unmarshal(val, &legacy); err == nil && (legacy.CertPath != "" || legacy.KeyPath != "") {
+			cert.Deployments = []Deployment{{CertPath: legacy.CertPath, KeyPath: legacy.KeyPath, ChainPath: legacy.ChainPath}}
+		}
+	}
+	return cert, nil
+}
+
+// badgerGetCert 在事务内读取证书记录，不存在时返回 ErrNotFound
+func badgerGetCert(txn *badger.Txn, id int) (Certificate, error) {
+	var cert Certificate
+	item, err := txn.Get(badgerCertKey(id))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return cert, ErrNotFound
+	} else if err != nil {
+		return cert, err
+	}
+	err = item.Value(func(val []byte) (err error) {
+		cert, err = decodeBadgerCert(val)
+		return err
 	})
-	return id, err
+	return cert, err
 }
 
-// 添加证书到Badger
-func addCertificateToBadgerDB(cert Certificate) error {
-	// 生成唯一ID
-	id, err := getNextBadgerID()
-	if err != nil {
+// badgerPutCert 在事务内写入证书记录并维护索引：old 为原记录（新增时为 nil），
+// 原记录中不再适用的索引（域名、密钥类型、来源、到期时间变化）删除，(域名, 密钥类型) 已被其他记录占用时报错
+func badgerPutCert(txn *badger.Txn, old *Certificate, cert Certificate) error {
+	domainKey := badgerDomainKey(cert.Domain, cert.KeyType)
+	if item, err := txn.Get(domainKey); err == nil {
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if string(val) != strconv.Itoa(cert.ID) {
+			return fmt.Errorf("域名 %s 的%w", cert.Domain, ErrExists)
+		}
+	} else if !errors.Is(err, badger.ErrKeyNotFound) {
 		return err
 	}
-	cert.ID = id
 
-	// 序列化证书
 	certData, err := json.Marshal(cert)
 	if err != nil {
 		return err
 	}
-
-	// 保存证书
-	return badgerDB.Update(func(txn *badger.Txn) error {
-		// 检查域名是否已存在（与 SQLite 的 UNIQUE 约束行为保持一致）
-		if _, err := txn.Get([]byte(fmt.Sprintf("domain:%s", cert.Domain))); err == nil {
-			return fmt.Errorf("域名 %s 的证书信息已存在", cert.Domain)
-		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+	newKeys := badgerIndexKeys(cert)
+	if old != nil {
+		for _, key := range badgerIndexKeys(*old) {
+			if containsKey(newKeys, key) {
+				continue
+			}
+			if err := txn.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := txn.Set(badgerCertKey(cert.ID), certData); err != nil {
+		return err
+	}
+	for _, key := range newKeys {
+		if err := txn.Set([]byte(key), []byte(strconv.Itoa(cert.ID))); err != nil {
 			return err
 		}
+	}
+	return nil
+}
+
+// containsKey keys 中是否包含 key
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
 
-		// 保存证书数据
-		err := txn.Set([]byte(fmt.Sprintf("cert:%d", cert.ID)), certData)
+// 添加证书到Badger（ID分配、唯一性检查、记录与索引写入在同一事务中完成）
+func addCertificateToBadgerDB(cert Certificate) error {
+	return badgerUpdate(func(txn *badger.Txn) error {
+		id, err := badgerNextID(txn)
 		if err != nil {
 			return err
 		}
-
-		// 保存域名索引
-		return txn.Set([]byte(fmt.Sprintf("domain:%s", cert.Domain)), []byte(fmt.Sprintf("%d", cert.ID)))
+		cert.ID = id
+		return badgerPutCert(txn, nil, cert)
 	})
 }
 
-// 从Badger删除证书
+// 从Badger删除证书（连同全部索引）
 func deleteCertificateFromBadgerDB(id int) error {
-	// 先获取证书信息以获取域名
-	cert, err := getCertificateFromBadger(id)
-	if err != nil {
-		return err
-	}
-
-	// 删除证书和域名索引
-	return badgerDB.Update(func(txn *badger.Txn) error {
-		// 删除证书数据
-		err := txn.Delete([]byte(fmt.Sprintf("cert:%d", id)))
+	return badgerUpdate(func(txn *badger.Txn) error {
+		cert, err := badgerGetCert(txn, id)
 		if err != nil {
 			return err
 		}
-
-		// 删除域名索引
-		return txn.Delete([]byte(fmt.Sprintf("domain:%s", cert.Domain)))
+		if err := txn.Delete(badgerCertKey(id)); err != nil {
+			return err
+		}
+		for _, key := range badgerIndexKeys(cert) {
+			if err := txn.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 }
 
-// 从Badger获取所有证书
+// 从Badger获取所有证书（按 ID 排序）
 func getAllCertificatesFromBadger() ([]Certificate, error) {
 	var certificates []Certificate
 
 	err := badgerDB.View(func(txn *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchSize = 10
+		opts.Prefix = []byte(badgerCertPrefix)
 		it := txn.NewIterator(opts)
 		defer it.Close()
 
-		prefix := []byte("cert:")
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			item := it.Item()
-
-			err := item.Value(func(val []byte) error {
-				var cert Certificate
-				if err := json.Unmarshal(val, &cert); err != nil {
+		for it.Rewind(); it.Valid(); it.Next() {
+			err := it.Item().Value(func(val []byte) error {
+				cert, err := decodeBadgerCert(val)
+				if err != nil {
 					return err
 				}
 				certificates = append(certificates, cert)
 				return nil
 			})
-
 			if err != nil {
 				return err
 			}
 		}
-
 		return nil
 	})
 
+	sortCertsByID(certificates)
 	return certificates, err
 }
 
+// sortCertsByID 按 ID 排序
+func sortCertsByID(certs []Certificate) {
+	sort.Slice(certs, func(i, j int) bool {
+		return certs[i].ID < certs[j].ID
+	})
+}
+
 // 从Badger获取证书
 func getCertificateFromBadger(id int) (Certificate, error) {
 	var cert Certificate
+	err := badgerDB.View(func(txn *badger.Txn) (err error) {
+		cert, err = badgerGetCert(txn, id)
+		return err
+	})
+	return cert, err
+}
 
+// badgerIndexIDs 扫描索引：从 seek 开始遍历 prefix 下的键，stop 返回 true 时结束，返回索引指向的证书ID
+func badgerIndexIDs(txn *badger.Txn, prefix, seek string, stop func(key string) bool) ([]int, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(prefix)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var ids []int
+	for it.Seek([]byte(seek)); it.Valid(); it.Next() {
+		item := it.Item()
+		if stop != nil && stop(string(item.Key())) {
+			break
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		id, err := strconv.Atoi(string(val))
+		if err != nil {
+			return nil, fmt.Errorf("索引 %s 损坏: %q", item.Key(), val)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// badgerCertsByIndex 按索引读取证书（按 ID 排序）；索引指向的记录不存在时跳过（由 db fsck 修复）
+func badgerCertsByIndex(prefix, seek string, stop func(key string) bool) ([]Certificate, error) {
+	var certs []Certificate
 	err := badgerDB.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(fmt.Sprintf("cert:%d", id)))
+		ids, err := badgerIndexIDs(txn, prefix, seek, stop)
 		if err != nil {
 			return err
 		}
-
-		return item.Value(func(val []byte) error {
-			return json.Unmarshal(val, &cert)
-		})
+		for _, id := range ids {
+			cert, err := badgerGetCert(txn, id)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			certs = append(certs, cert)
+		}
+		return nil
 	})
-	if errors.Is(err, badger.ErrKeyNotFound) {
-		return cert, ErrNotFound
+	sortCertsByID(certs)
+	return certs, err
+}
+
+// 从Badger获取证书（通过域名，多种密钥类型时返回最早添加的一条）
+func getDomainCertificateFromBadger(domain string) (Certificate, error) {
+	certs, err := getDomainCertificatesFromBadger(domain)
+	if err != nil {
+		return Certificate{}, err
 	}
+	if len(certs) == 0 {
+		return Certificate{}, ErrNotFound
+	}
+	return certs[0], nil
+}
 
-	return cert, err
+// 从Badger获取域名下全部密钥类型的证书（按 ID 排序，经域名索引读取）
+func getDomainCertificatesFromBadger(domain string) ([]Certificate, error) {
+	prefix := badgerDomainIdxPrefix + domain + "/"
+	return badgerCertsByIndex(prefix, prefix, nil)
 }
 
-// 从Badger获取证书（通过域名）
-func getDomainCertificateFromBadger(domain string) (Certificate, error) {
-	var cert Certificate
-	var certID int
+// getSourceCertificatesFromBadger 获取指定来源的证书（经来源索引读取）
+func getSourceCertificatesFromBadger(source string) ([]Certificate, error) {
+	prefix := badgerSourceIdxPrefix + source + "/"
+	return badgerCertsByIndex(prefix, prefix, nil)
+}
 
-	// 第一步：通过域名获取证书 ID
-	err := badgerDB.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(fmt.Sprintf("domain:%s", domain)))
-		if err != nil {
-			return err
+// getExpiringCertificatesFromBadger 获取到期时间在 [from, to) 内的证书：按日期桶范围扫描到期索引，再按精确时间过滤
+func getExpiringCertificatesFromBadger(from, to int64) ([]Certificate, error) {
+	last := badgerExpiryIdxPrefix + badgerExpiryBucket(to) + "/~" // '~' 大于 ID 中的数字，包含最后一个日期桶
+	certs, err := badgerCertsByIndex(badgerExpiryIdxPrefix, badgerExpiryIdxPrefix+badgerExpiryBucket(from)+"/",
+		func(key string) bool { return key > last })
+	if err != nil {
+		return nil, err
+	}
+	var out []Certificate
+	for _, c := range certs {
+		if c.ExpireTime >= from && c.ExpireTime < to {
+			out = append(out, c)
 		}
+	}
+	return out, nil
+}
 
-		return item.Value(func(val []byte) error {
-			// 将获取到的 ID 字符串转换为整数
-			_, err := fmt.Sscanf(string(val), "%d", &certID)
-			return err
-		})
-	})
-	if errors.Is(err, badger.ErrKeyNotFound) {
-		return cert, ErrNotFound
+// queryCertificatesFromBadger 按条件查询：选取最有区分度的索引（部署路径 > 标签 > 来源 > 到期时间）读取候选记录，
+// 再按全部条件过滤、排序与分页；无可用索引（仅状态条件或无条件）时读取全部记录
+func queryCertificatesFromBadger(q CertQuery) ([]Certificate, error) {
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+	var certs []Certificate
+	var err error
+	switch {
+	case q.Path != "":
+		prefix := badgerPathIdxPrefix + q.Path + "\x00"
+		certs, err = badgerCertsByIndex(prefix, prefix, nil)
+	case q.Tag != "":
+		prefix := badgerTagIdxPrefix + q.Tag + "/"
+		certs, err = badgerCertsByIndex(prefix, prefix, nil)
+	case q.Source != "":
+		certs, err = getSourceCertificatesFromBadger(q.Source)
+	case q.ExpiringBefore > 0:
+		certs, err = getExpiringCertificatesFromBadger(1, q.ExpiringBefore)
+	default:
+		certs, err = getAllCertificatesFromBadger()
 	}
 	if err != nil {
-		return cert, err
+		return nil, err
 	}
+	var matched []Certificate
+	for _, c := range certs {
+		if q.Match(c) {
+			matched = append(matched, c)
+		}
+	}
+	return q.sortAndPage(matched), nil
+}
 
-	// 第二步：通过证书 ID 获取完整的证书信息
-	err = badgerDB.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(fmt.Sprintf("cert:%d", certID)))
+// 更新Badger中的证书（读取原记录、校验版本、迁移索引、写入记录在同一事务中完成；
+// 域名或密钥类型变化时迁移域名索引，如申请中签发后识别出密钥类型；版本与原记录不一致时返回 ErrConflict）
+func updateCertificateInBadgerDB(cert Certificate) error {
+	return badgerUpdate(func(txn *badger.Txn) error {
+		old, err := badgerGetCert(txn, cert.ID)
 		if err != nil {
 			return err
 		}
-
-		return item.Value(func(val []byte) error {
-			return json.Unmarshal(val, &cert)
-		})
+		if old.Version != cert.Version {
+			return fmt.Errorf("域名 %s 的%w", cert.Domain, ErrConflict)
+		}
+		cert.Version++
+		return badgerPutCert(txn, &old, cert)
 	})
-
-	return cert, err
 }
 
-// 更新Badger中的证书
-func updateCertificateInBadgerDB(cert Certificate) error {
-	// 序列化证书
-	certData, err := json.Marshal(cert)
-	if err != nil {
-		return err
+// checkBadgerDB 一致性检查：记录无法解析或 ID 与键不符、索引缺失/指向不存在或不匹配的记录（孤立索引）、
+// 同一 (域名, 密钥类型) 存在多条记录、旧版域名索引残留、自增ID小于已有记录。
+// repair 时在同一事务中修复：按记录重建索引、删除孤立键、修正自增ID（无法解析的记录与重复域名需手动处理）
+func checkBadgerDB(repair bool) (CheckReport, error) {
+	report := CheckReport{Mode: "BadgerDB"}
+	run := badgerDB.View
+	if repair {
+		run = badgerUpdate
 	}
+	err := run(func(txn *badger.Txn) error {
+		report.Issues = nil
+		var certs []Certificate
+		var deletes []string
+		writes := map[string]string{}   // 待写入的键值
+		existing := map[string]string{} // 已有的索引
+		issue := func(key, format string, args ...any) *CheckIssue {
+			report.Issues = append(report.Issues, CheckIssue{Key: key, Problem: fmt.Sprintf(format, args...)})
+			return &report.Issues[len(report.Issues)-1]
+		}
 
-	// 更新证书
-	return badgerDB.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte(fmt.Sprintf("cert:%d", cert.ID)), certData)
+		// 证书记录
+		maxID, nextID := 0, 0
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			key := string(item.KeyCopy(nil))
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				it.Close()
+				return err
+			}
+			switch {
+			case strings.HasPrefix(key, badgerCertPrefix):
+				id, err := strconv.Atoi(strings.TrimPrefix(key, badgerCertPrefix))
+				if err != nil || id <= 0 {
+					issue(key, "证书记录键无效")
+					continue
+				}
+				cert, err := decodeBadgerCert(val)
+				if err != nil {
+					issue(key, "证书记录无法解析: %v", err)
+					continue
+				}
+				if cert.ID != id {
+					issue(key, "记录中的 ID %d 与键不符", cert.ID).Repaired = repair
+					cert.ID = id
+					if data, err := json.Marshal(cert); err == nil {
+						writes[key] = string(data)
+					}
+				}
+				if id > maxID {
+					maxID = id
+				}
+				certs = append(certs, cert)
+			case strings.HasPrefix(key, badgerIndexPrefix):
+				existing[key] = string(val)
+			case strings.HasPrefix(key, badgerLegacyDomainPrefix):
+				issue(key, "旧版域名索引").Repaired = repair
+				deletes = append(deletes, key)
+			case key == badgerNextIDKey:
+				nextID, _ = strconv.Atoi(string(val))
+			}
+		}
+		it.Close()
+		report.Records = len(certs)
+
+		// 由记录推导应有的索引
+		expected := map[string]string{}
+		for _, cert := range certs {
+			for _, key := range badgerIndexKeys(cert) {
+				if owner, ok := expected[key]; ok && strings.HasPrefix(key, badgerDomainIdxPrefix) {
+					issue(string(badgerCertKey(cert.ID)), "域名 %s 的证书与 ID %s 重复，请删除其中一条", cert.Domain, owner)
+					continue
+				}
+				expected[key] = strconv.Itoa(cert.ID)
+			}
+		}
+		for key, val := range existing {
+			want, ok := expected[key]
+			switch {
+			case !ok:
+				issue(key, "孤立索引（指向 %s，记录不存在或已不匹配）", val).Repaired = repair
+				deletes = append(deletes, key)
+			case want != val:
+				issue(key, "索引指向 %s，应为 %s", val, want).Repaired = repair
+				writes[key] = want
+			}
+		}
+		for key, want := range expected {
+			if _, ok := existing[key]; !ok {
+				issue(key, "缺少索引（记录 %s）", want).Repaired = repair
+				writes[key] = want
+			}
+		}
+		if nextID < maxID {
+			issue(badgerNextIDKey, "自增ID %d 小于已有记录的最大 ID %d", nextID, maxID).Repaired = repair
+			writes[badgerNextIDKey] = strconv.Itoa(maxID)
+		}
+		sort.SliceStable(report.Issues, func(i, j int) bool { return report.Issues[i].Key < report.Issues[j].Key })
+
+		if !repair {
+			return nil
+		}
+		for _, key := range deletes {
+			if err := txn.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		for key, val := range writes {
+			if err := txn.Set([]byte(key), []byte(val)); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
+	return report, err
 }
@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// CheckIssue 一致性检查发现的问题
+type CheckIssue struct {
+	Key      string // 问题所在的键（BadgerDB）或表/记录（SQLite）
+	Problem  string // 问题说明
+	Repaired bool   // 是否已修复
+}
+
+// CheckReport 一致性检查结果
+type CheckReport struct {
+	Mode    string // 数据库模式
+	Records int    // 证书记录数
+	Issues  []CheckIssue
+}
+
+// Unrepaired 未修复的问题数
+func (r CheckReport) Unrepaired() int {
+	n := 0
+	for _, issue := range r.Issues {
+		if !issue.Repaired {
+			n++
+		}
+	}
+	return n
+}
+
+// checkSQLiteDB 一致性检查：PRAGMA integrity_check 与孤立的部署位置、标签（对应的证书记录已不存在）；
+// repair 时删除孤立的部署位置与标签（integrity_check 报告的损坏需从备份恢复）
+func checkSQLiteDB(ctx context.Context, repair bool) (CheckReport, error) {
+	report := CheckReport{Mode: "SQLite"}
+	rows, err := db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return report, err
+	}
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			rows.Close()
+			return report, err
+		}
+		if msg != "ok" {
+			report.Issues = append(report.Issues, CheckIssue{Key: "integrity_check", Problem: msg})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM certificates").Scan(&report.Records); err != nil {
+		return report, err
+	}
+
+	rows, err = db.QueryContext(ctx, "SELECT id, cert_id, cert_path FROM deployments WHERE cert_id NOT IN (SELECT id FROM certificates) ORDER BY id")
+	if err != nil {
+		return report, err
+	}
+	var orphans []int
+	for rows.Next() {
+		var id, certID int
+		var certPath string
+		if err := rows.Scan(&id, &certID, &certPath); err != nil {
+			rows.Close()
+			return report, err
+		}
+		orphans = append(orphans, id)
+		report.Issues = append(report.Issues, CheckIssue{
+			Key:      fmt.Sprintf("deployments.id=%d", id),
+			Problem:  fmt.Sprintf("孤立的部署位置 %s（证书 %d 不存在）", certPath, certID),
+			Repaired: repair,
+		})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+	if repair && len(orphans) > 0 {
+		if _, err := db.ExecContext(ctx, "DELETE FROM deployments WHERE cert_id NOT IN (SELECT id FROM certificates)"); err != nil {
+			return report, err
+		}
+	}
+
+	var orphanTags int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM cert_tags WHERE cert_id NOT IN (SELECT id FROM certificates)").Scan(&orphanTags); err != nil {
+		return report, err
+	}
+	if orphanTags > 0 {
+		report.Issues = append(report.Issues, CheckIssue{Key: "cert_tags", Problem: fmt.Sprintf("%d 个孤立的标签（证书不存在）", orphanTags), Repaired: repair})
+		if repair {
+			if _, err := db.ExecContext(ctx, "DELETE FROM cert_tags WHERE cert_id NOT IN (SELECT id FROM certificates)"); err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, nil
+}
@@ -1,6 +1,9 @@
 package db
 
 import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/fatih/color"
@@ -17,14 +20,25 @@ var (
 // ErrNotFound 记录不存在的统一错误（SQLite 与 Badger 通用）
 var ErrNotFound = errors.New("not found")
 
-// dbInterface 数据库接口定义
+// ErrExists 同一域名与密钥类型已有证书记录（SQLite 唯一约束 / Badger 域名索引冲突），错误信息为「域名 xxx 的证书信息已存在」
+var ErrExists = errors.New("证书信息已存在")
+
+// ErrConflict 证书记录在读取后已被其他进程（如证书更新任务与命令行同时运行）修改，错误信息为「域名 xxx 的证书信息已被修改，请重新读取后再试」
+var ErrConflict = errors.New("证书信息已被修改，请重新读取后再试")
+
+// dbInterface 数据库接口定义（ctx 取消或超时时放弃操作；SQLite 写入在事务中完成，锁冲突时等待并重试）
 type dbInterface interface {
-	AddCertificate(cert Certificate) error
-	DeleteCertificate(id int) error
-	GetAllCertificates() ([]Certificate, error)
-	GetCertificate(id int) (Certificate, error)
-	GetDomainCertificate(domain string) (Certificate, error)
-	UpdateCertificate(cert Certificate) error
+	AddCertificate(ctx context.Context, cert Certificate) error
+	DeleteCertificate(ctx context.Context, id int) error
+	GetAllCertificates(ctx context.Context) ([]Certificate, error)
+	GetCertificate(ctx context.Context, id int) (Certificate, error)
+	GetDomainCertificate(ctx context.Context, domain string) (Certificate, error)
+	GetDomainCertificates(ctx context.Context, domain string) ([]Certificate, error)
+	// QueryCertificates 按条件查询（过滤、排序、分页）
+	QueryCertificates(ctx context.Context, q CertQuery) ([]Certificate, error)
+	UpdateCertificate(ctx context.Context, cert Certificate) error
+	// Check 一致性检查，repair 时修复可自动修复的问题
+	Check(ctx context.Context, repair bool) (CheckReport, error)
 	Close()
 }
 
@@ -37,37 +51,272 @@ type Certificate struct {
 	ExpireTime  int64  // 过期时间
 	PublicKey   string // 公钥
 	PrivateKey  string // 私钥
-	CertPath    string // 证书路径
-	KeyPath     string // 私钥路径
 	CertSource  string // 证书来源：certd
 	CertID      int    // 证书在来源平台的ID（如 certd 证书仓库ID），更新时优先使用
 	CertDomains string // 证书覆盖的域名列表（逗号分隔，来自平台 detail）
+	// 申请中跟进（certd code=20013）：PendingSince 为进入申请中的时间（秒时间戳，0 表示非申请中），
+	// PendingPolls 为已轮询次数（用于退避间隔计算）
+	PendingSince int64
+	PendingPolls int
+	// 过期告警：AlertDays 为已发送过的最小告警阈值（天，0 表示未告警），
+	// AlertExpire 为告警对应的到期时间（到期时间变化即证书已更换，告警状态重置）
+	AlertDays   int
+	AlertExpire int64
+	// 运行状态（metrics 指标）：LastRenew 为最近一次成功续期部署时间，
+	// LastError/LastErrorTime 为最近一次从平台获取失败的原因与时间（成功获取后清空）
+	LastRenew     int64
+	LastError     string
+	LastErrorTime int64
+	// 密钥类型（rsa / ecdsa / ed25519，由证书公钥识别；申请中尚无证书时为空）。
+	// 同一域名可按密钥类型保存多条（Nginx RSA + ECDSA 双证书），各自独立获取与部署
+	KeyType string
+	// 部署位置（一对多）：同一证书可同时部署到多处（Nginx、邮件服务、Docker 卷等），更新时获取一次、逐一写入
+	Deployments []Deployment
+	// 附加部署目标（PFX / JKS / DER 等格式），随主证书文件一同部署
+	Targets DeployTargets
+	// 证书级钩子命令（在全局钩子之后执行）
+	Hooks CertHooks
+	// 从 certbot / acme.sh 导入的证书的原工具信息（非导入证书为空）
+	Import CertImport
+	// 标签（用于分组筛选，如 prod、业务线名称）
+	Tags []string
+	// 证书级重载命令（非空时替代全局 restart_cmd，如该证书部署在其他服务上）
+	ReloadCmd string
+	// 证书级提前更新天数（0 使用全局 before_expiration_day）
+	RenewDays int
+	// 证书平台获取顺序（逗号分隔，如 certd,west；为空时使用 CertSource 对应的平台或全局 provider_order）
+	Providers string
+	// 续期记录（最近 MaxRenewals 次，记录每次续期实际提供证书的平台）
+	Renewals RenewHistory
+	// 证书来源平台的实例名（配置分区 third.<CertSource>.<实例名>；为空表示默认实例）
+	ProviderInstance string
+	// 记录版本（每次更新加 1）：更新时须与数据库中的版本一致，否则返回 ErrConflict，避免覆盖并发的修改
+	Version int
+}
+
+// MaxRenewals 每个证书保留的续期记录数
+const MaxRenewals = 20
+
+// RenewRecord 一次续期：时间、提供证书的平台与新证书的到期时间
+type RenewRecord struct {
+	Time       int64  `json:"time"`
+	Provider   string `json:"provider"`
+	ExpireTime int64  `json:"expire_time"`
+}
+
+// RenewHistory 续期记录（按时间先后，SQLite 以 JSON 文本保存）
+type RenewHistory []RenewRecord
+
+// Add 追加一条续期记录，超出 MaxRenewals 时丢弃最早的记录（返回新切片，不修改原记录）
+func (h RenewHistory) Add(r RenewRecord) RenewHistory {
+	out := append(append(RenewHistory(nil), h...), r)
+	if len(out) > MaxRenewals {
+		out = out[len(out)-MaxRenewals:]
+	}
+	return out
+}
+
+// Scan 实现 sql.Scanner
+func (h *RenewHistory) Scan(src any) error {
+	var text []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		text = []byte(v)
+	case []byte:
+		text = v
+	default:
+		return fmt.Errorf("无法解析续期记录: %T", src)
+	}
+	*h = nil
+	if len(text) == 0 {
+		return nil
+	}
+	return json.Unmarshal(text, h)
+}
+
+// Value 实现 driver.Valuer（无记录时保存空串）
+func (h RenewHistory) Value() (driver.Value, error) {
+	if len(h) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(h)
+	return string(data), err
+}
+
+// Deployment 证书部署位置（证书文件 + 私钥文件）
+type Deployment struct {
+	CertPath string // 证书路径
+	KeyPath  string // 私钥路径（与 CertPath 相同时为证书+私钥合并文件）
+	// 中间证书链路径（Apache SSLCertificateChainFile）：非空时部署将叶子证书写入 CertPath、证书链写入 ChainPath
+	ChainPath string
+	// 文件属主与权限（为空时保留已有文件的属主与权限，新建文件证书 0644、私钥 0600）
+	Owner   string // 属主（user 或 user:group）
+	Mode    string // 证书与证书链文件权限（八进制，如 0644）
+	KeyMode string // 私钥文件权限（八进制，如 0640；合并文件使用此权限）
+}
+
+// DeployTarget 附加部署目标：将证书按指定格式写入 Path（格式由 certfmt 本地生成）；
+// ssh 类型的目标通过 SSH 上传到远程主机，上传后执行远程检查与重载命令
+type DeployTarget struct {
+	Type     string `json:"type,omitempty"`     // 目标类型：空为本地文件，ssh 为远程主机
+	Format   string `json:"format"`             // fullchain / leaf / chain / key / combined / der / pfx / jks
+	Path     string `json:"path"`               // 目标文件路径（ssh 为远程路径）
+	Password string `json:"password,omitempty"` // PFX / JKS 密码
+	Owner    string `json:"owner,omitempty"`    // 属主（user 或 user:group，为空不修改）
+	Mode     string `json:"mode,omitempty"`     // 权限（八进制，如 0640；为空按格式默认）
+
+	// 以下仅 ssh 目标使用
+	KeyPath      string `json:"key_path,omitempty"`      // 远程私钥路径（格式不含私钥时必填，权限 0600）
+	Host         string `json:"host,omitempty"`          // 主机名或 IP
+	Port         int    `json:"port,omitempty"`          // 端口（0 为 ssh 默认）
+	User         string `json:"user,omitempty"`          // 登录用户
+	IdentityFile string `json:"identity_file,omitempty"` // 本机私钥文件（为空使用 ssh-agent 或默认密钥）
+	KnownHosts   string `json:"known_hosts,omitempty"`   // 本机 known_hosts 文件（为空使用 ~/.ssh/known_hosts）
+	Reload       string `json:"reload,omitempty"`        // 上传后在远程执行的检查与重载命令
+}
+
+// DeployTargets 部署目标列表（SQLite 以 JSON 文本保存）
+type DeployTargets []DeployTarget
+
+// Scan 实现 sql.Scanner
+func (t *DeployTargets) Scan(src any) error {
+	var text []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		text = []byte(v)
+	case []byte:
+		text = v
+	default:
+		return fmt.Errorf("无法解析部署目标: %T", src)
+	}
+	*t = nil
+	if len(text) == 0 {
+		return nil
+	}
+	return json.Unmarshal(text, t)
+}
+
+// Value 实现 driver.Valuer（无目标时保存空串）
+func (t DeployTargets) Value() (driver.Value, error) {
+	if len(t) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(t)
+	return string(data), err
+}
+
+// CertHooks 证书级钩子命令：在获取、部署、重载的各阶段执行（为空不执行）
+type CertHooks struct {
+	PreFetch   string `json:"pre_fetch,omitempty"`   // 从平台获取证书前
+	PreDeploy  string `json:"pre_deploy,omitempty"`  // 写入证书文件前（失败则放弃部署该证书）
+	PostDeploy string `json:"post_deploy,omitempty"` // 写入证书文件后
+	PostReload string `json:"post_reload,omitempty"` // 执行重载命令后
+}
+
+// Scan 实现 sql.Scanner
+func (h *CertHooks) Scan(src any) error {
+	var text []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		text = []byte(v)
+	case []byte:
+		text = v
+	default:
+		return fmt.Errorf("无法解析钩子命令: %T", src)
+	}
+	*h = CertHooks{}
+	if len(text) == 0 {
+		return nil
+	}
+	return json.Unmarshal(text, h)
+}
+
+// Value 实现 driver.Valuer（无钩子时保存空串）
+func (h CertHooks) Value() (driver.Value, error) {
+	if h == (CertHooks{}) {
+		return "", nil
+	}
+	data, err := json.Marshal(h)
+	return string(data), err
+}
+
+// CertImport 导入证书的原工具信息：原工具仍在续期时，更新任务读取原工具维护的证书文件而不从平台获取
+type CertImport struct {
+	Tool     string `json:"tool"`                // certbot / acme.sh
+	Name     string `json:"name"`                // certbot 证书名（live/<name>）/ acme.sh 证书目录名
+	ConfPath string `json:"conf_path,omitempty"` // 续期配置（certbot renewal/<name>.conf、acme.sh <域名>.conf）
+	CertPath string `json:"cert_path"`           // 原工具维护的完整证书链文件
+	KeyPath  string `json:"key_path"`            // 原工具维护的私钥文件
+	Renewing bool   `json:"renewing"`            // 原工具仍在自动续期（续期配置与定时任务均存在）
+}
+
+// Scan 实现 sql.Scanner
+func (i *CertImport) Scan(src any) error {
+	var text []byte
+	switch v := src.(type) {
+	case nil:
+	case string:
+		text = []byte(v)
+	case []byte:
+		text = v
+	default:
+		return fmt.Errorf("无法解析导入信息: %T", src)
+	}
+	*i = CertImport{}
+	if len(text) == 0 {
+		return nil
+	}
+	return json.Unmarshal(text, i)
+}
+
+// Value 实现 driver.Valuer（非导入证书保存空串）
+func (i CertImport) Value() (driver.Value, error) {
+	if i == (CertImport{}) {
+		return "", nil
+	}
+	data, err := json.Marshal(i)
+	return string(data), err
 }
 
 // SQLiteDB SQLite实现
 type SQLiteDB struct{}
 
-func (db *SQLiteDB) AddCertificate(cert Certificate) error {
-	return addCertificateToDB(cert)
+func (db *SQLiteDB) AddCertificate(ctx context.Context, cert Certificate) error {
+	return addCertificateToDB(ctx, cert)
 }
 
-func (db *SQLiteDB) DeleteCertificate(id int) error {
-	return deleteCertificateFromDB(id)
+func (db *SQLiteDB) DeleteCertificate(ctx context.Context, id int) error {
+	return deleteCertificateFromDB(ctx, id)
 }
 
-func (db *SQLiteDB) GetAllCertificates() ([]Certificate, error) {
-	return getAllCertificates()
+func (db *SQLiteDB) GetAllCertificates(ctx context.Context) ([]Certificate, error) {
+	return getAllCertificates(ctx)
 }
 
-func (db *SQLiteDB) GetCertificate(id int) (Certificate, error) {
-	return getCertificate(id)
+func (db *SQLiteDB) GetCertificate(ctx context.Context, id int) (Certificate, error) {
+	return getCertificate(ctx, id)
 }
-func (db *SQLiteDB) GetDomainCertificate(domain string) (Certificate, error) {
-	return getDomainCertificate(domain)
+func (db *SQLiteDB) GetDomainCertificate(ctx context.Context, domain string) (Certificate, error) {
+	return getDomainCertificate(ctx, domain)
 }
 
-func (db *SQLiteDB) UpdateCertificate(cert Certificate) error {
-	return updateCertificateInDB(cert)
+func (db *SQLiteDB) GetDomainCertificates(ctx context.Context, domain string) ([]Certificate, error) {
+	return getDomainCertificates(ctx, domain)
+}
+
+func (db *SQLiteDB) QueryCertificates(ctx context.Context, q CertQuery) ([]Certificate, error) {
+	return queryCertificatesByCondition(ctx, q)
+}
+
+func (db *SQLiteDB) UpdateCertificate(ctx context.Context, cert Certificate) error {
+	return updateCertificateInDB(ctx, cert)
+}
+
+func (db *SQLiteDB) Check(ctx context.Context, repair bool) (CheckReport, error) {
+	return checkSQLiteDB(ctx, repair)
 }
 
 func (db *SQLiteDB) Close() {
@@ -75,32 +324,71 @@ func (db *SQLiteDB) Close() {
 	closeDB()
 }
 
-// BadgerImpl BadgerDB实现
+// BadgerImpl BadgerDB实现（Badger 不支持 ctx，仅在操作开始前检查是否已取消）
 type BadgerImpl struct{}
 
-func (db *BadgerImpl) AddCertificate(cert Certificate) error {
+func (db *BadgerImpl) AddCertificate(ctx context.Context, cert Certificate) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return addCertificateToBadgerDB(cert)
 }
 
-func (db *BadgerImpl) DeleteCertificate(id int) error {
+func (db *BadgerImpl) DeleteCertificate(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return deleteCertificateFromBadgerDB(id)
 }
 
-func (db *BadgerImpl) GetAllCertificates() ([]Certificate, error) {
+func (db *BadgerImpl) GetAllCertificates(ctx context.Context) ([]Certificate, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	return getAllCertificatesFromBadger()
 }
 
-func (db *BadgerImpl) GetCertificate(id int) (Certificate, error) {
+func (db *BadgerImpl) GetCertificate(ctx context.Context, id int) (Certificate, error) {
+	if err := ctx.Err(); err != nil {
+		return Certificate{}, err
+	}
 	return getCertificateFromBadger(id)
 }
-func (db *BadgerImpl) GetDomainCertificate(domain string) (Certificate, error) {
+func (db *BadgerImpl) GetDomainCertificate(ctx context.Context, domain string) (Certificate, error) {
+	if err := ctx.Err(); err != nil {
+		return Certificate{}, err
+	}
 	return getDomainCertificateFromBadger(domain)
 }
 
-func (db *BadgerImpl) UpdateCertificate(cert Certificate) error {
+func (db *BadgerImpl) GetDomainCertificates(ctx context.Context, domain string) ([]Certificate, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return getDomainCertificatesFromBadger(domain)
+}
+
+func (db *BadgerImpl) QueryCertificates(ctx context.Context, q CertQuery) ([]Certificate, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return queryCertificatesFromBadger(q)
+}
+
+func (db *BadgerImpl) UpdateCertificate(ctx context.Context, cert Certificate) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	return updateCertificateInBadgerDB(cert)
 }
 
+func (db *BadgerImpl) Check(ctx context.Context, repair bool) (CheckReport, error) {
+	if err := ctx.Err(); err != nil {
+		return CheckReport{}, err
+	}
+	return checkBadgerDB(repair)
+}
+
 func (db *BadgerImpl) Close() {
 	closeBadgerDB()
 }
@@ -163,6 +451,16 @@ func initDatabase() error {
 	return nil
 }
 
+// CloseDatabase 关闭数据库并重置初始化状态，之后的 OpenDatabase 重新打开（如测试切换 HOME 后恢复）
+func CloseDatabase() {
+	if Interface != nil {
+		Interface.Close()
+		Interface = nil
+	}
+	databaseOnce = sync.Once{}
+	databaseInitErr = nil
+}
+
 // OpenDatabase 初始化数据库（返回错误而非直接退出）
 func OpenDatabase() error {
 	return InitDatabase()
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 查询排序字段
+const (
+	SortID     = "id"     // 按 ID（添加顺序，默认）
+	SortDomain = "domain" // 按域名
+	SortExpire = "expire" // 按到期时间
+)
+
+// CertQuery 证书查询条件：各条件同时满足，零值不过滤
+type CertQuery struct {
+	Source string // 证书来源（certd / west / local 等）
+	Status string // 状态
+	// ExpiringBefore 到期时间早于该时间（秒时间戳）；申请中尚无到期时间的证书不返回
+	ExpiringBefore int64
+	Tag            string // 标签
+	// Path 部署位置的证书、私钥或证书链路径等于该路径（按原样比较）
+	Path   string
+	Sort   string // 排序字段：SortID / SortDomain / SortExpire（为空按 ID）
+	Desc   bool   // 倒序
+	Limit  int    // 返回条数（0 不限）
+	Offset int    // 跳过条数
+}
+
+// validate 检查排序字段与分页参数
+func (q CertQuery) validate() error {
+	switch q.Sort {
+	case "", SortID, SortDomain, SortExpire:
+	default:
+		return fmt.Errorf("不支持的排序字段: %s（可选 %s / %s / %s）", q.Sort, SortID, SortDomain, SortExpire)
+	}
+	if q.Limit < 0 || q.Offset < 0 {
+		return fmt.Errorf("limit / offset 不能为负数")
+	}
+	return nil
+}
+
+// Match 证书是否满足查询条件（不含排序与分页）
+func (q CertQuery) Match(cert Certificate) bool {
+	if q.Source != "" && cert.CertSource != q.Source {
+		return false
+	}
+	if q.Status != "" && cert.Status != q.Status {
+		return false
+	}
+	if q.ExpiringBefore > 0 && (cert.ExpireTime <= 0 || cert.ExpireTime >= q.ExpiringBefore) {
+		return false
+	}
+	if q.Tag != "" && !cert.HasTag(q.Tag) {
+		return false
+	}
+	if q.Path != "" && !cert.UsesPath(q.Path) {
+		return false
+	}
+	return true
+}
+
+// HasTag 证书是否带有该标签
+func (cert Certificate) HasTag(tag string) bool {
+	for _, t := range cert.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// UsesPath 证书的部署位置是否使用该路径（证书、私钥或证书链）
+func (cert Certificate) UsesPath(path string) bool {
+	for _, d := range cert.Deployments {
+		if d.CertPath == path || d.KeyPath == path || d.ChainPath == path {
+			return true
+		}
+	}
+	return false
+}
+
+// sortAndPage 按查询的排序字段排序（相同时按 ID），再按 offset / limit 截取（BadgerDB 在内存中完成，SQLite 由 SQL 完成）
+func (q CertQuery) sortAndPage(certs []Certificate) []Certificate {
+	less := func(a, b Certificate) bool {
+		switch q.Sort {
+		case SortDomain:
+			if a.Domain != b.Domain {
+				return a.Domain < b.Domain
+			}
+		case SortExpire:
+			if a.ExpireTime != b.ExpireTime {
+				return a.ExpireTime < b.ExpireTime
+			}
+		}
+		return a.ID < b.ID
+	}
+	sort.SliceStable(certs, func(i, j int) bool {
+		if q.Desc {
+			return less(certs[j], certs[i])
+		}
+		return less(certs[i], certs[j])
+	})
+	if q.Offset >= len(certs) {
+		return nil
+	}
+	certs = certs[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(certs) {
+		certs = certs[:q.Limit]
+	}
+	return certs
+}
+
+// sqlClauses 查询条件对应的 SQLite WHERE / ORDER BY / LIMIT 子句与参数（各条件均可走索引）
+func (q CertQuery) sqlClauses() (string, []any) {
+	var where []string
+	var args []any
+	if q.Source != "" {
+		where = append(where, "cert_source = ?")
+		args = append(args, q.Source)
+	}
+	if q.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, q.Status)
+	}
+	if q.ExpiringBefore > 0 {
+		where = append(where, "expire_time > 0 AND expire_time < ?")
+		args = append(args, q.ExpiringBefore)
+	}
+	if q.Tag != "" {
+		where = append(where, "id IN (SELECT cert_id FROM cert_tags WHERE tag = ?)")
+		args = append(args, q.Tag)
+	}
+	if q.Path != "" {
+		where = append(where, "id IN (SELECT cert_id FROM deployments WHERE cert_path = ? UNION SELECT cert_id FROM deployments WHERE key_path = ? UNION SELECT cert_id FROM deployments WHERE chain_path = ?)")
+		args = append(args, q.Path, q.Path, q.Path)
+	}
+
+	var b strings.Builder
+	if len(where) > 0 {
+		b.WriteString(" WHERE " + strings.Join(where, " AND "))
+	}
+	order := map[string]string{"": "id", SortID: "id", SortDomain: "domain", SortExpire: "expire_time"}[q.Sort]
+	dir := ""
+	if q.Desc {
+		dir = " DESC"
+	}
+	b.WriteString(" ORDER BY " + order + dir)
+	if order != "id" {
+		b.WriteString(", id" + dir)
+	}
+	if q.Limit > 0 || q.Offset > 0 {
+		limit := q.Limit
+		if limit == 0 {
+			limit = -1 // SQLite：LIMIT -1 表示不限
+		}
+		b.WriteString(" LIMIT ? OFFSET ?")
+		args = append(args, limit, q.Offset)
+	}
+	return b.String(), args
+}
@@ -0,0 +1,9 @@
+//go:build !cgo
+
+package db
+
+// CGO_ENABLED=0 时 SQLite 不可用（使用 BadgerDB），不会产生 SQLite 错误
+
+func isSQLiteBusy(err error) bool { return false }
+
+func isSQLiteUnique(err error) bool { return false }
@@ -1,9 +1,16 @@
 package db
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/dgraph-io/badger/v3"
 )
 
 // TestMain 隔离用户主目录，避免测试污染真实 ~/.ssl_assistant 数据；
@@ -36,8 +43,7 @@ func TestCertificateCRUD(t *testing.T) {
 			ExpireTime:  200,
 			PublicKey:   "pub-" + domain,
 			PrivateKey:  "key-" + domain,
-			CertPath:    "/tmp/" + domain + ".pem",
-			KeyPath:     "/tmp/" + domain + ".key",
+			Deployments: []Deployment{{CertPath: "/tmp/" + domain + ".pem", KeyPath: "/tmp/" + domain + ".key"}},
 			CertSource:  "certd",
 			CertID:      0,
 			CertDomains: domain + ",www." + domain,
@@ -117,3 +123,831 @@ func TestCertificateCRUD(t *testing.T) {
 		t.Fatalf("重复删除应返回 ErrNotFound，实际: %v", err)
 	}
 }
+
+// 申请中字段（PendingSince/PendingPolls）读写一致
+func TestPendingFieldsRoundTrip(t *testing.T) {
+	if err := InitDatabase(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	cert := Certificate{Domain: "pending-roundtrip.com", Status: "申请中", CertSource: "certd", PendingSince: 1700000000, PendingPolls: 2}
+	if err := AddCertificateToDBWrapper(cert); err != nil {
+		t.Fatalf("添加申请中证书失败: %v", err)
+	}
+	got, err := GetCertificateWrapper(cert.Domain)
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if got.PendingSince != 1700000000 || got.PendingPolls != 2 {
+		t.Fatalf("申请中字段读写不一致: %+v", got)
+	}
+	got.PendingSince, got.PendingPolls = 0, 0
+	if err := UpdateCertificateInDBWrapper(got); err != nil {
+		t.Fatalf("更新失败: %v", err)
+	}
+	got2, _ := GetCertificateByIDWrapper(got.ID)
+	if got2.PendingSince != 0 || got2.PendingPolls != 0 {
+		t.Fatalf("清除申请中字段未生效: %+v", got2)
+	}
+	_ = DeleteCertificateFromDBWrapper(got.ID)
+}
+
+// 告警字段（AlertDays/AlertExpire）读写一致
+func TestAlertFieldsRoundTrip(t *testing.T) {
+	if err := InitDatabase(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	cert := Certificate{Domain: "alert-roundtrip.com", Status: "有效", CertSource: "local", ExpireTime: 1800000000}
+	if err := AddCertificateToDBWrapper(cert); err != nil {
+		t.Fatalf("添加证书失败: %v", err)
+	}
+	got, err := GetCertificateWrapper(cert.Domain)
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	got.AlertDays, got.AlertExpire = 7, 1800000000
+	if err := UpdateCertificateInDBWrapper(got); err != nil {
+		t.Fatalf("更新失败: %v", err)
+	}
+	got2, _ := GetCertificateByIDWrapper(got.ID)
+	if got2.AlertDays != 7 || got2.AlertExpire != 1800000000 {
+		t.Fatalf("告警字段读写不一致: %+v", got2)
+	}
+	_ = DeleteCertificateFromDBWrapper(got.ID)
+}
+
+// 运行状态字段（LastRenew/LastError/LastErrorTime）读写一致
+func TestRunStateFieldsRoundTrip(t *testing.T) {
+	if err := InitDatabase(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	cert := Certificate{Domain: "runstate-roundtrip.com", Status: "有效", CertSource: "certd", LastRenew: 1700000000, LastError: "接口超时", LastErrorTime: 1700000100}
+	if err := AddCertificateToDBWrapper(cert); err != nil {
+		t.Fatalf("添加证书失败: %v", err)
+	}
+	got, err := GetCertificateWrapper(cert.Domain)
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if got.LastRenew != 1700000000 || got.LastError != "接口超时" || got.LastErrorTime != 1700000100 {
+		t.Fatalf("运行状态字段读写不一致: %+v", got)
+	}
+	_ = DeleteCertificateFromDBWrapper(got.ID)
+}
+
+// 部署位置一对多：读写、按顺序返回、更新时整体替换与清空（含 Apache 证书链路径）
+func TestDeploymentsRoundTrip(t *testing.T) {
+	if err := InitDatabase(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	deps := []Deployment{
+		{CertPath: "/etc/nginx/ssl/a.pem", KeyPath: "/etc/nginx/ssl/a.key"},
+		{CertPath: "/etc/apache2/ssl/a.crt", KeyPath: "/etc/apache2/ssl/a.key", ChainPath: "/etc/apache2/ssl/chain.pem"},
+		{CertPath: "/etc/haproxy/certs/a.pem", KeyPath: "/etc/haproxy/certs/a.pem", Owner: "haproxy:haproxy", KeyMode: "0640"},
+		{CertPath: "/var/lib/pgsql/server.crt", KeyPath: "/var/lib/pgsql/server.key", Owner: "postgres", Mode: "0644", KeyMode: "0600"},
+	}
+	cert := Certificate{Domain: "deploy-roundtrip.com", Status: "有效", CertSource: "local", Deployments: deps}
+	if err := AddCertificateToDBWrapper(cert); err != nil {
+		t.Fatalf("添加证书失败: %v", err)
+	}
+	got, err := GetCertificateWrapper(cert.Domain)
+	if err != nil || !reflect.DeepEqual(got.Deployments, deps) {
+		t.Fatalf("部署位置读写不一致: %+v %v", got.Deployments, err)
+	}
+	all, _ := GetAllCertificatesWrapper()
+	for _, c := range all {
+		if c.ID == got.ID && !reflect.DeepEqual(c.Deployments, deps) {
+			t.Fatalf("列表中的部署位置不一致: %+v", c.Deployments)
+		}
+	}
+
+	got.Deployments = []Deployment{deps[1], {CertPath: "/srv/a.pem", KeyPath: "/srv/a.pem"}}
+	if err := UpdateCertificateInDBWrapper(got); err != nil {
+		t.Fatalf("更新失败: %v", err)
+	}
+	byID, _ := GetCertificateByIDWrapper(got.ID)
+	if !reflect.DeepEqual(byID.Deployments, got.Deployments) {
+		t.Fatalf("部署位置应整体替换: %+v", byID.Deployments)
+	}
+	// 基于最新读取的记录再次修改（沿用旧记录更新会因版本不一致返回 ErrConflict）
+	got = byID
+	got.Deployments = nil
+	if err := UpdateCertificateInDBWrapper(got); err != nil {
+		t.Fatalf("更新失败: %v", err)
+	}
+	if got, _ = GetCertificateWrapper(cert.Domain); len(got.Deployments) != 0 {
+		t.Fatalf("部署位置应被清空: %+v", got.Deployments)
+	}
+	_ = DeleteCertificateFromDBWrapper(got.ID)
+}
+
+// 附加部署目标以 JSON 保存，读写与清空
+func TestTargetsRoundTrip(t *testing.T) {
+	if err := InitDatabase(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	targets := DeployTargets{
+		{Format: "pfx", Path: "/tmp/a.pfx", Password: "secret", Owner: "iis", Mode: "0640"},
+		{Format: "der", Path: "/tmp/a.der"},
+		{Type: "ssh", Format: "fullchain", Path: "/etc/nginx/ssl/a.pem", KeyPath: "/etc/nginx/ssl/a.key", Host: "web1", Port: 2222, User: "deploy", IdentityFile: "/root/.ssh/id_ed25519", KnownHosts: "/root/.ssh/known_hosts", Reload: "nginx -t && nginx -s reload"},
+	}
+	cert := Certificate{Domain: "targets-roundtrip.com", Status: "有效", CertSource: "local", Targets: targets}
+	if err := AddCertificateToDBWrapper(cert); err != nil {
+		t.Fatalf("添加证书失败: %v", err)
+	}
+	got, err := GetCertificateWrapper(cert.Domain)
+	if err != nil || !reflect.DeepEqual(got.Targets, targets) {
+		t.Fatalf("部署目标读写不一致: %+v %v", got.Targets, err)
+	}
+	got.Targets = nil
+	if err := UpdateCertificateInDBWrapper(got); err != nil {
+		t.Fatalf("更新失败: %v", err)
+	}
+	if got, _ = GetCertificateWrapper(cert.Domain); len(got.Targets) != 0 {
+		t.Fatalf("部署目标应被清空: %+v", got.Targets)
+	}
+	_ = DeleteCertificateFromDBWrapper(got.ID)
+}
+
+// 证书级钩子命令以 JSON 保存，读写与清空
+func TestHooksRoundTrip(t *testing.T) {
+	if err := InitDatabase(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	hooks := CertHooks{PreDeploy: "test -w /etc/nginx/ssl", PostReload: "curl -fsS https://a.com/ >/dev/null"}
+	cert := Certificate{Domain: "hooks-roundtrip.com", Status: "有效", CertSource: "local", Hooks: hooks}
+	if err := AddCertificateToDBWrapper(cert); err != nil {
+		t.Fatalf("添加证书失败: %v", err)
+	}
+	got, err := GetCertificateWrapper(cert.Domain)
+	if err != nil || got.Hooks != hooks {
+		t.Fatalf("钩子命令读写不一致: %+v %v", got.Hooks, err)
+	}
+	got.Hooks = CertHooks{}
+	if err := UpdateCertificateInDBWrapper(got); err != nil {
+		t.Fatalf("更新失败: %v", err)
+	}
+	if got, _ = GetCertificateWrapper(cert.Domain); got.Hooks != (CertHooks{}) {
+		t.Fatalf("钩子命令应被清空: %+v", got.Hooks)
+	}
+	_ = DeleteCertificateFromDBWrapper(got.ID)
+}
+
+// 导入信息（certbot / acme.sh）读写与清空
+func TestImportRoundTrip(t *testing.T) {
+	if err := InitDatabase(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	imp := CertImport{Tool: "certbot", Name: "imp.com", ConfPath: "/etc/letsencrypt/renewal/imp.com.conf", CertPath: "/etc/letsencrypt/live/imp.com/fullchain.pem", KeyPath: "/etc/letsencrypt/live/imp.com/privkey.pem", Renewing: true}
+	cert := Certificate{Domain: "import-roundtrip.com", Status: "有效", CertSource: "certbot", Import: imp}
+	if err := AddCertificateToDBWrapper(cert); err != nil {
+		t.Fatalf("添加证书失败: %v", err)
+	}
+	got, err := GetCertificateWrapper(cert.Domain)
+	if err != nil || got.Import != imp {
+		t.Fatalf("导入信息读写不一致: %+v %v", got.Import, err)
+	}
+	got.Import.Renewing = false
+	if err := UpdateCertificateInDBWrapper(got); err != nil {
+		t.Fatalf("更新失败: %v", err)
+	}
+	if got, _ = GetCertificateWrapper(cert.Domain); got.Import.Renewing || got.Import.Tool != "certbot" {
+		t.Fatalf("续期状态应已更新: %+v", got.Import)
+	}
+	_ = DeleteCertificateFromDBWrapper(got.ID)
+}
+
+// 同一域名按密钥类型保存多条（RSA + ECDSA 双证书），(域名, 密钥类型) 唯一
+func TestKeyTypeVariants(t *testing.T) {
+	if err := InitDatabase(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	const domain = "dual-variant.com"
+	for _, kt := range []string{"rsa", "ecdsa"} {
+		if err := AddCertificateToDBWrapper(Certificate{Domain: domain, Status: "有效", CertSource: "local", KeyType: kt, Deployments: []Deployment{{CertPath: "/tmp/" + kt + ".pem"}}}); err != nil {
+			t.Fatalf("添加 %s 证书失败: %v", kt, err)
+		}
+	}
+	if err := AddCertificateToDBWrapper(Certificate{Domain: domain, Status: "有效", CertSource: "local", KeyType: "rsa"}); err == nil {
+		t.Fatal("相同域名与密钥类型重复添加应报错")
+	}
+
+	certs, err := GetDomainCertificatesWrapper(domain)
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if len(certs) != 2 || certs[0].KeyType != "rsa" || certs[1].KeyType != "ecdsa" || len(certs[1].Deployments) != 1 || certs[1].Deployments[0].CertPath != "/tmp/ecdsa.pem" {
+		t.Fatalf("双证书记录读写不一致: %+v", certs)
+	}
+	// 按域名查询返回最早添加的一条
+	if first, err := GetCertificateWrapper(domain); err != nil || first.ID != certs[0].ID {
+		t.Fatalf("按域名查询应返回最早添加的记录: err=%v got=%+v", err, first)
+	}
+
+	// 申请中（无密钥类型）签发后识别出类型：更新后按新类型唯一
+	pending := Certificate{Domain: "dual-pending.com", Status: "申请中", CertSource: "certd"}
+	if err := AddCertificateToDBWrapper(pending); err != nil {
+		t.Fatalf("添加申请中证书失败: %v", err)
+	}
+	got, _ := GetCertificateWrapper(pending.Domain)
+	got.KeyType = "ecdsa"
+	if err := UpdateCertificateInDBWrapper(got); err != nil {
+		t.Fatalf("更新密钥类型失败: %v", err)
+	}
+	if err := AddCertificateToDBWrapper(Certificate{Domain: pending.Domain, Status: "有效", CertSource: "local", KeyType: "ecdsa"}); err == nil {
+		t.Fatal("更新密钥类型后，相同类型重复添加应报错")
+	}
+	if err := AddCertificateToDBWrapper(pending); err != nil {
+		t.Fatalf("原空类型应可再次添加: %v", err)
+	}
+
+	for _, d := range []string{domain, pending.Domain} {
+		all, _ := GetDomainCertificatesWrapper(d)
+		for _, c := range all {
+			_ = DeleteCertificateFromDBWrapper(c.ID)
+		}
+	}
+	if certs, _ := GetDomainCertificatesWrapper(domain); len(certs) != 0 {
+		t.Fatalf("删除后应无记录: %+v", certs)
+	}
+}
+
+// useTestBadger 在临时目录打开独立的 BadgerDB 替换全局实例（与 SQLite/Badger 模式无关，直接测试 Badger 实现）
+func useTestBadger(t *testing.T) {
+	t.Helper()
+	bdb, err := openBadgerDB(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := badgerDB
+	badgerDB = bdb
+	t.Cleanup(func() {
+		bdb.Close()
+		badgerDB = old
+	})
+}
+
+// badgerKeys 指定前缀下的全部键
+func badgerKeys(t *testing.T, prefix string) []string {
+	t.Helper()
+	var keys []string
+	err := badgerDB.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte(prefix)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			keys = append(keys, string(it.Item().KeyCopy(nil)))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// badgerSet 直接写入键值（构造不一致的数据）
+func badgerSet(t *testing.T, key, val string) {
+	t.Helper()
+	if err := badgerDB.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(key), []byte(val))
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 索引随新增、修改（域名/来源/到期时间变化）、删除同步维护，按索引查询
+func TestBadgerIndexes(t *testing.T) {
+	useTestBadger(t)
+	const day = int64(86400)
+	base := int64(1760000000)
+	for i, domain := range []string{"a.com", "b.com", "c.com"} {
+		c := Certificate{Domain: domain, CertSource: "certd", ExpireTime: base + int64(i)*10*day}
+		if domain == "c.com" {
+			c.CertSource = "certbot"
+		}
+		if err := addCertificateToBadgerDB(c); err != nil {
+			t.Fatalf("添加 %s 失败: %v", domain, err)
+		}
+	}
+	if err := addCertificateToBadgerDB(Certificate{Domain: "a.com", CertSource: "local"}); err == nil {
+		t.Fatal("相同域名与密钥类型重复添加应报错")
+	}
+
+	certd, err := getSourceCertificatesFromBadger("certd")
+	if err != nil || len(certd) != 2 || certd[0].Domain != "a.com" || certd[1].Domain != "b.com" {
+		t.Fatalf("按来源查询结果不符: err=%v %+v", err, certd)
+	}
+	expiring, err := getExpiringCertificatesFromBadger(base, base+10*day+1)
+	if err != nil || len(expiring) != 2 || expiring[1].Domain != "b.com" {
+		t.Fatalf("按到期时间查询结果不符: err=%v %+v", err, expiring)
+	}
+	if expiring, _ := getExpiringCertificatesFromBadger(base+1, base+10*day); len(expiring) != 0 {
+		t.Fatalf("范围外的证书不应返回（同一日期桶内按精确时间过滤）: %+v", expiring)
+	}
+
+	// 修改域名、来源与到期时间：旧索引删除，新索引生效
+	a, err := getDomainCertificateFromBadger("a.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Domain, a.CertSource, a.ExpireTime = "a2.com", "local", base+100*day
+	if err := updateCertificateInBadgerDB(a); err != nil {
+		t.Fatalf("更新失败: %v", err)
+	}
+	if _, err := getDomainCertificateFromBadger("a.com"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("旧域名应查询不到，实际: %v", err)
+	}
+	if got, err := getDomainCertificateFromBadger("a2.com"); err != nil || got.ID != a.ID {
+		t.Fatalf("新域名应可查询: err=%v %+v", err, got)
+	}
+	if certd, _ := getSourceCertificatesFromBadger("certd"); len(certd) != 1 {
+		t.Fatalf("旧来源索引应已删除: %+v", certd)
+	}
+	if expiring, _ := getExpiringCertificatesFromBadger(base, base+day); len(expiring) != 0 {
+		t.Fatalf("旧到期索引应已删除: %+v", expiring)
+	}
+	if err := addCertificateToBadgerDB(Certificate{Domain: "a.com", CertSource: "local"}); err != nil {
+		t.Fatalf("原域名改名后应可再次添加: %v", err)
+	}
+	// 改为已被占用的域名报错，原记录不变
+	b, _ := getDomainCertificateFromBadger("b.com")
+	b.Domain = "c.com"
+	if err := updateCertificateInBadgerDB(b); err == nil {
+		t.Fatal("改为已存在的域名应报错")
+	}
+	if got, _ := getCertificateFromBadger(b.ID); got.Domain != "b.com" {
+		t.Fatalf("失败的更新不应写入: %+v", got)
+	}
+	if err := updateCertificateInBadgerDB(Certificate{ID: 999, Domain: "x.com"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("更新不存在的记录应返回 ErrNotFound，实际: %v", err)
+	}
+
+	all, _ := getAllCertificatesFromBadger()
+	for _, c := range all {
+		if err := deleteCertificateFromBadgerDB(c.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if keys := badgerKeys(t, badgerIndexPrefix); len(keys) != 0 {
+		t.Fatalf("删除全部记录后不应残留索引: %v", keys)
+	}
+	if report, err := checkBadgerDB(false); err != nil || len(report.Issues) != 0 {
+		t.Fatalf("一致的数据不应报告问题: err=%v %+v", err, report)
+	}
+}
+
+// 一致性检查：孤立索引、缺失索引、旧版索引、自增ID落后；repair 后再次检查无问题
+func TestBadgerCheck(t *testing.T) {
+	useTestBadger(t)
+	for _, domain := range []string{"a.com", "b.com"} {
+		if err := addCertificateToBadgerDB(Certificate{Domain: domain, CertSource: "certd", ExpireTime: 1760000000}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, _ := getDomainCertificateFromBadger("b.com")
+	if err := badgerDB.Update(func(txn *badger.Txn) error {
+		return txn.Delete(badgerSourceKey("certd", b.ID))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	badgerSet(t, string(badgerDomainKey("gone.com", "")), "42")
+	badgerSet(t, "domain:a.com", "1")
+	badgerSet(t, badgerNextIDKey, "1")
+
+	report, err := checkBadgerDB(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Records != 2 || len(report.Issues) != 4 || report.Unrepaired() != 4 {
+		t.Fatalf("检查结果不符: %+v", report)
+	}
+	var problems []string
+	for _, issue := range report.Issues {
+		problems = append(problems, issue.Key+" "+issue.Problem)
+	}
+	for _, want := range []string{"domain:a.com 旧版", "idx:domain:gone.com/ 孤立", "idx:source:certd/0000000002 缺少", "meta:next_id 自增ID 1"} {
+		if !strings.Contains(strings.Join(problems, "\n"), want) {
+			t.Fatalf("缺少问题 %q: %v", want, problems)
+		}
+	}
+
+	report, err = checkBadgerDB(true)
+	if err != nil || report.Unrepaired() != 0 {
+		t.Fatalf("修复失败: err=%v %+v", err, report)
+	}
+	if report, _ := checkBadgerDB(false); len(report.Issues) != 0 {
+		t.Fatalf("修复后仍有问题: %+v", report)
+	}
+	if err := addCertificateToBadgerDB(Certificate{Domain: "c.com", CertSource: "certd"}); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := getDomainCertificateFromBadger("c.com"); c.ID != 3 {
+		t.Fatalf("修复自增ID后新记录 ID 应为 3，实际 %d", c.ID)
+	}
+}
+
+// 旧版布局（domain: 索引）打开时重建索引并记录布局版本
+func TestBadgerLayoutMigration(t *testing.T) {
+	useTestBadger(t)
+	badgerSet(t, "cert:1", `{"ID":1,"Domain":"old.com","CertSource":"certd","CertPath":"/tmp/old.pem","KeyPath":"/tmp/old.key"}`)
+	badgerSet(t, "domain:old.com", "1")
+	badgerSet(t, badgerNextIDKey, "1")
+	if err := migrateBadgerLayout(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := getDomainCertificateFromBadger("old.com")
+	if err != nil || len(got.Deployments) != 1 || got.Deployments[0].CertPath != "/tmp/old.pem" {
+		t.Fatalf("迁移后应可按域名索引查询: err=%v %+v", err, got)
+	}
+	if keys := badgerKeys(t, badgerLegacyDomainPrefix); len(keys) != 0 {
+		t.Fatalf("旧版索引应已删除: %v", keys)
+	}
+	if keys := badgerKeys(t, badgerLayoutKey); len(keys) != 1 {
+		t.Fatal("应记录布局版本")
+	}
+}
+
+// CheckDatabaseWrapper 在当前模式下检查，正常数据无问题
+func TestCheckDatabase(t *testing.T) {
+	if err := InitDatabase(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	report, err := CheckDatabaseWrapper(false)
+	if err != nil {
+		t.Fatalf("检查失败: %v", err)
+	}
+	if report.Mode != DBMode() || len(report.Issues) != 0 {
+		t.Fatalf("检查结果不符: %+v", report)
+	}
+}
+
+// queryFixtures 查询用例的证书：来源、状态、到期时间、标签、部署路径各不相同
+func queryFixtures() []Certificate {
+	return []Certificate{
+		{Domain: "q-b.com", Status: "有效", CertSource: "certd", ExpireTime: 3000, Tags: []string{"prod"}, Deployments: []Deployment{{CertPath: "/q/b.pem", KeyPath: "/q/b.key"}}},
+		{Domain: "q-a.com", Status: "有效", CertSource: "certd", ExpireTime: 1000, Tags: []string{"prod", "web"}, Deployments: []Deployment{{CertPath: "/q/shared.pem", KeyPath: "/q/a.key", ChainPath: "/q/chain.pem"}}},
+		{Domain: "q-c.com", Status: "申请中", CertSource: "west", Tags: []string{"staging"}},
+		{Domain: "q-d.com", Status: "有效", CertSource: "west", ExpireTime: 2000, Deployments: []Deployment{{CertPath: "/q/shared.pem", KeyPath: "/q/d.key"}}},
+	}
+}
+
+// runQueryCases 按条件查询的公共用例（SQLite 与 BadgerDB 结果一致）
+func runQueryCases(t *testing.T, add func(Certificate) error, query func(CertQuery) ([]Certificate, error)) {
+	t.Helper()
+	for _, c := range queryFixtures() {
+		if err := add(c); err != nil {
+			t.Fatalf("添加 %s 失败: %v", c.Domain, err)
+		}
+	}
+	domains := func(q CertQuery) string {
+		t.Helper()
+		certs, err := query(q)
+		if err != nil {
+			t.Fatalf("查询 %+v 失败: %v", q, err)
+		}
+		var out []string
+		for _, c := range certs {
+			if strings.HasPrefix(c.Domain, "q-") {
+				out = append(out, c.Domain)
+			}
+		}
+		return strings.Join(out, ",")
+	}
+	cases := []struct {
+		q    CertQuery
+		want string
+	}{
+		{CertQuery{Source: "certd"}, "q-b.com,q-a.com"},
+		{CertQuery{Source: "west", Status: "有效"}, "q-d.com"},
+		{CertQuery{ExpiringBefore: 2500}, "q-a.com,q-d.com"},
+		{CertQuery{ExpiringBefore: 2500, Sort: SortExpire, Desc: true}, "q-d.com,q-a.com"},
+		{CertQuery{Tag: "prod", Sort: SortDomain}, "q-a.com,q-b.com"},
+		{CertQuery{Tag: "staging"}, "q-c.com"},
+		{CertQuery{Path: "/q/shared.pem"}, "q-a.com,q-d.com"},
+		{CertQuery{Path: "/q/chain.pem"}, "q-a.com"},
+		{CertQuery{Path: "/q/d.key", Source: "certd"}, ""},
+		{CertQuery{Source: "certd", Sort: SortExpire}, "q-a.com,q-b.com"},
+		{CertQuery{Tag: "prod", Sort: SortDomain, Limit: 1}, "q-a.com"},
+		{CertQuery{Tag: "prod", Sort: SortDomain, Limit: 1, Offset: 1}, "q-b.com"},
+		{CertQuery{Tag: "prod", Offset: 5}, ""},
+	}
+	for _, tc := range cases {
+		if got := domains(tc.q); got != tc.want {
+			t.Errorf("查询 %+v: got %q, want %q", tc.q, got, tc.want)
+		}
+	}
+	if _, err := query(CertQuery{Sort: "name"}); err == nil {
+		t.Error("不支持的排序字段应报错")
+	}
+
+	// 标签读写与修改
+	certs, _ := query(CertQuery{Tag: "web"})
+	if len(certs) != 1 || !reflect.DeepEqual(certs[0].Tags, []string{"prod", "web"}) {
+		t.Fatalf("标签读写不一致: %+v", certs)
+	}
+}
+
+// 按条件查询（当前模式：CGO=1 为 SQLite，CGO=0 为 BadgerDB）
+func TestQueryCertificates(t *testing.T) {
+	if err := InitDatabase(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	runQueryCases(t, AddCertificateToDBWrapper, QueryCertificatesWrapper)
+
+	// 修改标签与部署路径后按新条件查询
+	a, _ := GetCertificateWrapper("q-a.com")
+	a.Tags = []string{"web"}
+	a.Deployments = []Deployment{{CertPath: "/q/a.pem", KeyPath: "/q/a.key"}}
+	if err := UpdateCertificateInDBWrapper(a); err != nil {
+		t.Fatal(err)
+	}
+	if certs, _ := QueryCertificatesWrapper(CertQuery{Tag: "prod"}); len(certs) != 1 || certs[0].Domain != "q-b.com" {
+		t.Fatalf("修改标签后查询结果不符: %+v", certs)
+	}
+	if certs, _ := QueryCertificatesWrapper(CertQuery{Path: "/q/shared.pem"}); len(certs) != 1 || certs[0].Domain != "q-d.com" {
+		t.Fatalf("修改部署路径后查询结果不符: %+v", certs)
+	}
+	for _, c := range queryFixtures() {
+		got, _ := GetCertificateWrapper(c.Domain)
+		_ = DeleteCertificateFromDBWrapper(got.ID)
+	}
+	if certs, _ := QueryCertificatesWrapper(CertQuery{Tag: "web"}); len(certs) != 0 {
+		t.Fatalf("删除后不应查询到标签: %+v", certs)
+	}
+}
+
+// BadgerDB 按索引查询（与模式无关，直接测试 Badger 实现）
+func TestQueryCertificatesBadger(t *testing.T) {
+	useTestBadger(t)
+	runQueryCases(t, addCertificateToBadgerDB, queryCertificatesFromBadger)
+	if report, err := checkBadgerDB(false); err != nil || len(report.Issues) != 0 {
+		t.Fatalf("标签与路径索引应一致: err=%v %+v", err, report)
+	}
+}
+
+// concurrentAdds 多个 goroutine 并发添加证书（各自不同域名），返回全部错误
+func concurrentAdds(workers, each int, prefix string, add func(Certificate) error) []error {
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < each; i++ {
+				c := Certificate{Domain: fmt.Sprintf("%s-%d-%d.com", prefix, w, i), Status: "有效", CertSource: "certd", ExpireTime: int64(1000 + i)}
+				if err := add(c); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	return errs
+}
+
+// concurrentDuplicates 多个 goroutine 同时添加同一域名：只有一个成功，其余返回 ErrExists
+func concurrentDuplicates(t *testing.T, workers int, domain string, add func(Certificate) error) {
+	t.Helper()
+	results := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		go func() { results <- add(Certificate{Domain: domain, Status: "有效", CertSource: "certd"}) }()
+	}
+	ok := 0
+	for w := 0; w < workers; w++ {
+		err := <-results
+		switch {
+		case err == nil:
+			ok++
+		case !errors.Is(err, ErrExists):
+			t.Errorf("重复添加应返回 ErrExists，实际: %v", err)
+		}
+	}
+	if ok != 1 {
+		t.Fatalf("同一域名并发添加应只有一个成功，实际 %d 个", ok)
+	}
+}
+
+// 多个连接（模拟守护进程与命令行）同时写入同一数据库文件：WAL + busy timeout 下不出现 database is locked
+func TestSQLiteConcurrentWriters(t *testing.T) {
+	if err := InitDatabase(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	if DBMode() != "SQLite" {
+		t.Skip("SQLite 不可用（CGO_ENABLED=0）")
+	}
+	var mode string
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Fatalf("应使用 WAL 模式: mode=%q err=%v", mode, err)
+	}
+
+	// 另一个独立的连接池写入同一文件（相当于另一个进程）
+	other, err := openSQLite(DBPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	stop := make(chan struct{})
+	otherErr := make(chan error, 1)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				otherErr <- nil
+				return
+			default:
+			}
+			if _, err := other.Exec("UPDATE certificates SET last_error = ? WHERE domain LIKE 'conc-%'", fmt.Sprint(i)); err != nil {
+				otherErr <- err
+				return
+			}
+		}
+	}()
+
+	errs := concurrentAdds(4, 25, "conc", AddCertificateToDBWrapper)
+	close(stop)
+	if err := <-otherErr; err != nil {
+		t.Fatalf("另一连接写入失败: %v", err)
+	}
+	if len(errs) > 0 {
+		t.Fatalf("并发写入失败 %d 次，首个错误: %v", len(errs), errs[0])
+	}
+	all, _ := GetAllCertificatesWrapper()
+	ids := map[int]bool{}
+	for _, c := range all {
+		if strings.HasPrefix(c.Domain, "conc-") {
+			ids[c.ID] = true
+		}
+	}
+	if len(ids) != 100 {
+		t.Fatalf("应写入 100 条不同 ID 的记录，实际 %d", len(ids))
+	}
+
+	concurrentDuplicates(t, 8, "conc-dup.com", AddCertificateToDBWrapper)
+
+	for _, c := range all {
+		if strings.HasPrefix(c.Domain, "conc-") {
+			_ = DeleteCertificateFromDBWrapper(c.ID)
+		}
+	}
+	dup, _ := GetCertificateWrapper("conc-dup.com")
+	_ = DeleteCertificateFromDBWrapper(dup.ID)
+}
+
+// 两个写入方（守护进程与命令行）读取同一记录后先后保存：后保存的一方返回 ErrConflict，不覆盖先保存的修改
+func TestUpdateVersionConflict(t *testing.T) {
+	if err := InitDatabase(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	if err := AddCertificateToDBWrapper(Certificate{Domain: "version-conflict.com", Status: "有效", CertSource: "local"}); err != nil {
+		t.Fatalf("添加证书失败: %v", err)
+	}
+	daemon, _ := GetCertificateWrapper("version-conflict.com")
+	cli, _ := GetCertificateWrapper("version-conflict.com")
+	defer DeleteCertificateFromDBWrapper(daemon.ID)
+
+	cli.Tags = []string{"prod"}
+	if err := UpdateCertificateInDBWrapper(cli); err != nil {
+		t.Fatalf("先保存的一方应成功: %v", err)
+	}
+	daemon.Status = "过期"
+	if err := UpdateCertificateInDBWrapper(daemon); !errors.Is(err, ErrConflict) {
+		t.Fatalf("后保存的一方应返回 ErrConflict，实际: %v", err)
+	}
+	got, _ := GetCertificateByIDWrapper(daemon.ID)
+	if got.Status != "有效" || len(got.Tags) != 1 || got.Version != cli.Version+1 {
+		t.Fatalf("冲突时不应覆盖先保存的修改: %+v", got)
+	}
+	// 重新读取后可正常保存
+	got.Status = "过期"
+	if err := UpdateCertificateInDBWrapper(got); err != nil {
+		t.Fatalf("重新读取后保存应成功: %v", err)
+	}
+	if err := UpdateCertificateInDBWrapper(Certificate{ID: 1 << 30}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("记录不存在应返回 ErrNotFound: %v", err)
+	}
+
+	// Badger 实现同样校验版本
+	useTestBadger(t)
+	if err := addCertificateToBadgerDB(Certificate{Domain: "b.com", CertSource: "local"}); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := getCertificateFromBadger(1)
+	second := first
+	first.Tags = []string{"prod"}
+	if err := updateCertificateInBadgerDB(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := updateCertificateInBadgerDB(second); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Badger 后保存的一方应返回 ErrConflict，实际: %v", err)
+	}
+	if got, _ := getCertificateFromBadger(1); len(got.Tags) != 1 || got.Version != 1 {
+		t.Fatalf("Badger 冲突时不应覆盖先保存的修改: %+v", got)
+	}
+}
+
+// BadgerDB 并发写入：ID 分配与唯一性检查在同一事务中，写事务串行执行
+func TestBadgerConcurrentWriters(t *testing.T) {
+	useTestBadger(t)
+	if errs := concurrentAdds(8, 20, "conc", addCertificateToBadgerDB); len(errs) > 0 {
+		t.Fatalf("并发写入失败 %d 次，首个错误: %v", len(errs), errs[0])
+	}
+	all, _ := getAllCertificatesFromBadger()
+	ids := map[int]bool{}
+	for _, c := range all {
+		ids[c.ID] = true
+	}
+	if len(all) != 160 || len(ids) != 160 {
+		t.Fatalf("应写入 160 条不同 ID 的记录，实际 %d 条 %d 个 ID", len(all), len(ids))
+	}
+	concurrentDuplicates(t, 8, "conc-dup.com", addCertificateToBadgerDB)
+	if report, err := checkBadgerDB(false); err != nil || len(report.Issues) != 0 {
+		t.Fatalf("并发写入后索引应一致: err=%v %+v", err, report)
+	}
+}
+
+// ctx 已取消时放弃操作；冲突与不存在分别返回 ErrExists / ErrNotFound
+func TestContextAndConflicts(t *testing.T) {
+	if err := InitDatabase(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := AddCertificateToDBContext(ctx, Certificate{Domain: "ctx-canceled.com", Status: "有效", CertSource: "certd"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ctx 已取消应返回 context.Canceled，实际: %v", err)
+	}
+	if _, err := GetAllCertificatesContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ctx 已取消应返回 context.Canceled，实际: %v", err)
+	}
+	if _, err := GetCertificateWrapper("ctx-canceled.com"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("取消的添加不应写入: %v", err)
+	}
+
+	for _, d := range []string{"ctx-a.com", "ctx-b.com"} {
+		if err := AddCertificateToDBWrapper(Certificate{Domain: d, Status: "有效", CertSource: "certd"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := AddCertificateToDBWrapper(Certificate{Domain: "ctx-a.com", Status: "有效", CertSource: "certd"}); !errors.Is(err, ErrExists) || !strings.Contains(err.Error(), "域名 ctx-a.com 的证书信息已存在") {
+		t.Fatalf("重复添加应返回 ErrExists，实际: %v", err)
+	}
+	b, _ := GetCertificateWrapper("ctx-b.com")
+	b.Domain = "ctx-a.com"
+	if err := UpdateCertificateInDBWrapper(b); !errors.Is(err, ErrExists) {
+		t.Fatalf("改为已存在的域名应返回 ErrExists，实际: %v", err)
+	}
+	if err := UpdateCertificateInDBWrapper(Certificate{ID: 987654, Domain: "ctx-none.com"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("更新不存在的记录应返回 ErrNotFound，实际: %v", err)
+	}
+	for _, d := range []string{"ctx-a.com", "ctx-b.com"} {
+		c, _ := GetCertificateWrapper(d)
+		_ = DeleteCertificateFromDBWrapper(c.ID)
+	}
+}
+
+// 证书级重载命令、提前更新天数、平台顺序、平台实例与续期记录读写
+func TestReloadRenewRoundTrip(t *testing.T) {
+	if err := InitDatabase(); err != nil {
+		t.Fatalf("初始化数据库失败: %v", err)
+	}
+	renewals := RenewHistory{{Time: 1700000000, Provider: "certd", ExpireTime: 1707776000}}
+	cert := Certificate{Domain: "reload-roundtrip.com", Status: "有效", CertSource: "certd", ReloadCmd: "systemctl reload postfix", RenewDays: 20,
+		Providers: "certd.prod,west", Renewals: renewals, ProviderInstance: "prod"}
+	if err := AddCertificateToDBWrapper(cert); err != nil {
+		t.Fatalf("添加证书失败: %v", err)
+	}
+	got, err := GetCertificateWrapper(cert.Domain)
+	if err != nil || got.ReloadCmd != cert.ReloadCmd || got.RenewDays != 20 {
+		t.Fatalf("重载命令/提前更新天数读写不一致: %q %d %v", got.ReloadCmd, got.RenewDays, err)
+	}
+	if got.Providers != "certd.prod,west" || got.ProviderInstance != "prod" || len(got.Renewals) != 1 || got.Renewals[0] != renewals[0] {
+		t.Fatalf("平台顺序/平台实例/续期记录读写不一致: %q %q %+v", got.Providers, got.ProviderInstance, got.Renewals)
+	}
+	got.ReloadCmd, got.RenewDays, got.Providers, got.Renewals = "", 0, "", nil
+	if err := UpdateCertificateInDBWrapper(got); err != nil {
+		t.Fatalf("更新失败: %v", err)
+	}
+	if got, _ = GetCertificateWrapper(cert.Domain); got.ReloadCmd != "" || got.RenewDays != 0 || got.Providers != "" || len(got.Renewals) != 0 {
+		t.Fatalf("重载命令/提前更新天数/平台顺序/续期记录应被清空: %+v", got)
+	}
+	_ = DeleteCertificateFromDBWrapper(got.ID)
+}
+
+// 续期记录超出上限时丢弃最早的记录，且不修改原记录
+func TestRenewHistoryAdd(t *testing.T) {
+	var h RenewHistory
+	for i := 1; i <= MaxRenewals+2; i++ {
+		h = h.Add(RenewRecord{Time: int64(i)})
+	}
+	if len(h) != MaxRenewals || h[0].Time != 3 || h[len(h)-1].Time != MaxRenewals+2 {
+		t.Fatalf("续期记录截取错误: %d 条，首条 %d", len(h), h[0].Time)
+	}
+	base := h[:1:1]
+	_ = base.Add(RenewRecord{Time: 99})
+	if h[1].Time != 4 {
+		t.Fatal("Add 不应修改原记录")
+	}
+}
@@ -1,49 +1,123 @@
 package db
 
+import "context"
+
+// 各操作提供两种形式：XxxWrapper 使用 context.Background()，XxxContext 接受调用方的 ctx（超时或取消时放弃操作）
+
 // AddCertificateToDBWrapper 添加证书
 func AddCertificateToDBWrapper(cert Certificate) error {
+	return AddCertificateToDBContext(context.Background(), cert)
+}
+
+// AddCertificateToDBContext 添加证书（同一域名与密钥类型已存在时返回 ErrExists）
+func AddCertificateToDBContext(ctx context.Context, cert Certificate) error {
 	if err := OpenDatabase(); err != nil {
 		return err
 	}
-	return Interface.AddCertificate(cert)
+	return Interface.AddCertificate(ctx, cert)
 }
 
 // DeleteCertificateFromDBWrapper 删除证书
 func DeleteCertificateFromDBWrapper(id int) error {
+	return DeleteCertificateFromDBContext(context.Background(), id)
+}
+
+// DeleteCertificateFromDBContext 删除证书
+func DeleteCertificateFromDBContext(ctx context.Context, id int) error {
 	if err := OpenDatabase(); err != nil {
 		return err
 	}
-	return Interface.DeleteCertificate(id)
+	return Interface.DeleteCertificate(ctx, id)
 }
 
 // GetAllCertificatesWrapper 获取所有证书
 func GetAllCertificatesWrapper() ([]Certificate, error) {
+	return GetAllCertificatesContext(context.Background())
+}
+
+// GetAllCertificatesContext 获取所有证书
+func GetAllCertificatesContext(ctx context.Context) ([]Certificate, error) {
 	if err := OpenDatabase(); err != nil {
 		return nil, err
 	}
-	return Interface.GetAllCertificates()
+	return Interface.GetAllCertificates(ctx)
 }
 
 // GetCertificateByIDWrapper 通过ID获取指定证书
 func GetCertificateByIDWrapper(id int) (Certificate, error) {
+	return GetCertificateByIDContext(context.Background(), id)
+}
+
+// GetCertificateByIDContext 通过ID获取指定证书
+func GetCertificateByIDContext(ctx context.Context, id int) (Certificate, error) {
 	if err := OpenDatabase(); err != nil {
 		return Certificate{}, err
 	}
-	return Interface.GetCertificate(id)
+	return Interface.GetCertificate(ctx, id)
 }
 
 // GetCertificateWrapper 通过域名获取指定证书
 func GetCertificateWrapper(domain string) (Certificate, error) {
+	return GetCertificateContext(context.Background(), domain)
+}
+
+// GetCertificateContext 通过域名获取指定证书
+func GetCertificateContext(ctx context.Context, domain string) (Certificate, error) {
 	if err := OpenDatabase(); err != nil {
 		return Certificate{}, err
 	}
-	return Interface.GetDomainCertificate(domain)
+	return Interface.GetDomainCertificate(ctx, domain)
+}
+
+// GetDomainCertificatesWrapper 通过域名获取全部密钥类型的证书（RSA + ECDSA 双证书时多条）
+func GetDomainCertificatesWrapper(domain string) ([]Certificate, error) {
+	return GetDomainCertificatesContext(context.Background(), domain)
+}
+
+// GetDomainCertificatesContext 通过域名获取全部密钥类型的证书
+func GetDomainCertificatesContext(ctx context.Context, domain string) ([]Certificate, error) {
+	if err := OpenDatabase(); err != nil {
+		return nil, err
+	}
+	return Interface.GetDomainCertificates(ctx, domain)
+}
+
+// QueryCertificatesWrapper 按条件查询证书（来源、状态、到期时间、标签、部署路径，支持排序与分页）
+func QueryCertificatesWrapper(q CertQuery) ([]Certificate, error) {
+	return QueryCertificatesContext(context.Background(), q)
+}
+
+// QueryCertificatesContext 按条件查询证书
+func QueryCertificatesContext(ctx context.Context, q CertQuery) ([]Certificate, error) {
+	if err := OpenDatabase(); err != nil {
+		return nil, err
+	}
+	return Interface.QueryCertificates(ctx, q)
 }
 
 // UpdateCertificateInDBWrapper 更新证书信息
 func UpdateCertificateInDBWrapper(cert Certificate) error {
+	return UpdateCertificateInDBContext(context.Background(), cert)
+}
+
+// UpdateCertificateInDBContext 更新证书信息（记录不存在时返回 ErrNotFound，改为已存在的域名与密钥类型时返回 ErrExists，
+// 记录在读取后已被其他进程修改时返回 ErrConflict）
+func UpdateCertificateInDBContext(ctx context.Context, cert Certificate) error {
 	if err := OpenDatabase(); err != nil {
 		return err
 	}
-	return Interface.UpdateCertificate(cert)
+	return Interface.UpdateCertificate(ctx, cert)
+}
+
+// CheckDatabaseWrapper 数据库一致性检查（repair 时修复孤立键、缺失索引等可自动修复的问题）
+func CheckDatabaseWrapper(repair bool) (CheckReport, error) {
+	return CheckDatabaseContext(context.Background(), repair)
+}
+
+// CheckDatabaseContext 数据库一致性检查
+func CheckDatabaseContext(ctx context.Context, repair bool) (CheckReport, error) {
+	if err := OpenDatabase(); err != nil {
+		return CheckReport{}, err
+	}
+	return Interface.Check(ctx, repair)
 }
@@ -0,0 +1,80 @@
+// Package fswatch 监视目录中文件的创建、写入、删除与改名，并合并短时间内的连续变更。
+// Linux 使用 inotify，其他平台按间隔轮询目录。只监视目录本身（不递归）：
+// 编辑器与部署工具常以"写临时文件再改名"的方式替换文件，监视文件本身会在替换后失效。
+package fswatch
+
+import (
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Watcher 目录监视器：变更文件的路径发送到 Events，监视出错（如事件队列溢出）发送到 Errors。
+// Close 后两个通道均被关闭
+type Watcher struct {
+	Events chan string
+	Errors chan error
+	impl   watcherImpl
+}
+
+// watcherImpl 平台相关的实现
+type watcherImpl interface {
+	add(dir string) error
+	close() error
+}
+
+// New 创建监视器
+func New() (*Watcher, error) {
+	w := &Watcher{Events: make(chan string, 64), Errors: make(chan error, 8)}
+	impl, err := newImpl(w)
+	if err != nil {
+		return nil, err
+	}
+	w.impl = impl
+	return w, nil
+}
+
+// Add 监视目录（重复添加忽略）
+func (w *Watcher) Add(dir string) error {
+	return w.impl.add(filepath.Clean(dir))
+}
+
+// Close 停止监视
+func (w *Watcher) Close() error {
+	return w.impl.close()
+}
+
+// Debounce 合并变更：收到变更后等待 quiet 时间内没有新的变更，再以去重、排序后的路径列表调用 fn
+// （编辑器保存、部署工具替换文件时会在短时间内产生多个事件）。events 关闭时处理完剩余变更后返回
+func Debounce(events <-chan string, quiet time.Duration, fn func(paths []string)) {
+	pending := map[string]bool{}
+	timer := time.NewTimer(quiet)
+	timer.Stop()
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		paths := make([]string, 0, len(pending))
+		for p := range pending {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		pending = map[string]bool{}
+		fn(paths)
+	}
+	for {
+		select {
+		case p, ok := <-events:
+			if !ok {
+				timer.Stop()
+				flush()
+				return
+			}
+			pending[p] = true
+			timer.Stop()
+			timer.Reset(quiet)
+		case <-timer.C:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package fswatch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// inotifyMask 关注的事件：写入完成、创建、删除、移入/移出（改名替换）、属性变化（权限、属主）
+const inotifyMask = unix.IN_CLOSE_WRITE | unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_TO | unix.IN_MOVED_FROM | unix.IN_ATTRIB
+
+// inotifyWatcher 基于 inotify 的实现。文件描述符设为非阻塞并交给运行时轮询，Close 可中断读取
+type inotifyWatcher struct {
+	w    *Watcher
+	file *os.File
+	mu   sync.Mutex
+	dirs map[int]string // watch 描述符 → 目录
+	wds  map[string]int // 目录 → watch 描述符
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func newImpl(w *Watcher) (watcherImpl, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify 初始化失败: %v", err)
+	}
+	iw := &inotifyWatcher{
+		w:    w,
+		file: os.NewFile(uintptr(fd), "inotify"),
+		dirs: map[int]string{},
+		wds:  map[string]int{},
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go iw.read()
+	return iw, nil
+}
+
+func (iw *inotifyWatcher) add(dir string) error {
+	iw.mu.Lock()
+	defer iw.mu.Unlock()
+	if _, ok := iw.wds[dir]; ok {
+		return nil
+	}
+	wd, err := unix.InotifyAddWatch(int(iw.file.Fd()), dir, inotifyMask|unix.IN_ONLYDIR)
+	if err != nil {
+		return fmt.Errorf("监视目录 %s 失败: %v", dir, err)
+	}
+	iw.dirs[wd] = dir
+	iw.wds[dir] = wd
+	return nil
+}
+
+func (iw *inotifyWatcher) close() error {
+	var err error
+	iw.once.Do(func() {
+		close(iw.stop)
+		err = iw.file.Close()
+	})
+	<-iw.done
+	return err
+}
+
+// send 发送事件或错误；已停止监视时放弃（调用方可能已不再接收）
+func send[T any](stop <-chan struct{}, ch chan<- T, v T) bool {
+	select {
+	case ch <- v:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// read 读取事件：按 inotify_event 结构逐条解析，目录被删除（IN_IGNORED）时移除记录
+func (iw *inotifyWatcher) read() {
+	defer func() {
+		close(iw.w.Events)
+		close(iw.w.Errors)
+		close(iw.done)
+	}()
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := iw.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				send(iw.stop, iw.w.Errors, err)
+			}
+			return
+		}
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameBytes := buf[off+unix.SizeofInotifyEvent : off+unix.SizeofInotifyEvent+int(ev.Len)]
+			off += unix.SizeofInotifyEvent + int(ev.Len)
+
+			if ev.Mask&unix.IN_Q_OVERFLOW != 0 {
+				if !send(iw.stop, iw.w.Errors, errors.New("inotify 事件队列溢出，部分变更可能未被处理")) {
+					return
+				}
+				continue
+			}
+			iw.mu.Lock()
+			dir, ok := iw.dirs[int(ev.Wd)]
+			if ev.Mask&unix.IN_IGNORED != 0 {
+				delete(iw.dirs, int(ev.Wd))
+				delete(iw.wds, dir)
+			}
+			iw.mu.Unlock()
+			if !ok || ev.Mask&inotifyMask == 0 {
+				continue
+			}
+			name := string(nameBytes)
+			for len(name) > 0 && name[len(name)-1] == 0 {
+				name = name[:len(name)-1]
+			}
+			path := dir
+			if name != "" {
+				path = filepath.Join(dir, name)
+			}
+			if !send(iw.stop, iw.w.Events, path) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+//go:build !linux
+
+package fswatch
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// pollInterval 轮询间隔
+var pollInterval = time.Second
+
+// fileState 文件的修改时间、大小与权限，任一变化视为变更
+type fileState struct {
+	mod  time.Time
+	size int64
+	mode os.FileMode
+}
+
+// pollWatcher 轮询实现：定期读取各目录的文件列表，与上次结果比较
+type pollWatcher struct {
+	w    *Watcher
+	mu   sync.Mutex
+	dirs map[string]map[string]fileState
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func newImpl(w *Watcher) (watcherImpl, error) {
+	pw := &pollWatcher{w: w, dirs: map[string]map[string]fileState{}, stop: make(chan struct{}), done: make(chan struct{})}
+	go pw.loop()
+	return pw, nil
+}
+
+func (pw *pollWatcher) add(dir string) error {
+	state, err := snapshot(dir)
+	if err != nil {
+		return err
+	}
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if _, ok := pw.dirs[dir]; !ok {
+		pw.dirs[dir] = state
+	}
+	return nil
+}
+
+func (pw *pollWatcher) close() error {
+	pw.once.Do(func() { close(pw.stop) })
+	<-pw.done
+	return nil
+}
+
+func (pw *pollWatcher) loop() {
+	defer func() {
+		close(pw.w.Events)
+		close(pw.w.Errors)
+		close(pw.done)
+	}()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pw.stop:
+			return
+		case <-ticker.C:
+		}
+		pw.mu.Lock()
+		var changed []string
+		for dir, old := range pw.dirs {
+			state, err := snapshot(dir)
+			if err != nil {
+				// 目录被删除：停止监视（与 inotify 的 IN_IGNORED 一致）
+				delete(pw.dirs, dir)
+				continue
+			}
+			for name, s := range state {
+				if o, ok := old[name]; !ok || o != s {
+					changed = append(changed, filepath.Join(dir, name))
+				}
+			}
+			for name := range old {
+				if _, ok := state[name]; !ok {
+					changed = append(changed, filepath.Join(dir, name))
+				}
+			}
+			pw.dirs[dir] = state
+		}
+		pw.mu.Unlock()
+		for _, p := range changed {
+			select {
+			case pw.w.Events <- p:
+			case <-pw.stop:
+				return
+			}
+		}
+	}
+}
+
+// snapshot 目录中各文件的状态
+func snapshot(dir string) (map[string]fileState, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	state := make(map[string]fileState, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		state[e.Name()] = fileState{mod: info.ModTime(), size: info.Size(), mode: info.Mode()}
+	}
+	return state, nil
+}
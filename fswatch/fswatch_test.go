@@ -0,0 +1,87 @@
+package fswatch
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 连续变更合并为一次回调（路径去重、排序），静默期后的变更单独回调；通道关闭时处理剩余变更
+func TestDebounce(t *testing.T) {
+	events := make(chan string)
+	got := make(chan []string, 4)
+	go func() {
+		Debounce(events, 50*time.Millisecond, func(paths []string) { got <- paths })
+		close(got)
+	}()
+	for _, p := range []string{"/b", "/a", "/b", "/a"} {
+		events <- p
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case paths := <-got:
+		if !reflect.DeepEqual(paths, []string{"/a", "/b"}) {
+			t.Fatalf("应合并为一次回调: %v", paths)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("静默期后应回调")
+	}
+	events <- "/c"
+	close(events)
+	if paths := <-got; !reflect.DeepEqual(paths, []string{"/c"}) {
+		t.Fatalf("关闭时应处理剩余变更: %v", paths)
+	}
+	if _, ok := <-got; ok {
+		t.Fatal("通道关闭后应返回")
+	}
+}
+
+// 监视目录：新建、改名替换、删除文件均产生事件；Close 后通道关闭
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "old.pem"), []byte("1"), 0644)
+	w, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Add(dir); err != nil {
+		t.Fatalf("重复添加应忽略: %v", err)
+	}
+	if err := w.Add(filepath.Join(dir, "nope")); err == nil {
+		t.Fatal("不存在的目录应报错")
+	}
+
+	expect := func(want string, action func()) {
+		t.Helper()
+		action()
+		deadline := time.After(5 * time.Second)
+		for {
+			select {
+			case p := <-w.Events:
+				if p == want {
+					return
+				}
+			case <-deadline:
+				t.Fatalf("未收到 %s 的变更事件", want)
+			}
+		}
+	}
+	tmp := filepath.Join(dir, ".new.tmp")
+	expect(filepath.Join(dir, "a.conf"), func() { os.WriteFile(filepath.Join(dir, "a.conf"), []byte("server {}"), 0644) })
+	expect(filepath.Join(dir, "old.pem"), func() {
+		os.WriteFile(tmp, []byte("22"), 0644)
+		os.Rename(tmp, filepath.Join(dir, "old.pem"))
+	})
+	expect(filepath.Join(dir, "a.conf"), func() { os.Remove(filepath.Join(dir, "a.conf")) })
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for range w.Events {
+	}
+}
@@ -14,6 +14,7 @@ require (
 	github.com/rivo/tview v0.42.0
 	github.com/rivo/uniseg v0.4.7 // indirect
 	github.com/spf13/cobra v1.7.0
+	golang.org/x/crypto v0.31.0
 	golang.org/x/sys v0.29.0
 	golang.org/x/term v0.28.0
 )
@@ -7,7 +7,6 @@ import (
 	"github.com/rivo/tview"
 	"github.com/spf13/cobra"
 	"os"
-	"path/filepath"
 	"runtime"
 	"ssl_assistant/config"
 	"ssl_assistant/db"
@@ -56,9 +55,15 @@ var initCmd = &cobra.Command{
 var addCmd = &cobra.Command{
 	Use:   "add",
 	Short: "添加证书",
-	Long:  `添加证书，输入域名，程序自动根据域名获取证书信息，并将证书信息保存到数据库中。`,
+	Long: `添加证书，输入域名，程序自动根据域名获取证书信息，并将证书信息保存到数据库中。
+证书申请中（Certd 已触发申请、尚未签发）时记录为申请中，由证书更新任务自动跟进；--wait 阻塞等待签发。
+--configure 将证书部署到托管目录（managed_cert_dir，默认 /etc/ssl_assistant/certs/<域名>/），
+并修改该域名的 Nginx server / Apache VirtualHost 使用该证书（未启用 HTTPS 的同时启用），
+原配置备份到 ~/.ssl_assistant/backups，配置测试（nginx -t / apachectl -t）未通过时自动还原，通过后执行重载命令。`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return addCertificate()
+		wait, _ := cmd.Flags().GetBool("wait")
+		configure, _ := cmd.Flags().GetBool("configure")
+		return addCertificate(wait, configure)
 	},
 }
 
@@ -80,6 +85,115 @@ var showCmd = &cobra.Command{
 	},
 }
 
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "按条件查询证书",
+	Long: `按条件查询证书并显示表格，各条件同时满足：--expiring 到期时间范围（如 30d、12h，申请中尚无到期时间的不列出）、
+--source 证书来源、--status 状态、--tag 标签、--path 部署位置的证书/私钥/证书链路径；
+--sort 排序（id / domain / expire），--desc 倒序，--limit / --offset 分页。`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := initGuide(false); err != nil {
+			return err
+		}
+		var q db.CertQuery
+		q.Source, _ = cmd.Flags().GetString("source")
+		q.Status, _ = cmd.Flags().GetString("status")
+		q.Tag, _ = cmd.Flags().GetString("tag")
+		q.Path, _ = cmd.Flags().GetString("path")
+		q.Sort, _ = cmd.Flags().GetString("sort")
+		q.Desc, _ = cmd.Flags().GetBool("desc")
+		q.Limit, _ = cmd.Flags().GetInt("limit")
+		q.Offset, _ = cmd.Flags().GetInt("offset")
+		expiring, _ := cmd.Flags().GetString("expiring")
+		return listCommand(q, expiring)
+	},
+}
+
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "管理证书标签（list --tag 按标签筛选）",
+}
+
+var tagAddCmd = &cobra.Command{
+	Use:   "add <证书ID> <标签...>",
+	Short: "添加标签",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := initGuide(false); err != nil {
+			return err
+		}
+		return tagCommand(args[0], args[1:], false)
+	},
+}
+
+var tagDelCmd = &cobra.Command{
+	Use:   "del <证书ID> <标签...>",
+	Short: "删除标签",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := initGuide(false); err != nil {
+			return err
+		}
+		return tagCommand(args[0], args[1:], true)
+	},
+}
+
+var editCmd = &cobra.Command{
+	Use:   "edit <证书ID|域名>",
+	Short: "编辑证书的部署路径、来源、平台证书ID、重载命令与提前更新天数",
+	Long: `修改已保存证书的信息，无需删除后重新添加；只修改指定的参数，未指定任何参数时逐项询问（回车保留当前值）。
+--cert / --key / --chain 修改第 --index 个部署位置（序号见 deploy list，默认第 1 个）的路径，新路径须已存在且可写，或所在目录存在且可写；
+--move 将原文件移动到新路径（原文件被其他证书记录引用时复制），不移动时新路径尚无文件则立即写入数据库中的证书。
+--source 证书来源（certd / west / local / manual，local 按 provider_order 配置的平台顺序获取），--providers 平台获取顺序（如 certd,west，依次尝试，空串恢复默认），--cert-id 平台证书ID（0 为按域名查找），
+--reload 该证书的重载命令（替代全局重载命令，空串恢复使用全局命令），--renew-days 该证书的提前更新天数（0 为使用全局配置）。`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := initGuide(false); err != nil {
+			return err
+		}
+		// 未指定的参数为 nil（不修改）
+		str := func(name string) *string {
+			if !cmd.Flags().Changed(name) {
+				return nil
+			}
+			v, _ := cmd.Flags().GetString(name)
+			return &v
+		}
+		num := func(name string) *int {
+			if !cmd.Flags().Changed(name) {
+				return nil
+			}
+			v, _ := cmd.Flags().GetInt(name)
+			return &v
+		}
+		e := certEdit{
+			CertPath:  str("cert"),
+			KeyPath:   str("key"),
+			ChainPath: str("chain"),
+			Source:    str("source"),
+			Providers: str("providers"),
+			CertID:    num("cert-id"),
+			ReloadCmd: str("reload"),
+			RenewDays: num("renew-days"),
+		}
+		e.Index, _ = cmd.Flags().GetInt("index")
+		e.Move, _ = cmd.Flags().GetBool("move")
+		return editCommand(args[0], e)
+	},
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history <证书ID|域名>",
+	Short: "查看证书的续期记录（每次续期实际提供证书的平台）",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := initGuide(false); err != nil {
+			return err
+		}
+		return historyCommand(args[0])
+	},
+}
+
 var updateCmd = &cobra.Command{
 	Use:   "update",
 	Short: "更新证书",
@@ -91,8 +205,8 @@ var updateCmd = &cobra.Command{
 
 var findCmd = &cobra.Command{
 	Use:   "find",
-	Short: "快速添加域名（Nginx/Apache目录检索）",
-	Long:  `检索Nginx/Apache配置目录，程序会自动检索其中的证书配置，并将证书文件路径保存到数据库中，用于快速添加站点`,
+	Short: "快速添加域名（Nginx/Apache/Caddy/HAProxy/Traefik/lighttpd 配置检索）",
+	Long:  `检索Nginx/Apache/Caddy/HAProxy/Traefik/lighttpd配置，程序会自动检索其中的证书配置，并将证书文件路径保存到数据库中，用于快速添加站点`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if err := initGuide(true); err != nil {
 			return err
@@ -142,6 +256,318 @@ var versionCmd = &cobra.Command{
 	},
 }
 
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "输出 Prometheus 指标",
+	Long: `按 Prometheus 文本格式输出证书指标（数据库/本地文件到期时间、最近续期、获取失败、来源）与运行指标（更新/失败/跳过计数、重载结果、平台请求耗时）。
+使用 --textfile 写入 node_exporter textfile collector 目录下的 .prom 文件（原子替换），可配合 crontab 定时执行。`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		textfile, _ := cmd.Flags().GetString("textfile")
+		return metricsCommand(textfile)
+	},
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "启动 Prometheus 指标服务",
+	Long:  `启动 HTTP 指标服务，在 /metrics 提供 Prometheus 指标。证书更新任务（cron）配置 metrics_listen 后也会在守护进程内提供同样的接口。`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		listen, _ := cmd.Flags().GetString("listen")
+		return serveMetrics(listen)
+	},
+}
+
+var probeCmd = &cobra.Command{
+	Use:   "probe [域名...]",
+	Short: "TLS 探测证书是否生效",
+	Long: `以 SNI 连接各域名的 HTTPS 端口（取自 Nginx listen ... ssl / Apache <VirtualHost *:端口>，未解析到时为 443），
+比对服务端实际返回的证书（序列号与 SHA-256 指纹）与本地部署的证书文件，存在不一致时返回非零退出码。
+默认探测本机（probe_host，默认 127.0.0.1），不指定域名时探测全部证书。`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		host, _ := cmd.Flags().GetString("host")
+		return probeCommand(args, host)
+	},
+}
+
+var scanCmd = &cobra.Command{
+	Use:   "scan [配置路径...]",
+	Short: "比对 Web 服务器配置与证书记录（配置漂移检测）",
+	Long: `检索 Nginx/Apache 等配置（默认路径、已安装面板与参数中的路径），与数据库中的证书记录比对，报告：
+未纳管的 SSL 站点、配置中已找不到站点的证书、配置中的证书路径与部署位置不一致。
+--diff 仅列出不一致项，存在不一致时返回非零退出码；--apply 按配置修正数据库（添加站点、追加部署位置，确认后删除已移除站点的记录）。
+证书更新任务（cron）每天 3:30 执行一次检测并通知（drift_check = 0 关闭）。`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := initGuide(false); err != nil {
+			return err
+		}
+		diff, _ := cmd.Flags().GetBool("diff")
+		apply, _ := cmd.Flags().GetBool("apply")
+		return scanCommand(args, diff, apply)
+	},
+}
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [配置路径...]",
+	Short: "监视 Web 服务器配置与证书文件的变更",
+	Long: `监视配置目录（默认路径、已安装面板与参数中的路径，含 include 的文件所在目录）与证书部署目录，变更后立即比对：
+新出现的 SSL 站点在交互终端中询问是否添加，否则发送 drift 通知；部署的证书文件被替换（与数据库中的证书不一致）时，
+按 watch_cert_policy 通知（notify，默认）或重新部署并重载（redeploy）。连续变更合并处理（静默 2 秒后）。
+证书更新任务（cron）配置 watch = 1 后在守护进程内同样监视（只通知，不询问）。`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := initGuide(false); err != nil {
+			return err
+		}
+		return watchCommand(args)
+	},
+}
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "数据库维护",
+}
+
+var dbFsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "检查数据库一致性",
+	Long: `检查数据库一致性，存在问题时返回非零退出码。
+BadgerDB：证书记录无法解析、索引（域名/来源/到期时间/标签/部署路径）缺失或指向不存在的记录、同一域名与密钥类型重复、旧版索引残留、自增ID落后；
+SQLite：PRAGMA integrity_check 与孤立的部署位置、标签。
+--repair 修复可自动修复的问题（按记录重建索引、删除孤立键、修正自增ID）；无法解析的记录与重复域名需手动处理。`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repair, _ := cmd.Flags().GetBool("repair")
+		return dbFsckCommand(repair)
+	},
+}
+
+var deployCmd = &cobra.Command{
+	Use:   "deploy",
+	Short: "管理证书的部署位置（同一证书部署到多个位置）",
+	Long: `同一证书可部署到多个位置（如同时供 Nginx 与 Apache 使用，或多个站点目录各保存一份），
+更新时只从平台获取一次，依次写入全部部署位置。find/add 发现已有证书的新路径时也会自动追加为部署位置。`,
+}
+
+var deployListCmd = &cobra.Command{
+	Use:   "list [证书ID]",
+	Short: "列出部署位置",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := initGuide(false); err != nil {
+			return err
+		}
+		return deployList(args)
+	},
+}
+
+var deployAddCmd = &cobra.Command{
+	Use:   "add <证书ID>",
+	Short: "添加部署位置并立即写入证书文件",
+	Long: `添加部署位置并立即写入证书文件，之后每次证书更新时一并写入。
+证书与私钥合并在同一文件（HAProxy / lighttpd）时 --cert 与 --key 指定相同路径；Apache 2.4.8 以下单独配置证书链时使用 --chain。
+未指定 --owner / --mode / --key-mode 时保留已有文件的属主与权限，新建文件证书为 0644、私钥为 0600；
+合并文件含私钥，使用 --key-mode。启用 SELinux 时写入后自动执行 restorecon 恢复文件标签。`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := initGuide(false); err != nil {
+			return err
+		}
+		var d db.Deployment
+		d.CertPath, _ = cmd.Flags().GetString("cert")
+		d.KeyPath, _ = cmd.Flags().GetString("key")
+		d.ChainPath, _ = cmd.Flags().GetString("chain")
+		d.Owner, _ = cmd.Flags().GetString("owner")
+		d.Mode, _ = cmd.Flags().GetString("mode")
+		d.KeyMode, _ = cmd.Flags().GetString("key-mode")
+		return deployAdd(args[0], d)
+	},
+}
+
+var deploySetCmd = &cobra.Command{
+	Use:   "set <证书ID> <序号>",
+	Short: "修改部署位置的属主与权限并立即重新写入",
+	Long: `修改部署位置的属主与权限（序号见 deploy list），立即重新写入证书文件使其生效。
+只修改指定的参数；参数设为空串（如 --owner ''）恢复默认：保留已有文件的属主与权限。`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := initGuide(false); err != nil {
+			return err
+		}
+		// 未指定的参数为 nil（不修改）
+		flag := func(name string) *string {
+			if !cmd.Flags().Changed(name) {
+				return nil
+			}
+			v, _ := cmd.Flags().GetString(name)
+			return &v
+		}
+		owner, mode, keyMode := flag("owner"), flag("mode"), flag("key-mode")
+		if owner == nil && mode == nil && keyMode == nil {
+			return fmt.Errorf("请指定要修改的参数（--owner / --mode / --key-mode）")
+		}
+		return deploySet(args[0], args[1], owner, mode, keyMode)
+	},
+}
+
+var deployDelCmd = &cobra.Command{
+	Use:   "del <证书ID> <序号>",
+	Short: "删除部署位置（序号见 deploy list，已部署的文件保留）",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := initGuide(false); err != nil {
+			return err
+		}
+		return deployDel(args[0], args[1])
+	},
+}
+
+var targetCmd = &cobra.Command{
+	Use:   "target",
+	Short: "管理证书的附加部署目标（PFX/JKS/DER 等格式、SSH 远程主机）",
+	Long: `为证书添加附加部署目标：证书部署时按指定格式额外写入目标路径，格式由 PEM 本地生成。
+支持格式：fullchain（完整链）、leaf（叶子证书）、chain（中间证书）、key（私钥）、combined（证书+私钥）、
+der（叶子证书 DER）、pfx（PKCS#12，别名 p12）、jks（Java KeyStore，需设置密码）。
+ssh 类型的目标通过系统 ssh 客户端上传到远程主机，上传后执行远程检查与重载命令。`,
+}
+
+var targetListCmd = &cobra.Command{
+	Use:   "list [证书ID]",
+	Short: "列出部署目标",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := initGuide(false); err != nil {
+			return err
+		}
+		return targetList(args)
+	},
+}
+
+var targetAddCmd = &cobra.Command{
+	Use:   "add <证书ID>",
+	Short: "添加部署目标并立即写入",
+	Long: `添加部署目标并立即写入目标文件，之后每次证书续期部署时重新生成。
+权限未指定时含私钥的格式（key/combined/pfx/jks）为 0600，其余为 0644；--owner 为 user 或 user:group（Windows 本机目标不支持）。
+ssh 目标：--format 默认 fullchain，格式不含私钥时用 --key-path 指定远程私钥路径（权限 0600）；
+主机密钥必须已在 known_hosts 中（可先执行 ssh-keyscan 添加），以免连接到伪造的主机。`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := initGuide(false); err != nil {
+			return err
+		}
+		var t db.DeployTarget
+		t.Format, _ = cmd.Flags().GetString("format")
+		t.Path, _ = cmd.Flags().GetString("path")
+		t.Password, _ = cmd.Flags().GetString("password")
+		t.Owner, _ = cmd.Flags().GetString("owner")
+		t.Mode, _ = cmd.Flags().GetString("mode")
+		t.Type, _ = cmd.Flags().GetString("type")
+		t.Host, _ = cmd.Flags().GetString("host")
+		t.Port, _ = cmd.Flags().GetInt("port")
+		t.User, _ = cmd.Flags().GetString("user")
+		t.IdentityFile, _ = cmd.Flags().GetString("identity")
+		t.KnownHosts, _ = cmd.Flags().GetString("known-hosts")
+		t.KeyPath, _ = cmd.Flags().GetString("key-path")
+		t.Reload, _ = cmd.Flags().GetString("reload")
+		return targetAdd(args[0], t)
+	},
+}
+
+var targetDelCmd = &cobra.Command{
+	Use:   "del <证书ID> <序号>",
+	Short: "删除部署目标（序号见 target list，已生成的文件保留）",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := initGuide(false); err != nil {
+			return err
+		}
+		return targetDel(args[0], args[1])
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "导入 certbot / acme.sh 签发的证书",
+	Long: `检索 certbot（/etc/letsencrypt/live）与 acme.sh（~/.acme.sh）签发的证书，勾选后添加，
+证书域名取自证书 SAN，来源记为 certbot / acme.sh。
+原工具仍在自动续期时（续期配置与定时任务均存在），更新任务读取原工具续期后的证书文件并部署，不从证书平台获取；
+import takeover 停用原工具对该证书的续期，改由本程序从证书平台获取。`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := initGuide(false); err != nil {
+			return err
+		}
+		return importCertificates()
+	},
+}
+
+var importTakeoverCmd = &cobra.Command{
+	Use:   "takeover <证书ID>",
+	Short: "接管导入的证书：停用 certbot / acme.sh 对该证书的自动续期",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := initGuide(false); err != nil {
+			return err
+		}
+		return importTakeover(args[0])
+	},
+}
+
+var hookCmd = &cobra.Command{
+	Use:   "hook",
+	Short: "管理证书的钩子命令（获取、部署、重载前后执行）",
+	Long: `钩子命令在证书更新的各阶段执行：pre-fetch（获取证书前）、pre-deploy（写入证书文件前）、
+post-deploy（写入证书文件后）、post-reload（执行重载命令后）。
+全局钩子配置在 config/conf.ini 的 [hooks]（pre_fetch / pre_deploy / post_deploy / post_reload / timeout），
+对全部证书生效；证书级钩子通过 hook set 设置，在全局钩子之后执行。
+钩子通过系统 shell 执行，可读取环境变量 SSL_HOOK、SSL_DOMAIN、SSL_CERT_PATH、SSL_KEY_PATH、SSL_NOT_AFTER、SSL_SOURCE、SSL_SERIAL。
+退出码非 0 或超时视为失败：pre-fetch / pre-deploy 失败时放弃更新该证书，post-deploy / post-reload 失败计为该证书更新失败。`,
+}
+
+var hookListCmd = &cobra.Command{
+	Use:   "list [证书ID]",
+	Short: "列出全局钩子与证书级钩子",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := initGuide(false); err != nil {
+			return err
+		}
+		return hookList(args)
+	},
+}
+
+var hookSetCmd = &cobra.Command{
+	Use:   "set <证书ID> <阶段> <命令>",
+	Short: "设置证书级钩子（阶段：pre-fetch / pre-deploy / post-deploy / post-reload）",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := initGuide(false); err != nil {
+			return err
+		}
+		if strings.TrimSpace(args[2]) == "" {
+			return fmt.Errorf("命令不能为空，删除钩子请使用 hook del")
+		}
+		return hookSet(args[0], args[1], args[2])
+	},
+}
+
+var hookDelCmd = &cobra.Command{
+	Use:   "del <证书ID> <阶段>",
+	Short: "删除证书级钩子",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := initGuide(false); err != nil {
+			return err
+		}
+		return hookSet(args[0], args[1], "")
+	},
+}
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "发送测试通知",
+	Long:  `向 config/conf.ini 中已启用的通知渠道（Webhook、SMTP 邮件、钉钉、企业微信、飞书）各发送一条测试通知，用于验证通知配置。`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return sendTestNotification()
+	},
+}
+
 var checkUpdateCmd = &cobra.Command{
 	Use:   "checkupdate",
 	Short: "检查更新",
@@ -166,7 +592,79 @@ func init() {
 	rootCmd.AddCommand(findCmd)
 	rootCmd.AddCommand(cronCmd)
 	rootCmd.AddCommand(checkUpdateCmd)
+	rootCmd.AddCommand(notifyCmd)
+	rootCmd.AddCommand(metricsCmd)
+	rootCmd.AddCommand(probeCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(scanCmd)
+	scanCmd.Flags().Bool("diff", false, "仅列出配置与数据库不一致的项")
+	scanCmd.Flags().Bool("apply", false, "按配置修正数据库")
+	rootCmd.AddCommand(watchCmd)
+	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().String("expiring", "", "到期时间范围，如 30d、12h")
+	listCmd.Flags().String("source", "", "证书来源（certd / west / local 等）")
+	listCmd.Flags().String("status", "", "状态")
+	listCmd.Flags().String("tag", "", "标签")
+	listCmd.Flags().String("path", "", "部署位置的证书/私钥/证书链路径")
+	listCmd.Flags().String("sort", db.SortID, "排序：id / domain / expire")
+	listCmd.Flags().Bool("desc", false, "倒序")
+	listCmd.Flags().Int("limit", 0, "返回条数（0 不限）")
+	listCmd.Flags().Int("offset", 0, "跳过条数")
+	rootCmd.AddCommand(tagCmd)
+	rootCmd.AddCommand(editCmd)
+	rootCmd.AddCommand(historyCmd)
+	editCmd.Flags().Int("index", 1, "修改的部署位置序号（见 deploy list）")
+	editCmd.Flags().String("cert", "", "证书路径")
+	editCmd.Flags().String("key", "", "私钥路径")
+	editCmd.Flags().String("chain", "", "证书链路径（空串为不使用单独的证书链文件）")
+	editCmd.Flags().Bool("move", false, "将原文件移动到新路径")
+	editCmd.Flags().String("source", "", "证书来源（certd / west / local / manual，平台可指定实例如 certd.prod）")
+	editCmd.Flags().String("providers", "", "平台获取顺序（如 certd.prod,west；空串为使用证书来源或全局 provider_order）")
+	editCmd.Flags().Int("cert-id", 0, "平台证书ID（0 为按域名查找）")
+	editCmd.Flags().String("reload", "", "该证书的重载命令（空串为使用全局重载命令）")
+	editCmd.Flags().Int("renew-days", 0, "该证书的提前更新天数（0 为使用全局配置）")
+	tagCmd.AddCommand(tagAddCmd, tagDelCmd)
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbFsckCmd)
+	dbFsckCmd.Flags().Bool("repair", false, "修复可自动修复的问题")
+	rootCmd.AddCommand(deployCmd)
+	deployCmd.AddCommand(deployListCmd, deployAddCmd, deploySetCmd, deployDelCmd)
+	deployAddCmd.Flags().String("cert", "", "证书文件路径")
+	deployAddCmd.Flags().String("key", "", "私钥文件路径（与证书合并时同 --cert）")
+	deployAddCmd.Flags().String("chain", "", "证书链文件路径（可选）")
+	_ = deployAddCmd.MarkFlagRequired("cert")
+	_ = deployAddCmd.MarkFlagRequired("key")
+	for _, c := range []*cobra.Command{deployAddCmd, deploySetCmd} {
+		c.Flags().String("owner", "", "文件属主（user 或 user:group，默认保留已有文件的属主）")
+		c.Flags().String("mode", "", "证书与证书链文件权限（八进制，如 0644，默认保留）")
+		c.Flags().String("key-mode", "", "私钥文件权限（八进制，如 0640，默认保留；合并文件使用此项）")
+	}
+	rootCmd.AddCommand(importCmd)
+	importCmd.AddCommand(importTakeoverCmd)
+	rootCmd.AddCommand(hookCmd)
+	hookCmd.AddCommand(hookListCmd, hookSetCmd, hookDelCmd)
+	rootCmd.AddCommand(targetCmd)
+	targetCmd.AddCommand(targetListCmd, targetAddCmd, targetDelCmd)
+	targetAddCmd.Flags().String("format", "", "格式：fullchain/leaf/chain/key/combined/der/pfx/jks")
+	targetAddCmd.Flags().String("path", "", "目标文件路径")
+	targetAddCmd.Flags().String("password", "", "PFX/JKS 密码")
+	targetAddCmd.Flags().String("owner", "", "属主（user 或 user:group）")
+	targetAddCmd.Flags().String("mode", "", "权限（八进制，如 0640）")
+	targetAddCmd.Flags().String("type", "file", "目标类型：file（本机文件）或 ssh（远程主机）")
+	targetAddCmd.Flags().String("host", "", "ssh：主机名或 IP")
+	targetAddCmd.Flags().Int("port", 0, "ssh：端口（默认 22）")
+	targetAddCmd.Flags().String("user", "", "ssh：登录用户")
+	targetAddCmd.Flags().String("identity", "", "ssh：本机私钥文件（默认使用 ssh-agent 或 ~/.ssh 中的密钥）")
+	targetAddCmd.Flags().String("known-hosts", "", "ssh：known_hosts 文件（默认 ~/.ssh/known_hosts）")
+	targetAddCmd.Flags().String("key-path", "", "ssh：远程私钥路径（格式不含私钥时必填）")
+	targetAddCmd.Flags().String("reload", "", "ssh：上传后执行的远程命令，如 'nginx -t && systemctl reload nginx'")
+	_ = targetAddCmd.MarkFlagRequired("path")
+	metricsCmd.Flags().String("textfile", "", "写入 node_exporter textfile 文件路径（如 /var/lib/node_exporter/textfile/ssl_assistant.prom）")
+	probeCmd.Flags().String("host", "", "探测地址（默认取配置 probe_host，未配置为 127.0.0.1）")
+	serveCmd.Flags().String("listen", defaultMetricsListen, "指标服务监听地址")
 	cronCmd.Flags().BoolP("force", "f", false, "强制添加任务，覆盖已存在的任务")
+	addCmd.Flags().Bool("wait", false, "证书申请中（Certd 已触发申请）时阻塞等待签发后再部署")
+	addCmd.Flags().Bool("configure", false, "部署到托管路径并修改 Nginx/Apache 配置使用该证书（测试通过后重载）")
 }
 
 func main() {
@@ -179,15 +677,22 @@ func main() {
 		fmt.Printf("初始化配置文件失败: %v\n", err)
 		return
 	}
+	// 旧版平台配置（third.certd / third.west）迁移为默认实例
+	if err := migrateProviderConfig(); err != nil {
+		fmt.Println(err)
+	}
 
 	// Windows 下双击 exe 启动：进入交互菜单；带参数从 cmd 运行时照常执行子命令。
 	if IsDoubleClick() {
 		runInteractiveMenu()
+		saveMetrics()
 		return
 	}
 
-	// 执行命令
-	if err := rootCmd.Execute(); err != nil {
+	// 执行命令（运行指标在命令结束后写入状态文件，供 metrics/serve 读取）
+	err = rootCmd.Execute()
+	saveMetrics()
+	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -203,6 +708,7 @@ func runInteractiveMenu() {
 		"初始化程序",
 		"添加证书",
 		"删除证书",
+		"编辑证书",
 		"更新证书",
 		"快速添加域名",
 		"证书更新任务",
@@ -238,7 +744,7 @@ func runInteractiveMenu() {
 	certTable.SetBorder(true).SetTitle(" 证书列表 ")
 	refreshCertTable := func() {
 		certTable.Clear()
-		certs, err := db.GetAllCertificatesWrapper()
+		certs, err := db.QueryCertificatesWrapper(overviewQuery)
 		if err != nil {
 			certTable.SetCell(0, 0, tview.NewTableCell("获取证书列表失败: "+err.Error()).SetTextColor(tcell.ColorRed))
 			return
@@ -263,14 +769,11 @@ func runInteractiveMenu() {
 			if expireDay < 0 {
 				remain = "已过期"
 			}
-			// 路径只显示文件名，避免超长路径撑爆表格
-			certFile, keyFile := cert.CertPath, cert.KeyPath
-			if cert.CertPath != "" {
-				certFile = filepath.Base(cert.CertPath)
-			}
-			if cert.KeyPath != "" {
-				keyFile = filepath.Base(cert.KeyPath)
+			if cert.PendingSince > 0 {
+				status = pendingStatus
 			}
+			// 路径只显示文件名，避免超长路径撑爆表格（多个部署位置每行一个）
+			certFile, keyFile := deploymentFiles(cert)
 			rowData := []string{
 				strconv.Itoa(cert.ID),
 				cert.Domain,
@@ -278,7 +781,7 @@ func runInteractiveMenu() {
 				time.Unix(cert.CreateTime, 0).Format("2006-01-02"),
 				time.Unix(cert.ExpireTime, 0).Format("2006-01-02"),
 				remain,
-				cert.CertSource,
+				certSourceLabel(cert),
 				certFile,
 				keyFile,
 			}
@@ -286,6 +789,8 @@ func runInteractiveMenu() {
 				cell := tview.NewTableCell(v)
 				if c == 2 && status == "过期" {
 					cell.SetTextColor(tcell.ColorRed)
+				} else if c == 2 && status == pendingStatus {
+					cell.SetTextColor(tcell.ColorYellow)
 				} else if c == 2 {
 					cell.SetTextColor(tcell.ColorGreen)
 				}
@@ -335,12 +840,14 @@ func runInteractiveMenu() {
 		case 0:
 			runAction(app, feedback, "初始化程序", initConfig, refreshCertTable)
 		case 1:
-			runAction(app, feedback, "添加证书", func() { _ = addCertificate() }, refreshCertTable)
+			runAction(app, feedback, "添加证书", func() { _ = addCertificate(false, false) }, refreshCertTable)
 		case 2:
 			runAction(app, feedback, "删除证书", func() { _ = deleteCertificate() }, refreshCertTable)
 		case 3:
-			runAction(app, feedback, "更新证书", func() { _ = updateCertificates() }, refreshCertTable)
+			runAction(app, feedback, "编辑证书", func() { _ = editCertificate() }, refreshCertTable)
 		case 4:
+			runAction(app, feedback, "更新证书", func() { _ = updateCertificates() }, refreshCertTable)
+		case 5:
 			runAction(app, feedback, "快速添加域名", func() {
 				// 与"添加证书/删除证书/更新证书"一致：未初始化时自动初始化（initGuide(false)）
 				if err := initGuide(false); err != nil {
@@ -348,7 +855,7 @@ func runInteractiveMenu() {
 				}
 				_ = findNginxPathCmd()
 			}, refreshCertTable)
-		case 5:
+		case 6:
 			// Windows 下 cron 常驻进程不适用，引导使用任务计划程序；
 			// Linux 下与 CLI `cron` 一致
 			if runtime.GOOS == "windows" {
@@ -363,15 +870,15 @@ func runInteractiveMenu() {
 					cronTask(false)
 				}, refreshCertTable)
 			}
-		case 6:
-			runAction(app, feedback, "修改密钥", modifyKey, refreshCertTable)
 		case 7:
-			runAction(app, feedback, "修改重载命令", func() { _ = modifyRestartCmd() }, refreshCertTable)
+			runAction(app, feedback, "修改密钥", modifyKey, refreshCertTable)
 		case 8:
-			runAction(app, feedback, "修改提前更新天数", func() { _ = modifyExpirationDay() }, refreshCertTable)
+			runAction(app, feedback, "修改重载命令", func() { _ = modifyRestartCmd() }, refreshCertTable)
 		case 9:
-			runAction(app, feedback, "查看配置信息", func() { _ = getConfigInfo() }, nil) // 只读
+			runAction(app, feedback, "修改提前更新天数", func() { _ = modifyExpirationDay() }, refreshCertTable)
 		case 10:
+			runAction(app, feedback, "查看配置信息", func() { _ = getConfigInfo() }, nil) // 只读
+		case 11:
 			// "版本与更新"：显示版本信息 + 检查更新
 			runAction(app, feedback, "版本与更新", func() {
 				fmt.Printf("SSL Assistant %s\n项目地址: https://github.com/Youngxj/SSL-Assistant\n", displayVersion())
@@ -379,7 +886,7 @@ func runInteractiveMenu() {
 				_ = checkUpdate()
 			}, nil) // 只读
 		default:
-			// "退出"（index 11，两平台固定）与 Linux 的"查看任务"（已在上方处理）
+			// "退出"（index 12，两平台固定）与 Linux 的"查看任务"（已在上方处理）
 			if items[idx] == "退出" {
 				app.Stop()
 				return
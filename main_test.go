@@ -3,9 +3,31 @@ package main
 import (
 	"bytes"
 	"os"
+	"ssl_assistant/db"
 	"testing"
 )
 
+// TestMain 切换工作目录与 HOME 到临时目录，避免测试读写项目的 config/conf.ini 与用户目录下的数据库
+func TestMain(m *testing.M) {
+	tmp, err := os.MkdirTemp("", "ssl_assistant_test")
+	if err != nil {
+		panic(err)
+	}
+	oldWd, _ := os.Getwd()
+	if err := os.Chdir(tmp); err != nil {
+		panic(err)
+	}
+	os.Setenv("HOME", tmp)
+	os.Setenv("USERPROFILE", tmp)
+
+	code := m.Run()
+
+	db.CloseDatabase()
+	os.Chdir(oldWd)
+	os.RemoveAll(tmp)
+	os.Exit(code)
+}
+
 // 非交互无参数运行：rootCmd 应输出 help（含全部子命令），而不是进入交互菜单卡住（Linux 无参数场景的脚本/管道安全）
 func TestRootNoArgsNonInteractiveShowsHelp(t *testing.T) {
 	os.Unsetenv("SSL_ASSISTANT_INTERACTIVE")
@@ -2,7 +2,6 @@ package main
 
 import (
 	"os"
-	"path/filepath"
 	"testing"
 	"time"
 
@@ -33,7 +32,7 @@ func TestTUIEndToEndConfigView(t *testing.T) {
 
 	// 写入一条测试配置，验证 getConfigInfo 能读到
 	_ = config.SetConfig("", "restart_cmd", "nginx -s reload")
-	_ = config.SetConfig("third.certd", "api_url", "https://example.com")
+	_ = config.SetConfig("third.certd.default", "api_url", "https://example.com")
 
 	sim := tcell.NewSimulationScreen("UTF-8")
 	if err := sim.Init(); err != nil {
@@ -181,12 +180,15 @@ func captureAllOut(t *testing.T, fn func()) string {
 
 // TestTUIEndpointBatchDelete：TUI 批量删除证书——勾选→确认→删除，无需手动输入 ID
 func TestTUIEndpointBatchDelete(t *testing.T) {
-	// 固定临时目录（db 单例无法关闭，TempDir 自动清理会失败）
-	tmp := filepath.Join(os.TempDir(), "ssl_assistant_test_batchdelete")
-	_ = os.MkdirAll(tmp, 0755)
-	os.Setenv("USERPROFILE", tmp)
-	os.Setenv("HOME", tmp)
+	// 关闭之前测试打开的数据库，改用临时 HOME 下的数据库；测试结束关闭，TempDir 才能清理
+	db.CloseDatabase()
+	tmp := t.TempDir()
+	t.Cleanup(db.CloseDatabase)
+	t.Setenv("USERPROFILE", tmp)
+	t.Setenv("HOME", tmp)
+	oldwd, _ := os.Getwd()
 	_ = os.Chdir(tmp) // 隔离 config（相对路径），db 用 USERPROFILE
+	defer func() { _ = os.Chdir(oldwd) }()
 	_ = config.InitConfig()
 	_ = config.SetConfig("", "is_init", "1")
 	if err := db.InitDatabase(); err != nil {
@@ -194,9 +196,8 @@ func TestTUIEndpointBatchDelete(t *testing.T) {
 	}
 	_ = db.AddCertificateToDBWrapper(db.Certificate{ID: 1, Domain: "a.com", Status: "有效", CreateTime: 1700000000, ExpireTime: 1730000000, CertSource: "local"})
 	_ = db.AddCertificateToDBWrapper(db.Certificate{ID: 2, Domain: "b.com", Status: "有效", CreateTime: 1700000000, ExpireTime: 1730000000, CertSource: "local"})
-	// db 单例可能已被其他测试初始化（全量并行下无法隔离），此时跳过
-	if all, err := db.GetAllCertificatesWrapper(); err != nil || len(all) < 2 {
-		t.Skip("db 单例已被其他测试初始化，跳过（单跑验证通过）")
+	if all, err := db.GetAllCertificatesWrapper(); err != nil || len(all) != 2 {
+		t.Fatalf("添加测试证书失败: %d %v", len(all), err)
 	}
 
 	sim := tcell.NewSimulationScreen("UTF-8")
@@ -293,8 +294,8 @@ func TestGetConfigInfoChinese(t *testing.T) {
 	_ = config.SetConfig("", "is_init", "1")
 	_ = config.SetConfig("", "restart_cmd", "nginx -s reload")
 	_ = config.SetConfig("", "before_expiration_day", "30")
-	_ = config.SetConfig("third.certd", "api_url", "https://x.com")
-	_ = config.SetConfig("third.certd", "key_secret", "secret123")
+	_ = config.SetConfig("third.certd.default", "api_url", "https://x.com")
+	_ = config.SetConfig("third.certd.default", "key_secret", "secret123")
 	_ = config.SetConfig("", "cron_pid", "12345")
 
 	out := captureAllOut(t, func() { _ = getConfigInfo() })
@@ -323,13 +324,13 @@ func TestSetConfigPrefill(t *testing.T) {
 	}
 	defer func() { _ = os.Chdir(oldwd) }()
 	_ = config.InitConfig()
-	_ = config.SetConfig("third.certd", "api_url", "https://old-certd.com")
-	_ = config.SetConfig("third.certd", "key_id", "old-key-id")
-	_ = config.SetConfig("third.certd", "key_secret", "old-secret")
-	_ = config.SetConfig("third.certd", "auto_apply_template_id", "tpl-9")
-	_ = config.SetConfig("third.certd", "auto_apply_renew_days", "25")
-	_ = config.SetConfig("third.west", "username", "old-user")
-	_ = config.SetConfig("third.west", "api_key", "old-api-key")
+	_ = config.SetConfig("third.certd.default", "api_url", "https://old-certd.com")
+	_ = config.SetConfig("third.certd.default", "key_id", "old-key-id")
+	_ = config.SetConfig("third.certd.default", "key_secret", "old-secret")
+	_ = config.SetConfig("third.certd.default", "auto_apply_template_id", "tpl-9")
+	_ = config.SetConfig("third.certd.default", "auto_apply_renew_days", "25")
+	_ = config.SetConfig("third.west.default", "username", "old-user")
+	_ = config.SetConfig("third.west.default", "api_key", "old-api-key")
 
 	defs := []string{}
 	utils.TUIReadInput = func(prompt, def string) string {
@@ -340,7 +341,7 @@ func TestSetConfigPrefill(t *testing.T) {
 	utils.TUIConfirm = func(prompt string) bool { return false }
 	defer func() { utils.TUIReadInput, utils.TUIReadPassword, utils.TUIConfirm = nil, nil, nil }()
 
-	certdpkg.SetConfig()
+	certdpkg.SetConfig("third.certd.default")
 	// certd 预填：api_url/key_id/tpl/renewDays
 	want := []string{"https://old-certd.com", "old-key-id", "tpl-9", "25"}
 	if len(defs) < len(want) {
@@ -351,18 +352,18 @@ func TestSetConfigPrefill(t *testing.T) {
 			t.Errorf("certd 预填[%d]=%q, want %q", i, defs[i], w)
 		}
 	}
-	secret, _ := config.GetConfig("third.certd", "key_secret")
+	secret, _ := config.GetConfig("third.certd.default", "key_secret")
 	if secret != "old-secret" {
 		t.Errorf("certd 密钥留空应保留原值，实际 %q", secret)
 	}
 
 	// west：username 预填，api_key 留空保留
 	defs = defs[:0]
-	west.SetConfig()
+	west.SetConfig("third.west.default")
 	if len(defs) < 1 || defs[0] != "old-user" {
 		t.Errorf("west username 预填应为 old-user，实际 %v", defs)
 	}
-	apiKey, _ := config.GetConfig("third.west", "api_key")
+	apiKey, _ := config.GetConfig("third.west.default", "api_key")
 	if apiKey != "old-api-key" {
 		t.Errorf("west api_key 留空应保留原值，实际 %q", apiKey)
 	}
@@ -379,8 +380,8 @@ func TestModifyExpirationDayBothConfigs(t *testing.T) {
 	defer func() { _ = os.Chdir(oldwd) }()
 	_ = config.InitConfig()
 	_ = config.SetConfig("", "before_expiration_day", "12")
-	_ = config.SetConfig("third.certd", "api_url", "https://x.com")
-	_ = config.SetConfig("third.certd", "auto_apply_renew_days", "13")
+	_ = config.SetConfig("third.certd.default", "api_url", "https://x.com")
+	_ = config.SetConfig("third.certd.default", "auto_apply_renew_days", "13")
 
 	defs := []string{}
 	utils.TUIReadInput = func(prompt, def string) string {
@@ -234,7 +234,7 @@ func contains(s, sub string) bool {
 // buildTestMenuItems 返回与 runInteractiveMenu 一致的菜单项（不含平台差异分支）
 func buildTestMenuItems() []string {
 	return []string{
-		"初始化程序", "添加证书", "删除证书", "更新证书", "快速添加域名",
+		"初始化程序", "添加证书", "删除证书", "编辑证书", "更新证书", "快速添加域名",
 		"证书更新任务", "修改密钥", "修改重载命令", "修改提前更新天数",
 		"查看配置信息", "版本与更新", "退出",
 	}
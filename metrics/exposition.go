@@ -0,0 +1,201 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CertInfo 单张证书的指标数据（由调用方从数据库与证书文件收集）
+type CertInfo struct {
+	Domain        string
+	KeyType       string // 密钥类型（rsa / ecdsa，双证书站点区分同域名的多条记录）
+	Source        string
+	Status        string
+	CertID        int
+	Expire        int64 // 数据库记录的到期时间
+	FileExpire    int64 // 本地证书文件的到期时间（0 表示文件不存在或无法解析）
+	LastRenew     int64 // 最近一次成功续期部署时间（0 表示无记录）
+	LastError     string
+	LastErrorTime int64
+}
+
+// Collector 收集全部证书的指标数据
+type Collector func() ([]CertInfo, error)
+
+// writer Prometheus 文本格式（text/plain; version=0.0.4）输出辅助
+type writer struct {
+	buf bytes.Buffer
+}
+
+// header 输出指标的 HELP/TYPE 行
+func (w *writer) header(name, typ, help string) {
+	fmt.Fprintf(&w.buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample 输出一个样本，labels 为 key, value 交替排列
+func (w *writer) sample(name string, value float64, labels ...string) {
+	w.buf.WriteString(name)
+	if len(labels) > 0 {
+		w.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			fmt.Fprintf(&w.buf, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+		}
+		w.buf.WriteByte('}')
+	}
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	w.buf.WriteByte('\n')
+}
+
+// escapeLabel 转义标签值中的反斜杠、双引号与换行
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// labels 证书样本的公共标签：domain、key_type（为空时省略，与 Prometheus 空标签语义一致）、source
+func (c CertInfo) labels() []string {
+	labels := []string{"domain", c.Domain}
+	if c.KeyType != "" {
+		labels = append(labels, "key_type", c.KeyType)
+	}
+	return append(labels, "source", c.Source)
+}
+
+// boolValue 布尔值转 0/1
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Render 按 Prometheus 文本格式输出全部指标
+func Render(out io.Writer, certs []CertInfo, s State) error {
+	sort.Slice(certs, func(i, j int) bool {
+		if certs[i].Domain != certs[j].Domain {
+			return certs[i].Domain < certs[j].Domain
+		}
+		return certs[i].KeyType < certs[j].KeyType
+	})
+	w := &writer{}
+
+	w.header("ssl_assistant_certificate_info", "gauge", "Certificate metadata (value is always 1).")
+	for _, c := range certs {
+		w.sample("ssl_assistant_certificate_info", 1, append(c.labels(), "status", c.Status, "cert_id", strconv.Itoa(c.CertID))...)
+	}
+	w.header("ssl_assistant_certificate_expiry_timestamp_seconds", "gauge", "Certificate expiry recorded in the database, as a Unix timestamp.")
+	for _, c := range certs {
+		if c.Expire > 0 {
+			w.sample("ssl_assistant_certificate_expiry_timestamp_seconds", float64(c.Expire), c.labels()...)
+		}
+	}
+	w.header("ssl_assistant_certificate_file_expiry_timestamp_seconds", "gauge", "Expiry of the deployed certificate file on disk, as a Unix timestamp.")
+	for _, c := range certs {
+		if c.FileExpire > 0 {
+			w.sample("ssl_assistant_certificate_file_expiry_timestamp_seconds", float64(c.FileExpire), c.labels()...)
+		}
+	}
+	w.header("ssl_assistant_certificate_last_renewal_timestamp_seconds", "gauge", "Last successful renewal and deployment, as a Unix timestamp.")
+	for _, c := range certs {
+		if c.LastRenew > 0 {
+			w.sample("ssl_assistant_certificate_last_renewal_timestamp_seconds", float64(c.LastRenew), c.labels()...)
+		}
+	}
+	w.header("ssl_assistant_certificate_fetch_error", "gauge", "Whether the last fetch from the certificate platform failed (1) or not (0).")
+	for _, c := range certs {
+		w.sample("ssl_assistant_certificate_fetch_error", boolValue(c.LastError != ""), c.labels()...)
+	}
+	w.header("ssl_assistant_certificate_last_error_timestamp_seconds", "gauge", "Time of the last failed fetch, as a Unix timestamp.")
+	for _, c := range certs {
+		if c.LastErrorTime > 0 {
+			w.sample("ssl_assistant_certificate_last_error_timestamp_seconds", float64(c.LastErrorTime), c.labels()...)
+		}
+	}
+
+	w.header("ssl_assistant_update_runs_total", "counter", "Number of completed update runs.")
+	w.sample("ssl_assistant_update_runs_total", float64(s.Runs))
+	w.header("ssl_assistant_certificates_processed_total", "counter", "Certificates processed by update runs, by result.")
+	w.sample("ssl_assistant_certificates_processed_total", float64(s.Updated), "result", "updated")
+	w.sample("ssl_assistant_certificates_processed_total", float64(s.Failed), "result", "failed")
+	w.sample("ssl_assistant_certificates_processed_total", float64(s.Skipped), "result", "skipped")
+	w.sample("ssl_assistant_certificates_processed_total", float64(s.Pending), "result", "pending")
+	if s.LastRun > 0 {
+		w.header("ssl_assistant_last_run_timestamp_seconds", "gauge", "Completion time of the last update run, as a Unix timestamp.")
+		w.sample("ssl_assistant_last_run_timestamp_seconds", float64(s.LastRun))
+	}
+
+	w.header("ssl_assistant_reload_total", "counter", "Reload command executions, by result.")
+	w.sample("ssl_assistant_reload_total", float64(s.Reloads), "result", "success")
+	w.sample("ssl_assistant_reload_total", float64(s.ReloadFails), "result", "failure")
+	if s.LastReload > 0 {
+		w.header("ssl_assistant_reload_success", "gauge", "Whether the last reload command succeeded (1) or failed (0).")
+		w.sample("ssl_assistant_reload_success", boolValue(s.LastReloadOK))
+		w.header("ssl_assistant_last_reload_timestamp_seconds", "gauge", "Time of the last reload command, as a Unix timestamp.")
+		w.sample("ssl_assistant_last_reload_timestamp_seconds", float64(s.LastReload))
+	}
+
+	names := make([]string, 0, len(s.Platforms))
+	for name := range s.Platforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w.header("ssl_assistant_platform_request_duration_seconds", "summary", "Latency of certificate platform requests.")
+	for _, name := range names {
+		p := s.Platforms[name]
+		w.sample("ssl_assistant_platform_request_duration_seconds_sum", p.LatencySum, "platform", name)
+		w.sample("ssl_assistant_platform_request_duration_seconds_count", float64(p.Requests), "platform", name)
+	}
+	w.header("ssl_assistant_platform_request_errors_total", "counter", "Failed certificate platform requests.")
+	for _, name := range names {
+		w.sample("ssl_assistant_platform_request_errors_total", float64(s.Platforms[name].Errors), "platform", name)
+	}
+
+	_, err := out.Write(w.buf.Bytes())
+	return err
+}
+
+// Gather 收集证书与累计指标并输出
+func Gather(out io.Writer, collect Collector) error {
+	certs, err := collect()
+	if err != nil {
+		return fmt.Errorf("收集证书指标失败: %v", err)
+	}
+	s, err := Load()
+	if err != nil {
+		return err
+	}
+	return Render(out, certs, s)
+}
+
+// WriteTextfile 输出到 node_exporter textfile collector 目录下的 .prom 文件（原子替换）
+func WriteTextfile(path string, collect Collector) error {
+	var buf bytes.Buffer
+	if err := Gather(&buf, collect); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("写入指标文件失败: %v", err)
+	}
+	return nil
+}
+
+// Handler 返回 /metrics HTTP 处理器
+func Handler(collect Collector) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		if err := Gather(&buf, collect); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = rw.Write(buf.Bytes())
+	})
+}
@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// State 累计运行指标（跨进程持久化：update/cron/add 等各进程运行结束时合并写入状态文件，
+// metrics/serve 读取状态文件输出，保证计数器单调递增）
+type State struct {
+	Runs         uint64                   `json:"runs"`           // update 运行次数
+	Updated      uint64                   `json:"updated"`        // 续期部署成功的证书数
+	Failed       uint64                   `json:"failed"`         // 获取/部署失败的证书数
+	Skipped      uint64                   `json:"skipped"`        // 未到更新时间或平台证书未变化而跳过的证书数
+	Pending      uint64                   `json:"pending"`        // 进入申请中的证书数
+	LastRun      int64                    `json:"last_run"`       // 最近一次 update 完成时间（秒时间戳）
+	Reloads      uint64                   `json:"reloads"`        // 重载命令执行成功次数
+	ReloadFails  uint64                   `json:"reload_fails"`   // 重载命令执行失败次数
+	LastReload   int64                    `json:"last_reload"`    // 最近一次执行重载命令时间
+	LastReloadOK bool                     `json:"last_reload_ok"` // 最近一次重载是否成功
+	Platforms    map[string]*PlatformStat `json:"platforms"`      // 证书平台请求统计（certd/west）
+}
+
+// PlatformStat 证书平台请求统计
+type PlatformStat struct {
+	Requests   uint64  `json:"requests"`    // 请求次数
+	Errors     uint64  `json:"errors"`      // 失败次数
+	LatencySum float64 `json:"latency_sum"` // 累计耗时（秒）
+}
+
+// StatePath 状态文件路径（与数据库同目录 ~/.ssl_assistant；测试中可替换）
+var StatePath = func() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		homeDir = "."
+	}
+	return filepath.Join(homeDir, ".ssl_assistant", "metrics.json")
+}
+
+var (
+	mu    sync.Mutex
+	delta State // 本进程尚未写入状态文件的增量
+)
+
+// platform 返回增量中的平台统计（不存在则创建），调用方需持有 mu
+func (s *State) platform(name string) *PlatformStat {
+	if s.Platforms == nil {
+		s.Platforms = make(map[string]*PlatformStat)
+	}
+	p, ok := s.Platforms[name]
+	if !ok {
+		p = &PlatformStat{}
+		s.Platforms[name] = p
+	}
+	return p
+}
+
+// merge 将 d 的增量合并到 s（计数累加，时间戳与最近状态取较新值）
+func (s *State) merge(d State) {
+	s.Runs += d.Runs
+	s.Updated += d.Updated
+	s.Failed += d.Failed
+	s.Skipped += d.Skipped
+	s.Pending += d.Pending
+	if d.LastRun > s.LastRun {
+		s.LastRun = d.LastRun
+	}
+	s.Reloads += d.Reloads
+	s.ReloadFails += d.ReloadFails
+	if d.LastReload > 0 && d.LastReload >= s.LastReload {
+		s.LastReload = d.LastReload
+		s.LastReloadOK = d.LastReloadOK
+	}
+	for name, p := range d.Platforms {
+		sp := s.platform(name)
+		sp.Requests += p.Requests
+		sp.Errors += p.Errors
+		sp.LatencySum += p.LatencySum
+	}
+}
+
+// AddRun 记录一次 update 运行结果
+func AddRun(updated, failed, skipped, pending int) {
+	mu.Lock()
+	defer mu.Unlock()
+	delta.Runs++
+	delta.Updated += uint64(updated)
+	delta.Failed += uint64(failed)
+	delta.Skipped += uint64(skipped)
+	delta.Pending += uint64(pending)
+	delta.LastRun = time.Now().Unix()
+}
+
+// AddRenewed 记录 update 之外的续期部署成功（如守护进程跟进申请中证书签发）
+func AddRenewed(n int) {
+	mu.Lock()
+	defer mu.Unlock()
+	delta.Updated += uint64(n)
+}
+
+// ObserveReload 记录一次重载命令执行结果
+func ObserveReload(err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if err != nil {
+		delta.ReloadFails++
+	} else {
+		delta.Reloads++
+	}
+	delta.LastReload = time.Now().Unix()
+	delta.LastReloadOK = err == nil
+}
+
+// ObservePlatform 记录一次证书平台请求的耗时与结果
+func ObservePlatform(name string, d time.Duration, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	p := delta.platform(name)
+	p.Requests++
+	p.LatencySum += d.Seconds()
+	if err != nil {
+		p.Errors++
+	}
+}
+
+// readState 读取状态文件（不存在时返回空状态）
+func readState() (State, error) {
+	var s State
+	data, err := os.ReadFile(StatePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("读取指标状态文件失败: %v", err)
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return State{}, fmt.Errorf("解析指标状态文件失败: %v", err)
+	}
+	return s, nil
+}
+
+// Load 返回当前累计指标（状态文件 + 本进程未写入的增量）
+func Load() (State, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	s, err := readState()
+	s.merge(delta)
+	return s, err
+}
+
+// Save 将本进程的增量合并写入状态文件（写临时文件后 rename，避免读到半截文件）；无增量时不写入
+func Save() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if isZero(delta) {
+		return nil
+	}
+	s, err := readState()
+	if err != nil {
+		// 状态文件损坏：从零开始累计，不阻塞后续记录
+		s = State{}
+	}
+	s.merge(delta)
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(StatePath(), data, 0644); err != nil {
+		return fmt.Errorf("写入指标状态文件失败: %v", err)
+	}
+	delta = State{}
+	return nil
+}
+
+// isZero 判断增量是否为空
+func isZero(s State) bool {
+	return s.Runs == 0 && s.Updated == 0 && s.Failed == 0 && s.Skipped == 0 && s.Pending == 0 &&
+		s.Reloads == 0 && s.ReloadFails == 0 && len(s.Platforms) == 0
+}
+
+// writeFileAtomic 原子写入文件：同目录临时文件写入后 rename 覆盖（node_exporter textfile 亦要求如此）
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
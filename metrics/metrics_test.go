@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTempState 将状态文件指向临时目录并清空本进程增量
+func useTempState(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	old := StatePath
+	StatePath = func() string { return path }
+	mu.Lock()
+	delta = State{}
+	mu.Unlock()
+	t.Cleanup(func() { StatePath = old })
+	return path
+}
+
+// 增量写入状态文件后可跨进程累加，计数器单调递增
+func TestSaveAccumulates(t *testing.T) {
+	path := useTempState(t)
+	if err := Save(); err != nil {
+		t.Fatalf("无增量保存失败: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("无增量时不应写入状态文件")
+	}
+
+	AddRun(2, 1, 3, 0)
+	ObserveReload(nil)
+	ObservePlatform("certd", 500*time.Millisecond, nil)
+	if err := Save(); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	// 模拟另一次运行
+	AddRun(1, 0, 0, 1)
+	ObserveReload(errors.New("boom"))
+	ObservePlatform("certd", 1500*time.Millisecond, errors.New("timeout"))
+	if err := Save(); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+
+	s, err := Load()
+	if err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if s.Runs != 2 || s.Updated != 3 || s.Failed != 1 || s.Skipped != 3 || s.Pending != 1 {
+		t.Fatalf("运行计数累加错误: %+v", s)
+	}
+	if s.Reloads != 1 || s.ReloadFails != 1 || s.LastReloadOK {
+		t.Fatalf("重载统计错误: %+v", s)
+	}
+	p := s.Platforms["certd"]
+	if p == nil || p.Requests != 2 || p.Errors != 1 || p.LatencySum != 2 {
+		t.Fatalf("平台统计错误: %+v", p)
+	}
+}
+
+// 文本格式：HELP/TYPE、标签转义、按域名排序、平台 summary
+func TestRender(t *testing.T) {
+	var sb strings.Builder
+	certs := []CertInfo{
+		{Domain: "b.com", Source: "west", Status: "有效", Expire: 1800000000},
+		{Domain: "c.com", KeyType: "ecdsa", Source: "local", Status: "有效", Expire: 1800000000},
+		{Domain: "a.com", Source: "certd", Status: "有效", CertID: 7, Expire: 1700000000, FileExpire: 1690000000, LastRenew: 1600000000, LastError: `bad "x"`, LastErrorTime: 1650000000},
+	}
+	s := State{Runs: 1, Updated: 1, LastReload: 1, LastReloadOK: true, Platforms: map[string]*PlatformStat{"certd": {Requests: 2, LatencySum: 0.5}}}
+	if err := Render(&sb, certs, s); err != nil {
+		t.Fatalf("输出失败: %v", err)
+	}
+	out := sb.String()
+	for _, want := range []string{
+		"# TYPE ssl_assistant_certificate_expiry_timestamp_seconds gauge",
+		`ssl_assistant_certificate_info{domain="a.com",source="certd",status="有效",cert_id="7"} 1`,
+		`ssl_assistant_certificate_file_expiry_timestamp_seconds{domain="a.com",source="certd"} 1.69e+09`,
+		`ssl_assistant_certificate_fetch_error{domain="a.com",source="certd"} 1`,
+		`ssl_assistant_certificate_fetch_error{domain="b.com",source="west"} 0`,
+		`ssl_assistant_certificate_fetch_error{domain="c.com",key_type="ecdsa",source="local"} 0`,
+		`ssl_assistant_certificates_processed_total{result="updated"} 1`,
+		"ssl_assistant_reload_success 1",
+		`ssl_assistant_platform_request_duration_seconds_count{platform="certd"} 2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("输出缺少 %q\n%s", want, out)
+		}
+	}
+	if strings.Index(out, `domain="a.com"`) > strings.Index(out, `domain="b.com"`) {
+		t.Error("证书指标应按域名排序")
+	}
+	if strings.Contains(out, `ssl_assistant_certificate_file_expiry_timestamp_seconds{domain="b.com"`) {
+		t.Error("无本地文件的证书不应输出文件到期时间")
+	}
+	if got := escapeLabel("a\\b\"c\nd"); got != `a\\b\"c\nd` {
+		t.Errorf("标签转义错误: %s", got)
+	}
+}
+
+// textfile 原子写入与 HTTP 处理器
+func TestTextfileAndHandler(t *testing.T) {
+	useTempState(t)
+	collect := func() ([]CertInfo, error) {
+		return []CertInfo{{Domain: "a.com", Source: "local", Expire: 1700000000}}, nil
+	}
+	path := filepath.Join(t.TempDir(), "ssl_assistant.prom")
+	if err := WriteTextfile(path, collect); err != nil {
+		t.Fatalf("写入 textfile 失败: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(data), `domain="a.com"`) {
+		t.Fatalf("textfile 内容错误: %v\n%s", err, data)
+	}
+
+	rec := httptest.NewRecorder()
+	Handler(collect).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != 200 || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("HTTP 响应错误: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	rec = httptest.NewRecorder()
+	Handler(func() ([]CertInfo, error) { return nil, errors.New("db down") }).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != 500 {
+		t.Fatalf("收集失败应返回 500，实际 %d", rec.Code)
+	}
+}
@@ -0,0 +1,180 @@
+package nginxconf
+
+import (
+	"fmt"
+	"ssl_assistant/confedit"
+	"strings"
+)
+
+// ConfigureSSL 生成将 server 块的证书指向 certPath / keyPath 的行级修改：
+// 已有 ssl_certificate / ssl_certificate_key 时替换第一组，多余的（如双证书的第二组）删除；
+// 没有（或只继承了 http 块的证书）时在 server 块末尾插入。
+// 没有 ssl 监听时，把 listen 443 改为 listen 443 ssl，没有 443 监听则插入 listen 443 ssl（有 IPv6 监听时同时插入 [::]:443）。
+// 要修改的指令与其他内容共用一行，或 server 块的 } 不在单独一行时无法安全修改，返回错误
+func ConfigureSSL(site Site, certPath, keyPath string) ([]confedit.Edit, error) {
+	e := &editor{files: map[string]*confedit.File{}}
+	var edits []confedit.Edit
+	var tailLines []string
+
+	for _, item := range []struct {
+		name string
+		path string
+		dirs []*Directive
+	}{
+		{"ssl_certificate", certPath, site.Certificates},
+		{"ssl_certificate_key", keyPath, site.Keys},
+	} {
+		if site.Inherited || len(item.dirs) == 0 {
+			tailLines = append(tailLines, render(item.name, item.path))
+			continue
+		}
+		for i, d := range item.dirs {
+			var lines []string
+			if i == 0 {
+				lines = []string{render(item.name, item.path)}
+			}
+			edit, err := e.replace(d, lines...)
+			if err != nil {
+				return nil, err
+			}
+			edits = append(edits, edit)
+		}
+	}
+
+	if len(site.SSLListens()) == 0 {
+		var https []*Directive
+		ipv6 := false
+		for _, l := range site.Listens {
+			if addr := l.Arg(0); addr == "443" || strings.HasSuffix(addr, ":443") {
+				https = append(https, l)
+			}
+			ipv6 = ipv6 || strings.HasPrefix(l.Arg(0), "[")
+		}
+		listens := []string{render("listen", "443", "ssl")}
+		if ipv6 {
+			listens = append(listens, render("listen", "[::]:443", "ssl"))
+		}
+		switch {
+		case len(https) > 0:
+			for _, l := range https {
+				args := append([]string{l.Args[0], "ssl"}, l.Args[1:]...)
+				edit, err := e.replace(l, render("listen", args...))
+				if err != nil {
+					return nil, err
+				}
+				edits = append(edits, edit)
+			}
+		case len(site.Listens) > 0:
+			last := site.Listens[len(site.Listens)-1]
+			edit, err := e.insertAfter(last, listens...)
+			if err != nil {
+				return nil, err
+			}
+			edits = append(edits, edit)
+		default:
+			tailLines = append(listens, tailLines...)
+		}
+	}
+
+	if len(tailLines) > 0 {
+		edit, err := e.insertAtEnd(site.Server, tailLines...)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, edit)
+	}
+	return edits, nil
+}
+
+// editor 缓存读取的配置文件
+type editor struct {
+	files map[string]*confedit.File
+}
+
+func (e *editor) file(name string) (*confedit.File, error) {
+	if f, ok := e.files[name]; ok {
+		return f, nil
+	}
+	f, err := confedit.Read(name)
+	if err != nil {
+		return nil, err
+	}
+	e.files[name] = f
+	return f, nil
+}
+
+// ownLines 检查指令独占所在的行（行首是指令名，行内只有这一条指令），返回所在文件
+func (e *editor) ownLines(d *Directive) (*confedit.File, error) {
+	f, err := e.file(d.File)
+	if err != nil {
+		return nil, err
+	}
+	var parts []string
+	for n := d.Line; n <= d.EndLine; n++ {
+		parts = append(parts, strings.TrimSpace(confedit.StripComment(f.Line(n))))
+	}
+	text := strings.Join(parts, " ")
+	if !strings.HasPrefix(text, d.Name) || !strings.HasSuffix(text, ";") ||
+		strings.Count(text, ";") != 1 || strings.ContainsAny(text, "{}") {
+		return nil, fmt.Errorf("%s 的 %s 指令与其他内容在同一行，无法自动修改，请手动配置", d.Pos(), d.Name)
+	}
+	return f, nil
+}
+
+// replace 用 lines 替换指令所在的行（保留原缩进；lines 为空即删除）
+func (e *editor) replace(d *Directive, lines ...string) (confedit.Edit, error) {
+	f, err := e.ownLines(d)
+	if err != nil {
+		return confedit.Edit{}, err
+	}
+	return confedit.Replace(d.File, d.Line, d.EndLine, indent(confedit.Indent(f.Line(d.Line)), lines)...), nil
+}
+
+// insertAfter 在指令之后插入，缩进与该指令相同
+func (e *editor) insertAfter(d *Directive, lines ...string) (confedit.Edit, error) {
+	f, err := e.ownLines(d)
+	if err != nil {
+		return confedit.Edit{}, err
+	}
+	return confedit.Insert(d.File, d.EndLine+1, indent(confedit.Indent(f.Line(d.Line)), lines)...), nil
+}
+
+// insertAtEnd 在块的 } 之前插入，缩进与块内第一条指令相同（没有时在 } 的缩进上加 4 个空格）
+func (e *editor) insertAtEnd(block *Directive, lines ...string) (confedit.Edit, error) {
+	f, err := e.file(block.File)
+	if err != nil {
+		return confedit.Edit{}, err
+	}
+	closing := f.Line(block.EndLine)
+	if strings.TrimSpace(confedit.StripComment(closing)) != "}" {
+		return confedit.Edit{}, fmt.Errorf("%s 的 %s 块的 } 不在单独一行，无法自动修改，请手动配置", block.Pos(), block.Name)
+	}
+	prefix := confedit.Indent(closing) + "    "
+	for _, d := range block.Block {
+		if d.File == block.File && d.Line > block.Line {
+			prefix = confedit.Indent(f.Line(d.Line))
+			break
+		}
+	}
+	return confedit.Insert(block.File, block.EndLine, indent(prefix, lines)...), nil
+}
+
+func indent(prefix string, lines []string) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = prefix + l
+	}
+	return out
+}
+
+// render 生成一行指令（参数含空白或特殊字符时加双引号）
+func render(name string, args ...string) string {
+	parts := []string{name}
+	for _, a := range args {
+		if a == "" || strings.ContainsAny(a, " \t\r\n;{}#\"'\\") {
+			a = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(a) + `"`
+		}
+		parts = append(parts, a)
+	}
+	return strings.Join(parts, " ") + ";"
+}
@@ -0,0 +1,132 @@
+package nginxconf
+
+import (
+	"os"
+	"path/filepath"
+	"ssl_assistant/confedit"
+	"strings"
+	"testing"
+)
+
+// applyEdits 将修改写回文件
+func applyEdits(t *testing.T, edits []confedit.Edit) {
+	t.Helper()
+	files, err := confedit.Render(edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if err := os.WriteFile(name, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// 未启用证书的 server 插入证书与 443 监听；已有证书的替换路径并删除多余的一组；指令同行时报错
+func TestConfigureSSL(t *testing.T) {
+	dir := t.TempDir()
+	main := writeFile(t, dir, "nginx.conf", `http {
+    ssl_certificate /etc/ssl/default.pem;
+    ssl_certificate_key /etc/ssl/default.key;
+    include sites/*.conf;
+}
+`)
+	writeFile(t, dir, "sites/plain.conf", `server {
+	listen 80;
+	listen [::]:80;
+	server_name plain.com; # 站点
+	root /var/www;
+}
+server {
+    listen 443 default_server;
+    server_name half.com;
+}
+server {
+    server_name bare.com;
+}
+`)
+	writeFile(t, dir, "sites/own.conf", `server {
+    listen 443 ssl;
+    server_name own.com;
+    ssl_certificate "/etc/ssl/own rsa.pem";
+    ssl_certificate_key /etc/ssl/own-rsa.key;
+    ssl_certificate /etc/ssl/own-ecc.pem;
+    ssl_certificate_key
+        /etc/ssl/own-ecc.key;
+}
+server {
+    listen 443 ssl;
+    server_name inline.com;
+    ssl_certificate /a.pem; ssl_certificate_key /a.key;
+}
+`)
+	cfg, err := Parse(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := map[string]Site{}
+	for _, s := range Servers(cfg) {
+		byName[s.ServerNames[0]] = s
+	}
+	if len(byName) != 5 || len(Sites(cfg)) != 2 {
+		t.Fatalf("应列出 5 个 server（其中 2 个启用证书）: %d %d", len(byName), len(Sites(cfg)))
+	}
+	if _, err := ConfigureSSL(byName["inline.com"], "/m/c.pem", "/m/k.pem"); err == nil || !strings.Contains(err.Error(), "同一行") {
+		t.Fatalf("指令同行时应报错: %v", err)
+	}
+
+	var edits []confedit.Edit
+	for _, name := range []string{"plain.com", "half.com", "bare.com", "own.com"} {
+		e, err := ConfigureSSL(byName[name], "/m/"+name+"/fullchain.pem", "/m/"+name+"/privkey.pem")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		edits = append(edits, e...)
+	}
+	applyEdits(t, edits)
+
+	data, _ := os.ReadFile(filepath.Join(dir, "sites/plain.conf"))
+	want := `server {
+	listen 80;
+	listen [::]:80;
+	listen 443 ssl;
+	listen [::]:443 ssl;
+	server_name plain.com; # 站点
+	root /var/www;
+	ssl_certificate /m/plain.com/fullchain.pem;
+	ssl_certificate_key /m/plain.com/privkey.pem;
+}
+server {
+    listen 443 ssl default_server;
+    server_name half.com;
+    ssl_certificate /m/half.com/fullchain.pem;
+    ssl_certificate_key /m/half.com/privkey.pem;
+}
+server {
+    server_name bare.com;
+    listen 443 ssl;
+    ssl_certificate /m/bare.com/fullchain.pem;
+    ssl_certificate_key /m/bare.com/privkey.pem;
+}
+`
+	if string(data) != want {
+		t.Fatalf("修改结果错误:\n%s", data)
+	}
+
+	cfg, err = Parse(main)
+	if err != nil {
+		t.Fatalf("修改后解析失败: %v", err)
+	}
+	sites := Sites(cfg)
+	if len(sites) != 5 {
+		t.Fatalf("修改后应有 5 个启用证书的站点: %d", len(sites))
+	}
+	for _, s := range sites {
+		if s.ServerNames[0] == "own.com" && (len(s.Pairs()) != 1 || s.CertPath() != "/m/own.com/fullchain.pem" || s.KeyPath() != "/m/own.com/privkey.pem") {
+			t.Fatalf("已有证书应替换为一组: %+v", s.Pairs())
+		}
+		if s.Inherited {
+			t.Fatalf("%s 应使用自己的证书", s.ServerNames[0])
+		}
+	}
+}
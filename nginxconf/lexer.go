@@ -0,0 +1,170 @@
+package nginxconf
+
+import (
+	"errors"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF       tokenKind = iota
+	tokWord                // 指令名或参数（引号内容已去引号）
+	tokSemicolon           // ;
+	tokOpen                // {
+	tokClose               // }
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+// lexer Nginx 配置分词器：# 注释（仅在词首）、单/双引号（支持 \ 转义，引号内的 ; { } # 为普通字符）、
+// 变量 ${name} 中的花括号不作为块边界
+type lexer struct {
+	data []byte
+	pos  int
+	line int
+}
+
+func newLexer(data []byte) *lexer {
+	return &lexer{data: data, line: 1}
+}
+
+func (lx *lexer) peek() byte {
+	if lx.pos < len(lx.data) {
+		return lx.data[lx.pos]
+	}
+	return 0
+}
+
+// advance 前进一个字节并维护行号
+func (lx *lexer) advance() byte {
+	c := lx.data[lx.pos]
+	lx.pos++
+	if c == '\n' {
+		lx.line++
+	}
+	return c
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// next 返回下一个词法单元
+func (lx *lexer) next() (token, error) {
+	// 跳过空白与注释
+	for lx.pos < len(lx.data) {
+		c := lx.peek()
+		if isSpace(c) {
+			lx.advance()
+			continue
+		}
+		if c == '#' {
+			for lx.pos < len(lx.data) && lx.peek() != '\n' {
+				lx.advance()
+			}
+			continue
+		}
+		break
+	}
+	if lx.pos >= len(lx.data) {
+		return token{kind: tokEOF, line: lx.line}, nil
+	}
+
+	line := lx.line
+	switch c := lx.peek(); c {
+	case ';':
+		lx.advance()
+		return token{kind: tokSemicolon, line: line}, nil
+	case '{':
+		lx.advance()
+		return token{kind: tokOpen, line: line}, nil
+	case '}':
+		lx.advance()
+		return token{kind: tokClose, line: line}, nil
+	case '"', '\'':
+		text, err := lx.quoted(c)
+		return token{kind: tokWord, text: text, line: line}, err
+	}
+
+	var sb strings.Builder
+	for lx.pos < len(lx.data) {
+		c := lx.peek()
+		if isSpace(c) || c == ';' || c == '{' || c == '}' {
+			break
+		}
+		if c == '$' && lx.pos+1 < len(lx.data) && lx.data[lx.pos+1] == '{' {
+			// ${var}：花括号属于变量名
+			for lx.pos < len(lx.data) {
+				ch := lx.advance()
+				sb.WriteByte(ch)
+				if ch == '}' {
+					break
+				}
+			}
+			continue
+		}
+		if c == '\\' && lx.pos+1 < len(lx.data) {
+			sb.WriteByte(lx.advance())
+		}
+		sb.WriteByte(lx.advance())
+	}
+	return token{kind: tokWord, text: sb.String(), line: line}, nil
+}
+
+// quoted 读取引号字符串（返回去引号、处理 \" \' \\ 转义后的内容）
+func (lx *lexer) quoted(q byte) (string, error) {
+	lx.advance() // 开引号
+	var sb strings.Builder
+	for lx.pos < len(lx.data) {
+		c := lx.advance()
+		if c == '\\' && lx.pos < len(lx.data) {
+			n := lx.peek()
+			if n == q || n == '\\' {
+				sb.WriteByte(lx.advance())
+				continue
+			}
+			sb.WriteByte(c)
+			continue
+		}
+		if c == q {
+			return sb.String(), nil
+		}
+		sb.WriteByte(c)
+	}
+	return "", errors.New("引号未闭合")
+}
+
+// skipRawBlock 跳过原样代码块（如 content_by_lua_block），按花括号配对，忽略字符串与注释中的花括号
+func (lx *lexer) skipRawBlock() error {
+	depth := 1
+	for lx.pos < len(lx.data) {
+		c := lx.advance()
+		switch c {
+		case '"', '\'':
+			lx.pos--
+			if _, err := lx.quoted(c); err != nil {
+				return err
+			}
+		case '-':
+			// Lua 注释 -- 至行尾
+			if lx.peek() == '-' {
+				for lx.pos < len(lx.data) && lx.peek() != '\n' {
+					lx.advance()
+				}
+			}
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return nil
+			}
+		}
+	}
+	return errors.New("代码块缺少 }")
+}
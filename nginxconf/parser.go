@@ -0,0 +1,277 @@
+// Package nginxconf 解析 Nginx 配置：分词（注释、引号、${var}）、块结构、include 展开（相对 nginx 前缀目录、
+// 通配符、循环检测），每条指令记录所在文件与行号。
+package nginxconf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Directive 一条配置指令（块指令的子指令在 Block 中；include 已展开为被包含文件的指令）
+type Directive struct {
+	Name     string
+	Args     []string
+	File     string
+	Line     int
+	EndLine  int          // 结束行（; 或块的 } 所在行）
+	HasBlock bool         // 是否为块指令（server { ... }）
+	Block    []*Directive // 块内指令
+}
+
+// Pos 返回指令位置（file:line）
+func (d *Directive) Pos() string {
+	return fmt.Sprintf("%s:%d", d.File, d.Line)
+}
+
+// Arg 返回第 i 个参数（不存在时返回空串）
+func (d *Directive) Arg(i int) string {
+	if i < len(d.Args) {
+		return d.Args[i]
+	}
+	return ""
+}
+
+// Children 返回块内指定名称的直接子指令
+func (d *Directive) Children(name string) []*Directive {
+	return find(d.Block, name)
+}
+
+// find 返回指令列表中指定名称的指令
+func find(dirs []*Directive, name string) []*Directive {
+	var out []*Directive
+	for _, d := range dirs {
+		if d.Name == name {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// ParseError 语法或 include 错误（带文件与行号）
+type ParseError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// Config 解析结果
+type Config struct {
+	Directives []*Directive
+	Files      []string // 已解析的文件（主文件与全部被包含文件，按解析顺序）
+	Prefix     string   // include 相对路径的基准目录
+	Errors     []error  // 非致命错误（include 文件缺失、循环包含、被包含文件语法错误等）
+}
+
+// Parse 解析配置文件，include 相对路径以自动探测的 nginx 前缀目录为基准（见 DetectPrefix）
+func Parse(path string) (*Config, error) {
+	return ParseWithPrefix(path, DetectPrefix(path))
+}
+
+// ParseWithPrefix 解析配置文件，include 相对路径以 prefix 为基准。
+// 主文件读取失败或语法错误时返回错误；被包含文件的问题记录在 Config.Errors 中，不中断解析。
+func ParseWithPrefix(path, prefix string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseData(data, path, prefix)
+}
+
+// ParseBytes 解析配置内容（name 用于错误与指令位置显示）
+func ParseBytes(data []byte, name, prefix string) (*Config, error) {
+	return parseData(data, name, prefix)
+}
+
+func parseData(data []byte, name, prefix string) (*Config, error) {
+	if prefix == "" {
+		prefix = filepath.Dir(name)
+	}
+	p := &parser{cfg: &Config{Prefix: prefix}}
+	dirs, err := p.parseFile(data, name, []string{cleanPath(name)})
+	if err != nil {
+		return nil, err
+	}
+	p.cfg.Directives = dirs
+	return p.cfg, nil
+}
+
+// DetectPrefix 探测 nginx 前缀目录（nginx.conf 所在目录）：自文件所在目录向上最多三级查找 nginx.conf，
+// 找不到时使用文件所在目录
+func DetectPrefix(path string) string {
+	dir := filepath.Dir(path)
+	for i, d := 0, dir; i < 3; i++ {
+		if _, err := os.Stat(filepath.Join(d, "nginx.conf")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			break
+		}
+		d = parent
+	}
+	return dir
+}
+
+// cleanPath 规范化路径（循环检测用）
+func cleanPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+type parser struct {
+	cfg *Config
+}
+
+// parseFile 解析单个文件的内容；stack 为当前 include 链（用于循环检测）
+func (p *parser) parseFile(data []byte, name string, stack []string) ([]*Directive, error) {
+	p.cfg.Files = append(p.cfg.Files, name)
+	lx := newLexer(data)
+	dirs, err := p.parseBlock(lx, name, stack, false)
+	if err != nil {
+		return nil, err
+	}
+	return dirs, nil
+}
+
+// parseBlock 解析指令序列直至 }（inBlock）或文件结束
+func (p *parser) parseBlock(lx *lexer, name string, stack []string, inBlock bool) ([]*Directive, error) {
+	var dirs []*Directive
+	var words []token
+	for {
+		tok, err := lx.next()
+		if err != nil {
+			return nil, &ParseError{File: name, Line: lx.line, Msg: err.Error()}
+		}
+		switch tok.kind {
+		case tokEOF:
+			if len(words) > 0 {
+				return nil, &ParseError{File: name, Line: words[0].line, Msg: fmt.Sprintf("指令 %q 缺少结尾的 ;", words[0].text)}
+			}
+			if inBlock {
+				return nil, &ParseError{File: name, Line: lx.line, Msg: "缺少 }"}
+			}
+			return dirs, nil
+		case tokWord:
+			words = append(words, tok)
+		case tokSemicolon:
+			if len(words) == 0 {
+				return nil, &ParseError{File: name, Line: tok.line, Msg: "意外的 ;"}
+			}
+			d := newDirective(words, name)
+			d.EndLine = tok.line
+			words = nil
+			if d.Name == "include" {
+				dirs = append(dirs, p.include(d, stack)...)
+				continue
+			}
+			dirs = append(dirs, d)
+		case tokOpen:
+			if len(words) == 0 {
+				return nil, &ParseError{File: name, Line: tok.line, Msg: "意外的 {"}
+			}
+			d := newDirective(words, name)
+			d.HasBlock = true
+			words = nil
+			if strings.HasSuffix(d.Name, "_by_lua_block") {
+				// Lua 代码块不是 nginx 语法，原样跳过
+				if err := lx.skipRawBlock(); err != nil {
+					return nil, &ParseError{File: name, Line: d.Line, Msg: err.Error()}
+				}
+			} else {
+				block, err := p.parseBlock(lx, name, stack, true)
+				if err != nil {
+					return nil, err
+				}
+				d.Block = block
+			}
+			// 块已读到 }，当前行即 } 所在行
+			d.EndLine = lx.line
+			dirs = append(dirs, d)
+		case tokClose:
+			if len(words) > 0 {
+				return nil, &ParseError{File: name, Line: words[0].line, Msg: fmt.Sprintf("指令 %q 缺少结尾的 ;", words[0].text)}
+			}
+			if !inBlock {
+				return nil, &ParseError{File: name, Line: tok.line, Msg: "意外的 }"}
+			}
+			return dirs, nil
+		}
+	}
+}
+
+func newDirective(words []token, file string) *Directive {
+	d := &Directive{Name: words[0].text, File: file, Line: words[0].line}
+	for _, w := range words[1:] {
+		d.Args = append(d.Args, w.text)
+	}
+	return d
+}
+
+// include 展开 include 指令：相对路径以前缀目录为基准，支持通配符（按文件名排序，无匹配不报错），
+// 非通配符文件缺失、循环包含与被包含文件语法错误记录到 Errors 并跳过该文件
+func (p *parser) include(d *Directive, stack []string) []*Directive {
+	pattern := d.Arg(0)
+	if pattern == "" {
+		p.cfg.Errors = append(p.cfg.Errors, &ParseError{File: d.File, Line: d.Line, Msg: "include 缺少文件参数"})
+		return nil
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(p.cfg.Prefix, pattern)
+	}
+	var files []string
+	if strings.ContainsAny(pattern, "*?[") {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			p.cfg.Errors = append(p.cfg.Errors, &ParseError{File: d.File, Line: d.Line, Msg: fmt.Sprintf("include 通配符 %s 无效: %v", pattern, err)})
+			return nil
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			if info, err := os.Stat(m); err == nil && !info.IsDir() {
+				files = append(files, m)
+			}
+		}
+	} else {
+		files = []string{pattern}
+	}
+
+	var out []*Directive
+	for _, f := range files {
+		abs := cleanPath(f)
+		if i := indexOf(stack, abs); i >= 0 {
+			chain := append(append([]string{}, stack[i:]...), abs)
+			p.cfg.Errors = append(p.cfg.Errors, &ParseError{File: d.File, Line: d.Line, Msg: "include 循环: " + strings.Join(chain, " -> ")})
+			continue
+		}
+		data, err := os.ReadFile(f)
+		if err != nil {
+			p.cfg.Errors = append(p.cfg.Errors, &ParseError{File: d.File, Line: d.Line, Msg: fmt.Sprintf("include %s 失败: %v", f, err)})
+			continue
+		}
+		dirs, err := p.parseFile(data, f, append(append([]string{}, stack...), abs))
+		if err != nil {
+			p.cfg.Errors = append(p.cfg.Errors, err)
+			continue
+		}
+		out = append(out, dirs...)
+	}
+	return out
+}
+
+func indexOf(list []string, s string) int {
+	for i, x := range list {
+		if x == s {
+			return i
+		}
+	}
+	return -1
+}
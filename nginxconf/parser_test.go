@@ -0,0 +1,182 @@
+package nginxconf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFile 在 dir 下写入文件（自动创建目录）
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// 分词：注释、引号内的 ; { } #、${var}、转义、map/if 嵌套
+func TestLexerAndBlocks(t *testing.T) {
+	conf := `# 顶部注释
+http {
+    map $http_upgrade $connection_upgrade {
+        default upgrade;
+        ''      close;
+    }
+    server {
+        server_name a.com "b.com"; # 行尾注释 ssl_certificate /nope.pem;
+        add_header X-Test "a;b{c}#d";
+        set $v "say \"hi\"";
+        if ($request_uri ~* "^/x;y") {
+            return 403;
+        }
+        location ~ \.php$ {
+            fastcgi_param SCRIPT ${document_root}$fastcgi_script_name;
+        }
+        content_by_lua_block {
+            ngx.say("}") -- }
+            if x then y() end
+        }
+    }
+}
+`
+	cfg, err := ParseBytes([]byte(conf), "nginx.conf", "")
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	http := cfg.Directives[0]
+	mp := http.Children("map")[0]
+	if !mp.HasBlock || len(mp.Block) != 2 || mp.Block[1].Name != "" || mp.Block[1].Arg(0) != "close" {
+		t.Fatalf("map 块解析错误: %+v", mp.Block)
+	}
+	server := http.Children("server")[0]
+	if got := server.Children("server_name")[0].Args; !reflect.DeepEqual(got, []string{"a.com", "b.com"}) {
+		t.Fatalf("server_name 解析错误: %v", got)
+	}
+	if len(server.Children("ssl_certificate")) != 0 {
+		t.Fatal("注释中的指令不应被解析")
+	}
+	hdr := server.Children("add_header")[0]
+	if hdr.Arg(1) != "a;b{c}#d" || hdr.Line != 9 {
+		t.Fatalf("引号内特殊字符解析错误: %+v", hdr)
+	}
+	if got := server.Children("set")[0].Arg(1); got != `say "hi"` {
+		t.Fatalf("引号转义错误: %s", got)
+	}
+	loc := server.Children("location")[0]
+	if loc.Arg(1) != `\.php$` || loc.Block[0].Arg(1) != "${document_root}$fastcgi_script_name" {
+		t.Fatalf("location/变量解析错误: %+v %+v", loc.Args, loc.Block[0].Args)
+	}
+	if lua := server.Children("content_by_lua_block"); len(lua) != 1 || len(lua[0].Block) != 0 {
+		t.Fatalf("Lua 代码块应原样跳过: %+v", lua)
+	}
+}
+
+// 语法错误带文件与行号
+func TestSyntaxErrors(t *testing.T) {
+	cases := map[string]string{
+		"server {\n    listen 443\n}\n": "a.conf:2",
+		"server {\n    listen 443;\n":   "a.conf:3",
+		"}\n":                           "a.conf:1",
+		"add_header X \"abc;\n":         "引号未闭合",
+	}
+	for conf, want := range cases {
+		_, err := ParseBytes([]byte(conf), "a.conf", "")
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q 应报错 %q，实际 %v", conf, want, err)
+		}
+	}
+}
+
+// include：相对前缀目录、通配符排序展开、被包含指令保留各自 file:line、缺失文件与循环包含记录错误
+func TestInclude(t *testing.T) {
+	prefix := t.TempDir()
+	main := writeFile(t, prefix, "nginx.conf", "http {\n    include conf.d/*.conf;\n    include missing.conf;\n    include conf.d/none-*.conf;\n}\n")
+	writeFile(t, prefix, "conf.d/b.conf", "server {\n    server_name b.com;\n}\n")
+	writeFile(t, prefix, "conf.d/a.conf", "\nserver {\n    server_name a.com;\n    include loop.inc;\n}\n")
+	writeFile(t, prefix, "loop.inc", "include conf.d/a.conf;\nssl_protocols TLSv1.2;\n")
+
+	cfg, err := Parse(main)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if cfg.Prefix != prefix {
+		t.Fatalf("前缀目录探测错误: %s", cfg.Prefix)
+	}
+	servers := cfg.Directives[0].Children("server")
+	if len(servers) != 2 || servers[0].Children("server_name")[0].Arg(0) != "a.com" {
+		t.Fatalf("通配符 include 应按文件名排序展开: %+v", servers)
+	}
+	if pos := servers[0].Pos(); pos != filepath.Join(prefix, "conf.d/a.conf")+":2" {
+		t.Fatalf("被包含指令位置错误: %s", pos)
+	}
+	if p := servers[0].Children("ssl_protocols"); len(p) != 1 || p[0].Pos() != filepath.Join(prefix, "loop.inc")+":2" {
+		t.Fatalf("嵌套 include 未展开: %+v", p)
+	}
+	var msgs []string
+	for _, e := range cfg.Errors {
+		msgs = append(msgs, e.Error())
+	}
+	joined := strings.Join(msgs, "\n")
+	if len(cfg.Errors) != 2 || !strings.Contains(joined, "nginx.conf:3: include") || !strings.Contains(joined, "include 循环") {
+		t.Fatalf("缺失文件与循环包含应记录错误:\n%s", joined)
+	}
+}
+
+// 站点发现：server 级证书、http 块继承证书（需 ssl 监听）、忽略 _ 与正则 server_name
+func TestSites(t *testing.T) {
+	conf := `http {
+    ssl_certificate     /etc/ssl/default.pem;
+    ssl_certificate_key /etc/ssl/default.key;
+    server {
+        listen 443 ssl;
+        server_name inherit.com www.inherit.com;
+    }
+    server {
+        listen 80;
+        server_name plain.com;
+    }
+    server {
+        listen 443 ssl;
+        server_name own.com _ ~^(.+)\.own\.com$;
+        ssl_certificate /etc/ssl/own-rsa.pem;
+        ssl_certificate_key /etc/ssl/own-rsa.key;
+        ssl_certificate /etc/ssl/own-ecc.pem;
+        ssl_certificate_key /etc/ssl/own-ecc.key;
+    }
+}
+stream {
+    server {
+        listen 8443 ssl;
+        ssl_certificate /etc/ssl/stream.pem;
+        ssl_certificate_key /etc/ssl/stream.key;
+    }
+}
+`
+	cfg, err := ParseBytes([]byte(conf), "nginx.conf", "")
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	sites := Sites(cfg)
+	if len(sites) != 2 {
+		t.Fatalf("应发现 2 个站点，实际 %d", len(sites))
+	}
+	if !sites[0].Inherited || sites[0].CertPath() != "/etc/ssl/default.pem" || !reflect.DeepEqual(sites[0].ServerNames, []string{"inherit.com", "www.inherit.com"}) {
+		t.Fatalf("http 块证书继承错误: %+v", sites[0])
+	}
+	if sites[1].Inherited || len(sites[1].Certificates) != 2 || sites[1].KeyPath() != "/etc/ssl/own-rsa.key" || !reflect.DeepEqual(sites[1].ServerNames, []string{"own.com"}) {
+		t.Fatalf("server 级证书解析错误: %+v", sites[1])
+	}
+	if p := sites[1].Pairs(); len(p) != 2 || p[1].CertPath() != "/etc/ssl/own-ecc.pem" || p[1].KeyPath() != "/etc/ssl/own-ecc.key" {
+		t.Fatalf("双证书配对错误: %+v", p)
+	}
+	if l := sites[1].SSLListens(); len(l) != 1 || l[0].Arg(0) != "443" {
+		t.Fatalf("ssl 监听解析错误: %+v", l)
+	}
+}
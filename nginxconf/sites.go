@@ -0,0 +1,137 @@
+package nginxconf
+
+import "strings"
+
+// Site 一个启用证书的 server 块
+type Site struct {
+	Server       *Directive   // server 指令
+	ServerNames  []string     // server_name 全部域名（忽略 _ 与正则名）
+	Certificates []*Directive // ssl_certificate（可多条，如 RSA + ECDSA 双证书）
+	Keys         []*Directive // ssl_certificate_key（与 Certificates 按顺序对应）
+	Listens      []*Directive // listen 指令
+	SSLOn        bool         // 旧写法 ssl on;
+	Inherited    bool         // 证书继承自 http 块
+}
+
+// CertPath 返回第一条 ssl_certificate 的路径
+func (s Site) CertPath() string {
+	if len(s.Certificates) == 0 {
+		return ""
+	}
+	return s.Certificates[0].Arg(0)
+}
+
+// KeyPath 返回第一条 ssl_certificate_key 的路径
+func (s Site) KeyPath() string {
+	if len(s.Keys) == 0 {
+		return ""
+	}
+	return s.Keys[0].Arg(0)
+}
+
+// Pair 一组证书/私钥（ssl_certificate 与 ssl_certificate_key 按出现顺序配对）
+type Pair struct {
+	Cert *Directive
+	Key  *Directive
+}
+
+// CertPath 证书路径
+func (p Pair) CertPath() string { return p.Cert.Arg(0) }
+
+// KeyPath 私钥路径
+func (p Pair) KeyPath() string { return p.Key.Arg(0) }
+
+// Pairs 返回全部证书/私钥对（RSA + ECDSA 双证书时为两组；数量不等时多出的指令忽略）
+func (s Site) Pairs() []Pair {
+	var pairs []Pair
+	for i := 0; i < len(s.Certificates) && i < len(s.Keys); i++ {
+		pairs = append(pairs, Pair{Cert: s.Certificates[i], Key: s.Keys[i]})
+	}
+	return pairs
+}
+
+// SSLListens 返回启用 ssl 的 listen 指令（ssl on; 时为全部 listen）
+func (s Site) SSLListens() []*Directive {
+	var out []*Directive
+	for _, l := range s.Listens {
+		if s.SSLOn || (len(l.Args) > 1 && containsArg(l.Args[1:], "ssl")) {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// Sites 返回配置中启用证书的 server 块：
+// 支持 http { server { } } 与站点片段文件（顶层直接是 server 块）；
+// server 未声明 ssl_certificate 时继承 http 块的证书（此时要求 server 启用了 ssl 监听）。
+// stream 等非 http 上下文的 server 不在此列。
+func Sites(cfg *Config) []Site {
+	var sites []Site
+	eachServer(cfg, func(site Site, ok bool) {
+		if ok {
+			sites = append(sites, site)
+		}
+	})
+	return sites
+}
+
+// Servers 返回配置中全部有域名的 http server 块（含未启用证书的，供自动配置证书使用）
+func Servers(cfg *Config) []Site {
+	var sites []Site
+	eachServer(cfg, func(site Site, _ bool) {
+		if len(site.ServerNames) > 0 {
+			sites = append(sites, site)
+		}
+	})
+	return sites
+}
+
+// eachServer 遍历 http 上下文的 server 块，ok 表示是否启用了证书
+func eachServer(cfg *Config, fn func(site Site, ok bool)) {
+	collect := func(dirs []*Directive, certs, keys []*Directive) {
+		for _, d := range find(dirs, "server") {
+			fn(buildSite(d, certs, keys))
+		}
+	}
+	collect(cfg.Directives, nil, nil)
+	for _, http := range find(cfg.Directives, "http") {
+		collect(http.Block, http.Children("ssl_certificate"), http.Children("ssl_certificate_key"))
+	}
+}
+
+func buildSite(server *Directive, httpCerts, httpKeys []*Directive) (Site, bool) {
+	site := Site{
+		Server:       server,
+		Certificates: server.Children("ssl_certificate"),
+		Keys:         server.Children("ssl_certificate_key"),
+		Listens:      server.Children("listen"),
+	}
+	for _, sn := range server.Children("server_name") {
+		for _, name := range sn.Args {
+			if name == "" || name == "_" || strings.HasPrefix(name, "~") {
+				continue
+			}
+			site.ServerNames = append(site.ServerNames, name)
+		}
+	}
+	for _, s := range server.Children("ssl") {
+		if s.Arg(0) == "on" {
+			site.SSLOn = true
+		}
+	}
+	// 未启用 ssl 监听的 server 不使用 http 块的证书
+	if len(site.Certificates) == 0 && len(httpCerts) > 0 && len(site.SSLListens()) > 0 {
+		site.Certificates, site.Keys, site.Inherited = httpCerts, httpKeys, true
+	}
+	ok := len(site.ServerNames) > 0 && len(site.Certificates) > 0 && len(site.Keys) > 0
+	return site, ok
+}
+
+func containsArg(args []string, s string) bool {
+	for _, a := range args {
+		if a == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Email SMTP 邮件通知。
+// TLS 取值：ssl（隐式 TLS，常见端口 465）、starttls（明文连接后升级，常见端口 587）、
+// none（不加密，仅限内网中继）；留空时端口 465 按 ssl，其余端口服务端支持则 STARTTLS。
+type Email struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	To       []string
+	TLS      string
+	filter
+}
+
+// smtpTimeout SMTP 连接超时
+var smtpTimeout = 15 * time.Second
+
+func (e *Email) Name() string { return "email" }
+
+func (e *Email) Send(title string, events []Event) error {
+	port := e.Port
+	if port == "" {
+		port = "25"
+	}
+	addr := net.JoinHostPort(e.Host, port)
+	from := e.From
+	if from == "" {
+		from = e.Username
+	}
+
+	mode := strings.ToLower(e.TLS)
+	if mode == "" && port == "465" {
+		mode = "ssl"
+	}
+	tlsConfig := &tls.Config{ServerName: e.Host}
+
+	var conn net.Conn
+	var err error
+	if mode == "ssl" {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: smtpTimeout}, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, smtpTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("连接 SMTP 服务器失败: %v", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(2 * smtpTimeout))
+
+	client, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP 握手失败: %v", err)
+	}
+	defer client.Close()
+
+	if mode != "ssl" && mode != "none" {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("STARTTLS 失败: %v", err)
+			}
+		} else if mode == "starttls" {
+			return fmt.Errorf("SMTP 服务器不支持 STARTTLS")
+		}
+	}
+	if e.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.Username, e.Password, e.Host)); err != nil {
+			return fmt.Errorf("SMTP 认证失败: %v", err)
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return fmt.Errorf("MAIL FROM 失败: %v", err)
+	}
+	for _, to := range e.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("RCPT TO %s 失败: %v", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("DATA 失败: %v", err)
+	}
+	if _, err := w.Write(buildMessage(from, e.To, title, FormatText(events))); err != nil {
+		return fmt.Errorf("写入邮件内容失败: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("发送邮件失败: %v", err)
+	}
+	return client.Quit()
+}
+
+// buildMessage 构造 UTF-8 纯文本邮件（主题按 RFC 2047 编码，正文 base64 避免中文乱码）
+func buildMessage(from string, to []string, subject, body string) []byte {
+	var sb strings.Builder
+	sb.WriteString("From: " + from + "\r\n")
+	sb.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	sb.WriteString("Subject: =?UTF-8?B?" + base64.StdEncoding.EncodeToString([]byte(subject)) + "?=\r\n")
+	sb.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	sb.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(body))
+	for len(encoded) > 76 {
+		sb.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	sb.WriteString(encoded + "\r\n")
+	return []byte(sb.String())
+}
@@ -0,0 +1,267 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"ssl_assistant/config"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventType 通知事件类型
+type EventType string
+
+const (
+	EventRenewed      EventType = "renewed"       // 证书续期成功（已部署）
+	EventFailed       EventType = "failed"        // 证书获取/部署失败
+	EventReloadFailed EventType = "reload_failed" // 重载命令执行失败
+	EventExpiring     EventType = "expiring"      // 证书即将过期（未能续期）
+	EventPending      EventType = "pending"       // 证书申请中（等待平台签发）
+	EventDrift        EventType = "drift"         // Web 服务器配置与证书记录不一致
+)
+
+// AllEvents 全部事件类型（渠道未配置 events 时订阅全部）
+var AllEvents = []EventType{EventRenewed, EventFailed, EventReloadFailed, EventExpiring, EventPending, EventDrift}
+
+// eventNames 事件类型中文显示名
+var eventNames = map[EventType]string{
+	EventRenewed:      "续期成功",
+	EventFailed:       "续期失败",
+	EventReloadFailed: "重载失败",
+	EventExpiring:     "即将过期",
+	EventPending:      "申请中",
+	EventDrift:        "配置漂移",
+}
+
+// Name 返回事件类型中文显示名
+func (t EventType) Name() string {
+	if n, ok := eventNames[t]; ok {
+		return n
+	}
+	return string(t)
+}
+
+// Event 一条通知事件
+type Event struct {
+	Type    EventType `json:"type"`
+	Domain  string    `json:"domain"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// Notifier 通知渠道
+type Notifier interface {
+	Name() string                            // 渠道名（配置节后缀，如 webhook/email/dingtalk）
+	Accepts(t EventType) bool                // 是否订阅该事件类型
+	Send(title string, events []Event) error // 发送一批事件
+}
+
+// 包级 http.Client 复用连接池（各 Webhook 渠道共用）
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// channelSections 通知渠道配置节（conf.ini 中 [notify.<渠道>]，enable=1 启用）
+var channelSections = []string{"webhook", "email", "dingtalk", "wecom", "feishu"}
+
+// Batch 单次运行的通知事件收集器：运行过程中 Add，结束时 Flush 合并发送（每个渠道一条消息）
+type Batch struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// NewBatch 创建通知批次
+func NewBatch() *Batch {
+	return &Batch{}
+}
+
+// Add 记录一条事件
+func (b *Batch) Add(t EventType, domain, format string, args ...any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events = append(b.events, Event{Type: t, Domain: domain, Message: fmt.Sprintf(format, args...), Time: time.Now()})
+}
+
+// Events 返回已记录事件的副本
+func (b *Batch) Events() []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Event(nil), b.events...)
+}
+
+// Flush 将本批次事件发送到全部已启用渠道（按渠道订阅过滤，无事件的渠道不发送），发送后清空批次。
+// 单个渠道失败不影响其他渠道，错误合并返回。
+func (b *Batch) Flush() error {
+	return b.FlushTo(LoadChannels())
+}
+
+// FlushTo 将本批次事件发送到指定渠道（Flush 的可测试实现）
+func (b *Batch) FlushTo(channels []Notifier) error {
+	b.mu.Lock()
+	events := b.events
+	b.events = nil
+	b.mu.Unlock()
+	if len(events) == 0 || len(channels) == 0 {
+		return nil
+	}
+
+	title := Title()
+	var errs []string
+	for _, ch := range channels {
+		var accepted []Event
+		for _, e := range events {
+			if ch.Accepts(e.Type) {
+				accepted = append(accepted, e)
+			}
+		}
+		if len(accepted) == 0 {
+			continue
+		}
+		if err := ch.Send(title, accepted); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", ch.Name(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Title 通知标题（含主机名，便于多台服务器区分来源）
+func Title() string {
+	host, _ := os.Hostname()
+	if host == "" {
+		return "SSL Assistant 证书通知"
+	}
+	return fmt.Sprintf("SSL Assistant 证书通知（%s）", host)
+}
+
+// FormatText 将事件格式化为纯文本（每行一条：[类型] 域名: 内容）
+func FormatText(events []Event) string {
+	var sb strings.Builder
+	for i, e := range events {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		if e.Domain != "" {
+			fmt.Fprintf(&sb, "[%s] %s: %s", e.Type.Name(), e.Domain, e.Message)
+		} else {
+			fmt.Fprintf(&sb, "[%s] %s", e.Type.Name(), e.Message)
+		}
+	}
+	return sb.String()
+}
+
+// FormatMarkdown 将事件格式化为 Markdown 列表（钉钉/企业微信 markdown 消息）
+func FormatMarkdown(title string, events []Event) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "### %s\n\n", title)
+	for _, e := range events {
+		if e.Domain != "" {
+			fmt.Fprintf(&sb, "- **%s** `%s`：%s\n", e.Type.Name(), e.Domain, e.Message)
+		} else {
+			fmt.Fprintf(&sb, "- **%s**：%s\n", e.Type.Name(), e.Message)
+		}
+	}
+	return sb.String()
+}
+
+// filter 渠道事件订阅过滤（空表示订阅全部）
+type filter []EventType
+
+func (f filter) Accepts(t EventType) bool {
+	if len(f) == 0 {
+		return true
+	}
+	for _, x := range f {
+		if x == t {
+			return true
+		}
+	}
+	return false
+}
+
+// parseEvents 解析渠道 events 配置（逗号分隔，如 failed,reload_failed；留空为全部）
+func parseEvents(v string) filter {
+	var f filter
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			f = append(f, EventType(s))
+		}
+	}
+	return f
+}
+
+// sectionName 渠道配置节名
+func sectionName(channel string) string {
+	return "notify." + channel
+}
+
+// getConf 读取渠道配置项（去除首尾空白）
+func getConf(channel, key string) string {
+	v, _ := config.GetConfig(sectionName(channel), key)
+	return strings.TrimSpace(v)
+}
+
+// Enabled 渠道是否启用（enable=1/true）
+func Enabled(channel string) bool {
+	v := getConf(channel, "enable")
+	return v == "1" || v == "true"
+}
+
+// LoadChannels 从配置加载全部已启用的通知渠道（配置不完整的渠道跳过）
+func LoadChannels() []Notifier {
+	var channels []Notifier
+	for _, name := range channelSections {
+		if !Enabled(name) {
+			continue
+		}
+		if ch := loadChannel(name); ch != nil {
+			channels = append(channels, ch)
+		}
+	}
+	return channels
+}
+
+// loadChannel 按渠道名构造通知渠道（必填项缺失返回 nil）
+func loadChannel(name string) Notifier {
+	events := parseEvents(getConf(name, "events"))
+	switch name {
+	case "webhook":
+		if u := getConf(name, "url"); u != "" {
+			return &Webhook{URL: u, Secret: getConf(name, "secret"), filter: events}
+		}
+	case "dingtalk":
+		if u := getConf(name, "url"); u != "" {
+			return &DingTalk{URL: u, Secret: getConf(name, "secret"), filter: events}
+		}
+	case "wecom":
+		if u := getConf(name, "url"); u != "" {
+			return &WeCom{URL: u, filter: events}
+		}
+	case "feishu":
+		if u := getConf(name, "url"); u != "" {
+			return &Feishu{URL: u, Secret: getConf(name, "secret"), filter: events}
+		}
+	case "email":
+		e := &Email{
+			Host:     getConf(name, "host"),
+			Port:     getConf(name, "port"),
+			Username: getConf(name, "username"),
+			Password: getConf(name, "password"),
+			From:     getConf(name, "from"),
+			TLS:      getConf(name, "tls"),
+			filter:   events,
+		}
+		for _, to := range strings.Split(getConf(name, "to"), ",") {
+			if to = strings.TrimSpace(to); to != "" {
+				e.To = append(e.To, to)
+			}
+		}
+		if e.Host != "" && len(e.To) > 0 {
+			return e
+		}
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package notify
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"ssl_assistant/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain 切换工作目录到临时目录并初始化配置，避免污染项目 config/conf.ini
+func TestMain(m *testing.M) {
+	tmp, err := os.MkdirTemp("", "notify_test")
+	if err != nil {
+		panic(err)
+	}
+	oldWd, _ := os.Getwd()
+	if err := os.Chdir(tmp); err != nil {
+		panic(err)
+	}
+	if err := config.InitConfig(); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+
+	os.Chdir(oldWd)
+	os.RemoveAll(tmp)
+	os.Exit(code)
+}
+
+// fakeNotifier 记录收到的事件
+type fakeNotifier struct {
+	name string
+	filter
+	got []Event
+	err error
+}
+
+func (f *fakeNotifier) Name() string { return f.name }
+func (f *fakeNotifier) Send(title string, events []Event) error {
+	f.got = append(f.got, events...)
+	return f.err
+}
+
+// 批次按渠道订阅过滤，单个渠道失败不影响其他渠道，发送后清空
+func TestBatchFlushTo(t *testing.T) {
+	b := NewBatch()
+	b.Add(EventRenewed, "a.com", "已续期")
+	b.Add(EventFailed, "b.com", "获取失败: %s", "timeout")
+
+	all := &fakeNotifier{name: "all"}
+	failedOnly := &fakeNotifier{name: "failed", filter: filter{EventFailed}, err: errors.New("boom")}
+	pendingOnly := &fakeNotifier{name: "pending", filter: filter{EventPending}}
+
+	err := b.FlushTo([]Notifier{all, failedOnly, pendingOnly})
+	if err == nil || !strings.Contains(err.Error(), "failed: boom") {
+		t.Fatalf("应返回失败渠道的错误，实际: %v", err)
+	}
+	if len(all.got) != 2 {
+		t.Fatalf("未过滤渠道应收到 2 条事件，实际 %d", len(all.got))
+	}
+	if len(failedOnly.got) != 1 || failedOnly.got[0].Message != "获取失败: timeout" {
+		t.Fatalf("failed 渠道应只收到失败事件: %+v", failedOnly.got)
+	}
+	if len(pendingOnly.got) != 0 {
+		t.Fatalf("无订阅事件的渠道不应发送: %+v", pendingOnly.got)
+	}
+	if len(b.Events()) != 0 {
+		t.Fatal("Flush 后批次应清空")
+	}
+}
+
+// 通用 Webhook：JSON 请求体 + HMAC 签名头
+func TestWebhookSend(t *testing.T) {
+	var body []byte
+	var sig string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		sig = r.Header.Get("X-SSL-Assistant-Signature")
+	}))
+	defer ts.Close()
+
+	wh := &Webhook{URL: ts.URL, Secret: "s3cret"}
+	if err := wh.Send("标题", []Event{{Type: EventRenewed, Domain: "a.com", Message: "ok"}}); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("请求体非 JSON: %v", err)
+	}
+	if payload.Title != "标题" || len(payload.Events) != 1 || payload.Events[0].Domain != "a.com" {
+		t.Fatalf("请求体内容错误: %+v", payload)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	if sig != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		t.Fatalf("签名头错误: %s", sig)
+	}
+
+	// 非 2xx 视为失败
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer bad.Close()
+	if err := (&Webhook{URL: bad.URL}).Send("t", []Event{{Type: EventFailed}}); err == nil {
+		t.Fatal("HTTP 502 应返回错误")
+	}
+}
+
+// 钉钉：加签参数追加到 URL（保留 access_token），errcode 非 0 视为失败
+func TestDingTalkSend(t *testing.T) {
+	nowFunc = func() time.Time { return time.UnixMilli(1700000000123) }
+	defer func() { nowFunc = time.Now }()
+
+	var query map[string][]string
+	var msgtype string
+	errcode := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		msgtype, _ = req["msgtype"].(string)
+		json.NewEncoder(w).Encode(map[string]any{"errcode": errcode, "errmsg": "sign not match"})
+	}))
+	defer ts.Close()
+
+	d := &DingTalk{URL: ts.URL + "/robot/send?access_token=abc", Secret: "SECxyz"}
+	if err := d.Send("标题", []Event{{Type: EventExpiring, Domain: "a.com", Message: "剩余 3 天"}}); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if msgtype != "markdown" {
+		t.Fatalf("应发送 markdown 消息，实际 %s", msgtype)
+	}
+	if query["access_token"][0] != "abc" || query["timestamp"][0] != "1700000000123" {
+		t.Fatalf("URL 参数错误: %v", query)
+	}
+	if query["sign"][0] != dingTalkSign("1700000000123", "SECxyz") {
+		t.Fatalf("签名错误: %v", query["sign"])
+	}
+
+	errcode = 310000
+	if err := d.Send("标题", []Event{{Type: EventFailed}}); err == nil || !strings.Contains(err.Error(), "sign not match") {
+		t.Fatalf("errcode 非 0 应返回错误，实际: %v", err)
+	}
+}
+
+// 钉钉签名算法：base64(HMAC-SHA256(secret, timestamp+"\n"+secret))
+func TestDingTalkSign(t *testing.T) {
+	mac := hmac.New(sha256.New, []byte("sec"))
+	mac.Write([]byte("1\nsec"))
+	if got := dingTalkSign("1", "sec"); got != base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+		t.Fatalf("签名错误: %s", got)
+	}
+}
+
+// 企业微信：markdown.content 含事件内容
+func TestWeComSend(t *testing.T) {
+	var content string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Markdown struct {
+				Content string `json:"content"`
+			} `json:"markdown"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		content = req.Markdown.Content
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	defer ts.Close()
+
+	if err := (&WeCom{URL: ts.URL + "?key=k"}).Send("标题", []Event{{Type: EventReloadFailed, Domain: "a.com", Message: "exit 1"}}); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if !strings.Contains(content, "重载失败") || !strings.Contains(content, "a.com") {
+		t.Fatalf("消息内容错误: %s", content)
+	}
+}
+
+// 飞书：请求体携带 timestamp/sign，code 非 0 视为失败
+func TestFeishuSend(t *testing.T) {
+	nowFunc = func() time.Time { return time.Unix(1700000000, 0) }
+	defer func() { nowFunc = time.Now }()
+
+	var req map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Write([]byte(`{"code":0,"msg":"success"}`))
+	}))
+	defer ts.Close()
+
+	if err := (&Feishu{URL: ts.URL, Secret: "fs"}).Send("标题", []Event{{Type: EventPending, Domain: "a.com", Message: "等待签发"}}); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if req["timestamp"] != "1700000000" || req["sign"] != feishuSign("1700000000", "fs") {
+		t.Fatalf("签名参数错误: %v", req)
+	}
+	mac := hmac.New(sha256.New, []byte("1700000000\nfs"))
+	if feishuSign("1700000000", "fs") != base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+		t.Fatal("飞书签名算法错误")
+	}
+}
+
+// fakeSMTP 本地 SMTP 替身：记录认证、收件人与正文
+type fakeSMTP struct {
+	ln    net.Listener
+	auth  string
+	rcpts []string
+	data  string
+	done  chan struct{}
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{ln: ln, done: make(chan struct{})}
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTP) serve() {
+	defer close(s.done)
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	write := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	write("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			write("250-fake")
+			write("250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "AUTH PLAIN"):
+			s.auth = strings.TrimSpace(line[len("AUTH PLAIN"):])
+			write("235 ok")
+		case strings.HasPrefix(cmd, "MAIL FROM"):
+			write("250 ok")
+		case strings.HasPrefix(cmd, "RCPT TO"):
+			s.rcpts = append(s.rcpts, line)
+			write("250 ok")
+		case cmd == "DATA":
+			write("354 go ahead")
+			var sb strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				sb.WriteString(l)
+			}
+			s.data = sb.String()
+			write("250 queued")
+		case cmd == "QUIT":
+			write("221 bye")
+			return
+		default:
+			write("250 ok")
+		}
+	}
+}
+
+// SMTP 邮件：本地替身验证认证、多收件人与 base64 正文
+func TestEmailSend(t *testing.T) {
+	s := newFakeSMTP(t)
+	defer s.ln.Close()
+	host, port, _ := net.SplitHostPort(s.ln.Addr().String())
+
+	e := &Email{Host: host, Port: port, Username: "bot@example.com", Password: "pw", To: []string{"a@example.com", "b@example.com"}, TLS: "none"}
+	if err := e.Send("证书通知", []Event{{Type: EventFailed, Domain: "a.com", Message: "获取失败"}}); err != nil {
+		t.Fatalf("发送邮件失败: %v", err)
+	}
+	<-s.done
+
+	if want := base64.StdEncoding.EncodeToString([]byte("\x00bot@example.com\x00pw")); s.auth != want {
+		t.Fatalf("AUTH PLAIN 凭证错误: %s", s.auth)
+	}
+	if len(s.rcpts) != 2 {
+		t.Fatalf("应有 2 个收件人，实际 %v", s.rcpts)
+	}
+	parts := strings.SplitN(s.data, "\r\n\r\n", 2)
+	if len(parts) != 2 || !strings.Contains(parts[0], "Subject: =?UTF-8?B?") {
+		t.Fatalf("邮件头错误: %q", s.data)
+	}
+	body, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(parts[1], "\r\n", ""))
+	if err != nil || !strings.Contains(string(body), "[续期失败] a.com: 获取失败") {
+		t.Fatalf("邮件正文错误: %q (%v)", body, err)
+	}
+}
+
+// 从配置加载渠道：未启用或必填项缺失的渠道跳过，events 订阅生效
+func TestLoadChannels(t *testing.T) {
+	config.SetConfig("notify.webhook", "enable", "1")
+	config.SetConfig("notify.webhook", "url", "http://127.0.0.1/hook")
+	config.SetConfig("notify.webhook", "events", "failed, reload_failed")
+	config.SetConfig("notify.dingtalk", "enable", "1")                  // 缺少 url，跳过
+	config.SetConfig("notify.feishu", "url", "http://127.0.0.1/feishu") // 未启用，跳过
+	defer func() {
+		config.SetConfig("notify.webhook", "enable", "0")
+		config.SetConfig("notify.dingtalk", "enable", "0")
+	}()
+
+	channels := LoadChannels()
+	if len(channels) != 1 || channels[0].Name() != "webhook" {
+		t.Fatalf("应只加载 webhook 渠道，实际 %d 个", len(channels))
+	}
+	if channels[0].Accepts(EventRenewed) || !channels[0].Accepts(EventReloadFailed) {
+		t.Fatal("events 订阅过滤未生效")
+	}
+}
@@ -0,0 +1,200 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nowFunc 当前时间（机器人签名时间戳；测试中可替换）
+var nowFunc = time.Now
+
+// Webhook 通用 JSON Webhook：POST {title, host, events:[...]}；
+// 配置 secret 时附带 X-SSL-Assistant-Signature: sha256=<HMAC-SHA256(body) 十六进制>，便于接收端校验来源
+type Webhook struct {
+	URL    string
+	Secret string
+	filter
+}
+
+// webhookPayload 通用 Webhook 请求体
+type webhookPayload struct {
+	Title  string  `json:"title"`
+	Host   string  `json:"host"`
+	Text   string  `json:"text"`
+	Events []Event `json:"events"`
+}
+
+func (w *Webhook) Name() string { return "webhook" }
+
+func (w *Webhook) Send(title string, events []Event) error {
+	host, _ := os.Hostname()
+	body, err := json.Marshal(webhookPayload{Title: title, Host: host, Text: FormatText(events), Events: events})
+	if err != nil {
+		return fmt.Errorf("构造请求体失败: %v", err)
+	}
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	if w.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write(body)
+		req.Header.Set("X-SSL-Assistant-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	_, err = doJSON(req)
+	return err
+}
+
+// DingTalk 钉钉群机器人（markdown 消息）；配置 secret 时按加签方式追加 timestamp/sign 参数：
+// sign = urlencode(base64(HMAC-SHA256(key=secret, timestamp+"\n"+secret)))，timestamp 为毫秒
+type DingTalk struct {
+	URL    string
+	Secret string
+	filter
+}
+
+func (d *DingTalk) Name() string { return "dingtalk" }
+
+func (d *DingTalk) Send(title string, events []Event) error {
+	target := d.URL
+	if d.Secret != "" {
+		ts := strconv.FormatInt(nowFunc().UnixMilli(), 10)
+		sign := dingTalkSign(ts, d.Secret)
+		target = appendQuery(target, url.Values{"timestamp": {ts}, "sign": {sign}})
+	}
+	payload := map[string]any{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": title,
+			"text":  FormatMarkdown(title, events),
+		},
+	}
+	return postBot(target, payload, "errcode", "errmsg")
+}
+
+// dingTalkSign 钉钉加签
+func dingTalkSign(timestamp, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// WeCom 企业微信群机器人（markdown 消息）；鉴权依赖 URL 中的 key 参数，无额外签名
+type WeCom struct {
+	URL string
+	filter
+}
+
+func (w *WeCom) Name() string { return "wecom" }
+
+func (w *WeCom) Send(title string, events []Event) error {
+	payload := map[string]any{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"content": FormatMarkdown(title, events),
+		},
+	}
+	return postBot(w.URL, payload, "errcode", "errmsg")
+}
+
+// Feishu 飞书群机器人（文本消息）；配置 secret 时请求体附带 timestamp/sign：
+// sign = base64(HMAC-SHA256(key=timestamp+"\n"+secret, 空消息))，timestamp 为秒
+type Feishu struct {
+	URL    string
+	Secret string
+	filter
+}
+
+func (f *Feishu) Name() string { return "feishu" }
+
+func (f *Feishu) Send(title string, events []Event) error {
+	payload := map[string]any{
+		"msg_type": "text",
+		"content": map[string]string{
+			"text": title + "\n" + FormatText(events),
+		},
+	}
+	if f.Secret != "" {
+		ts := strconv.FormatInt(nowFunc().Unix(), 10)
+		payload["timestamp"] = ts
+		payload["sign"] = feishuSign(ts, f.Secret)
+	}
+	return postBot(f.URL, payload, "code", "msg")
+}
+
+// feishuSign 飞书加签
+func feishuSign(timestamp, secret string) string {
+	mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// postBot 发送机器人消息并检查业务错误码（codeKey 非 0 视为失败，msgKey 为错误信息字段）
+func postBot(target string, payload any, codeKey, msgKey string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("构造请求体失败: %v", err)
+	}
+	req, err := http.NewRequest("POST", target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	respBody, err := doJSON(req)
+	if err != nil {
+		return err
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+	if code, ok := resp[codeKey].(float64); ok && code != 0 {
+		return fmt.Errorf("接口返回错误(%s=%v): %v", codeKey, code, resp[msgKey])
+	}
+	return nil
+}
+
+// doJSON 发送请求，非 2xx 状态码视为失败，返回响应体（限制大小）
+func doJSON(req *http.Request) ([]byte, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("接口返回HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// appendQuery 向 URL 追加查询参数（保留原有参数，如钉钉的 access_token）
+func appendQuery(rawURL string, extra url.Values) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	for k, vs := range extra {
+		for _, v := range vs {
+			q.Add(k, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
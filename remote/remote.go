@@ -0,0 +1,266 @@
+// Package remote 通过 SSH 将证书文件部署到远程主机并执行命令。
+// 使用 golang.org/x/crypto/ssh 直接连接（不依赖系统 ssh 客户端）：公钥认证（指定的私钥文件，或 ssh-agent 与 ~/.ssh 中的默认密钥），
+// 并强制按 known_hosts 校验主机密钥，未知主机或密钥不匹配直接失败。
+package remote
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Timeout 单次 SSH 调用（上传一个文件或执行一条命令）的超时时间
+var Timeout = 60 * time.Second
+
+// dialTimeout 建立连接与握手的超时时间
+var dialTimeout = 15 * time.Second
+
+// defaultKeys 未指定私钥文件时尝试的 ~/.ssh 默认密钥（与 OpenSSH 顺序一致）
+var defaultKeys = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// Host SSH 连接参数
+type Host struct {
+	Addr         string // 主机名或 IP
+	Port         int    // 端口，0 表示 22
+	User         string // 登录用户，为空使用当前用户
+	IdentityFile string // 私钥文件，为空使用 ssh-agent 与 ~/.ssh 中的默认密钥
+	KnownHosts   string // known_hosts 文件，为空使用 ~/.ssh/known_hosts
+}
+
+// String 主机描述：user@host:port
+func (h Host) String() string {
+	s := h.Addr
+	if h.User != "" {
+		s = h.User + "@" + s
+	}
+	if h.Port > 0 {
+		s += ":" + strconv.Itoa(h.Port)
+	}
+	return s
+}
+
+// Run 在远程主机上执行命令（由远程用户的登录 shell 解释），返回合并的标准输出与错误输出
+func (h Host) Run(cmd string) (string, error) {
+	return h.run(cmd, nil)
+}
+
+// Upload 上传文件：先写入同目录临时文件（名称随机，同一路径的并发上传互不影响）并设置权限/属主，
+// 再原子替换目标文件，避免服务在写入过程中读到不完整的证书。owner 为 user 或 user:group，为空不修改
+func (h Host) Upload(remotePath string, data []byte, mode os.FileMode, owner string) error {
+	if !path.IsAbs(remotePath) {
+		return fmt.Errorf("远程路径必须是绝对路径: %s", remotePath)
+	}
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	tmp := path.Join(path.Dir(remotePath), "."+path.Base(remotePath)+".ssl-assistant-"+hex.EncodeToString(suffix)+".tmp")
+	steps := []string{
+		"mkdir -p " + Quote(path.Dir(remotePath)),
+		"(umask 077 && cat > " + Quote(tmp) + ")",
+		fmt.Sprintf("chmod %04o %s", mode.Perm(), Quote(tmp)),
+	}
+	if owner != "" {
+		steps = append(steps, "chown "+Quote(owner)+" "+Quote(tmp))
+	}
+	steps = append(steps, "mv -f "+Quote(tmp)+" "+Quote(remotePath))
+	cmd := strings.Join(steps, " && ") + " || { rm -f " + Quote(tmp) + "; exit 1; }"
+	if _, err := h.run(cmd, data); err != nil {
+		return fmt.Errorf("上传 %s 失败: %v", remotePath, err)
+	}
+	return nil
+}
+
+func (h Host) run(cmd string, stdin []byte) (string, error) {
+	client, err := h.dial()
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+	// 超时后关闭连接，使阻塞中的会话返回
+	var timedOut atomic.Bool
+	timer := time.AfterFunc(Timeout, func() {
+		timedOut.Store(true)
+		client.Close()
+	})
+	defer timer.Stop()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("连接 %s 失败: %v", h, err)
+	}
+	defer session.Close()
+	if stdin != nil {
+		session.Stdin = bytes.NewReader(stdin)
+	}
+	out, err := session.CombinedOutput(cmd)
+	output := strings.TrimSpace(string(out))
+	if timedOut.Load() {
+		return output, fmt.Errorf("执行超时（%s）", Timeout)
+	}
+	if err != nil {
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			err = fmt.Errorf("退出码 %d", exitErr.ExitStatus())
+		}
+		if output != "" {
+			return output, fmt.Errorf("%v: %s", err, output)
+		}
+		return output, err
+	}
+	return output, nil
+}
+
+// dial 建立 SSH 连接（校验主机密钥并完成公钥认证）
+func (h Host) dial() (*ssh.Client, error) {
+	if h.Addr == "" {
+		return nil, errors.New("未指定主机")
+	}
+	hostKey, err := h.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+	auth, closeAgent, err := h.authMethod()
+	if err != nil {
+		return nil, err
+	}
+	defer closeAgent()
+	port := h.Port
+	if port == 0 {
+		port = 22
+	}
+	addr := net.JoinHostPort(h.Addr, strconv.Itoa(port))
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("连接 %s 失败: %v", h, err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(dialTimeout))
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            h.user(),
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: hostKey,
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("连接 %s 失败: %v", h, err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// user 登录用户：未指定时使用当前用户（Windows 去掉域名前缀）
+func (h Host) user() string {
+	if h.User != "" {
+		return h.User
+	}
+	if u, err := user.Current(); err == nil {
+		name := u.Username
+		if i := strings.LastIndex(name, `\`); i >= 0 {
+			name = name[i+1:]
+		}
+		return name
+	}
+	return os.Getenv("USER")
+}
+
+// hostKeyCallback 按 known_hosts 校验主机密钥；未知主机与密钥不匹配给出明确的错误
+func (h Host) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	file := h.KnownHosts
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("读取 known_hosts 失败: %v", err)
+		}
+		file = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	check, err := knownhosts.New(file)
+	if err != nil {
+		return nil, fmt.Errorf("读取 known_hosts 失败: %v", err)
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := check(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			if len(keyErr.Want) == 0 {
+				return fmt.Errorf("主机密钥未知（%s 中没有 %s，可先执行 ssh-keyscan 添加）", file, hostname)
+			}
+			return fmt.Errorf("主机密钥与 %s 中记录的不一致", file)
+		}
+		return err
+	}, nil
+}
+
+// authMethod 公钥认证：指定了私钥文件时只使用该密钥，否则使用 ssh-agent 与 ~/.ssh 中的默认密钥（返回关闭 agent 连接的函数）
+func (h Host) authMethod() (ssh.AuthMethod, func(), error) {
+	closeAgent := func() {}
+	if h.IdentityFile != "" {
+		signer, err := loadKey(h.IdentityFile)
+		if err != nil {
+			return nil, closeAgent, err
+		}
+		return ssh.PublicKeys(signer), closeAgent, nil
+	}
+	var signers []ssh.Signer
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			closeAgent = func() { conn.Close() }
+			if list, err := agent.NewClient(conn).Signers(); err == nil {
+				signers = append(signers, list...)
+			}
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, name := range defaultKeys {
+			file := filepath.Join(home, ".ssh", name)
+			if _, err := os.Stat(file); err != nil {
+				continue
+			}
+			// 已加密的默认密钥无法使用（不交互询问口令），跳过
+			if signer, err := loadKey(file); err == nil {
+				signers = append(signers, signer)
+			}
+		}
+	}
+	if len(signers) == 0 {
+		closeAgent()
+		return nil, func() {}, errors.New("未找到可用的 SSH 私钥（使用 --identity 指定，或加入 ssh-agent）")
+	}
+	return ssh.PublicKeys(signers...), closeAgent, nil
+}
+
+// loadKey 读取未加密的私钥文件
+func loadKey(file string) (ssh.Signer, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("读取私钥 %s 失败: %v", file, err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		return nil, fmt.Errorf("私钥 %s 已加密，请加入 ssh-agent 后使用", file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析私钥 %s 失败: %v", file, err)
+	}
+	return signer, nil
+}
+
+// Quote 按 POSIX shell 单引号规则转义参数
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,161 @@
+package remote
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"ssl_assistant/remote/remotetest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// host 连接进程内 SSH 服务器的参数
+func host(s *remotetest.Server) Host {
+	return Host{Addr: s.Addr, Port: s.Port, User: s.User, IdentityFile: s.IdentityFile, KnownHosts: s.KnownHosts}
+}
+
+func TestString(t *testing.T) {
+	h := Host{Addr: "web1", Port: 2222, User: "deploy"}
+	if h.String() != "deploy@web1:2222" || (Host{Addr: "web1"}).String() != "web1" {
+		t.Fatalf("主机描述错误: %s", h)
+	}
+}
+
+// 上传：写入内容、设置权限、替换已有文件且不残留临时文件；路径含空格与单引号时正确转义
+func TestUpload(t *testing.T) {
+	s := remotetest.NewServer(t)
+	h := host(s)
+	dir := filepath.Join(t.TempDir(), "it's ssl")
+	target := filepath.Join(dir, "a.key")
+	if err := h.Upload(target, []byte("old"), 0644, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Upload(target, []byte("KEY"), 0600, ""); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(target)
+	info, _ := os.Stat(target)
+	if string(data) != "KEY" || info.Mode().Perm() != 0600 {
+		t.Fatalf("上传结果错误: %q %v", data, info.Mode())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("不应残留临时文件: %v", entries)
+	}
+
+	if err := h.Upload("relative/a.pem", []byte("x"), 0644, ""); err == nil {
+		t.Fatal("相对路径应报错")
+	}
+	// 无法写入（父路径是文件）时报错
+	blocker := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(blocker, nil, 0644)
+	if err := h.Upload(filepath.Join(blocker, "a.pem"), []byte("x"), 0644, ""); err == nil {
+		t.Fatal("写入失败应报错")
+	}
+}
+
+// 同一路径的并发上传使用各自的临时文件：全部成功，目标为其中一份完整内容
+func TestUploadConcurrent(t *testing.T) {
+	h := host(remotetest.NewServer(t))
+	dir := t.TempDir()
+	target := filepath.Join(dir, "a.pem")
+	contents := []string{strings.Repeat("A", 64<<10), strings.Repeat("B", 64<<10), strings.Repeat("C", 64<<10)}
+	var wg sync.WaitGroup
+	errs := make([]error, len(contents))
+	for i, c := range contents {
+		wg.Add(1)
+		go func(i int, c string) {
+			defer wg.Done()
+			errs[i] = h.Upload(target, []byte(c), 0644, "")
+		}(i, c)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("并发上传失败: %v", err)
+		}
+	}
+	data, _ := os.ReadFile(target)
+	if got := string(data); got != contents[0] && got != contents[1] && got != contents[2] {
+		t.Fatalf("目标文件内容不完整: %d 字节", len(got))
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("不应残留临时文件: %v", entries)
+	}
+}
+
+func TestRun(t *testing.T) {
+	s := remotetest.NewServer(t)
+	h := host(s)
+	if out, err := h.Run("echo ok"); err != nil || out != "ok" {
+		t.Fatalf("执行结果错误: %q %v", out, err)
+	}
+	_, err := h.Run("echo 'nginx: configuration file test failed' >&2; exit 3")
+	if err == nil || !strings.Contains(err.Error(), "退出码 3") || !strings.Contains(err.Error(), "test failed") {
+		t.Fatalf("命令失败应返回退出码与输出: %v", err)
+	}
+	if cmds := s.Commands(); len(cmds) != 2 || cmds[0] != "echo ok" {
+		t.Fatalf("服务器收到的命令错误: %q", cmds)
+	}
+}
+
+// 连接失败、主机密钥未知或不一致、私钥未授权时均失败，且不执行命令
+func TestRunConnectErrors(t *testing.T) {
+	s := remotetest.NewServer(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := host(s)
+	down.Port = l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	if _, err := down.Run("true"); err == nil || !strings.Contains(err.Error(), "连接 deploy@127.0.0.1") {
+		t.Fatalf("连接失败描述错误: %v", err)
+	}
+
+	empty := filepath.Join(t.TempDir(), "known_hosts")
+	os.WriteFile(empty, nil, 0644)
+	unknown := host(s)
+	unknown.KnownHosts = empty
+	if _, err := unknown.Run("true"); err == nil || !strings.Contains(err.Error(), "主机密钥未知") {
+		t.Fatalf("未知主机应失败: %v", err)
+	}
+
+	other := remotetest.NewServer(t)
+	mismatch := host(s)
+	mismatch.KnownHosts = rewriteKnownHosts(t, other, s)
+	if _, err := mismatch.Run("true"); err == nil || !strings.Contains(err.Error(), "不一致") {
+		t.Fatalf("主机密钥不一致应失败: %v", err)
+	}
+
+	denied := host(s)
+	denied.IdentityFile = other.IdentityFile
+	if _, err := denied.Run("true"); err == nil {
+		t.Fatal("未授权的私钥应认证失败")
+	}
+	if cmds := s.Commands(); len(cmds) != 0 {
+		t.Fatalf("连接失败时不应执行命令: %q", cmds)
+	}
+}
+
+// rewriteKnownHosts 将 from 的主机密钥记录为 to 的地址（模拟主机密钥变更）
+func rewriteKnownHosts(t *testing.T, from, to *remotetest.Server) string {
+	t.Helper()
+	data, err := os.ReadFile(from.KnownHosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, key, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+	file := filepath.Join(t.TempDir(), "known_hosts")
+	line := "[" + to.Addr + "]:" + strconv.Itoa(to.Port) + " " + key + "\n"
+	os.WriteFile(file, []byte(line), 0644)
+	return file
+}
+
+func TestQuote(t *testing.T) {
+	if got := Quote("it's"); got != `'it'\''s'` {
+		t.Fatalf("转义错误: %s", got)
+	}
+}
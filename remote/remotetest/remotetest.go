@@ -0,0 +1,203 @@
+// Package remotetest 提供进程内 SSH 服务器，供 remote 包与 ssh 部署目标的测试使用（类似 net/http/httptest）。
+// 服务器只接受生成的客户端密钥登录，以本机 sh 执行远程命令（远程路径即本机路径）。
+package remotetest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Server 进程内 SSH 服务器
+type Server struct {
+	Addr         string // 监听地址（127.0.0.1）
+	Port         int    // 监听端口
+	User         string // 允许登录的用户
+	IdentityFile string // 已授权的客户端私钥文件
+	KnownHosts   string // 包含服务器主机密钥的 known_hosts 文件
+
+	listener   net.Listener
+	config     *ssh.ServerConfig
+	wg         sync.WaitGroup
+	mu         sync.Mutex
+	commands   []string
+	connsMu    sync.Mutex
+	conns      map[net.Conn]bool
+	closedOnce sync.Once
+}
+
+// NewServer 启动服务器，测试结束时关闭；未安装 sh（如 Windows）时跳过测试
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("进程内 SSH 服务器以 sh 执行命令，依赖 POSIX shell")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("未安装 sh")
+	}
+	dir := t.TempDir()
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientPub, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized, err := ssh.NewPublicKey(clientPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		User:         "deploy",
+		IdentityFile: filepath.Join(dir, "id_ed25519"),
+		KnownHosts:   filepath.Join(dir, "known_hosts"),
+		conns:        map[net.Conn]bool{},
+	}
+	if err := os.WriteFile(s.IdentityFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s.config = &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == s.User && bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("未授权的用户或密钥")
+		},
+	}
+	s.config.AddHostKey(hostSigner)
+
+	if s.listener, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	addr := s.listener.Addr().(*net.TCPAddr)
+	s.Addr, s.Port = addr.IP.String(), addr.Port
+	line := knownhosts.Line([]string{knownhosts.Normalize(net.JoinHostPort(s.Addr, strconv.Itoa(s.Port)))}, hostSigner.PublicKey())
+	if err := os.WriteFile(s.KnownHosts, []byte(line+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Commands 服务器收到的远程命令（按执行顺序）
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// Close 停止监听并断开全部连接
+func (s *Server) Close() {
+	s.closedOnce.Do(func() {
+		s.listener.Close()
+		s.connsMu.Lock()
+		for c := range s.conns {
+			c.Close()
+		}
+		s.connsMu.Unlock()
+		s.wg.Wait()
+	})
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.connsMu.Lock()
+		s.conns[conn] = true
+		s.connsMu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleConn(conn)
+			s.connsMu.Lock()
+			delete(s.conns, conn)
+			s.connsMu.Unlock()
+		}()
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			_ = newCh.Reject(ssh.UnknownChannelType, "仅支持 session")
+			continue
+		}
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleSession(ch, chReqs)
+		}()
+	}
+}
+
+// handleSession 处理 exec 请求：以 sh -c 执行命令，返回输出与退出码
+func (s *Server) handleSession(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer ch.Close()
+	for req := range reqs {
+		if req.Type != "exec" {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		var payload struct{ Command string }
+		if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		_ = req.Reply(true, nil)
+		s.mu.Lock()
+		s.commands = append(s.commands, payload.Command)
+		s.mu.Unlock()
+
+		cmd := exec.Command("sh", "-c", payload.Command)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = ch, ch, ch.Stderr()
+		status := 0
+		if err := cmd.Run(); err != nil {
+			status = 1
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				status = exitErr.ExitCode()
+			}
+		}
+		_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+		return
+	}
+}
@@ -0,0 +1,229 @@
+package serverconf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// caddyToken Caddyfile 词法单元
+type caddyToken struct {
+	text   string
+	line   int
+	quoted bool
+}
+
+// caddyNode 一行指令（args[0] 为指令名）；以 { 结尾的行带子块
+type caddyNode struct {
+	args  []caddyToken
+	block []*caddyNode
+	file  string
+}
+
+func (n *caddyNode) name() string {
+	if len(n.args) == 0 {
+		return ""
+	}
+	return n.args[0].text
+}
+
+// caddyLex 按行分词：空白分隔，支持 "..." 与 `...` 引号，# 开头的词起为注释
+func caddyLex(data []byte) [][]caddyToken {
+	var lines [][]caddyToken
+	for i, raw := range strings.Split(string(data), "\n") {
+		var toks []caddyToken
+		s := raw
+		for {
+			s = strings.TrimLeft(s, " \t\r")
+			if s == "" || s[0] == '#' {
+				break
+			}
+			if s[0] == '"' || s[0] == '`' {
+				q := s[0]
+				end := strings.IndexByte(s[1:], q)
+				if end < 0 {
+					toks = append(toks, caddyToken{text: s[1:], line: i + 1, quoted: true})
+					break
+				}
+				toks = append(toks, caddyToken{text: s[1 : end+1], line: i + 1, quoted: true})
+				s = s[end+2:]
+				continue
+			}
+			end := strings.IndexAny(s, " \t\r")
+			if end < 0 {
+				end = len(s)
+			}
+			toks = append(toks, caddyToken{text: s[:end], line: i + 1})
+			s = s[end:]
+		}
+		if len(toks) > 0 {
+			lines = append(lines, toks)
+		}
+	}
+	return lines
+}
+
+// caddyTree 由行构建块结构（只有单独的 { / } 词才是块边界，{host} 等占位符不受影响）
+func caddyTree(data []byte, name string) ([]*caddyNode, error) {
+	root := &caddyNode{}
+	stack := []*caddyNode{root}
+	for _, toks := range caddyLex(data) {
+		cur := stack[len(stack)-1]
+		last := toks[len(toks)-1]
+		switch {
+		case len(toks) == 1 && last.text == "}" && !last.quoted:
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("%s:%d: 多余的 }", name, last.line)
+			}
+			stack = stack[:len(stack)-1]
+		case last.text == "{" && !last.quoted:
+			n := &caddyNode{args: toks[:len(toks)-1], file: name}
+			cur.block = append(cur.block, n)
+			stack = append(stack, n)
+		default:
+			cur.block = append(cur.block, &caddyNode{args: toks, file: name})
+		}
+	}
+	if len(stack) > 1 {
+		open := stack[len(stack)-1]
+		line := 0
+		if len(open.args) > 0 {
+			line = open.args[0].line
+		}
+		return nil, fmt.Errorf("%s:%d: 块缺少 }", name, line)
+	}
+	return root.block, nil
+}
+
+// caddyParser 展开 import（同文件片段 (name) 与相对 Caddyfile 目录的文件/通配符）
+type caddyParser struct {
+	dir      string
+	snippets map[string][]*caddyNode
+	visited  map[string]bool
+}
+
+// expand 展开一组节点中的 import：片段名优先，其次按文件路径导入
+func (p *caddyParser) expand(nodes []*caddyNode) []*caddyNode {
+	var out []*caddyNode
+	for _, n := range nodes {
+		if n.name() != "import" || len(n.args) < 2 || n.block != nil {
+			out = append(out, n)
+			continue
+		}
+		target := n.args[1].text
+		if snippet, ok := p.snippets[target]; ok {
+			out = append(out, p.expand(snippet)...)
+			continue
+		}
+		matches, _ := filepath.Glob(resolvePath(p.dir, target))
+		sort.Strings(matches)
+		for _, m := range matches {
+			abs, _ := filepath.Abs(m)
+			if p.visited[abs] {
+				continue
+			}
+			p.visited[abs] = true
+			data, err := os.ReadFile(m)
+			if err != nil {
+				continue
+			}
+			nodes, err := caddyTree(data, m)
+			if err != nil {
+				continue
+			}
+			out = append(out, p.expand(p.collectSnippets(nodes))...)
+		}
+	}
+	return out
+}
+
+// collectSnippets 登记 (name) { ... } 片段，返回其余节点
+func (p *caddyParser) collectSnippets(nodes []*caddyNode) []*caddyNode {
+	var rest []*caddyNode
+	for _, n := range nodes {
+		if name := n.name(); len(n.args) == 1 && n.block != nil && strings.HasPrefix(name, "(") && strings.HasSuffix(name, ")") {
+			p.snippets[strings.Trim(name, "()")] = n.block
+			continue
+		}
+		rest = append(rest, n)
+	}
+	return rest
+}
+
+// ParseCaddy 解析 Caddyfile 中站点块的 tls <证书> <私钥>（自动 HTTPS 的站点不由本工具管理）。
+// 站点地址可带协议与端口（https://example.com:8443），http:// 地址忽略；未写端口时为 443。
+func ParseCaddy(data []byte, name string) ([]Site, error) {
+	nodes, err := caddyTree(data, name)
+	if err != nil {
+		return nil, err
+	}
+	abs, _ := filepath.Abs(name)
+	p := &caddyParser{dir: filepath.Dir(name), snippets: map[string][]*caddyNode{}, visited: map[string]bool{abs: true}}
+	nodes = p.expand(p.collectSnippets(nodes))
+
+	// 全局选项块（首个无地址的块）
+	if len(nodes) > 0 && len(nodes[0].args) == 0 && nodes[0].block != nil {
+		nodes = nodes[1:]
+	}
+	// 单站点 Caddyfile 可省略花括号：首行为地址，其余行为指令
+	if len(nodes) > 0 && nodes[0].block == nil && nodes[0].name() != "import" {
+		nodes = []*caddyNode{{args: nodes[0].args, block: nodes[1:], file: nodes[0].file}}
+	}
+
+	var sites []Site
+	for _, n := range nodes {
+		if n.block == nil {
+			continue
+		}
+		names, ports, ok := caddyAddresses(n.args)
+		if !ok {
+			continue
+		}
+		for _, d := range p.expand(n.block) {
+			if d.name() != "tls" || len(d.args) != 3 || strings.Contains(d.args[1].text, "@") || d.args[1].text == "internal" {
+				continue
+			}
+			sites = append(sites, Site{
+				Kind:     Caddy,
+				Names:    names,
+				CertPath: resolvePath(p.dir, d.args[1].text),
+				KeyPath:  resolvePath(p.dir, d.args[2].text),
+				Ports:    ports,
+				File:     d.file,
+				Line:     d.args[0].line,
+			})
+		}
+	}
+	return sites, nil
+}
+
+// caddyAddresses 解析站点地址（逗号或空白分隔）；全部为 http:// 地址时 ok 为 false
+func caddyAddresses(args []caddyToken) (names, ports []string, ok bool) {
+	for _, t := range args {
+		for _, addr := range strings.Split(t.text, ",") {
+			addr = strings.TrimSpace(addr)
+			if addr == "" || strings.HasPrefix(addr, "http://") {
+				continue
+			}
+			ok = true
+			addr = strings.TrimPrefix(addr, "https://")
+			if i := strings.Index(addr, "/"); i >= 0 {
+				addr = addr[:i]
+			}
+			host, port := addr, ""
+			if i := strings.LastIndex(addr, ":"); i >= 0 && !strings.HasSuffix(addr, "]") {
+				host, port = addr[:i], addr[i+1:]
+			}
+			if port == "" {
+				port = "443"
+			}
+			ports = appendUnique(ports, portOf(port))
+			if host != "" && !strings.Contains(host, "{") {
+				names = appendUnique(names, host)
+			}
+		}
+	}
+	return names, ports, ok
+}
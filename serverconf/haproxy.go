@@ -0,0 +1,154 @@
+package serverconf
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// haproxySectionReg 匹配 HAProxy 节关键字（识别 .cfg 文件是否为 HAProxy 配置）
+var haproxySectionReg = regexp.MustCompile(`(?m)^\s*(frontend|listen|backend)\s+\S+`)
+
+// haproxyExtraExts crt 目录中不作为证书加载的附加文件（ssl-load-extra-files）
+var haproxyExtraExts = map[string]bool{".key": true, ".ocsp": true, ".issuer": true, ".sctl": true, ".csr": true}
+
+// ParseHAProxy 解析 HAProxy 配置中 bind ... ssl 的 crt / crt-list：
+// crt 为合并了证书与私钥的 PEM 文件（同名 .key 文件存在时私钥单独存放），也可以是目录（加载目录下全部证书）；
+// 相对路径以 global 中的 crt-base 为基准。crt-list 中的 SNI 过滤器作为域名。
+func ParseHAProxy(data []byte, name string) ([]Site, error) {
+	base := filepath.Dir(name)
+	var crtBase, keyBase string
+	var sites []Site
+	for i, raw := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(stripHashComment(raw))
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "crt-base":
+			if len(fields) > 1 {
+				crtBase = resolvePath(base, unquote(fields[1]))
+			}
+			continue
+		case "key-base":
+			if len(fields) > 1 {
+				keyBase = resolvePath(base, unquote(fields[1]))
+			}
+			continue
+		case "bind":
+		default:
+			continue
+		}
+		if len(fields) < 2 {
+			continue
+		}
+		var ports []string
+		for _, addr := range strings.Split(fields[1], ",") {
+			ports = appendUnique(ports, portOf(addr))
+		}
+		certBase := crtBase
+		if certBase == "" {
+			certBase = base
+		}
+		for j := 2; j+1 < len(fields); j++ {
+			arg := unquote(fields[j+1])
+			switch fields[j] {
+			case "crt":
+				for _, crt := range haproxyCertFiles(resolvePath(certBase, arg)) {
+					sites = append(sites, Site{Kind: HAProxy, CertPath: crt, KeyPath: haproxyKeyPath(crt, keyBase), Ports: ports, File: name, Line: i + 1})
+				}
+			case "crt-list":
+				for _, s := range parseCrtList(resolvePath(base, arg), certBase, keyBase) {
+					s.Ports = ports
+					sites = append(sites, s)
+				}
+			}
+		}
+	}
+	return sites, nil
+}
+
+// haproxyCertFiles crt 为目录时返回目录下的证书文件（排除 .key/.ocsp 等附加文件，按文件名排序），否则返回路径本身
+func haproxyCertFiles(path string) []string {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return []string{path}
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() || haproxyExtraExts[strings.ToLower(filepath.Ext(e.Name()))] {
+			continue
+		}
+		files = append(files, filepath.Join(path, e.Name()))
+	}
+	sort.Strings(files)
+	return files
+}
+
+// haproxyKeyPath 私钥路径：key-base 或证书同目录下存在 <证书文件名>.key 时为单独私钥，否则私钥合并在证书文件中
+func haproxyKeyPath(crt, keyBase string) string {
+	candidates := []string{crt + ".key"}
+	if keyBase != "" {
+		candidates = append([]string{filepath.Join(keyBase, filepath.Base(crt)+".key")}, candidates...)
+	}
+	for _, k := range candidates {
+		if _, err := os.Stat(k); err == nil {
+			return k
+		}
+	}
+	return crt
+}
+
+// parseCrtList 解析 crt-list 文件：每行 <crt> [ssl 参数] [SNI 过滤器...]，! 开头的过滤器为排除项
+func parseCrtList(path, crtBase, keyBase string) []Site {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var sites []Site
+	for i, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(stripHashComment(raw))
+		if line == "" {
+			continue
+		}
+		// 去掉 [ ... ] 中的 ssl 参数
+		if l, r := strings.Index(line, "["), strings.Index(line, "]"); l >= 0 && r > l {
+			line = line[:l] + " " + line[r+1:]
+		}
+		fields := strings.Fields(line)
+		crt := resolvePath(crtBase, unquote(fields[0]))
+		var names []string
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "!") {
+				names = appendUnique(names, f)
+			}
+		}
+		sites = append(sites, Site{Kind: HAProxy, Names: names, CertPath: crt, KeyPath: haproxyKeyPath(crt, keyBase), File: path, Line: i + 1})
+	}
+	return sites
+}
+
+// stripHashComment 去掉 # 注释（引号内的 # 保留）
+func stripHashComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,182 @@
+package serverconf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// lighttpdSSLReg 匹配 lighttpd 证书设置（识别非 lighttpd 命名的配置文件）
+var lighttpdSSLReg = regexp.MustCompile(`(?m)^\s*ssl\.pemfile\s*\+?=`)
+
+// lighttpdCondReg 匹配条件块头：$HTTP["host"] == "example.com" / $SERVER["socket"] == ":443"
+var lighttpdCondReg = regexp.MustCompile(`^\$(\w+)\["([^"]+)"\]\s*(==|!=|=~|!~|=\^|=\$)\s*"([^"]*)"$`)
+
+// lighttpdScope 一层条件块：块内的 ssl.pemfile / ssl.privkey 与块头条件确定的域名、端口
+type lighttpdScope struct {
+	names          []string
+	ports          []string
+	pemfile        string
+	privkey        string
+	line           int
+	file           string
+	inheritPrivkey string
+}
+
+// lighttpdParser 解析状态（include 展开与循环检测）
+type lighttpdParser struct {
+	sites   []Site
+	visited map[string]bool
+	vars    map[string]string // var.xxx 变量（路径拼接用）
+}
+
+// ParseLighttpd 解析 lighttpd 配置中的 ssl.pemfile（证书，未配置 ssl.privkey 时同时包含私钥）与 ssl.privkey：
+// 所在条件块 $HTTP["host"] == "域名" 确定域名，$SERVER["socket"] == ":端口" 确定端口；
+// 跟随 include（相对当前文件目录，支持通配符）。
+func ParseLighttpd(data []byte, name string) ([]Site, error) {
+	abs, _ := filepath.Abs(name)
+	p := &lighttpdParser{visited: map[string]bool{abs: true}, vars: map[string]string{}}
+	root := &lighttpdScope{file: name}
+	if err := p.parse(data, name, []*lighttpdScope{root}); err != nil {
+		return nil, err
+	}
+	p.closeScope(root)
+	return p.sites, nil
+}
+
+func (p *lighttpdParser) parse(data []byte, name string, stack []*lighttpdScope) error {
+	depth := len(stack)
+	pending := "" // 条件与 { 不在同一行时暂存条件
+	for i, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(stripHashComment(raw))
+		for line != "" {
+			cur := stack[len(stack)-1]
+			switch {
+			case strings.HasPrefix(line, "else") && (len(line) == 4 || strings.ContainsAny(line[4:5], " \t{$")):
+				// else / else $HTTP[...] 分支（与上一块的 } 不在同一行）
+				line = strings.TrimSpace(line[4:])
+				continue
+			case strings.HasPrefix(line, "}"):
+				if len(stack) <= depth {
+					return fmt.Errorf("%s:%d: 多余的 }", name, i+1)
+				}
+				p.closeScope(stack[len(stack)-1])
+				stack = stack[:len(stack)-1]
+				line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line[1:]), "else"))
+				continue
+			case strings.HasPrefix(line, "$") || strings.HasPrefix(line, "{"):
+				brace := strings.Index(line, "{")
+				if brace < 0 {
+					pending, line = line, ""
+					continue
+				}
+				cond := strings.TrimSpace(line[:brace])
+				if cond == "" {
+					cond, pending = pending, ""
+				}
+				scope := &lighttpdScope{file: name, names: cur.names, ports: cur.ports, inheritPrivkey: cur.privkeyOrInherited()}
+				if m := lighttpdCondReg.FindStringSubmatch(cond); m != nil && m[3] == "==" {
+					switch {
+					case strings.EqualFold(m[1], "HTTP") && m[2] == "host":
+						scope.names = []string{m[4]}
+					case strings.EqualFold(m[1], "SERVER") && m[2] == "socket":
+						scope.ports = appendUnique(nil, portOf(m[4]))
+					}
+				}
+				stack = append(stack, scope)
+				line = strings.TrimSpace(line[brace+1:])
+				continue
+			}
+			stmt := line
+			if j := strings.Index(line, "}"); j >= 0 {
+				stmt, line = line[:j], line[j:]
+			} else {
+				line = ""
+			}
+			p.statement(strings.TrimSpace(stmt), cur, name, i+1, stack)
+		}
+	}
+	if len(stack) > depth {
+		return fmt.Errorf("%s: 条件块缺少 }", name)
+	}
+	return nil
+}
+
+// statement 处理一条赋值或 include
+func (p *lighttpdParser) statement(stmt string, cur *lighttpdScope, name string, line int, stack []*lighttpdScope) {
+	if strings.HasPrefix(stmt, "include ") {
+		p.include(unquote(strings.TrimSpace(strings.TrimPrefix(stmt, "include "))), name, stack)
+		return
+	}
+	eq := strings.Index(stmt, "=")
+	if eq < 0 {
+		return
+	}
+	key := strings.TrimSpace(strings.TrimSuffix(stmt[:eq], "+"))
+	value := p.eval(stmt[eq+1:])
+	switch {
+	case strings.HasPrefix(key, "var."):
+		p.vars[key] = value
+	case key == "ssl.pemfile":
+		cur.pemfile, cur.line, cur.file = value, line, name
+	case key == "ssl.privkey":
+		cur.privkey = value
+	}
+}
+
+// eval 求值字符串表达式："a" + var.x + env.Y（未定义的变量按空串处理）
+func (p *lighttpdParser) eval(expr string) string {
+	var sb strings.Builder
+	for _, part := range strings.Split(expr, "+") {
+		part = strings.TrimSpace(part)
+		switch {
+		case strings.HasPrefix(part, "var."):
+			sb.WriteString(p.vars[part])
+			continue
+		case strings.HasPrefix(part, "env."):
+			sb.WriteString(os.Getenv(strings.TrimPrefix(part, "env.")))
+			continue
+		}
+		sb.WriteString(unquote(part))
+	}
+	return sb.String()
+}
+
+// include 展开 include "path"（相对当前文件目录，支持通配符；循环包含跳过）
+func (p *lighttpdParser) include(pattern, name string, stack []*lighttpdScope) {
+	matches, _ := filepath.Glob(resolvePath(filepath.Dir(name), pattern))
+	sort.Strings(matches)
+	for _, m := range matches {
+		abs, _ := filepath.Abs(m)
+		if p.visited[abs] {
+			continue
+		}
+		p.visited[abs] = true
+		if data, err := os.ReadFile(m); err == nil {
+			_ = p.parse(data, m, stack)
+		}
+	}
+}
+
+// privkeyOrInherited 本层或外层配置的 ssl.privkey
+func (s *lighttpdScope) privkeyOrInherited() string {
+	if s.privkey != "" {
+		return s.privkey
+	}
+	return s.inheritPrivkey
+}
+
+// closeScope 条件块结束：配置了 ssl.pemfile 时记为一个站点（未配置 ssl.privkey 时私钥在证书文件中）
+func (p *lighttpdParser) closeScope(s *lighttpdScope) {
+	if s.pemfile == "" {
+		return
+	}
+	key := s.privkeyOrInherited()
+	if key == "" {
+		key = s.pemfile
+	}
+	p.sites = append(p.sites, Site{Kind: Lighttpd, Names: s.names, CertPath: s.pemfile, KeyPath: key, Ports: s.ports, File: s.file, Line: s.line})
+}
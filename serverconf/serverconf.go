@@ -0,0 +1,141 @@
+// Package serverconf 解析 Caddy、HAProxy、Traefik（file provider）与 lighttpd 配置中的证书设置。
+// 与 nginxconf/apacheconf 不同，这几种服务的配置只需提取证书路径（及可确定的域名与端口），不做完整的结构解析。
+package serverconf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Kind 配置类型
+type Kind string
+
+const (
+	Caddy    Kind = "caddy"
+	HAProxy  Kind = "haproxy"
+	Traefik  Kind = "traefik"
+	Lighttpd Kind = "lighttpd"
+)
+
+// Site 配置中的一组证书
+type Site struct {
+	Kind     Kind
+	Names    []string // 配置中声明的域名（HAProxy crt、Traefik 不声明域名时为空，由证书 SAN 确定）
+	CertPath string
+	KeyPath  string   // 证书与私钥合并在同一文件时（HAProxy crt、lighttpd 未配置 ssl.privkey）与 CertPath 相同
+	Ports    []string // HTTPS 监听端口（无法确定时为空）
+	File     string   // 证书指令所在文件
+	Line     int      // 证书指令所在行
+}
+
+// Pos 返回证书指令位置（file:line）
+func (s Site) Pos() string {
+	return fmt.Sprintf("%s:%d", s.File, s.Line)
+}
+
+// Combined 证书与私钥是否合并在同一文件
+func (s Site) Combined() bool {
+	return s.CertPath != "" && filepath.Clean(s.CertPath) == filepath.Clean(s.KeyPath)
+}
+
+// Detect 按文件名与内容识别配置类型，不属于这几种时返回空
+func Detect(path string, content []byte) Kind {
+	base := strings.ToLower(filepath.Base(path))
+	ext := filepath.Ext(base)
+	text := string(content)
+	switch {
+	case strings.HasPrefix(base, "caddyfile") || ext == ".caddy" || ext == ".caddyfile":
+		return Caddy
+	case strings.HasPrefix(base, "haproxy") || (ext == ".cfg" && haproxySectionReg.MatchString(text)):
+		return HAProxy
+	case ext == ".yml" || ext == ".yaml" || ext == ".toml":
+		// Nginx/Apache 不使用这些格式，统一按 Traefik 动态配置解析（未配置 tls 证书时没有结果）
+		return Traefik
+	case strings.HasPrefix(base, "lighttpd") || lighttpdSSLReg.MatchString(text):
+		return Lighttpd
+	}
+	return ""
+}
+
+// Parse 识别并解析配置文件；不属于这几种配置时 kind 为空
+func Parse(path string) (Kind, []Site, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	kind := Detect(path, content)
+	sites, err := ParseBytes(kind, content, path)
+	return kind, sites, err
+}
+
+// ParseBytes 按指定类型解析配置内容（name 为文件路径，用于相对路径与位置显示）
+func ParseBytes(kind Kind, data []byte, name string) ([]Site, error) {
+	switch kind {
+	case Caddy:
+		return ParseCaddy(data, name)
+	case HAProxy:
+		return ParseHAProxy(data, name)
+	case Traefik:
+		return ParseTraefik(data, name)
+	case Lighttpd:
+		return ParseLighttpd(data, name)
+	}
+	return nil, nil
+}
+
+// resolvePath 相对路径以 base 目录为基准
+func resolvePath(base, path string) string {
+	if path == "" || filepath.IsAbs(path) || base == "" {
+		return path
+	}
+	return filepath.Join(base, path)
+}
+
+// portOf 取监听地址中的端口：443 / :443 / *:443 / 1.2.3.4:443 / [::]:443 / ipv6@:443；无端口返回空
+func portOf(addr string) string {
+	if i := strings.LastIndex(addr, "@"); i >= 0 {
+		addr = addr[i+1:]
+	}
+	if i := strings.LastIndex(addr, ":"); i >= 0 {
+		addr = addr[i+1:]
+	}
+	if addr == "" {
+		return ""
+	}
+	for _, c := range addr {
+		if c < '0' || c > '9' {
+			return ""
+		}
+	}
+	return addr
+}
+
+// appendUnique 追加不重复的字符串
+func appendUnique(list []string, items ...string) []string {
+	for _, s := range items {
+		if s == "" {
+			continue
+		}
+		found := false
+		for _, x := range list {
+			if x == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// unquote 去掉首尾成对的引号
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,235 @@
+package serverconf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeFile 在 dir 下写入文件（自动创建目录）
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		path, content string
+		want          Kind
+	}{
+		{"/etc/caddy/Caddyfile", "a.com {\n}", Caddy},
+		{"/etc/caddy/sites/a.caddy", "", Caddy},
+		{"/etc/haproxy/haproxy.cfg", "", HAProxy},
+		{"/etc/lb/edge.cfg", "frontend https\n  bind :443 ssl crt /a.pem\n", HAProxy},
+		{"/etc/traefik/dynamic/tls.yml", "tls:\n  certificates:\n    - certFile: /a\n", Traefik},
+		{"/etc/traefik/traefik.toml", "[entryPoints]\n", Traefik},
+		{"/etc/lighttpd/lighttpd.conf", "", Lighttpd},
+		{"/etc/lighttpd/conf-enabled/10-ssl.conf", "ssl.pemfile = \"/a.pem\"\n", Lighttpd},
+		{"/etc/nginx/conf.d/a.conf", "server { ssl_certificate /a.pem; }", ""},
+	}
+	for _, c := range cases {
+		if got := Detect(c.path, []byte(c.content)); got != c.want {
+			t.Fatalf("%s 识别错误: %q，应为 %q", c.path, got, c.want)
+		}
+	}
+}
+
+// Caddyfile：全局选项块、片段 import、多地址、http:// 地址忽略、自动 HTTPS 站点跳过
+func TestParseCaddy(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "sites/b.caddy", "b.com:8443 {\n    tls /certs/b.pem /certs/b.key\n}\n")
+	conf := `{
+    email admin@example.com
+}
+
+(mytls) {
+    tls /certs/a.pem /certs/a.key
+}
+
+a.com, www.a.com https://api.a.com {
+    # tls /comment.pem /comment.key
+    import mytls
+    reverse_proxy {
+        to localhost:8080
+    }
+}
+
+http://plain.com {
+    tls /certs/plain.pem /certs/plain.key
+}
+
+auto.com {
+    tls admin@auto.com
+}
+
+import sites/*.caddy
+`
+	path := writeFile(t, dir, "Caddyfile", conf)
+	sites, err := ParseCaddy([]byte(conf), path)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if len(sites) != 2 {
+		t.Fatalf("应解析出 2 个站点，实际 %d: %+v", len(sites), sites)
+	}
+	a := sites[0]
+	if !reflect.DeepEqual(a.Names, []string{"a.com", "www.a.com", "api.a.com"}) || a.CertPath != "/certs/a.pem" || a.KeyPath != "/certs/a.key" || a.Combined() {
+		t.Fatalf("站点 a.com 解析错误: %+v", a)
+	}
+	if !reflect.DeepEqual(a.Ports, []string{"443"}) || a.Line != 6 {
+		t.Fatalf("端口/位置错误: %+v", a)
+	}
+	if b := sites[1]; b.Names[0] != "b.com" || b.Ports[0] != "8443" || b.File != filepath.Join(dir, "sites/b.caddy") {
+		t.Fatalf("import 文件中的站点解析错误: %+v", b)
+	}
+
+	// 单站点省略花括号
+	sites, _ = ParseCaddy([]byte("single.com\ntls /s.pem /s.key\n"), "Caddyfile")
+	if len(sites) != 1 || sites[0].Names[0] != "single.com" {
+		t.Fatalf("单站点 Caddyfile 解析错误: %+v", sites)
+	}
+	if _, err := ParseCaddy([]byte("a.com {\n"), "Caddyfile"); err == nil {
+		t.Fatal("缺少 } 应返回错误")
+	}
+}
+
+// HAProxy：crt-base、目录 crt、同名 .key、crt-list SNI、server 行的客户端证书不计入
+func TestParseHAProxy(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "certs/a.pem", "A")
+	writeFile(t, dir, "certs/b.pem", "B")
+	writeFile(t, dir, "certs/b.pem.key", "BK")
+	writeFile(t, dir, "certs/b.pem.ocsp", "O")
+	writeFile(t, dir, "list.txt", "# 注释\ncerts/a.pem [alpn h2] a.com *.a.com !x.a.com\n")
+	conf := `global
+    crt-base ` + dir + `
+frontend https
+    bind :443,:::8443 ssl crt certs/ alpn h2 # 目录
+    bind 10.0.0.1:9443 ssl crt-list ` + filepath.Join(dir, "list.txt") + `
+backend app
+    server s1 10.0.0.2:443 ssl crt /client.pem
+`
+	sites, err := ParseHAProxy([]byte(conf), filepath.Join(dir, "haproxy.cfg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sites) != 3 {
+		t.Fatalf("应解析出 3 组证书，实际 %d: %+v", len(sites), sites)
+	}
+	a, b, l := sites[0], sites[1], sites[2]
+	if a.CertPath != filepath.Join(dir, "certs/a.pem") || !a.Combined() || !reflect.DeepEqual(a.Ports, []string{"443", "8443"}) || a.Line != 4 {
+		t.Fatalf("合并 PEM 解析错误: %+v", a)
+	}
+	if b.KeyPath != filepath.Join(dir, "certs/b.pem.key") || b.Combined() {
+		t.Fatalf("同名 .key 应作为私钥: %+v", b)
+	}
+	if !reflect.DeepEqual(l.Names, []string{"a.com", "*.a.com"}) || l.Ports[0] != "9443" || l.Line != 2 {
+		t.Fatalf("crt-list 解析错误: %+v", l)
+	}
+}
+
+// Traefik：YAML 与 TOML，tls.certificates 与 defaultCertificate，serversTransports 的客户端证书不计入
+func TestParseTraefik(t *testing.T) {
+	yml := `tls:
+  certificates:
+    - certFile: /certs/a.crt
+      keyFile: "/certs/a.key"
+      stores:
+        - default
+    - keyFile: /certs/b.key
+      certFile: /certs/b.crt
+  stores:
+    default:
+      defaultCertificate:
+        certFile: /certs/d.crt
+        keyFile: /certs/d.key
+http:
+  serversTransports:
+    mtls:
+      certificates:
+        - certFile: /certs/client.crt
+          keyFile: /certs/client.key
+`
+	sites, _ := ParseTraefik([]byte(yml), "dynamic.yml")
+	var got []string
+	for _, s := range sites {
+		got = append(got, s.CertPath+"|"+s.KeyPath)
+	}
+	want := []string{"/certs/a.crt|/certs/a.key", "/certs/b.crt|/certs/b.key", "/certs/d.crt|/certs/d.key"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("YAML 解析错误: %v", got)
+	}
+	if sites[0].Line != 3 || sites[1].Line != 8 {
+		t.Fatalf("位置错误: %+v", sites)
+	}
+
+	toml := `[[tls.certificates]]
+  certFile = "/certs/a.crt"
+  keyFile = "/certs/a.key"
+
+[tls.stores.default.defaultCertificate]
+  certFile = "/certs/d.crt"
+  keyFile = "/certs/d.key"
+
+[[http.serversTransports.mtls.certificates]]
+  certFile = "/certs/client.crt"
+  keyFile = "/certs/client.key"
+`
+	sites, _ = ParseTraefik([]byte(toml), "dynamic.toml")
+	if len(sites) != 2 || sites[0].CertPath != "/certs/a.crt" || sites[1].KeyPath != "/certs/d.key" || sites[0].Line != 2 {
+		t.Fatalf("TOML 解析错误: %+v", sites)
+	}
+}
+
+// lighttpd：$HTTP["host"] 域名、$SERVER["socket"] 端口、ssl.privkey 继承、var 拼接、include、else 分支
+func TestParseLighttpd(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "conf-enabled/20-b.conf", `$HTTP["host"] == "b.com" { ssl.pemfile = var.certdir + "/b.pem" }`+"\n")
+	conf := `var.certdir = "/etc/lighttpd/certs"
+$SERVER["socket"] == ":443" {
+    ssl.engine = "enable"
+    ssl.pemfile = var.certdir + "/default.pem"
+    ssl.privkey = var.certdir + "/default.key"
+    $HTTP["host"] == "a.com"
+    {
+        ssl.pemfile = "/etc/lighttpd/certs/a.pem"
+    }
+    else $HTTP["host"] =~ "example" {
+        ssl.pemfile = "/etc/lighttpd/certs/re.pem"
+    }
+}
+include "conf-enabled/*.conf"
+`
+	path := writeFile(t, dir, "lighttpd.conf", conf)
+	sites, err := ParseLighttpd([]byte(conf), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sites) != 4 {
+		t.Fatalf("应解析出 4 组证书，实际 %d: %+v", len(sites), sites)
+	}
+	a, re, def, b := sites[0], sites[1], sites[2], sites[3]
+	if a.Names[0] != "a.com" || a.Ports[0] != "443" || a.KeyPath != "/etc/lighttpd/certs/default.key" || a.Line != 8 {
+		t.Fatalf("站点 a.com 解析错误: %+v", a)
+	}
+	if len(re.Names) != 0 || re.CertPath != "/etc/lighttpd/certs/re.pem" {
+		t.Fatalf("正则条件不应作为域名: %+v", re)
+	}
+	if def.CertPath != "/etc/lighttpd/certs/default.pem" || def.KeyPath != "/etc/lighttpd/certs/default.key" || len(def.Names) != 0 {
+		t.Fatalf("socket 块证书解析错误: %+v", def)
+	}
+	if b.Names[0] != "b.com" || b.CertPath != "/etc/lighttpd/certs/b.pem" || !b.Combined() || b.File != filepath.Join(dir, "conf-enabled/20-b.conf") {
+		t.Fatalf("include 文件中的站点解析错误: %+v", b)
+	}
+	if _, err := ParseLighttpd([]byte("$HTTP[\"host\"] == \"x\" {\n"), "x.conf"); err == nil {
+		t.Fatal("缺少 } 应返回错误")
+	}
+}
@@ -0,0 +1,128 @@
+package serverconf
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// ParseTraefik 解析 Traefik file provider 动态配置（YAML 或 TOML）中 tls 下的 certFile / keyFile：
+// tls.certificates 列表与 tls.stores.<名称>.defaultCertificate。http.serversTransports 等处的客户端证书不计入。
+// Traefik 按证书 SAN 匹配 SNI，配置中不声明域名，Names 为空。
+func ParseTraefik(data []byte, name string) ([]Site, error) {
+	if strings.EqualFold(filepath.Ext(name), ".toml") {
+		return parseTraefikTOML(data, name), nil
+	}
+	return parseTraefikYAML(data, name), nil
+}
+
+// traefikPair 收集中的一组 certFile / keyFile
+type traefikPair struct {
+	cert, key string
+	line      int
+}
+
+func (p traefikPair) site(name string) Site {
+	return Site{Kind: Traefik, CertPath: p.cert, KeyPath: p.key, File: name, Line: p.line}
+}
+
+// yamlKey 一层 YAML 映射键及其缩进
+type yamlKey struct {
+	indent int
+	key    string
+}
+
+// parseTraefikYAML 按缩进跟踪键路径（只需处理 Traefik 动态配置用到的块映射与列表），
+// 在 tls 路径下同一映射（或同一列表项）中的 certFile 与 keyFile 配成一组
+func parseTraefikYAML(data []byte, name string) []Site {
+	var sites []Site
+	var stack []yamlKey
+	var cur traefikPair
+	curIndent := -1
+	flush := func() {
+		if cur.cert != "" && cur.key != "" {
+			sites = append(sites, cur.site(name))
+		}
+		cur, curIndent = traefikPair{}, -1
+	}
+	for i, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimRight(stripHashComment(raw), " \t\r")
+		content := strings.TrimLeft(line, " ")
+		if content == "" || content == "---" {
+			continue
+		}
+		indent := len(line) - len(content)
+		newItem := false
+		for strings.HasPrefix(content, "- ") || content == "-" {
+			newItem = true
+			content = strings.TrimLeft(strings.TrimPrefix(content, "-"), " ")
+			indent = len(line) - len(content)
+		}
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		if newItem || (curIndent >= 0 && indent != curIndent) {
+			flush()
+		}
+		colon := strings.Index(content, ":")
+		if colon < 0 {
+			continue
+		}
+		key := strings.TrimSpace(unquote(content[:colon]))
+		value := unquote(strings.TrimSpace(content[colon+1:]))
+		if value == "" {
+			stack = append(stack, yamlKey{indent: indent, key: key})
+			continue
+		}
+		if len(stack) == 0 || stack[0].key != "tls" {
+			continue
+		}
+		switch key {
+		case "certFile":
+			cur.cert, cur.line, curIndent = value, i+1, indent
+		case "keyFile":
+			cur.key, curIndent = value, indent
+		default:
+			continue
+		}
+		if cur.cert != "" && cur.key != "" {
+			flush()
+		}
+	}
+	flush()
+	return sites
+}
+
+// parseTraefikTOML 按表头（[[tls.certificates]] / [tls.stores.default.defaultCertificate]）分组收集 certFile 与 keyFile
+func parseTraefikTOML(data []byte, name string) []Site {
+	var sites []Site
+	var cur traefikPair
+	table := ""
+	flush := func() {
+		if cur.cert != "" && cur.key != "" {
+			sites = append(sites, cur.site(name))
+		}
+		cur = traefikPair{}
+	}
+	for i, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(stripHashComment(raw))
+		if strings.HasPrefix(line, "[") {
+			flush()
+			table = strings.TrimSpace(strings.Trim(line, "[]"))
+			continue
+		}
+		eq := strings.Index(line, "=")
+		if eq < 0 || (table != "tls" && !strings.HasPrefix(table, "tls.")) {
+			continue
+		}
+		key := strings.TrimSpace(unquote(strings.TrimSpace(line[:eq])))
+		value := unquote(strings.TrimSpace(line[eq+1:]))
+		switch key {
+		case "certFile":
+			cur.cert, cur.line = value, i+1
+		case "keyFile":
+			cur.key = value
+		}
+	}
+	flush()
+	return sites
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -9,6 +12,7 @@ import (
 	"math/big"
 	"os"
 	"path/filepath"
+	"ssl_assistant/config"
 	"testing"
 	"time"
 )
@@ -20,6 +24,22 @@ func genSelfSignedCert(t *testing.T, dir, domain string, validDays int) (certPat
 	if err != nil {
 		t.Fatal(err)
 	}
+	return writeSelfSignedCert(t, dir, domain, validDays, key)
+}
+
+// genSelfSignedECDSACert 生成 ECDSA（P-256）自签证书 + 私钥文件（双证书场景）
+func genSelfSignedECDSACert(t *testing.T, dir, domain string, validDays int) (certPath, keyPath string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeSelfSignedCert(t, dir, domain, validDays, key)
+}
+
+// writeSelfSignedCert 以给定私钥签发自签证书，写入 dir/fullchain.pem 与 dir/privkey.pem
+func writeSelfSignedCert(t *testing.T, dir, domain string, validDays int, key crypto.Signer) (certPath, keyPath string) {
+	t.Helper()
 	tmpl := x509.Certificate{
 		SerialNumber: big.NewInt(1),
 		Subject:      pkix.Name{CommonName: domain},
@@ -27,7 +47,7 @@ func genSelfSignedCert(t *testing.T, dir, domain string, validDays int) (certPat
 		NotBefore:    time.Now().Add(-time.Hour),
 		NotAfter:     time.Now().Add(time.Duration(validDays) * 24 * time.Hour),
 	}
-	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, key.Public(), key)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -42,3 +62,14 @@ func genSelfSignedCert(t *testing.T, dir, domain string, validDays int) (certPat
 	}
 	return
 }
+
+// setConfig 临时修改配置项（section 为空表示顶层配置），测试结束后恢复原值。
+// 配置文件位于 TestMain 切换到的临时目录中，不会修改项目的 config/conf.ini
+func setConfig(t *testing.T, section, key, value string) {
+	t.Helper()
+	old, _ := config.GetConfig(section, key)
+	if err := config.SetConfig(section, key, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = config.SetConfig(section, key, old) })
+}
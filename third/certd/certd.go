@@ -68,21 +68,22 @@ type autoApplyParams struct {
 var httpClient = &http.Client{Timeout: 15 * time.Second}
 
 // GetCertificateInfo 获取证书信息
+// @param section 平台实例配置分区（如 third.certd.default）
 // @param domain 域名（与 certID 二选一）
 // @param certID 证书仓库ID（优先于域名）
 // @return crt 全链证书PEM, key 私钥PEM, detail 证书详情（可能为nil）
-func GetCertificateInfo(domain string, certID int) (crt, key []byte, detail *CertDetail, err error) {
-	ApiUrl, err := config.GetConfig("third.certd", "api_url")
+func GetCertificateInfo(section, domain string, certID int) (crt, key []byte, detail *CertDetail, err error) {
+	ApiUrl, err := config.GetConfig(section, "api_url")
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("获取api_url配置失败: %v", err)
 	}
 	// 兼容手工修改 conf.ini 时尾部残留 / 或 \ 的情况
 	ApiUrl = strings.TrimRight(ApiUrl, `/\`)
-	KeyId, err := config.GetConfig("third.certd", "key_id")
+	KeyId, err := config.GetConfig(section, "key_id")
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("获取key_id配置失败: %v", err)
 	}
-	KeySecret, err := config.GetConfig("third.certd", "key_secret")
+	KeySecret, err := config.GetConfig(section, "key_secret")
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("获取key_secret配置失败: %v", err)
 	}
@@ -96,7 +97,7 @@ func GetCertificateInfo(domain string, certID int) (crt, key []byte, detail *Cer
 		CertID:  certID,
 		Format:  "pem",
 	}
-	if apply, tplID, renewDays := loadAutoApplyConfig(); apply {
+	if apply, tplID, renewDays := loadAutoApplyConfig(section); apply {
 		payload.AutoApply = true
 		payload.AutoApplyTemplateID = tplID
 		payload.AutoApplyParams = &autoApplyParams{RenewDays: renewDays}
@@ -171,12 +172,12 @@ func doRequest(url, token string, payload certGetRequest) (crt, key []byte, deta
 }
 
 // loadAutoApplyConfig 读取自动申请配置
-func loadAutoApplyConfig() (enable bool, templateID int, renewDays int) {
-	apply, _ := config.GetConfig("third.certd", "auto_apply")
+func loadAutoApplyConfig(section string) (enable bool, templateID int, renewDays int) {
+	apply, _ := config.GetConfig(section, "auto_apply")
 	enable = apply == "1" || apply == "true"
-	tplID, _ := config.GetConfig("third.certd", "auto_apply_template_id")
+	tplID, _ := config.GetConfig(section, "auto_apply_template_id")
 	templateID, _ = strconv.Atoi(strings.TrimSpace(tplID))
-	renew, _ := config.GetConfig("third.certd", "auto_apply_renew_days")
+	renew, _ := config.GetConfig(section, "auto_apply_renew_days")
 	renewDays, _ = strconv.Atoi(strings.TrimSpace(renew))
 	if renewDays <= 0 {
 		renewDays = 10 // 与本地默认提前更新天数保持一致
@@ -184,10 +185,10 @@ func loadAutoApplyConfig() (enable bool, templateID int, renewDays int) {
 	return
 }
 
-// SetConfig Certd配置
-func SetConfig() {
-	color.Cyan("正在配置Certd相关参数")
-	var rootName string = "third.certd"
+// SetConfig Certd配置，section 为平台实例配置分区（如 third.certd.default）
+func SetConfig(section string) {
+	color.Cyan("正在配置Certd相关参数（%s）", section)
+	var rootName string = section
 
 	// 读取当前配置（未配置时为空），用于输入框预填
 	curApi, _ := config.GetConfig(rootName, "api_url")
@@ -13,6 +13,9 @@ import (
 	"testing"
 )
 
+// testSection 测试使用的平台实例配置分区
+const testSection = "third.certd.default"
+
 // TestMain 切换工作目录到临时目录并初始化配置，避免污染项目 config/conf.ini
 func TestMain(m *testing.M) {
 	tmp, err := os.MkdirTemp("", "certd_test")
@@ -94,12 +97,12 @@ func (s *testServer) close() {
 
 func (s *testServer) setup(t *testing.T) {
 	t.Helper()
-	config.SetConfig("third.certd", "api_url", s.ts.URL)
-	config.SetConfig("third.certd", "key_id", "test-key-id")
-	config.SetConfig("third.certd", "key_secret", "test-key-secret")
-	config.SetConfig("third.certd", "auto_apply", "0")
-	config.SetConfig("third.certd", "auto_apply_template_id", "")
-	config.SetConfig("third.certd", "auto_apply_renew_days", "")
+	config.SetConfig(testSection, "api_url", s.ts.URL)
+	config.SetConfig(testSection, "key_id", "test-key-id")
+	config.SetConfig(testSection, "key_secret", "test-key-secret")
+	config.SetConfig(testSection, "auto_apply", "0")
+	config.SetConfig(testSection, "auto_apply_template_id", "")
+	config.SetConfig(testSection, "auto_apply_renew_days", "")
 }
 
 func TestGetCertificateInfoBasic(t *testing.T) {
@@ -108,7 +111,7 @@ func TestGetCertificateInfoBasic(t *testing.T) {
 	s.setup(t)
 
 	// 域名查询：format=pem、detail 解析
-	crt, key, detail, err := GetCertificateInfo("example.com", 0)
+	crt, key, detail, err := GetCertificateInfo(testSection, "example.com", 0)
 	if err != nil {
 		t.Fatalf("获取证书失败: %v", err)
 	}
@@ -140,7 +143,7 @@ func TestGetCertificateInfoCertIDPriority(t *testing.T) {
 	s.setup(t)
 
 	// 传 certId 时 domains 应省略
-	if _, _, _, err := GetCertificateInfo("", 555); err != nil {
+	if _, _, _, err := GetCertificateInfo(testSection, "", 555); err != nil {
 		t.Fatalf("certId 查询失败: %v", err)
 	}
 	var body map[string]interface{}
@@ -158,11 +161,11 @@ func TestGetCertificateInfoAutoApply(t *testing.T) {
 	defer s.close()
 	s.setup(t)
 
-	config.SetConfig("third.certd", "auto_apply", "1")
-	config.SetConfig("third.certd", "auto_apply_template_id", "9")
-	config.SetConfig("third.certd", "auto_apply_renew_days", "23")
+	config.SetConfig(testSection, "auto_apply", "1")
+	config.SetConfig(testSection, "auto_apply_template_id", "9")
+	config.SetConfig(testSection, "auto_apply_renew_days", "23")
 
-	if _, _, _, err := GetCertificateInfo("auto.com", 0); err != nil {
+	if _, _, _, err := GetCertificateInfo(testSection, "auto.com", 0); err != nil {
 		t.Fatalf("autoApply 请求失败: %v", err)
 	}
 	var body map[string]interface{}
@@ -185,7 +188,7 @@ func TestGetCertificateInfoApplying(t *testing.T) {
 	s.setup(t)
 
 	// code=20013 → ErrCertApplying
-	if _, _, _, err := GetCertificateInfo("applying.com", 0); !errors.Is(err, ErrCertApplying) {
+	if _, _, _, err := GetCertificateInfo(testSection, "applying.com", 0); !errors.Is(err, ErrCertApplying) {
 		t.Fatalf("20013 应返回 ErrCertApplying，实际: %v", err)
 	}
 }
@@ -197,7 +200,7 @@ func TestGetCertificateInfoRetry(t *testing.T) {
 
 	// 5xx：重试 3 次后成功
 	atomic.StoreInt32(&s.failCount, 0)
-	crt, _, detail, err := GetCertificateInfo("svc500.com", 0)
+	crt, _, detail, err := GetCertificateInfo(testSection, "svc500.com", 0)
 	if err != nil {
 		t.Fatalf("5xx 重试后应成功，实际: %v", err)
 	}
@@ -210,7 +213,7 @@ func TestGetCertificateInfoRetry(t *testing.T) {
 
 	// 4xx：不应重试，立即失败
 	atomic.StoreInt32(&s.failCount, 0)
-	if _, _, _, err := GetCertificateInfo("unauth.com", 0); err == nil {
+	if _, _, _, err := GetCertificateInfo(testSection, "unauth.com", 0); err == nil {
 		t.Fatal("401 应失败")
 	}
 	if atomic.LoadInt32(&s.failCount) != 0 {
@@ -220,16 +223,16 @@ func TestGetCertificateInfoRetry(t *testing.T) {
 
 func TestGetCertificateInfoMissingConfig(t *testing.T) {
 	// 清空配置应报"配置不完整"；完成后恢复原值，避免影响其他测试（-shuffle 安全）
-	config.SetConfig("third.certd", "api_url", "")
-	config.SetConfig("third.certd", "key_id", "")
-	config.SetConfig("third.certd", "key_secret", "")
+	config.SetConfig(testSection, "api_url", "")
+	config.SetConfig(testSection, "key_id", "")
+	config.SetConfig(testSection, "key_secret", "")
 	defer func() {
-		config.SetConfig("third.certd", "api_url", "http://restored.local")
-		config.SetConfig("third.certd", "key_id", "restored-id")
-		config.SetConfig("third.certd", "key_secret", "restored-secret")
+		config.SetConfig(testSection, "api_url", "http://restored.local")
+		config.SetConfig(testSection, "key_id", "restored-id")
+		config.SetConfig(testSection, "key_secret", "restored-secret")
 	}()
 
-	if _, _, _, err := GetCertificateInfo("x.com", 0); err == nil {
+	if _, _, _, err := GetCertificateInfo(testSection, "x.com", 0); err == nil {
 		t.Fatal("配置不完整时应报错")
 	}
 }
@@ -31,10 +31,10 @@ var (
 	apiUrl = "https://api.west.cn/newapi/ssl"
 )
 
-// SetConfig West配置
-func SetConfig() {
-	color.Cyan("正在配置West相关参数")
-	var rootName string = "third.west"
+// SetConfig West配置，section 为平台实例配置分区（如 third.west.default）
+func SetConfig(section string) {
+	color.Cyan("正在配置West相关参数（%s）", section)
+	var rootName string = section
 
 	// 读取当前配置（未配置时为空），用于输入框预填
 	curUser, _ := config.GetConfig(rootName, "username")
@@ -65,8 +65,9 @@ func SetConfig() {
 }
 
 // GetCert 获取证书信息 https://console-docs.apipost.cn/preview/4e5d940c9be19cda/73e3028374812fc5?target_id=fae505a9-c375-4e17-a126-656d0b40ba07
-func GetCert(domain string) (error, []byte, []byte, []byte) {
-	authParam, err := getAuth()
+// section 为平台实例配置分区（如 third.west.default）
+func GetCert(section, domain string) (error, []byte, []byte, []byte) {
+	authParam, err := getAuth(section)
 	if err != nil {
 		return err, nil, nil, nil
 	}
@@ -158,12 +159,12 @@ func getCertQuery(domain string) string {
 }
 
 // 获取鉴权参数
-func getAuth() (string, error) {
-	username, err := config.GetThirdCofig("west", "username")
+func getAuth(section string) (string, error) {
+	username, err := config.GetConfig(section, "username")
 	if err != nil {
 		return "", fmt.Errorf("获取username配置失败: %s", err)
 	}
-	apiKey, err := config.GetThirdCofig("west", "api_key")
+	apiKey, err := config.GetConfig(section, "api_key")
 	if err != nil {
 		return "", fmt.Errorf("获取api_key配置失败: %s", err)
 	}
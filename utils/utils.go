@@ -7,7 +7,6 @@ import (
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
-	"io/fs"
 	"log"
 	"os"
 	"strings"
@@ -57,7 +56,7 @@ func ExistDir(path string) {
 	_, err := os.ReadDir(path)
 	if err != nil {
 		// 不存在就创建
-		err = os.MkdirAll(path, fs.ModePerm)
+		err = os.MkdirAll(path, 0755)
 		if err != nil {
 			fmt.Println(err)
 		}
@@ -73,9 +72,12 @@ func ArrayToString(arr []string, suffix string) string {
 	return strings.Join([]string(arr), suffix)
 }
 
-// ParseCertificate 解析证书
+// ParseCertificate 解析证书（取第一个 CERTIFICATE 块；证书与私钥合并的 PEM 中私钥可在证书之前）
 func ParseCertificate(endCertBytes []byte) (*x509.Certificate, error) {
-	endBlocks, _ := pem.Decode(endCertBytes)
+	endBlocks, rest := pem.Decode(endCertBytes)
+	for endBlocks != nil && endBlocks.Type != "CERTIFICATE" {
+		endBlocks, rest = pem.Decode(rest)
+	}
 	if endBlocks == nil {
 		return nil, fmt.Errorf("failed to parse certificate PEM")
 	}
@@ -87,10 +89,33 @@ func ParseCertificate(endCertBytes []byte) (*x509.Certificate, error) {
 	return endCert, nil
 }
 
+// CertKeyType 返回证书公钥类型：rsa / ecdsa / ed25519（其他算法返回空）
+func CertKeyType(cert *x509.Certificate) string {
+	switch cert.PublicKeyAlgorithm {
+	case x509.RSA:
+		return "rsa"
+	case x509.ECDSA:
+		return "ecdsa"
+	case x509.Ed25519:
+		return "ed25519"
+	}
+	return ""
+}
+
+// PEMKeyType 解析 PEM 证书并返回公钥类型（无法解析时返回空）
+func PEMKeyType(certPEM []byte) string {
+	cert, err := ParseCertificate(certPEM)
+	if err != nil {
+		return ""
+	}
+	return CertKeyType(cert)
+}
+
 // ShowCertificateInfo 打印证书信息
 func ShowCertificateInfo(endCert *x509.Certificate) {
 	fmt.Println("\n=============== 证书信息 start cert ===============")
-	fmt.Printf("组织(O): %s %s\n", endCert.Issuer.Organization[0], endCert.Issuer.CommonName)
+	// 自签等证书的颁发者可能没有组织(O)字段
+	fmt.Printf("组织(O): %s %s\n", strings.Join(endCert.Issuer.Organization, ","), endCert.Issuer.CommonName)
 	fmt.Println("通用名称(CN): ", endCert.Subject.CommonName)
 	fmt.Println("证书生效时间: ", endCert.NotBefore.UTC().Format(time.DateTime))
 	fmt.Println("证书过期时间: ", endCert.NotAfter.UTC().Format(time.DateTime))